@@ -0,0 +1,119 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	"to-mrz/controllers"
+	"to-mrz/openapi"
+
+	"github.com/gin-gonic/gin"
+)
+
+// samplePathParams are the values of path parameters used when the contract is checked
+var samplePathParams = map[string]string{
+	"id":         "1",
+	"student_id": "1",
+	"ip":         "127.0.0.1",
+	"role":       "teacher",
+	"name":       "backup-20000101-000000.db",
+}
+
+// CheckContract checks that the API of r matches its OpenAPI document. Besides the
+// problems of CheckRoutes, it logs in with username and password, requests every GET
+// route with sample parameters and sends an empty body to the routes whose body has
+// required fields, and reports the responses that do not match their schemas.
+func CheckContract(r *gin.Engine, username, password string) []string {
+	routes := r.Routes()
+	problems := CheckRoutes(routes)
+	doc := Document(routes)
+
+	request := func(method, path, target string, body []byte, token string) {
+		w := serve(r, method, target, body, token)
+		for _, problem := range doc.ValidateResponse(method, path, w.Code, w.Header().Get("Content-Type"), w.Body.Bytes()) {
+			problems = append(problems, fmt.Sprintf("%s %s (%d): %s", method, target, w.Code, problem))
+		}
+	}
+
+	credentials, _ := json.Marshal(controllers.LoginRequest{Username: username, Password: password})
+	w := serve(r, http.MethodPost, "/api/login", credentials, "")
+	for _, problem := range doc.ValidateResponse(http.MethodPost, "/api/login", w.Code, w.Header().Get("Content-Type"), w.Body.Bytes()) {
+		problems = append(problems, fmt.Sprintf("POST /api/login (%d): %s", w.Code, problem))
+	}
+	var login controllers.LoginResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &login); err != nil || login.Token == "" {
+		return append(problems, fmt.Sprintf("cannot log in as %s: %s", username, w.Body.String()))
+	}
+
+	// an error response of the authentication middleware
+	request(http.MethodGet, "/api/user", "/api/user", nil, "")
+
+	for _, d := range routeDocs {
+		op := doc.Operation(d.method, d.path)
+		if op == nil {
+			continue
+		}
+		var body []byte
+		switch {
+		case d.method == http.MethodGet:
+		case hasRequiredFields(doc, op):
+			body = []byte("{}")
+		default:
+			continue // would change the data
+		}
+		request(d.method, d.path, sampleTarget(d.path, d.route.Query), body, login.Token)
+	}
+	return problems
+}
+
+// serve sends a request to the router and records the response
+func serve(r *gin.Engine, method, target string, body []byte, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, bytes.NewReader(body))
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// hasRequiredFields reports whether the JSON body of an operation has required fields,
+// so an empty body is rejected without changing any data
+func hasRequiredFields(doc *openapi.Document, op *openapi.Operation) bool {
+	if op.RequestBody == nil {
+		return false
+	}
+	media, ok := op.RequestBody.Content["application/json"]
+	return ok && len(doc.Resolve(media.Schema).Required) > 0
+}
+
+// sampleTarget fills in the path parameters of a gin path and adds the query
+// parameters that have examples
+func sampleTarget(path string, params []openapi.Param) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			segments[i] = url.PathEscape(samplePathParams[segment[1:]])
+		}
+	}
+	target := strings.Join(segments, "/")
+
+	query := url.Values{}
+	for _, p := range params {
+		if p.Example != "" {
+			query.Set(p.Name, p.Example)
+		}
+	}
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	return target
+}
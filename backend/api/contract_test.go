@@ -0,0 +1,81 @@
+package api
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+
+	"to-mrz/config"
+	"to-mrz/controllers"
+	"to-mrz/db"
+	"to-mrz/models"
+	"to-mrz/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// contractFixtures adds a class with two students, a teacher and graded enrollments
+// to the sample data, so the routes with sample parameters (ID 1) answer with real
+// rows. The administrator is user 1.
+const contractFixtures = `
+INSERT INTO users (username, password, name, role, email, phone) VALUES
+	('2023001', 'x', '张三', 'student', '', ''),
+	('t1001', 'x', '王老师', 'teacher', '', ''),
+	('2023002', 'x', '李四', 'student', '', '');
+INSERT INTO majors (name, code, department_id) VALUES ('计算机科学与技术', 'CS01', 1);
+INSERT INTO classes (name, code, major_id, year) VALUES ('计科2023-1班', 'CS2023-1', 1, 2023);
+INSERT INTO students (user_id, student_id, class_id, enroll_year) VALUES (2, '2023001', 1, 2023), (4, '2023002', 1, 2023);
+INSERT INTO teachers (user_id, department_id, title) VALUES (3, 1, '讲师');
+INSERT INTO semesters (name, start_date, end_date) VALUES
+	('2023-2024学年第一学期', '2023-09-01 00:00:00', '2024-01-15 00:00:00'),
+	('2023-2024学年第二学期', '2024-02-20 00:00:00', '2024-07-01 00:00:00');
+INSERT INTO course_offerings (course_id, semester_id, teacher_id, capacity, status) VALUES
+	(2, 1, 1, 50, 'open'), (2, 2, 1, 50, 'open'), (1, 1, 1, 50, 'open');
+INSERT INTO enrollments (student_id, course_offering_id, grade, status) VALUES
+	(1, 1, 45, '未通过'), (1, 3, 88, '已完成'), (2, 1, 76.5, '已完成');
+INSERT INTO grade_components (course_offering_id, name, weight) VALUES (1, '平时成绩', 0.3), (1, '期末考试', 0.7);
+`
+
+// TestContract checks the API against its OpenAPI document on a new SQLite database
+// with the sample data and contractFixtures: every route is documented, and the
+// responses of the read routes and of write routes given an empty body match their
+// schemas.
+func TestContract(t *testing.T) {
+	dir := t.TempDir()
+	if err := db.InitDB(config.DriverSQLite, filepath.Join(dir, "school.db")); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.CloseDB() })
+	if err := db.SeedDB(); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.InitSearchIndex(); err != nil {
+		t.Fatal(err)
+	}
+
+	savedBackupDir, savedTwoFactorRoles := db.BackupDir, db.TwoFactorRoles
+	t.Cleanup(func() { db.BackupDir, db.TwoFactorRoles = savedBackupDir, savedTwoFactorRoles })
+	db.BackupDir = filepath.Join(dir, "backups")
+	db.TwoFactorRoles = nil
+
+	password, err := utils.CurrentPasswordPolicy.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repos := db.NewSQLRepositories()
+	admin := &models.User{Username: "admin", Name: "系统管理员"}
+	if _, err := controllers.NewServer(repos).BootstrapAdmin(admin, password); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.DB.Exec(contractFixtures); err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	savedWriter := gin.DefaultWriter
+	t.Cleanup(func() { gin.DefaultWriter = savedWriter })
+	gin.DefaultWriter = io.Discard
+	for _, problem := range CheckContract(SetupRouter(config.ServerConfig{}, repos), admin.Username, password) {
+		t.Error(problem)
+	}
+}
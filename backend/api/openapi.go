@@ -0,0 +1,572 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"to-mrz/controllers"
+	"to-mrz/models"
+	"to-mrz/openapi"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIVersion is the version of the API in the OpenAPI document
+const APIVersion = "1.0.0"
+
+// routeDoc documents one route of SetupRouter
+type routeDoc struct {
+	method string
+	path   string
+	route  openapi.Route
+}
+
+// messageResponse is the body of responses that only confirm an action
+type messageResponse struct {
+	Message string `json:"message"`
+}
+
+// dataResponse wraps the data of the grade and make-up exam responses
+type dataResponse struct {
+	Data interface{} `json:"data"`
+}
+
+// errorResponse is the body of every error response (see apierr.Error.Response).
+// Besides error and code, a few errors add the fields their clients need.
+type errorResponse struct {
+	Error                  string              `json:"error"`
+	Code                   string              `json:"code"`
+	Details                []fieldError        `json:"details,omitempty"`
+	RetryAfter             int                 `json:"retry_after,omitempty"`
+	ChallengeExpired       bool                `json:"challenge_expired,omitempty"`
+	MustChangePassword     bool                `json:"must_change_password,omitempty"`
+	TwoFactorSetupRequired bool                `json:"two_factor_setup_required,omitempty"`
+	BlockedBy              []models.Dependency `json:"blocked_by,omitempty"`
+	Errors                 []importError       `json:"errors,omitempty"`
+}
+
+// fieldError is a problem with one field of an invalid request
+type fieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// importError is a problem with a row of an imported grade or account file
+type importError struct {
+	Row       int    `json:"row"`
+	Column    string `json:"column,omitempty"`
+	StudentNo string `json:"student_no,omitempty"`
+	Username  string `json:"username,omitempty"`
+	Message   string `json:"message"`
+}
+
+// importResponse is the result of a grade or account import, or of its dry run
+type importResponse struct {
+	Message  string      `json:"message"`
+	DryRun   bool        `json:"dry_run,omitempty"`
+	Imported int         `json:"imported,omitempty"`
+	Data     interface{} `json:"data"`
+}
+
+// departmentSummary is returned when a department is created or updated
+type departmentSummary struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+	Code string `json:"code"`
+}
+
+// createdUserResponse is the new account, with its password when one was generated
+type createdUserResponse struct {
+	User     *models.User `json:"user"`
+	Password string       `json:"password,omitempty"`
+}
+
+// createdGradeResponse is the new grade record
+type createdGradeResponse struct {
+	Message string            `json:"message"`
+	Data    models.Enrollment `json:"data"`
+}
+
+// revokedResponse counts the sessions a logout revoked
+type revokedResponse struct {
+	Message string `json:"message"`
+	Revoked int    `json:"revoked"`
+}
+
+// page describes a page of a list with items of the given slice type
+func page(name string, items interface{}) openapi.Instance {
+	return openapi.Instance{Name: name + "Page", Type: models.Page{}, Fields: map[string]interface{}{"items": items}}
+}
+
+// data describes a response with the value under "data"
+func data(name string, value interface{}) openapi.Instance {
+	return openapi.Instance{Name: name, Type: dataResponse{}, Fields: map[string]interface{}{"data": value}}
+}
+
+// pageParams returns the paging parameters of the list endpoints, for lists that
+// cannot be sorted or searched
+func pageParams() []openapi.Param {
+	return []openapi.Param{
+		{Name: "page", Type: "integer", Description: "页码，从1开始"},
+		{Name: "page_size", Type: "integer", Description: "每页条数"},
+		{Name: "cursor", Description: "游标分页：上一页返回的 next_cursor"},
+	}
+}
+
+// listParams returns the query parameters shared by the list endpoints, followed by
+// the filters of one list
+func listParams(filters ...openapi.Param) []openapi.Param {
+	params := append(pageParams(),
+		openapi.Param{Name: "sort", Description: "排序字段，逗号分隔，前缀 - 表示降序"},
+		openapi.Param{Name: "q", Description: "搜索关键字"})
+	return append(params, filters...)
+}
+
+// idParam is an optional ID query parameter
+func idParam(name, description string) openapi.Param {
+	return openapi.Param{Name: name, Type: "integer", Description: description}
+}
+
+var (
+	deletedParam = openapi.Param{Name: "deleted", Type: "boolean", Description: "为 true 时列出已删除的数据"}
+	formatParam  = openapi.Param{Name: "format", Description: "文件格式，默认 xlsx", Enum: []string{"xlsx", "csv"}}
+	dryRunParam  = openapi.Param{Name: "dry_run", Type: "boolean", Description: "为 true 时只校验并返回预览"}
+	importRole   = openapi.Param{Name: "role", Required: true, Description: "导入的账号角色", Enum: []string{string(models.RoleStudent), string(models.RoleTeacher)}, Example: string(models.RoleStudent)}
+	offeringID   = openapi.Param{Name: "course_offering_id", Type: "integer", Required: true, Description: "开课ID", Example: "1"}
+	ownStudentID = openapi.Param{Name: "student_id", Type: "integer", Description: "学生ID，学生本人查询时可省略", Example: "1"}
+	spreadsheets = []string{"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", "text/csv"}
+)
+
+// tags lists the groups of operations in the order the documentation shows them
+var tags = []openapi.Tag{
+	{Name: "认证", Description: "登录、令牌刷新与首次安装"},
+	{Name: "当前用户", Description: "当前登录用户的资料、密码与登录记录"},
+	{Name: "两步验证"},
+	{Name: "用户管理"},
+	{Name: "登录安全", Description: "登录记录与登录锁定"},
+	{Name: "会话"},
+	{Name: "院系"},
+	{Name: "专业"},
+	{Name: "课程"},
+	{Name: "开课"},
+	{Name: "成绩"},
+	{Name: "补考"},
+	{Name: "培养方案"},
+	{Name: "毕业审核"},
+	{Name: "角色权限"},
+	{Name: "备份"},
+	{Name: "搜索"},
+	{Name: "文档"},
+}
+
+// routeDocs documents every route of SetupRouter. CheckRoutes reports routes missing
+// here, so a new route fails the contract check until it is documented.
+var routeDocs = []routeDoc{
+	// 认证
+	{"POST", "/api/login", openapi.Route{Tag: "认证", Public: true, Summary: "用户名密码登录",
+		Description: "启用两步验证的用户返回挑战令牌，需再调用 /login/2fa",
+		Body:        controllers.LoginRequest{}, Response: openapi.OneOf(controllers.LoginResponse{}, controllers.TwoFactorChallengeResponse{})}},
+	{"POST", "/api/login/2fa", openapi.Route{Tag: "认证", Public: true, Summary: "提交两步验证码完成登录",
+		Body: controllers.TwoFactorLoginRequest{}, Response: controllers.LoginResponse{}}},
+	{"POST", "/api/login/sso", openapi.Route{Tag: "认证", Public: true, Summary: "统一身份认证（CAS / OIDC）登录",
+		Body: controllers.SSOLoginRequest{}, Response: openapi.OneOf(controllers.LoginResponse{}, controllers.TwoFactorChallengeResponse{})}},
+	{"GET", "/api/auth/providers", openapi.Route{Tag: "认证", Public: true, Summary: "获取统一身份认证登录入口",
+		Query: []openapi.Param{{Name: "state", Description: "随机值，OIDC 回跳时原样带回"}},
+		Response: struct {
+			SSO []controllers.SSOProvider `json:"sso"`
+		}{}}},
+	{"POST", "/api/refresh", openapi.Route{Tag: "认证", Public: true, Summary: "用刷新令牌换取新的令牌",
+		Body: controllers.RefreshRequest{}, Response: controllers.TokenResponse{}}},
+	{"POST", "/api/setup", openapi.Route{Tag: "认证", Public: true, Summary: "首次安装时创建管理员",
+		Body: controllers.SetupRequest{}, Status: http.StatusCreated, Response: struct {
+			Message string       `json:"message"`
+			User    *models.User `json:"user"`
+		}{}}},
+
+	// 当前用户
+	{"GET", "/api/user", openapi.Route{Tag: "当前用户", Summary: "获取当前用户（含角色与权限）",
+		Response: controllers.CurrentUserResponse{}}},
+	{"PUT", "/api/user/profile", openapi.Route{Tag: "当前用户", Summary: "修改个人资料",
+		Body: controllers.ProfileRequest{}, Response: models.User{}}},
+	{"PUT", "/api/user/password", openapi.Route{Tag: "当前用户", Summary: "修改密码",
+		Body: controllers.ChangePasswordRequest{}, Response: messageResponse{}}},
+	{"GET", "/api/user/login-events", openapi.Route{Tag: "当前用户", Summary: "获取本人的登录记录",
+		Query: listParams(), Response: page("LoginEvent", []models.LoginEvent{})}},
+
+	// 两步验证
+	{"GET", "/api/user/2fa", openapi.Route{Tag: "两步验证", Summary: "获取两步验证状态",
+		Response: models.TwoFactorStatus{}}},
+	{"POST", "/api/user/2fa/setup", openapi.Route{Tag: "两步验证", Summary: "生成新的 TOTP 密钥",
+		Response: controllers.TwoFactorSetupResponse{}}},
+	{"POST", "/api/user/2fa/enable", openapi.Route{Tag: "两步验证", Summary: "确认验证码并启用两步验证",
+		Body: controllers.TwoFactorCodeRequest{}, Response: struct {
+			Message       string   `json:"message"`
+			RecoveryCodes []string `json:"recovery_codes"`
+		}{}}},
+	{"POST", "/api/user/2fa/disable", openapi.Route{Tag: "两步验证", Summary: "关闭两步验证",
+		Body: controllers.DisableTwoFactorRequest{}, Response: messageResponse{}}},
+	{"POST", "/api/user/2fa/recovery-codes", openapi.Route{Tag: "两步验证", Summary: "重新生成恢复码",
+		Body: controllers.TwoFactorCodeRequest{}, Response: struct {
+			RecoveryCodes []string `json:"recovery_codes"`
+		}{}}},
+
+	// 用户管理
+	{"GET", "/api/users", openapi.Route{Tag: "用户管理", Summary: "用户列表",
+		Query: listParams(
+			openapi.Param{Name: "role", Description: "按角色筛选"},
+			idParam("department_id", "按院系筛选"),
+			openapi.Param{Name: "disabled", Type: "boolean", Description: "按是否停用筛选"},
+			deletedParam),
+		Response: page("User", []models.User{})}},
+	{"GET", "/api/users/:id", openapi.Route{Tag: "用户管理", Summary: "获取用户",
+		Response: models.User{}}},
+	{"POST", "/api/users", openapi.Route{Tag: "用户管理", Summary: "创建用户",
+		Description: "未指定密码时生成随机密码并在响应中返回",
+		Body:        controllers.CreateUserRequest{}, Status: http.StatusCreated, Response: createdUserResponse{}}},
+	{"POST", "/api/users/import", openapi.Route{Tag: "用户管理", Summary: "批量导入学生或教师账号",
+		Query: []openapi.Param{importRole, dryRunParam}, Upload: "file",
+		Response: openapi.Instance{Name: "UserImportResult", Type: importResponse{}, Fields: map[string]interface{}{"data": []models.UserImportRow{}}}}},
+	{"GET", "/api/users/import/template", openapi.Route{Tag: "用户管理", Summary: "下载账号导入模板",
+		Query: []openapi.Param{importRole, formatParam}, File: spreadsheets}},
+	{"PUT", "/api/users/:id", openapi.Route{Tag: "用户管理", Summary: "修改用户资料",
+		Body: controllers.ProfileRequest{}, Response: models.User{}}},
+	{"PUT", "/api/users/:id/role", openapi.Route{Tag: "用户管理", Summary: "修改用户主角色",
+		Body: controllers.UserRoleRequest{}, Response: messageResponse{}}},
+	{"PUT", "/api/users/:id/status", openapi.Route{Tag: "用户管理", Summary: "停用或启用用户",
+		Body: controllers.UserStatusRequest{}, Response: messageResponse{}}},
+	{"DELETE", "/api/users/:id", openapi.Route{Tag: "用户管理", Summary: "删除用户（可恢复）",
+		Response: messageResponse{}}},
+	{"POST", "/api/users/:id/restore", openapi.Route{Tag: "用户管理", Summary: "恢复已删除的用户",
+		Response: messageResponse{}}},
+	{"DELETE", "/api/users/:id/purge", openapi.Route{Tag: "用户管理", Summary: "彻底删除已删除的用户",
+		Response: messageResponse{}}},
+	{"POST", "/api/users/:id/reset-password", openapi.Route{Tag: "用户管理", Summary: "重置用户密码",
+		Description: "未指定密码时生成随机密码并在响应中返回",
+		Body:        controllers.ResetPasswordRequest{}, BodyOptional: true, Response: struct {
+			Message  string `json:"message"`
+			Password string `json:"password,omitempty"`
+		}{}}},
+	{"POST", "/api/users/:id/unlock", openapi.Route{Tag: "用户管理", Summary: "解除用户的登录锁定",
+		Response: messageResponse{}}},
+	{"DELETE", "/api/users/:id/2fa", openapi.Route{Tag: "用户管理", Summary: "重置用户的两步验证",
+		Response: messageResponse{}}},
+	{"POST", "/api/users/:id/logout", openapi.Route{Tag: "用户管理", Summary: "强制用户在所有设备下线",
+		Response: revokedResponse{}}},
+	{"GET", "/api/users/:id/roles", openapi.Route{Tag: "用户管理", Summary: "获取用户的全部角色",
+		Response: []models.Role{}}},
+	{"PUT", "/api/users/:id/roles", openapi.Route{Tag: "用户管理", Summary: "设置用户的附加角色",
+		Body: controllers.UserRolesRequest{}, Response: []models.Role{}}},
+
+	// 登录安全
+	{"GET", "/api/login-events", openapi.Route{Tag: "登录安全", Summary: "登录记录",
+		Query: listParams(
+			openapi.Param{Name: "username", Description: "按用户名筛选"},
+			openapi.Param{Name: "ip", Description: "按客户端 IP 筛选"},
+			openapi.Param{Name: "success", Type: "boolean", Description: "按是否成功筛选"}),
+		Response: page("LoginEvent", []models.LoginEvent{})}},
+	{"GET", "/api/login-lockouts", openapi.Route{Tag: "登录安全", Summary: "当前被锁定的用户名与 IP",
+		Query:    listParams(openapi.Param{Name: "kind", Description: "锁定类型", Enum: []string{"user", "ip"}}),
+		Response: page("LoginLockout", []models.LoginLockout{})}},
+	{"DELETE", "/api/login-lockouts/ip/:ip", openapi.Route{Tag: "登录安全", Summary: "解除 IP 的登录锁定",
+		Response: messageResponse{}}},
+
+	// 会话
+	{"POST", "/api/logout", openapi.Route{Tag: "会话", Summary: "退出当前会话",
+		Response: messageResponse{}}},
+	{"POST", "/api/logout/all", openapi.Route{Tag: "会话", Summary: "退出所有设备",
+		Response: revokedResponse{}}},
+	{"GET", "/api/sessions", openapi.Route{Tag: "会话", Summary: "当前用户的登录会话",
+		Query: listParams(), Response: page("Session", []models.Session{})}},
+	{"DELETE", "/api/sessions/:id", openapi.Route{Tag: "会话", Summary: "注销一个会话",
+		Response: messageResponse{}}},
+
+	// 院系
+	{"GET", "/api/departments", openapi.Route{Tag: "院系", Summary: "院系列表",
+		Query: listParams(deletedParam), Response: page("Department", []models.Department{})}},
+	{"GET", "/api/departments/:id", openapi.Route{Tag: "院系", Summary: "获取院系",
+		Response: models.Department{}}},
+	{"POST", "/api/departments", openapi.Route{Tag: "院系", Summary: "创建院系",
+		Body: controllers.DepartmentRequest{}, Status: http.StatusCreated, Response: departmentSummary{}}},
+	{"PUT", "/api/departments/:id", openapi.Route{Tag: "院系", Summary: "修改院系",
+		Body: controllers.DepartmentRequest{}, Response: departmentSummary{}}},
+	{"DELETE", "/api/departments/:id", openapi.Route{Tag: "院系", Summary: "删除院系（可恢复）",
+		Response: messageResponse{}}},
+	{"POST", "/api/departments/:id/restore", openapi.Route{Tag: "院系", Summary: "恢复已删除的院系",
+		Response: messageResponse{}}},
+	{"DELETE", "/api/departments/:id/purge", openapi.Route{Tag: "院系", Summary: "彻底删除已删除的院系",
+		Response: messageResponse{}}},
+
+	// 专业
+	{"GET", "/api/majors", openapi.Route{Tag: "专业", Summary: "专业列表",
+		Query:    listParams(idParam("department_id", "按院系筛选"), deletedParam),
+		Response: page("Major", []models.Major{})}},
+	{"DELETE", "/api/majors/:id", openapi.Route{Tag: "专业", Summary: "删除专业（可恢复）",
+		Response: messageResponse{}}},
+	{"POST", "/api/majors/:id/restore", openapi.Route{Tag: "专业", Summary: "恢复已删除的专业",
+		Response: messageResponse{}}},
+	{"DELETE", "/api/majors/:id/purge", openapi.Route{Tag: "专业", Summary: "彻底删除已删除的专业",
+		Response: messageResponse{}}},
+
+	// 课程
+	{"GET", "/api/courses", openapi.Route{Tag: "课程", Summary: "课程列表",
+		Query: listParams(
+			openapi.Param{Name: "type", Description: "按课程类型筛选"},
+			idParam("department_id", "按院系筛选"),
+			openapi.Param{Name: "min_credits", Type: "number", Description: "最低学分"},
+			openapi.Param{Name: "max_credits", Type: "number", Description: "最高学分"},
+			deletedParam),
+		Response: page("Course", []models.Course{})}},
+	{"GET", "/api/courses/:id", openapi.Route{Tag: "课程", Summary: "获取课程",
+		Response: models.Course{}}},
+	{"POST", "/api/courses", openapi.Route{Tag: "课程", Summary: "创建课程",
+		Body: controllers.CourseRequest{}, Status: http.StatusCreated, Response: models.Course{}}},
+	{"PUT", "/api/courses/:id", openapi.Route{Tag: "课程", Summary: "修改课程",
+		Body: controllers.CourseRequest{}, Response: models.Course{}}},
+	{"DELETE", "/api/courses/:id", openapi.Route{Tag: "课程", Summary: "删除课程（可恢复）",
+		Response: messageResponse{}}},
+	{"POST", "/api/courses/:id/restore", openapi.Route{Tag: "课程", Summary: "恢复已删除的课程",
+		Response: messageResponse{}}},
+	{"DELETE", "/api/courses/:id/purge", openapi.Route{Tag: "课程", Summary: "彻底删除已删除的课程",
+		Response: messageResponse{}}},
+
+	// 开课
+	{"GET", "/api/course-offerings", openapi.Route{Tag: "开课", Summary: "开课列表",
+		Query: listParams(
+			openapi.Param{Name: "status", Description: "按状态筛选"},
+			idParam("semester_id", "按学期筛选"),
+			idParam("course_id", "按课程筛选"),
+			idParam("teacher_id", "按授课教师筛选"),
+			deletedParam),
+		Response: page("CourseOffering", []models.CourseOffering{})}},
+	{"DELETE", "/api/course-offerings/:id", openapi.Route{Tag: "开课", Summary: "删除开课（可恢复）",
+		Response: messageResponse{}}},
+	{"POST", "/api/course-offerings/:id/restore", openapi.Route{Tag: "开课", Summary: "恢复已删除的开课",
+		Response: messageResponse{}}},
+	{"DELETE", "/api/course-offerings/:id/purge", openapi.Route{Tag: "开课", Summary: "彻底删除已删除的开课",
+		Response: messageResponse{}}},
+
+	// 成绩
+	{"GET", "/api/grades/student", openapi.Route{Tag: "成绩", Summary: "获取学生成绩",
+		Description: "排序字段：semester（默认降序）、course_code、course_name、grade、created_at；搜索课程名称与代码",
+		Query:       listParams(ownStudentID),
+		Response:    page("Enrollment", []models.Enrollment{})}},
+	{"GET", "/api/grades/course", openapi.Route{Tag: "成绩", Summary: "获取课程的所有学生成绩",
+		Description: "排序字段：student_no（默认）、name、class、grade；搜索学号与姓名",
+		Query:       listParams(offeringID),
+		Response:    page("Enrollment", []models.Enrollment{})}},
+	{"POST", "/api/grades", openapi.Route{Tag: "成绩", Summary: "录入成绩",
+		Body: controllers.GradeRequest{}, Status: http.StatusCreated, Response: createdGradeResponse{}}},
+	{"PUT", "/api/grades/:id", openapi.Route{Tag: "成绩", Summary: "修改成绩",
+		Body: struct {
+			Grade *float64 `json:"grade" binding:"required,gte=0,lte=100"`
+		}{}, Response: messageResponse{}}},
+	{"POST", "/api/grades/import", openapi.Route{Tag: "成绩", Summary: "批量导入成绩（.xlsx 或 .csv）",
+		Query:  []openapi.Param{offeringID, dryRunParam},
+		Upload: "file", Response: openapi.Instance{Name: "GradeImportResult", Type: importResponse{}, Fields: map[string]interface{}{"data": []models.GradeImportRow{}}}}},
+	{"GET", "/api/grades/import/template", openapi.Route{Tag: "成绩", Summary: "下载预填学生名单的成绩导入模板",
+		Query: []openapi.Param{offeringID, formatParam},
+		File:  spreadsheets}},
+	{"GET", "/api/grades/export", openapi.Route{Tag: "成绩", Summary: "导出成绩表",
+		Description: "按开课、学期或院系之一导出",
+		Query: []openapi.Param{
+			{Name: "course_offering_id", Type: "integer", Description: "开课ID", Example: "1"},
+			idParam("semester_id", "学期ID"),
+			idParam("department_id", "院系ID"),
+			formatParam},
+		File: spreadsheets}},
+	{"GET", "/api/grades/transcript", openapi.Route{Tag: "成绩", Summary: "获取学生成绩单",
+		Query:    []openapi.Param{ownStudentID},
+		Response: data("TranscriptData", models.Transcript{})}},
+	{"POST", "/api/grades/:id/retake", openapi.Route{Tag: "成绩", Summary: "为未通过的课程登记重修",
+		Body: struct {
+			CourseOfferingID uint `json:"course_offering_id" binding:"required"`
+		}{}, Status: http.StatusCreated, Response: struct {
+			Message string `json:"message"`
+			ID      uint   `json:"id"`
+		}{}}},
+
+	// 补考
+	{"GET", "/api/makeup-exams", openapi.Route{Tag: "补考", Summary: "获取补考名单",
+		Query:    []openapi.Param{idParam("semester_id", "学期ID"), idParam("course_offering_id", "开课ID")},
+		Response: data("ExamAttemptList", []models.ExamAttempt{})}},
+	{"POST", "/api/makeup-exams/generate", openapi.Route{Tag: "补考", Summary: "根据未通过的成绩生成补考名单",
+		Body: controllers.MakeupRosterRequest{}, Response: struct {
+			Message string `json:"message"`
+			Created int    `json:"created"`
+		}{}}},
+	{"PUT", "/api/makeup-exams/:id", openapi.Route{Tag: "补考", Summary: "录入补考成绩",
+		Body: struct {
+			Score *float64 `json:"score" binding:"required,gte=0,lte=100"`
+		}{}, Response: messageResponse{}}},
+
+	// 培养方案
+	{"GET", "/api/training-plans", openapi.Route{Tag: "培养方案", Summary: "培养方案列表",
+		Query:    listParams(idParam("major_id", "按专业筛选"), openapi.Param{Name: "cohort_year", Type: "integer", Description: "按入学年份筛选"}),
+		Response: page("TrainingPlan", []models.TrainingPlan{})}},
+	{"GET", "/api/training-plans/:id", openapi.Route{Tag: "培养方案", Summary: "获取培养方案（含课程组）",
+		Response: models.TrainingPlan{}}},
+	{"POST", "/api/training-plans", openapi.Route{Tag: "培养方案", Summary: "创建培养方案",
+		Body: controllers.TrainingPlanRequest{}, Status: http.StatusCreated, Response: models.TrainingPlan{}}},
+	{"PUT", "/api/training-plans/:id", openapi.Route{Tag: "培养方案", Summary: "修改培养方案",
+		Body: controllers.TrainingPlanRequest{}, Response: models.TrainingPlan{}}},
+	{"DELETE", "/api/training-plans/:id", openapi.Route{Tag: "培养方案", Summary: "删除培养方案",
+		Response: messageResponse{}}},
+	{"GET", "/api/degree-audit", openapi.Route{Tag: "培养方案", Summary: "学位审核：对照培养方案检查学生修读情况",
+		Query:    []openapi.Param{ownStudentID},
+		Response: models.DegreeAudit{}}},
+
+	// 毕业审核
+	{"GET", "/api/graduation-reviews", openapi.Route{Tag: "毕业审核", Summary: "毕业审核批次列表",
+		Query: listParams(
+			openapi.Param{Name: "status", Description: "按状态筛选", Enum: []string{models.ReviewDraft, models.ReviewConfirmed}},
+			openapi.Param{Name: "graduation_year", Type: "integer", Description: "按毕业年份筛选"},
+			openapi.Param{Name: "cohort_year", Type: "integer", Description: "按入学年份筛选"},
+			idParam("major_id", "按专业筛选")),
+		Response: page("GraduationReview", []models.GraduationReview{})}},
+	{"GET", "/api/graduation-reviews/:id", openapi.Route{Tag: "毕业审核", Summary: "获取毕业审核批次（含每个学生的结果）",
+		Response: models.GraduationReview{}}},
+	{"POST", "/api/graduation-reviews", openapi.Route{Tag: "毕业审核", Summary: "执行毕业审核",
+		Body: controllers.GraduationReviewRequest{}, Status: http.StatusCreated, Response: models.GraduationReview{}}},
+	{"PUT", "/api/graduation-reviews/:id/results/:student_id", openapi.Route{Tag: "毕业审核", Summary: "人工调整学生的审核结果",
+		Body: controllers.GraduationOverrideRequest{}, Response: messageResponse{}}},
+	{"POST", "/api/graduation-reviews/:id/confirm", openapi.Route{Tag: "毕业审核", Summary: "确认审核结果并将合格学生标记为已毕业",
+		Response: struct {
+			Message   string `json:"message"`
+			Graduated int    `json:"graduated"`
+		}{}}},
+
+	// 角色权限
+	{"GET", "/api/permissions", openapi.Route{Tag: "角色权限", Summary: "全部权限",
+		Response: []models.PermissionInfo{}}},
+	{"GET", "/api/roles", openapi.Route{Tag: "角色权限", Summary: "全部角色及其权限",
+		Query: pageParams(), Response: page("RolePermissions", []models.RolePermissions{})}},
+	{"PUT", "/api/roles/:role/permissions", openapi.Route{Tag: "角色权限", Summary: "设置角色的权限",
+		Body: controllers.RolePermissionsRequest{}, Response: messageResponse{}}},
+
+	// 备份
+	{"GET", "/api/backups", openapi.Route{Tag: "备份", Summary: "备份列表，最新的在前",
+		Query: pageParams(), Response: page("Backup", []models.Backup{})}},
+	{"POST", "/api/backups", openapi.Route{Tag: "备份", Summary: "立即备份数据库",
+		Status: http.StatusCreated, Response: models.Backup{}}},
+	{"GET", "/api/backups/:name", openapi.Route{Tag: "备份", Summary: "下载备份文件",
+		Description: "校验和在 X-Checksum-SHA256 响应头中",
+		File:        []string{"application/octet-stream"}}},
+
+	// 搜索
+	{"GET", "/api/search", openapi.Route{Tag: "搜索", Summary: "搜索课程、教师和学生",
+		Query: []openapi.Param{
+			{Name: "q", Required: true, Description: "关键字", Example: "数据"},
+			{Name: "types", Description: "逗号分隔的结果类型：course、teacher、student"},
+			{Name: "limit", Type: "integer", Description: "每类最多返回的条数"}},
+		Response: models.SearchResult{}}},
+
+	// 文档
+	{"GET", "/api/openapi.json", openapi.Route{Tag: "文档", Public: true, OperationID: "getOpenAPIDocument", Summary: "本 OpenAPI 文档",
+		Response: map[string]interface{}{}}},
+	{"GET", "/api/docs", openapi.Route{Tag: "文档", Public: true, OperationID: "getAPIDocs", Summary: "接口文档页面",
+		HTML: true}},
+}
+
+// Document returns the OpenAPI document of the routes. Routes without documentation
+// are left out; CheckRoutes reports them.
+func Document(routes gin.RoutesInfo) *openapi.Document {
+	handlers := map[string]string{}
+	for _, route := range routes {
+		handlers[route.Method+" "+route.Path] = route.Handler
+	}
+
+	doc := openapi.New(openapi.Info{
+		Title:       "高校教学管理系统 API",
+		Description: "需要登录的接口在 Authorization 头中携带 Bearer 访问令牌（见 /login）。错误响应均为 ErrorResponse。",
+		Version:     APIVersion,
+	}, "/api", errorResponse{})
+
+	roles := make([]interface{}, len(models.AllRoles))
+	for i, role := range models.AllRoles {
+		roles[i] = string(role)
+	}
+	doc.Enum(models.Role(""), roles...)
+	permissions := make([]interface{}, len(models.AllPermissions))
+	for i, permission := range models.AllPermissions {
+		permissions[i] = string(permission.Name)
+	}
+	doc.Enum(models.Permission(""), permissions...)
+
+	for _, tag := range tags {
+		doc.AddTag(tag.Name, tag.Description)
+	}
+	for _, d := range routeDocs {
+		handler, ok := handlers[d.method+" "+d.path]
+		if !ok {
+			continue
+		}
+		doc.Add(d.method, d.path, openapi.OperationID(handler), d.route)
+	}
+	return doc
+}
+
+// CheckRoutes reports the routes that are not documented in routeDocs, the documented
+// routes that do not exist and problems with the document itself
+func CheckRoutes(routes gin.RoutesInfo) []string {
+	var problems []string
+	registered := map[string]bool{}
+	for _, route := range routes {
+		registered[route.Method+" "+route.Path] = true
+	}
+	documented := map[string]bool{}
+	for _, d := range routeDocs {
+		key := d.method + " " + d.path
+		if documented[key] {
+			problems = append(problems, fmt.Sprintf("%s is documented twice", key))
+		}
+		documented[key] = true
+		if !registered[key] {
+			problems = append(problems, fmt.Sprintf("%s is documented but not routed", key))
+		}
+	}
+	for _, route := range routes {
+		key := route.Method + " " + route.Path
+		if !documented[key] {
+			problems = append(problems, fmt.Sprintf("%s is not documented", key))
+		}
+	}
+	problems = append(problems, Document(routes).Check()...)
+	sort.Strings(problems)
+	return problems
+}
+
+// apiDocs serves the OpenAPI document and a page to browse it. The document is built
+// once all routes are registered.
+type apiDocs struct {
+	document *openapi.Document
+}
+
+// spec serves the OpenAPI document
+func (d *apiDocs) spec(c *gin.Context) {
+	c.JSON(http.StatusOK, d.document)
+}
+
+// ui serves Swagger UI showing the OpenAPI document
+func (d *apiDocs) ui(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(docsPage))
+}
+
+// docsPage loads Swagger UI from a CDN and points it at /api/openapi.json
+const docsPage = `<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+  <meta charset="utf-8">
+  <title>高校教学管理系统 API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({ url: 'openapi.json', dom_id: '#swagger-ui', persistAuthorization: true })
+  </script>
+</body>
+</html>
+`
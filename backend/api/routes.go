@@ -1,22 +1,73 @@
 package api
 
 import (
+	"fmt"
+
+	"to-mrz/config"
 	"to-mrz/controllers"
+	"to-mrz/db"
 	"to-mrz/middleware"
+	"to-mrz/models"
+	"to-mrz/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
-// SetupRouter configures the API routes
-func SetupRouter() *gin.Engine {
-	r := gin.Default()
+// Row-level data scoping targets (see middleware.ScopeMiddleware)
+var (
+	departmentParam   = middleware.ParamOwner("id", db.ScopeRepository.DepartmentOwner)
+	departmentQuery   = middleware.QueryOwner("department_id", db.ScopeRepository.DepartmentOwner)
+	departmentBody    = middleware.BodyOwner("department_id", db.ScopeRepository.DepartmentOwner)
+	majorParam        = middleware.ParamOwner("id", db.ScopeRepository.MajorOwner)
+	courseParam       = middleware.ParamOwner("id", db.ScopeRepository.CourseOwner)
+	offeringParam     = middleware.ParamOwner("id", db.ScopeRepository.CourseOfferingOwner)
+	offeringQuery     = middleware.QueryOwner("course_offering_id", db.ScopeRepository.CourseOfferingOwner)
+	offeringBody      = middleware.BodyOwner("course_offering_id", db.ScopeRepository.CourseOfferingOwner)
+	enrollmentParam   = middleware.ParamOwner("id", db.ScopeRepository.EnrollmentOwner)
+	examAttemptParam  = middleware.ParamOwner("id", db.ScopeRepository.ExamAttemptOwner)
+	studentQuery      = middleware.StudentQueryOwner("student_id")
+	majorBody         = middleware.BodyOwner("major_id", db.ScopeRepository.MajorOwner)
+	trainingPlanParam = middleware.ParamOwner("id", db.ScopeRepository.TrainingPlanOwner)
+)
+
+// SetupRouter configures the API routes. server.CORSOrigins lists the allowed
+// browser origins, "*" allowing any origin, and server.TrustedProxies the proxies
+// whose X-Forwarded-For header is believed. The handlers access data through repos.
+func SetupRouter(server config.ServerConfig, repos *db.Repositories) *gin.Engine {
+	r := gin.New()
+	// c.ClientIP() keys the login lockouts and is recorded in the sign-in history and
+	// sessions, so it only comes from X-Forwarded-For behind a configured proxy
+	if err := r.SetTrustedProxies(server.TrustedProxies); err != nil {
+		panic(fmt.Sprintf("invalid trusted proxies: %v", err)) // checked by config.Validate
+	}
+	if utils.LogEnabled(utils.LogInfo) {
+		r.Use(gin.Logger())
+	}
+	// Errors writes the error responses of everything after it, including panics
+	// caught by Recovery
+	r.Use(middleware.Errors(), middleware.Recovery())
+	r.NoRoute(middleware.NotFound)
+	s := controllers.NewServer(repos)
+
+	allowedOrigins := map[string]bool{}
+	for _, origin := range server.CORSOrigins {
+		allowedOrigins[origin] = true
+	}
 
 	// Configure CORS
 	r.Use(func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		origin := c.GetHeader("Origin")
+		switch {
+		case allowedOrigins["*"]:
+			c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		case origin != "" && allowedOrigins[origin]:
+			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+			c.Writer.Header().Add("Vary", "Origin")
+		}
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
 		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "Content-Disposition, X-Checksum-SHA256")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -29,48 +80,180 @@ func SetupRouter() *gin.Engine {
 	// Public routes
 	public := r.Group("/api")
 	{
-		public.POST("/login", controllers.Login)
+		public.POST("/login", s.Login)
+		public.POST("/login/2fa", s.LoginTwoFactor)
+		public.POST("/login/sso", s.LoginSSO)
+		public.GET("/auth/providers", s.GetAuthProviders)
+		public.POST("/refresh", s.RefreshToken)
+		public.POST("/setup", s.Setup)
 	}
 
+	// API documentation, built once every route is registered
+	docs := &apiDocs{}
+	public.GET("/openapi.json", docs.spec)
+	public.GET("/docs", docs.ui)
+
 	// Protected routes
 	protected := r.Group("/api")
-	protected.Use(middleware.AuthMiddleware())
+	protected.Use(middleware.AuthMiddleware(repos))
 	{
 		// User routes
-		protected.GET("/user", controllers.GetCurrentUser)
+		protected.GET("/user", s.GetCurrentUser)
+		protected.PUT("/user/profile", s.UpdateProfile)
+		protected.PUT("/user/password", s.ChangePassword)
+		protected.GET("/user/login-events", s.GetMyLoginEvents)
+
+		// Two-factor authentication routes
+		protected.GET("/user/2fa", s.GetTwoFactorStatus)
+		protected.POST("/user/2fa/setup", s.SetupTwoFactor)
+		protected.POST("/user/2fa/enable", s.EnableTwoFactor)
+		protected.POST("/user/2fa/disable", s.DisableTwoFactor)
+		protected.POST("/user/2fa/recovery-codes", s.RegenerateRecoveryCodes)
+
+		// User management routes
+		users := protected.Group("/users")
+		{
+			users.GET("", middleware.PermissionMiddleware(models.PermUserManage), s.GetUsers)
+			users.GET("/:id", middleware.PermissionMiddleware(models.PermUserManage), s.GetUser)
+			users.POST("", middleware.PermissionMiddleware(models.PermUserManage), s.CreateUser)
+			users.POST("/import", middleware.PermissionMiddleware(models.PermUserManage), s.ImportUsers)
+			users.GET("/import/template", middleware.PermissionMiddleware(models.PermUserManage), s.GetUserImportTemplate)
+			users.PUT("/:id", middleware.PermissionMiddleware(models.PermUserManage), s.UpdateUser)
+			users.PUT("/:id/role", middleware.PermissionMiddleware(models.PermUserManage), s.UpdateUserRole)
+			users.PUT("/:id/status", middleware.PermissionMiddleware(models.PermUserManage), s.UpdateUserStatus)
+			users.DELETE("/:id", middleware.PermissionMiddleware(models.PermUserManage), s.DeleteUser)
+			users.POST("/:id/restore", middleware.PermissionMiddleware(models.PermUserManage), s.RestoreUser)
+			users.DELETE("/:id/purge", middleware.PermissionMiddleware(models.PermDataPurge), s.PurgeUser)
+			users.POST("/:id/reset-password", middleware.PermissionMiddleware(models.PermUserManage), s.ResetUserPassword)
+			users.POST("/:id/unlock", middleware.PermissionMiddleware(models.PermUserManage), s.UnlockUser)
+			users.DELETE("/:id/2fa", middleware.PermissionMiddleware(models.PermUserManage), s.ResetUserTwoFactor)
+			users.POST("/:id/logout", middleware.PermissionMiddleware(models.PermSessionManage), s.ForceLogoutUser)
+			users.GET("/:id/roles", middleware.PermissionMiddleware(models.PermRoleManage), s.GetUserRoles)
+			users.PUT("/:id/roles", middleware.PermissionMiddleware(models.PermRoleManage), s.UpdateUserRoles)
+		}
+
+		// Login security routes
+		protected.GET("/login-events", middleware.PermissionMiddleware(models.PermUserManage), s.GetLoginEvents)
+		protected.GET("/login-lockouts", middleware.PermissionMiddleware(models.PermUserManage), s.GetLoginLockouts)
+		protected.DELETE("/login-lockouts/ip/:ip", middleware.PermissionMiddleware(models.PermUserManage), s.UnlockIP)
+
+		// Session routes
+		protected.POST("/logout", s.Logout)
+		protected.POST("/logout/all", s.LogoutAll)
+		protected.GET("/sessions", s.GetSessions)
+		protected.DELETE("/sessions/:id", s.RevokeSession)
 
 		// Department routes
 		departments := protected.Group("/departments")
 		{
-			departments.GET("", controllers.GetDepartments)
-			departments.GET("/:id", controllers.GetDepartment)
-			departments.POST("", middleware.RoleMiddleware("admin", "academic", "department"), controllers.CreateDepartment)
-			departments.PUT("/:id", middleware.RoleMiddleware("admin", "academic", "department"), controllers.UpdateDepartment)
-			departments.DELETE("/:id", middleware.RoleMiddleware("admin", "academic"), controllers.DeleteDepartment)
+			departments.GET("", s.GetDepartments)
+			departments.GET("/:id", s.GetDepartment)
+			departments.POST("", middleware.PermissionMiddleware(models.PermDepartmentWrite), middleware.ScopeMiddleware(middleware.NewTopLevelRow()), s.CreateDepartment)
+			departments.PUT("/:id", middleware.PermissionMiddleware(models.PermDepartmentWrite), middleware.ScopeMiddleware(departmentParam), s.UpdateDepartment)
+			departments.DELETE("/:id", middleware.PermissionMiddleware(models.PermDepartmentDelete), middleware.ScopeMiddleware(departmentParam), s.DeleteDepartment)
+			departments.POST("/:id/restore", middleware.PermissionMiddleware(models.PermDepartmentDelete), middleware.ScopeMiddleware(departmentParam), s.RestoreDepartment)
+			departments.DELETE("/:id/purge", middleware.PermissionMiddleware(models.PermDataPurge), s.PurgeDepartment)
+		}
+
+		// Major routes
+		majors := protected.Group("/majors")
+		{
+			majors.GET("", s.GetMajors)
+			majors.DELETE("/:id", middleware.PermissionMiddleware(models.PermDepartmentDelete), middleware.ScopeMiddleware(majorParam), s.DeleteMajor)
+			majors.POST("/:id/restore", middleware.PermissionMiddleware(models.PermDepartmentDelete), middleware.ScopeMiddleware(majorParam), s.RestoreMajor)
+			majors.DELETE("/:id/purge", middleware.PermissionMiddleware(models.PermDataPurge), s.PurgeMajor)
 		}
 
 		// Course routes
 		courses := protected.Group("/courses")
 		{
-			courses.GET("", controllers.GetCourses)
-			courses.GET("/:id", controllers.GetCourse)
-			courses.POST("", middleware.RoleMiddleware("admin", "academic", "department"), controllers.CreateCourse)
-			courses.PUT("/:id", middleware.RoleMiddleware("admin", "academic", "department"), controllers.UpdateCourse)
-			courses.DELETE("/:id", middleware.RoleMiddleware("admin", "academic"), controllers.DeleteCourse)
+			courses.GET("", s.GetCourses)
+			courses.GET("/:id", s.GetCourse)
+			courses.POST("", middleware.PermissionMiddleware(models.PermCourseWrite), middleware.ScopeMiddleware(departmentBody), s.CreateCourse)
+			courses.PUT("/:id", middleware.PermissionMiddleware(models.PermCourseWrite), middleware.ScopeMiddleware(courseParam, departmentBody), s.UpdateCourse)
+			courses.DELETE("/:id", middleware.PermissionMiddleware(models.PermCourseDelete), middleware.ScopeMiddleware(courseParam), s.DeleteCourse)
+			courses.POST("/:id/restore", middleware.PermissionMiddleware(models.PermCourseDelete), middleware.ScopeMiddleware(courseParam), s.RestoreCourse)
+			courses.DELETE("/:id/purge", middleware.PermissionMiddleware(models.PermDataPurge), s.PurgeCourse)
+		}
+
+		// Course offering routes
+		courseOfferings := protected.Group("/course-offerings")
+		{
+			courseOfferings.GET("", s.GetCourseOfferings)
+			courseOfferings.DELETE("/:id", middleware.PermissionMiddleware(models.PermCourseDelete), middleware.ScopeMiddleware(offeringParam), s.DeleteCourseOffering)
+			courseOfferings.POST("/:id/restore", middleware.PermissionMiddleware(models.PermCourseDelete), middleware.ScopeMiddleware(offeringParam), s.RestoreCourseOffering)
+			courseOfferings.DELETE("/:id/purge", middleware.PermissionMiddleware(models.PermDataPurge), s.PurgeCourseOffering)
 		}
 
 		// Grade routes
 		grades := protected.Group("/grades")
 		{
-			grades.GET("/student", controllers.GetStudentGrades)
-			grades.GET("/course", middleware.RoleMiddleware("admin", "academic", "department", "teacher"), controllers.GetCourseGrades)
-			grades.POST("", middleware.RoleMiddleware("admin", "academic", "department", "teacher"), controllers.CreateGrade)
-			grades.PUT("/:id", middleware.RoleMiddleware("admin", "academic", "department", "teacher"), controllers.UpdateGrade)
+			grades.GET("/student", middleware.PermissionMiddleware(models.PermGradeRead), middleware.ScopeMiddleware(studentQuery), s.GetStudentGrades)
+			grades.GET("/course", middleware.PermissionMiddleware(models.PermGradeRead), middleware.ScopeMiddleware(offeringQuery), s.GetCourseGrades)
+			grades.POST("", middleware.PermissionMiddleware(models.PermGradeWrite), middleware.ScopeMiddleware(offeringBody), s.CreateGrade)
+			grades.PUT("/:id", middleware.PermissionMiddleware(models.PermGradeWrite), middleware.ScopeMiddleware(enrollmentParam), s.UpdateGrade)
+			grades.POST("/import", middleware.PermissionMiddleware(models.PermGradeImport), middleware.ScopeMiddleware(offeringQuery), s.ImportGrades)
+			grades.GET("/import/template", middleware.PermissionMiddleware(models.PermGradeImport), middleware.ScopeMiddleware(offeringQuery), s.GetGradeImportTemplate)
+			grades.GET("/export", middleware.PermissionMiddleware(models.PermGradeExport), middleware.ScopeMiddleware(offeringQuery, departmentQuery), s.ExportGrades)
+			grades.GET("/transcript", middleware.PermissionMiddleware(models.PermGradeRead), middleware.ScopeMiddleware(studentQuery), s.GetTranscript)
+			grades.POST("/:id/retake", middleware.PermissionMiddleware(models.PermGradeRetake), middleware.ScopeMiddleware(enrollmentParam, offeringBody), s.CreateRetake)
+		}
+
+		// Make-up exam routes (补考)
+		makeupExams := protected.Group("/makeup-exams")
+		{
+			makeupExams.GET("", middleware.PermissionMiddleware(models.PermMakeupRead), middleware.ScopeMiddleware(offeringQuery), s.GetMakeupRoster)
+			makeupExams.POST("/generate", middleware.PermissionMiddleware(models.PermMakeupGenerate), middleware.ScopeMiddleware(offeringBody), s.GenerateMakeupRoster)
+			makeupExams.PUT("/:id", middleware.PermissionMiddleware(models.PermMakeupWrite), middleware.ScopeMiddleware(examAttemptParam), s.RecordMakeupScore)
 		}
 
+		// Training plan routes (培养方案)
+		trainingPlans := protected.Group("/training-plans")
+		{
+			trainingPlans.GET("", s.GetTrainingPlans)
+			trainingPlans.GET("/:id", s.GetTrainingPlan)
+			trainingPlans.POST("", middleware.PermissionMiddleware(models.PermTrainingPlanWrite), middleware.ScopeMiddleware(majorBody), s.CreateTrainingPlan)
+			trainingPlans.PUT("/:id", middleware.PermissionMiddleware(models.PermTrainingPlanWrite), middleware.ScopeMiddleware(trainingPlanParam, majorBody), s.UpdateTrainingPlan)
+			trainingPlans.DELETE("/:id", middleware.PermissionMiddleware(models.PermTrainingPlanDelete), middleware.ScopeMiddleware(trainingPlanParam), s.DeleteTrainingPlan)
+		}
+
+		protected.GET("/degree-audit", middleware.ScopeMiddleware(studentQuery), s.GetDegreeAudit)
+
+		// Graduation review routes
+		graduationReviews := protected.Group("/graduation-reviews")
+		{
+			graduationReviews.GET("", middleware.PermissionMiddleware(models.PermGraduationRead, models.PermGraduationReview), s.GetGraduationReviews)
+			graduationReviews.GET("/:id", middleware.PermissionMiddleware(models.PermGraduationRead, models.PermGraduationReview), s.GetGraduationReview)
+			graduationReviews.POST("", middleware.PermissionMiddleware(models.PermGraduationReview), s.CreateGraduationReview)
+			graduationReviews.PUT("/:id/results/:student_id", middleware.PermissionMiddleware(models.PermGraduationReview), s.OverrideGraduationResult)
+			graduationReviews.POST("/:id/confirm", middleware.PermissionMiddleware(models.PermGraduationReview), s.ConfirmGraduationReview)
+		}
+
+		// Role and permission management routes
+		protected.GET("/permissions", middleware.PermissionMiddleware(models.PermRoleManage), s.GetPermissions)
+		roles := protected.Group("/roles")
+		roles.Use(middleware.PermissionMiddleware(models.PermRoleManage))
+		{
+			roles.GET("", s.GetRoles)
+			roles.PUT("/:role/permissions", s.UpdateRolePermissions)
+		}
+
+		// Database backup routes
+		backups := protected.Group("/backups")
+		backups.Use(middleware.PermissionMiddleware(models.PermBackupManage))
+		{
+			backups.GET("", s.GetBackups)
+			backups.POST("", s.CreateBackup)
+			backups.GET("/:name", s.DownloadBackup)
+		}
+
+		// Search across courses, teachers and students, limited by the data scope
+		protected.GET("/search", s.SearchAll)
+
 		// Future routes for majors, classes, courses, etc.
 		// TODO: Implement these routes as we develop the controllers
 	}
 
+	docs.document = Document(r.Routes())
 	return r
 }
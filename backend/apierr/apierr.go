@@ -0,0 +1,187 @@
+// Package apierr defines the errors returned by the HTTP API. Every error response
+// has the same shape:
+//
+//	{"error": "课程不存在", "code": "not_found", "details": [...]}
+//
+// error is the message in the client's language, code is machine-readable and
+// details lists the problems with each field of an invalid request. Handlers record
+// errors with Abort and the Errors middleware writes the response.
+package apierr
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Error codes shared by many responses. Handlers use more specific codes where
+// clients need to tell errors apart, such as CodeInvalidCredentials.
+const (
+	CodeBadRequest      = "bad_request"
+	CodeValidation      = "validation_failed"
+	CodeUnauthorized    = "unauthorized"
+	CodeForbidden       = "forbidden"
+	CodeNotFound        = "not_found"
+	CodeConflict        = "conflict"
+	CodeTooManyRequests = "too_many_requests"
+	CodeInternal        = "internal_error"
+	CodeNotImplemented  = "not_implemented"
+	CodeUnavailable     = "service_unavailable"
+)
+
+// Error codes of authentication and authorization errors
+const (
+	CodeInvalidCredentials     = "invalid_credentials"
+	CodeInvalidTwoFactor       = "invalid_two_factor"
+	CodeChallengeExpired       = "challenge_expired"
+	CodeAccountDisabled        = "account_disabled"
+	CodeLoginThrottled         = "login_throttled"
+	CodeSessionExpired         = "session_expired"
+	CodePasswordChangeRequired = "password_change_required"
+	CodeTwoFactorSetupRequired = "two_factor_setup_required"
+	CodePermissionDenied       = "permission_denied"
+	CodeOutOfScope             = "out_of_scope"
+)
+
+// Error codes of field errors, besides the names of the binding rules (required,
+// min, max, oneof, ...)
+const (
+	FieldInvalid  = "invalid"   // not a valid value for the field
+	FieldNotFound = "not_found" // refers to a row that does not exist
+	FieldTaken    = "taken"     // must be unique but is already in use
+)
+
+// Error is an error response. Message is an English fmt format, which is also the key
+// of its translations (see Translate); Err is the cause, logged but never sent.
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+	Args    []interface{}
+	Details []FieldError
+	Fields  map[string]interface{} // additional response fields
+	Err     error
+}
+
+func (e *Error) Error() string {
+	message := fmt.Sprintf(e.Message, e.Args...)
+	if e.Err != nil {
+		return message + ": " + e.Err.Error()
+	}
+	return message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// With adds a field to the response, for data the client needs besides the message
+func (e *Error) With(key string, value interface{}) *Error {
+	if e.Fields == nil {
+		e.Fields = map[string]interface{}{}
+	}
+	e.Fields[key] = value
+	return e
+}
+
+// Cause sets the error that caused this one
+func (e *Error) Cause(err error) *Error {
+	e.Err = err
+	return e
+}
+
+// FieldError is a problem with one field of a request. Field is the JSON name of the
+// field, with the path to it for nested fields ("items[0].course_id").
+type FieldError struct {
+	Field   string
+	Code    string
+	Message string // English fmt format, translated like Error.Message
+	Args    []interface{}
+}
+
+// Field returns a field error
+func Field(field, code, message string, args ...interface{}) FieldError {
+	return FieldError{Field: field, Code: code, Message: message, Args: args}
+}
+
+// New returns an error with the given status and code
+func New(status int, code, message string, args ...interface{}) *Error {
+	return &Error{Status: status, Code: code, Message: message, Args: args}
+}
+
+// BadRequest returns a 400 error for a malformed request
+func BadRequest(message string, args ...interface{}) *Error {
+	return New(http.StatusBadRequest, CodeBadRequest, message, args...)
+}
+
+// Invalid returns a 400 error listing the problems with the fields of a request
+func Invalid(details ...FieldError) *Error {
+	err := New(http.StatusBadRequest, CodeValidation, "Invalid request")
+	err.Details = details
+	return err
+}
+
+// InvalidField returns a 400 error for a single invalid field, whose message is
+// also the message of the error
+func InvalidField(field, code, message string, args ...interface{}) *Error {
+	err := New(http.StatusBadRequest, CodeValidation, message, args...)
+	err.Details = []FieldError{Field(field, code, message, args...)}
+	return err
+}
+
+// Unauthorized returns a 401 error
+func Unauthorized(message string, args ...interface{}) *Error {
+	return New(http.StatusUnauthorized, CodeUnauthorized, message, args...)
+}
+
+// Forbidden returns a 403 error
+func Forbidden(message string, args ...interface{}) *Error {
+	return New(http.StatusForbidden, CodeForbidden, message, args...)
+}
+
+// NotFound returns a 404 error
+func NotFound(message string, args ...interface{}) *Error {
+	return New(http.StatusNotFound, CodeNotFound, message, args...)
+}
+
+// Conflict returns a 409 error for a request that clashes with the stored data
+func Conflict(message string, args ...interface{}) *Error {
+	return New(http.StatusConflict, CodeConflict, message, args...)
+}
+
+// Internal returns a 500 error caused by err. The message should say what failed
+// without the details of err, which are only logged.
+func Internal(err error, message string, args ...interface{}) *Error {
+	return New(http.StatusInternalServerError, CodeInternal, message, args...).Cause(err)
+}
+
+// Unavailable returns a 503 error caused by err
+func Unavailable(err error, message string, args ...interface{}) *Error {
+	return New(http.StatusServiceUnavailable, CodeUnavailable, message, args...).Cause(err)
+}
+
+// Abort records err for the Errors middleware and stops the handlers after the
+// current one. Errors other than *Error are answered with a 500 error.
+func Abort(c *gin.Context, err error) {
+	_ = c.Error(err)
+	c.Abort()
+}
+
+// Response returns the body of the error response in a language chosen with Language
+func (e *Error) Response(lang string) gin.H {
+	body := gin.H{}
+	for key, value := range e.Fields {
+		body[key] = value
+	}
+	body["error"] = Translate(lang, e.Message, e.Args...)
+	body["code"] = e.Code
+	if len(e.Details) > 0 {
+		details := make([]gin.H, len(e.Details))
+		for i, d := range e.Details {
+			details[i] = gin.H{"field": d.Field, "code": d.Code, "message": Translate(lang, d.Message, d.Args...)}
+		}
+		body["details"] = details
+	}
+	return body
+}
@@ -0,0 +1,64 @@
+package apierr
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Languages of error messages
+const (
+	LangChinese = "zh"
+	LangEnglish = "en"
+)
+
+// DefaultLanguage is used when Accept-Language names no language the API speaks
+const DefaultLanguage = LangChinese
+
+// catalogs holds the translations of messages by language, keyed by the English
+// message. English needs none.
+var catalogs = map[string]map[string]string{
+	LangChinese: zhMessages,
+}
+
+// Language picks the language of the messages from an Accept-Language header,
+// following its quality values
+func Language(acceptLanguage string) string {
+	type choice struct {
+		lang    string
+		quality float64
+	}
+	var choices []choice
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		quality := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if value, err := strconv.ParseFloat(q, 64); err == nil {
+				quality = value
+			}
+		}
+		primary, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		if (primary == LangChinese || primary == LangEnglish) && quality > 0 {
+			choices = append(choices, choice{primary, quality})
+		}
+	}
+	if len(choices) == 0 {
+		return DefaultLanguage
+	}
+	// The first of the most preferred languages wins
+	sort.SliceStable(choices, func(i, j int) bool { return choices[i].quality > choices[j].quality })
+	return choices[0].lang
+}
+
+// Translate formats a message in a language. Messages without a translation are
+// formatted in English.
+func Translate(lang, message string, args ...interface{}) string {
+	if translated, ok := catalogs[lang][message]; ok {
+		message = translated
+	}
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
@@ -0,0 +1,297 @@
+package apierr
+
+// zhMessages 错误信息的中文翻译，以英文信息为键。格式占位符（%s、%d、%q）须与英文一致。
+var zhMessages = map[string]string{
+	// 通用
+	"Internal server error":    "服务器内部错误",
+	"Route not found":          "接口不存在",
+	"Invalid request":          "请求参数有误",
+	"Invalid request format":   "请求格式无效",
+	"Request body is required": "请求体不能为空",
+	"Invalid %s":               "无效的参数 %s",
+	"Invalid ID":               "无效的ID",
+
+	// 字段校验
+	"This field is required":         "此项为必填项",
+	"Is invalid":                     "取值无效",
+	"Must be a %s":                   "类型应为 %s",
+	"Must be a valid email address":  "请输入有效的邮箱地址",
+	"Must be at least %s":            "不能小于 %s",
+	"Must be at most %s":             "不能大于 %s",
+	"Must be at least %s characters": "长度不能少于 %s 个字符",
+	"Must be at most %s characters":  "长度不能超过 %s 个字符",
+	"Must have at least %s items":    "至少需要 %s 项",
+	"Must have at most %s items":     "最多只能有 %s 项",
+	"Must be greater than %s":        "必须大于 %s",
+	"Must be less than %s":           "必须小于 %s",
+	"Must be one of: %s":             "必须是以下值之一：%s",
+	"Failed to check references":     "校验关联数据失败",
+	"Department does not exist":      "院系不存在",
+	"Major does not exist":           "专业不存在",
+	"Class does not exist":           "班级不存在",
+	"Course does not exist":          "课程不存在",
+	"Course offering does not exist": "课程开设不存在",
+	"Semester does not exist":        "学期不存在",
+	"Student does not exist":         "学生不存在",
+	"Teacher does not exist":         "教师不存在",
+
+	// 列表与搜索
+	"Use either page or cursor, not both":        "page 与 cursor 只能使用其一",
+	"Unknown sort field %q, expected one of %s":  "未知的排序字段 %q，可选值为 %s",
+	"Malformed cursor":                           "游标格式无效",
+	"The cursor belongs to another sort":         "游标与当前排序方式不一致",
+	"This list cannot be searched":               "此列表不支持搜索",
+	"This list cannot be sorted":                 "此列表不支持排序",
+	"The cursor's item no longer exists":         "游标所指的条目已不存在",
+	"Invalid deleted filter":                     "无效的 deleted 筛选条件",
+	"Invalid disabled filter":                    "无效的 disabled 筛选条件",
+	"Invalid success filter":                     "无效的 success 筛选条件",
+	"Invalid limit":                              "无效的 limit 参数",
+	"Search keyword (q) is required":             "必须提供搜索关键词（q）",
+	"Invalid search type %q, expected one of %s": "无效的搜索类型 %q，可选值为 %s",
+	"Failed to search":                           "搜索失败",
+
+	// 登录与会话
+	"Invalid username or password":                                                  "用户名或密码错误",
+	"User account is disabled":                                                      "账号已被禁用",
+	"Too many failed login attempts, please try again later":                        "登录失败次数过多，请稍后再试",
+	"Please wait before trying to log in again":                                     "请稍候再尝试登录",
+	"Login challenge is invalid or has expired, please log in again":                "登录验证已失效，请重新登录",
+	"Invalid two-factor code":                                                       "两步验证码错误",
+	"Enter either a code or a recovery code":                                        "请输入验证码或恢复码（二选一）",
+	"Authentication service is unavailable, please try again later":                 "认证服务暂不可用，请稍后再试",
+	"Sign-in ticket is invalid or has expired, please sign in again":                "登录票据无效或已过期，请重新登录",
+	"Unknown sign-in provider":                                                      "未知的登录方式",
+	"An account with this username already exists, please contact an administrator": "已存在同名账号，请联系管理员",
+	"Your account has no access to this system, please contact an administrator":    "您的账号无权访问本系统，请联系管理员",
+	"Failed to authenticate":                                                        "认证失败",
+	"Failed to check login attempts":                                                "检查登录尝试失败",
+	"Failed to check login challenge":                                               "检查登录验证失败",
+	"Failed to verify two-factor code":                                              "校验两步验证码失败",
+	"Failed to complete login":                                                      "完成登录失败",
+	"Failed to start two-factor login":                                              "发起两步验证登录失败",
+	"Failed to generate token":                                                      "生成令牌失败",
+	"Authorization header is required":                                              "缺少 Authorization 请求头",
+	"Authorization header format must be Bearer {token}":                            "Authorization 请求头格式应为 Bearer {token}",
+	"Invalid or expired token":                                                      "令牌无效或已过期",
+	"Invalid or expired refresh token":                                              "刷新令牌无效或已过期",
+	"Refresh token already used, session has been revoked":                          "刷新令牌已被使用，会话已注销",
+	"Session has ended, please log in again":                                        "会话已结束，请重新登录",
+	"User role has changed, please log in again":                                    "用户角色已变更，请重新登录",
+	"Failed to verify session":                                                      "校验会话失败",
+	"Failed to refresh token":                                                       "刷新令牌失败",
+	"Failed to log out":                                                             "退出登录失败",
+	"Failed to log out user":                                                        "注销用户登录失败",
+	"Failed to get sessions":                                                        "获取会话列表失败",
+	"Failed to revoke session":                                                      "注销会话失败",
+	"Invalid session ID":                                                            "无效的会话ID",
+	"Session not found":                                                             "会话不存在",
+	"Failed to get lockouts":                                                        "获取锁定列表失败",
+	"Failed to get login events":                                                    "获取登录记录失败",
+	"Invalid lockout kind":                                                          "无效的锁定类型",
+	"No failed logins recorded for this IP":                                         "该IP没有登录失败记录",
+	"Failed to unlock IP":                                                           "解锁IP失败",
+	"Failed to unlock user":                                                         "解锁用户失败",
+
+	// 权限
+	"User not authenticated":                       "用户未登录",
+	"User does not have the required permission":   "没有执行此操作的权限",
+	"Data is outside your scope":                   "数据不在您的管理范围内",
+	"The requested data does not exist":            "请求的数据不存在",
+	"Failed to load permissions":                   "加载权限失败",
+	"Failed to load data scope":                    "加载数据范围失败",
+	"Failed to check data scope":                   "检查数据范围失败",
+	"Password change required":                     "请先修改密码",
+	"Two-factor authentication must be set up":     "请先设置两步验证",
+	"Role not found":                               "角色不存在",
+	"Unknown permission: %s":                       "未知的权限：%s",
+	"Unknown role: %s":                             "未知的角色：%s",
+	"Only the admin role can hold this permission": "只有管理员角色可以拥有此权限",
+	"The admin role always has every permission":   "管理员角色始终拥有全部权限",
+	"Failed to get roles":                          "获取角色失败",
+	"Failed to update role permissions":            "更新角色权限失败",
+	"Failed to update user roles":                  "更新用户角色失败",
+
+	// 管理员账号只能由管理员修改
+	"Only administrators can give the admin role":           "只有管理员可以授予管理员角色",
+	"Only administrators can change administrator accounts": "只有管理员可以修改管理员账号",
+
+	// 两步验证
+	"Two-factor authentication is already enabled":        "两步验证已开启",
+	"Two-factor authentication is required for your role": "您的角色必须开启两步验证",
+	"Start two-factor setup first":                        "请先发起两步验证设置",
+	"Failed to get two-factor settings":                   "获取两步验证设置失败",
+	"Failed to get two-factor status":                     "获取两步验证状态失败",
+	"Failed to start two-factor setup":                    "发起两步验证设置失败",
+	"Failed to enable two-factor authentication":          "开启两步验证失败",
+	"Failed to disable two-factor authentication":         "关闭两步验证失败",
+	"Failed to reset two-factor authentication":           "重置两步验证失败",
+	"Failed to generate secret":                           "生成密钥失败",
+	"Failed to generate recovery codes":                   "生成恢复码失败",
+	"Failed to save recovery codes":                       "保存恢复码失败",
+
+	// 用户与密码
+	"Invalid user ID":                                            "无效的用户ID",
+	"User not found":                                             "用户不存在",
+	"Deleted user not found":                                     "未找到已删除的用户",
+	"Username already exists":                                    "用户名已存在",
+	"Cannot remove the last active administrator":                "不能移除最后一个启用的管理员",
+	"Department admins must have a department":                   "院系管理员必须指定所属院系",
+	"You cannot delete your own account":                         "不能删除自己的账号",
+	"You cannot disable your own account":                        "不能禁用自己的账号",
+	"Current password is incorrect":                              "当前密码错误",
+	"This account signs in through %s; reset the password there": "该账号通过 %s 登录，请在该系统中重置密码",
+	"Your password is managed by %s; change it there":            "您的密码由 %s 管理，请在该系统中修改",
+	"Password must be at least %d characters":                    "密码长度不能少于 %d 个字符",
+	"Password must be at most 72 bytes":                          "密码长度不能超过 72 字节",
+	"Password must contain at least %d of: lowercase letters, uppercase letters, digits, symbols": "密码须至少包含以下 %d 类字符：小写字母、大写字母、数字、符号",
+	"Password must differ from your last %d passwords":                                            "新密码不能与最近 %d 次使用的密码相同",
+	"Failed to get users":              "获取用户列表失败",
+	"Failed to get user":               "获取用户失败",
+	"Failed to get user roles":         "获取用户角色失败",
+	"Failed to get user permissions":   "获取用户权限失败",
+	"Failed to create user":            "创建用户失败",
+	"Failed to update user":            "更新用户失败",
+	"Failed to update user role":       "更新用户角色失败",
+	"Failed to update user status":     "更新用户状态失败",
+	"Failed to delete user":            "删除用户失败",
+	"Failed to restore user":           "恢复用户失败",
+	"Failed to purge user":             "彻底删除用户失败",
+	"Failed to reset password":         "重置密码失败",
+	"Failed to change password":        "修改密码失败",
+	"Failed to check password":         "校验密码失败",
+	"Failed to check password history": "检查历史密码失败",
+	"Failed to generate password":      "生成密码失败",
+	"Failed to hash password":          "密码加密失败",
+
+	// 初始化
+	"Invalid setup token":              "无效的初始化令牌",
+	"Setup has already been completed": "系统已完成初始化",
+	"Failed to create administrator":   "创建管理员失败",
+
+	// 院系、专业、课程
+	"Invalid department ID":                               "无效的院系ID",
+	"Department not found":                                "院系不存在",
+	"Deleted department not found":                        "未找到已删除的院系",
+	"Department code already exists":                      "院系代码已存在",
+	"Cannot delete department with related majors":        "院系下仍有专业，无法删除",
+	"Failed to retrieve departments":                      "获取院系列表失败",
+	"Failed to retrieve department":                       "获取院系失败",
+	"Failed to create department":                         "创建院系失败",
+	"Failed to update department":                         "更新院系失败",
+	"Failed to delete department":                         "删除院系失败",
+	"Failed to restore department":                        "恢复院系失败",
+	"Failed to purge department":                          "彻底删除院系失败",
+	"Invalid major ID":                                    "无效的专业ID",
+	"Major not found":                                     "专业不存在",
+	"Deleted major not found":                             "未找到已删除的专业",
+	"The major's department is deleted, restore it first": "该专业所属院系已删除，请先恢复院系",
+	"Failed to retrieve majors":                           "获取专业列表失败",
+	"Failed to delete major":                              "删除专业失败",
+	"Failed to restore major":                             "恢复专业失败",
+	"Failed to purge major":                               "彻底删除专业失败",
+	"Invalid course ID":                                   "无效的课程ID",
+	"Course not found":                                    "课程不存在",
+	"Deleted course not found":                            "未找到已删除的课程",
+	"Failed to get courses":                               "获取课程列表失败",
+	"Failed to get course":                                "获取课程失败",
+	"Failed to create course":                             "创建课程失败",
+	"Failed to update course":                             "更新课程失败",
+	"Failed to delete course":                             "删除课程失败",
+	"Failed to restore course":                            "恢复课程失败",
+	"Failed to purge course":                              "彻底删除课程失败",
+	"Invalid teacher ID":                                  "无效的教师ID",
+	"Invalid semester ID":                                 "无效的学期ID",
+	"Invalid course offering ID":                          "无效的课程开设ID",
+	"Course offering ID is required":                      "必须提供课程开设ID",
+	"Course offering not found":                           "未找到课程开设",
+	"Deleted course offering not found":                   "未找到已删除的课程开设",
+	"Failed to get course offerings":                      "获取课程开设失败",
+	"Failed to delete course offering":                    "删除课程开设失败",
+	"Failed to restore course offering":                   "恢复课程开设失败",
+	"Failed to purge course offering":                     "彻底删除课程开设失败",
+
+	// 软删除
+	"Only deleted records can be purged, delete it first": "只能彻底删除已删除的记录，请先删除",
+	"Other records still refer to it":                     "仍有其他记录引用该数据",
+
+	// 培养方案与毕业审核
+	"Invalid training plan ID":                                  "无效的培养方案ID",
+	"Training plan not found":                                   "培养方案不存在",
+	"Invalid course group category: %s":                         "无效的课程组类别：%s",
+	"Invalid or duplicate course in group %s":                   "课程组 %s 中存在无效或重复的课程",
+	"No training plan found for the student's major and cohort": "未找到该学生所在专业和年级的培养方案",
+	"Failed to get training plans":                              "获取培养方案列表失败",
+	"Failed to get training plan":                               "获取培养方案失败",
+	"Failed to create training plan":                            "创建培养方案失败",
+	"Failed to update training plan":                            "更新培养方案失败",
+	"Failed to delete training plan":                            "删除培养方案失败",
+	"Failed to run degree audit":                                "学位审核失败",
+	"Invalid graduation review ID":                              "无效的毕业审核ID",
+	"Invalid graduation year":                                   "无效的毕业年份",
+	"Invalid cohort year":                                       "无效的入学年份",
+	"Graduation review not found":                               "毕业审核不存在",
+	"Graduation result not found":                               "毕业审核结果不存在",
+	"Graduation review already confirmed":                       "毕业审核已确认",
+	"Failed to run graduation review":                           "执行毕业审核失败",
+	"Failed to get graduation reviews":                          "获取毕业审核列表失败",
+	"Failed to get graduation review":                           "获取毕业审核失败",
+	"Failed to override graduation result":                      "修改毕业审核结果失败",
+	"Failed to confirm graduation review":                       "确认毕业审核失败",
+
+	// 成绩、补考与重修
+	"Invalid student ID":                                       "无效的学生ID",
+	"Invalid grade record ID":                                  "无效的成绩记录ID",
+	"Grade record not found":                                   "未找到成绩记录",
+	"Failed to get grades":                                     "获取成绩失败",
+	"Failed to update grade":                                   "更新成绩失败",
+	"Failed to create grade":                                   "创建成绩失败",
+	"Failed to load grade":                                     "加载成绩失败",
+	"The student already has a grade for this offering":        "该学生在此课程开设中已有成绩记录",
+	"Failed to get grade components":                           "获取成绩组成失败",
+	"Failed to export grades":                                  "导出成绩失败",
+	"Failed to get transcript":                                 "获取成绩单失败",
+	"A grade file is required":                                 "必须上传成绩文件",
+	"The grade file is too large":                              "成绩文件过大",
+	"Cannot read the grade file":                               "无法读取成绩文件",
+	"Cannot parse the grade file":                              "无法解析成绩文件",
+	"Failed to check the grade file":                           "校验成绩文件失败",
+	"The grade file has errors":                                "成绩文件校验未通过",
+	"Failed to import grades":                                  "导入成绩失败",
+	"Only .xlsx and .csv files are supported":                  "仅支持 .xlsx 或 .csv 文件",
+	"Only the xlsx and csv formats are supported":              "仅支持 xlsx 或 csv 格式",
+	"Failed to generate the import template":                   "生成导入模板失败",
+	"A course offering, semester or department ID is required": "必须提供课程开设ID、学期ID或院系ID",
+	"No courses to export":                                     "没有可导出的课程",
+	"Grades of several courses can only be exported as xlsx":   "多门课程的成绩只能导出为 xlsx",
+	"Grade record or course offering not found":                "未找到成绩记录或课程开设",
+	"Only failed courses can be retaken":                       "只有未通过的课程才能重修",
+	"The retake offering is for a different course":            "重修的开课与原课程不一致",
+	"A retake must be in a later semester":                     "重修必须在之后的学期进行",
+	"A retake is already registered":                           "已登记过重修",
+	"Failed to register the retake":                            "登记重修失败",
+	"Invalid makeup exam ID":                                   "无效的补考记录ID",
+	"Makeup exam not found":                                    "未找到补考记录",
+	"The makeup exam score has already been recorded":          "补考成绩已录入",
+	"Failed to record makeup exam score":                       "录入补考成绩失败",
+	"Failed to get makeup exam roster":                         "获取补考名单失败",
+	"Failed to generate makeup exam roster":                    "生成补考名单失败",
+
+	// 账号导入
+	"Only student and teacher accounts can be imported": "仅支持导入学生（student）或教师（teacher）账号",
+	"An account file is required":                       "必须上传账号文件",
+	"The account file is too large":                     "账号文件过大",
+	"Cannot read the account file":                      "无法读取账号文件",
+	"Cannot parse the account file":                     "无法解析账号文件",
+	"Failed to check the account file":                  "校验账号文件失败",
+	"The account file has errors":                       "账号文件校验未通过",
+	"Failed to generate initial passwords":              "生成初始密码失败",
+	"Failed to import accounts":                         "导入账号失败",
+
+	// 备份
+	"Backup not found": "备份不存在",
+	"Backups are only supported for SQLite databases": "仅 SQLite 数据库支持备份",
+	"Failed to list backups":                          "获取备份列表失败",
+	"Failed to create backup":                         "创建备份失败",
+	"Failed to get backup":                            "获取备份失败",
+}
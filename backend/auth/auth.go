@@ -0,0 +1,259 @@
+// Package auth checks who a user is. Password logins go through a chain of
+// PasswordAuthenticators (local accounts, LDAP); single sign-on goes through an
+// SSOAuthenticator (CAS, OIDC). Users of external backends get a local account on
+// their first login, with a role mapped from their directory groups.
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"to-mrz/db"
+	"to-mrz/models"
+	"to-mrz/utils"
+)
+
+// Backend names, also stored as the auth_source of the accounts they create
+const (
+	ProviderLocal = models.AuthSourceLocal
+	ProviderLDAP  = "ldap"
+	ProviderCAS   = "cas"
+	ProviderOIDC  = "oidc"
+)
+
+// Authentication errors
+var (
+	// ErrUnknownUser means the backend does not know the username, so the next
+	// backend in the chain should be tried
+	ErrUnknownUser = errors.New("user is not known to this authenticator")
+
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrInvalidTicket      = errors.New("sign-in ticket is invalid or has expired")
+	ErrNoRole             = errors.New("no role is mapped for this user")
+	ErrAccountConflict    = errors.New("an account with this username already exists")
+	ErrUnknownProvider    = errors.New("unknown sign-in provider")
+
+	// ErrUnavailable wraps the failure of a backend that could not be reached
+	ErrUnavailable = errors.New("authentication backend is unavailable")
+)
+
+// ProvisioningError reports an external user who authenticated but cannot be given
+// an account; Err is ErrNoRole or ErrAccountConflict
+type ProvisioningError struct {
+	Username string
+	Err      error
+}
+
+func (e *ProvisioningError) Error() string { return e.Err.Error() + ": " + e.Username }
+
+func (e *ProvisioningError) Unwrap() error { return e.Err }
+
+// Identity is a user as an authentication backend knows them
+type Identity struct {
+	UserID   uint // set by the local backend, whose users already have an account
+	Username string
+	Name     string
+	Email    string
+	Phone    string
+	Groups   []string // groups or affiliations, used for role mapping
+}
+
+// PasswordAuthenticator checks a username and password. It returns ErrUnknownUser
+// for usernames it does not know, ErrInvalidCredentials for a wrong password and any
+// other error when it cannot check the password at all. Backends that keep their
+// users in the database look them up through repos.
+type PasswordAuthenticator interface {
+	Name() string
+	Authenticate(ctx context.Context, repos *db.Repositories, username, password string) (*Identity, error)
+}
+
+// SSOAuthenticator signs users in on an external login page, which sends them back
+// with a ticket (CAS) or authorization code (OIDC) for Validate
+type SSOAuthenticator interface {
+	Name() string
+	Label() string // shown on the login button
+
+	// LoginURL returns where to send the browser. Backends that support it echo
+	// state back to the client, which should check it.
+	LoginURL(ctx context.Context, state string) (string, error)
+	Validate(ctx context.Context, ticket string) (*Identity, error)
+}
+
+// External is implemented by backends whose users are provisioned on first login
+type External interface {
+	Provisioning() ProvisioningPolicy
+}
+
+// ProvisioningPolicy decides how users of an external backend get local accounts
+type ProvisioningPolicy struct {
+	RoleMapping map[string]models.Role // group → role given to new accounts
+	DefaultRole models.Role            // role for users without a mapped group; empty refuses them
+
+	// LinkExistingUsers lets external users sign in to a local account with the same
+	// username, which then becomes an external account. Use it when moving existing
+	// (for example bulk imported) accounts over to single sign-on. Administrators and
+	// accounts with two-factor authentication are never linked, so that a directory
+	// user named like them cannot take them over.
+	LinkExistingUsers bool
+}
+
+// Role returns the role for a new account with the given groups. When several groups
+// are mapped, the role listed first in models.AllRoles wins.
+func (p ProvisioningPolicy) Role(groups []string) (models.Role, bool) {
+	mapped := map[models.Role]bool{}
+	for _, group := range groups {
+		if role, ok := p.RoleMapping[group]; ok {
+			mapped[role] = true
+		}
+	}
+	for _, role := range models.AllRoles {
+		if mapped[role] {
+			return role, true
+		}
+	}
+	return p.DefaultRole, p.DefaultRole != ""
+}
+
+// Password lists the backends password logins are checked against, in order
+var Password = []PasswordAuthenticator{Local{}}
+
+// SSO holds the single sign-on backends by name
+var SSO = map[string]SSOAuthenticator{}
+
+// httpClient makes the requests to CAS and OIDC servers
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// Login checks a username and password against each password backend in turn and
+// returns the user's account from repos, creating it on the first login through an
+// external backend
+func Login(ctx context.Context, repos *db.Repositories, username, password string) (*models.User, error) {
+	if password == "" {
+		// An empty password would be an anonymous bind to most LDAP servers
+		return nil, ErrInvalidCredentials
+	}
+
+	// The first backend to accept the password wins. A wrong password for one backend
+	// may still be right for the next, e.g. a local account that is linked to LDAP.
+	var unavailable error
+	for _, backend := range Password {
+		identity, err := backend.Authenticate(ctx, repos, username, password)
+		switch {
+		case err == nil:
+			return resolve(repos, backend, identity)
+		case errors.Is(err, ErrUnknownUser), errors.Is(err, ErrInvalidCredentials):
+		default:
+			if unavailable == nil {
+				unavailable = fmt.Errorf("%w: %s: %v", ErrUnavailable, backend.Name(), err)
+			}
+		}
+	}
+	if unavailable != nil {
+		// Don't count the attempt as a wrong password when a backend could not check it
+		return nil, unavailable
+	}
+	return nil, ErrInvalidCredentials
+}
+
+// LoginSSO validates a ticket from a single sign-on backend and returns the user's
+// account from repos, creating it on their first login
+func LoginSSO(ctx context.Context, repos *db.Repositories, provider, ticket string) (*models.User, error) {
+	backend, ok := SSO[provider]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	identity, err := backend.Validate(ctx, ticket)
+	if err != nil {
+		return nil, err
+	}
+	return resolve(repos, backend, identity)
+}
+
+// resolve returns the account of an authenticated identity
+func resolve(repos *db.Repositories, backend interface{ Name() string }, identity *Identity) (*models.User, error) {
+	external, ok := backend.(External)
+	if !ok {
+		return repos.Users.Get(identity.UserID)
+	}
+	return provision(repos, backend.Name(), identity, external.Provisioning())
+}
+
+// canLink reports whether an external user may take over an existing local account
+// with the same username
+func canLink(repos *db.Repositories, user *models.User, policy ProvisioningPolicy) (bool, error) {
+	if !policy.LinkExistingUsers || user.AuthSource != ProviderLocal || user.TwoFactorEnabled {
+		return false, nil
+	}
+	roles, err := repos.Roles.UserRoles(user.ID)
+	if err != nil {
+		return false, err
+	}
+	for _, role := range roles {
+		if role == models.RoleAdmin {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// provision returns the account of an external user, creating it if needed
+func provision(repos *db.Repositories, source string, identity *Identity, policy ProvisioningPolicy) (*models.User, error) {
+	user, err := repos.Users.GetByUsername(identity.Username)
+	switch {
+	case err == nil:
+		if user.AuthSource != source {
+			linkable, err := canLink(repos, user, policy)
+			if err != nil {
+				return nil, err
+			}
+			if !linkable {
+				return nil, &ProvisioningError{Username: identity.Username, Err: ErrAccountConflict}
+			}
+		}
+		if err := repos.Users.SyncExternal(user.ID, source, identity.Name, identity.Email, identity.Phone); err != nil {
+			return nil, err
+		}
+		return repos.Users.Get(user.ID)
+
+	case errors.Is(err, sql.ErrNoRows):
+		role, ok := policy.Role(identity.Groups)
+		if !ok {
+			return nil, &ProvisioningError{Username: identity.Username, Err: ErrNoRole}
+		}
+
+		// External accounts cannot sign in with a local password; store an unguessable one
+		secret, err := utils.RandomToken(32)
+		if err != nil {
+			return nil, err
+		}
+		hash, err := utils.HashPassword(secret)
+		if err != nil {
+			return nil, err
+		}
+
+		name := identity.Name
+		if name == "" {
+			name = identity.Username
+		}
+		id, err := repos.Users.Create(&models.User{
+			Username:   identity.Username,
+			Password:   hash,
+			Name:       name,
+			Role:       role,
+			Email:      identity.Email,
+			Phone:      identity.Phone,
+			AuthSource: source,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to provision %s user %q: %w", source, identity.Username, err)
+		}
+		return repos.Users.Get(id)
+
+	default:
+		return nil, err
+	}
+}
@@ -0,0 +1,235 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"to-mrz/db"
+	"to-mrz/models"
+)
+
+// openTestDB gives the test a new SQLite database with the current schema and
+// returns the repositories backed by it
+func openTestDB(t *testing.T) *db.Repositories {
+	t.Helper()
+	if err := db.InitDB(string(db.SQLite), filepath.Join(t.TempDir(), "test.db")); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.CloseDB() })
+	return db.NewSQLRepositories()
+}
+
+// createLocalUser adds a local account and returns it
+func createLocalUser(t *testing.T, username string, role models.Role) *models.User {
+	t.Helper()
+	id, err := db.CreateUser(&models.User{Username: username, Password: "x", Name: "本地" + username, Role: role})
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := db.GetUserByID(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func TestProvisioningPolicyRole(t *testing.T) {
+	policy := ProvisioningPolicy{
+		RoleMapping: map[string]models.Role{
+			"staff":    models.RoleTeacher,
+			"students": models.RoleStudent,
+			"jwc":      models.RoleAcademic,
+		},
+	}
+	for _, tc := range []struct {
+		name        string
+		defaultRole models.Role
+		groups      []string
+		role        models.Role
+		ok          bool
+	}{
+		{"mapped group", "", []string{"other", "students"}, models.RoleStudent, true},
+		{"earliest role of several", "", []string{"students", "staff", "jwc"}, models.RoleAcademic, true},
+		{"default role", models.RoleStudent, []string{"other"}, models.RoleStudent, true},
+		{"mapped group before default", models.RoleStudent, []string{"staff"}, models.RoleTeacher, true},
+		{"no mapping and no default", "", []string{"other"}, "", false},
+		{"no groups", "", nil, "", false},
+	} {
+		policy.DefaultRole = tc.defaultRole
+		role, ok := policy.Role(tc.groups)
+		if role != tc.role || ok != tc.ok {
+			t.Errorf("%s: Role(%v) = %q, %v, want %q, %v", tc.name, tc.groups, role, ok, tc.role, tc.ok)
+		}
+	}
+}
+
+func TestProvisionCreatesAccount(t *testing.T) {
+	repos := openTestDB(t)
+	policy := ProvisioningPolicy{RoleMapping: map[string]models.Role{"staff": models.RoleTeacher}}
+
+	user, err := provision(repos, ProviderCAS, &Identity{Username: "zhang", Email: "zhang@example.edu", Groups: []string{"staff"}}, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Role != models.RoleTeacher || user.AuthSource != ProviderCAS {
+		t.Errorf("new account has role %q and source %q", user.Role, user.AuthSource)
+	}
+	if user.Name != "zhang" || user.Email != "zhang@example.edu" {
+		t.Errorf("new account is named %q with email %q", user.Name, user.Email)
+	}
+
+	// the next login finds the account and syncs the profile
+	again, err := provision(repos, ProviderCAS, &Identity{Username: "zhang", Name: "张老师", Groups: []string{"staff"}}, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != user.ID || again.Name != "张老师" || again.Email != "zhang@example.edu" {
+		t.Errorf("second login gave %+v", again)
+	}
+
+	_, err = provision(repos, ProviderCAS, &Identity{Username: "guest", Groups: []string{"visitors"}}, policy)
+	var refused *ProvisioningError
+	if !errors.As(err, &refused) || !errors.Is(err, ErrNoRole) || refused.Username != "guest" {
+		t.Errorf("unmapped user: %v, want a ProvisioningError with ErrNoRole", err)
+	}
+}
+
+func TestProvisionExistingAccounts(t *testing.T) {
+	repos := openTestDB(t)
+	createLocalUser(t, "local", models.RoleTeacher)
+	createLocalUser(t, "linked", models.RoleTeacher)
+	createLocalUser(t, "admin", models.RoleAdmin)
+
+	extraAdmin := createLocalUser(t, "deputy", models.RoleAcademic)
+	if err := db.SetUserRoles(extraAdmin.ID, []models.Role{models.RoleAdmin}); err != nil {
+		t.Fatal(err)
+	}
+
+	secured := createLocalUser(t, "secured", models.RoleTeacher)
+	if err := db.StartTwoFactorSetup(secured.ID, "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.EnableTwoFactor(secured.ID, 1, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	ldapUser, err := provision(repos, ProviderLDAP, &Identity{Username: "ldapuser"}, ProvisioningPolicy{DefaultRole: models.RoleStudent})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	noLink := ProvisioningPolicy{DefaultRole: models.RoleStudent}
+	link := ProvisioningPolicy{DefaultRole: models.RoleStudent, LinkExistingUsers: true}
+	for _, tc := range []struct {
+		name     string
+		username string
+		policy   ProvisioningPolicy
+		linked   bool
+	}{
+		{"local account without linking", "local", noLink, false},
+		{"local account with linking", "linked", link, true},
+		{"administrator", "admin", link, false},
+		{"administrator through an additional role", "deputy", link, false},
+		{"account with two-factor authentication", "secured", link, false},
+		{"account of another external backend", "ldapuser", link, false},
+	} {
+		user, err := provision(repos, ProviderOIDC, &Identity{Username: tc.username, Name: "外部用户"}, tc.policy)
+		if !tc.linked {
+			if !errors.Is(err, ErrAccountConflict) {
+				t.Errorf("%s: %v, want ErrAccountConflict", tc.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if user.AuthSource != ProviderOIDC || user.Name != "外部用户" || user.MustChangePassword {
+			t.Errorf("%s: linked account %+v", tc.name, user)
+		}
+	}
+
+	// refused takeovers leave the accounts alone
+	for _, username := range []string{"admin", "secured", "ldapuser"} {
+		user, err := db.GetUserByUsername(username)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := ProviderLocal
+		if user.ID == ldapUser.ID {
+			want = ProviderLDAP
+		}
+		if user.AuthSource != want {
+			t.Errorf("%s now signs in through %q", username, user.AuthSource)
+		}
+	}
+}
+
+// fakePassword is a password backend that answers with a fixed result
+type fakePassword struct {
+	name     string
+	identity *Identity
+	err      error
+	policy   *ProvisioningPolicy
+}
+
+func (f fakePassword) Name() string { return f.name }
+
+func (f fakePassword) Authenticate(context.Context, *db.Repositories, string, string) (*Identity, error) {
+	return f.identity, f.err
+}
+
+// fakeExternal is a fakePassword whose users are provisioned
+type fakeExternal struct{ fakePassword }
+
+func (f fakeExternal) Provisioning() ProvisioningPolicy { return *f.policy }
+
+func TestLoginChain(t *testing.T) {
+	repos := openTestDB(t)
+	local := createLocalUser(t, "local", models.RoleTeacher)
+	saved := Password
+	t.Cleanup(func() { Password = saved })
+
+	unknown := fakePassword{name: "unknown", err: ErrUnknownUser}
+	wrong := fakePassword{name: "wrong", err: ErrInvalidCredentials}
+	down := fakePassword{name: "down", err: errors.New("connection refused")}
+	accepts := fakePassword{name: ProviderLocal, identity: &Identity{UserID: local.ID, Username: "local"}}
+	directory := fakeExternal{fakePassword{
+		name:     ProviderLDAP,
+		identity: &Identity{Username: "li", Groups: []string{"students"}},
+		policy:   &ProvisioningPolicy{RoleMapping: map[string]models.Role{"students": models.RoleStudent}},
+	}}
+
+	for _, tc := range []struct {
+		name     string
+		backends []PasswordAuthenticator
+		password string
+		username string
+		err      error
+	}{
+		{"first backend to accept wins", []PasswordAuthenticator{unknown, wrong, accepts}, "secret", "local", nil},
+		{"accepted despite an unavailable backend", []PasswordAuthenticator{down, accepts}, "secret", "local", nil},
+		{"external users are provisioned", []PasswordAuthenticator{unknown, directory}, "secret", "li", nil},
+		{"no backend accepts", []PasswordAuthenticator{unknown, wrong}, "secret", "", ErrInvalidCredentials},
+		{"unavailable is not a wrong password", []PasswordAuthenticator{wrong, down}, "secret", "", ErrUnavailable},
+		{"empty password", []PasswordAuthenticator{accepts}, "", "", ErrInvalidCredentials},
+	} {
+		Password = tc.backends
+		user, err := Login(context.Background(), repos, "someone", tc.password)
+		if tc.err != nil {
+			if !errors.Is(err, tc.err) {
+				t.Errorf("%s: %v, want %v", tc.name, err, tc.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if user.Username != tc.username {
+			t.Errorf("%s: signed in as %q, want %q", tc.name, user.Username, tc.username)
+		}
+	}
+}
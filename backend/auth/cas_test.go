@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"to-mrz/models"
+)
+
+const casService = "https://school.example.edu/login/sso/cas"
+
+// newFakeCAS starts a stand-in CAS server under /cas. The ticket ST-good validates
+// for casService; other tickets fail, and ST-error makes the server fail.
+func newFakeCAS(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/cas/p3/serviceValidate", func(w http.ResponseWriter, r *http.Request) {
+		ticket := r.URL.Query().Get("ticket")
+		if ticket == "ST-error" {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		if ticket != "ST-good" || r.URL.Query().Get("service") != casService {
+			fmt.Fprintf(w, `<cas:serviceResponse xmlns:cas="http://www.yale.edu/tp/cas">
+  <cas:authenticationFailure code="INVALID_TICKET">Ticket %s not recognized</cas:authenticationFailure>
+</cas:serviceResponse>`, ticket)
+			return
+		}
+		fmt.Fprint(w, `<cas:serviceResponse xmlns:cas="http://www.yale.edu/tp/cas">
+  <cas:authenticationSuccess>
+    <cas:user> 2023001 </cas:user>
+    <cas:attributes>
+      <cas:displayName>张三</cas:displayName>
+      <cas:mail>zhangsan@example.edu</cas:mail>
+      <cas:memberOf>students</cas:memberOf>
+      <cas:memberOf>cs2023</cas:memberOf>
+    </cas:attributes>
+  </cas:authenticationSuccess>
+</cas:serviceResponse>`)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newCAS(server *httptest.Server) *CAS {
+	return &CAS{
+		URL:        server.URL + "/cas/",
+		ServiceURL: casService,
+		Attributes: SSOAttributes{Name: "displayName", Email: "mail", Groups: "memberOf"},
+		Policy:     ProvisioningPolicy{RoleMapping: map[string]models.Role{"students": models.RoleStudent}},
+	}
+}
+
+func TestCASLoginURL(t *testing.T) {
+	c := newCAS(newFakeCAS(t))
+	loginURL, err := c.LoginURL(context.Background(), "ignored")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(loginURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Path != "/cas/login" || u.Query().Get("service") != casService {
+		t.Errorf("login URL = %s", loginURL)
+	}
+}
+
+func TestCASValidate(t *testing.T) {
+	c := newCAS(newFakeCAS(t))
+
+	identity, err := c.Validate(context.Background(), "ST-good")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.Username != "2023001" || identity.Name != "张三" || identity.Email != "zhangsan@example.edu" {
+		t.Errorf("identity = %+v", identity)
+	}
+	if len(identity.Groups) != 2 || identity.Groups[0] != "students" || identity.Groups[1] != "cs2023" {
+		t.Errorf("groups = %v", identity.Groups)
+	}
+
+	if _, err := c.Validate(context.Background(), "ST-expired"); !errors.Is(err, ErrInvalidTicket) {
+		t.Errorf("unknown ticket: %v, want ErrInvalidTicket", err)
+	}
+	if _, err := c.Validate(context.Background(), "ST-error"); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("failing server: %v, want ErrUnavailable", err)
+	}
+
+	c.ServiceURL = "https://other.example.edu/"
+	if _, err := c.Validate(context.Background(), "ST-good"); !errors.Is(err, ErrInvalidTicket) {
+		t.Errorf("ticket for another service: %v, want ErrInvalidTicket", err)
+	}
+}
+
+func TestLoginSSOWithCAS(t *testing.T) {
+	repos := openTestDB(t)
+	saved := SSO
+	t.Cleanup(func() { SSO = saved })
+	SSO = map[string]SSOAuthenticator{ProviderCAS: newCAS(newFakeCAS(t))}
+
+	user, err := LoginSSO(context.Background(), repos, ProviderCAS, "ST-good")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Username != "2023001" || user.Role != models.RoleStudent || user.AuthSource != ProviderCAS {
+		t.Errorf("provisioned %+v", user)
+	}
+}
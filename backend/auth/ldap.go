@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"to-mrz/db"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// LDAPAttributes names the directory attributes read into an Identity
+type LDAPAttributes struct {
+	Username string // e.g. uid or sAMAccountName; the login name if empty
+	Name     string // e.g. cn or displayName
+	Email    string // e.g. mail
+	Phone    string // e.g. telephoneNumber or mobile
+	Groups   string // e.g. memberOf or eduPersonAffiliation
+}
+
+// LDAP checks passwords by binding to an LDAP directory as the user. The user's DN
+// is found with a search, made as the service account if one is configured.
+type LDAP struct {
+	URL          string // ldap://host:389 or ldaps://host:636
+	StartTLS     bool
+	SkipVerify   bool // accept any server certificate; for testing only
+	BindDN       string
+	BindPassword string
+	BaseDN       string
+	UserFilter   string // e.g. (uid=%s); %s is replaced by the escaped username
+	Attributes   LDAPAttributes
+	Timeout      time.Duration
+	Policy       ProvisioningPolicy
+}
+
+// Name returns the backend name
+func (l *LDAP) Name() string { return ProviderLDAP }
+
+// Provisioning returns how directory users get local accounts
+func (l *LDAP) Provisioning() ProvisioningPolicy { return l.Policy }
+
+// Authenticate looks the user up in the directory and binds with their password
+func (l *LDAP) Authenticate(ctx context.Context, _ *db.Repositories, username, password string) (*Identity, error) {
+	conn, err := l.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if l.BindDN != "" {
+		if err := conn.Bind(l.BindDN, l.BindPassword); err != nil {
+			return nil, fmt.Errorf("service account bind failed: %w", err)
+		}
+	}
+
+	attributes := []string{"dn"}
+	for _, name := range []string{l.Attributes.Username, l.Attributes.Name, l.Attributes.Email, l.Attributes.Phone, l.Attributes.Groups} {
+		if name != "" {
+			attributes = append(attributes, name)
+		}
+	}
+	result, err := conn.Search(ldap.NewSearchRequest(
+		l.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+		2, int(l.timeout().Seconds()), false,
+		fmt.Sprintf(l.UserFilter, ldap.EscapeFilter(username)),
+		attributes, nil,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("user search failed: %w", err)
+	}
+	switch len(result.Entries) {
+	case 0:
+		return nil, ErrUnknownUser
+	case 1:
+	default:
+		return nil, fmt.Errorf("user search for %q matched %d entries", username, len(result.Entries))
+	}
+	entry := result.Entries[0]
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("user bind failed: %w", err)
+	}
+
+	identity := &Identity{
+		Username: username,
+		Name:     entry.GetAttributeValue(l.Attributes.Name),
+		Email:    entry.GetAttributeValue(l.Attributes.Email),
+		Phone:    entry.GetAttributeValue(l.Attributes.Phone),
+		Groups:   entry.GetAttributeValues(l.Attributes.Groups),
+	}
+	// Use the directory's spelling of the username so that "Zhang3" and "zhang3"
+	// end up in the same account
+	if l.Attributes.Username != "" {
+		if name := entry.GetAttributeValue(l.Attributes.Username); name != "" {
+			identity.Username = name
+		}
+	}
+	return identity, nil
+}
+
+// dial connects to the directory, upgrading to TLS if configured
+func (l *LDAP) dial(ctx context.Context) (*ldap.Conn, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: l.SkipVerify}
+	dialer := &net.Dialer{Timeout: l.timeout()}
+	if deadline, ok := ctx.Deadline(); ok {
+		dialer.Deadline = deadline
+	}
+
+	conn, err := ldap.DialURL(l.URL, ldap.DialWithDialer(dialer), ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(l.timeout())
+
+	if l.StartTLS {
+		if strings.HasPrefix(l.URL, "ldaps://") {
+			conn.Close()
+			return nil, errors.New("start_tls cannot be used with an ldaps:// URL")
+		}
+		tlsConfig.ServerName, _, _ = net.SplitHostPort(strings.TrimPrefix(l.URL, "ldap://"))
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// timeout returns the network timeout for directory requests
+func (l *LDAP) timeout() time.Duration {
+	if l.Timeout > 0 {
+		return l.Timeout
+	}
+	return 10 * time.Second
+}
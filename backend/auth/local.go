@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"to-mrz/db"
+	"to-mrz/utils"
+)
+
+// Local checks passwords against the bcrypt hashes in the users table
+type Local struct{}
+
+// Name returns the backend name
+func (Local) Name() string { return ProviderLocal }
+
+// Authenticate checks the password of a local account. Accounts of external
+// backends are left to those backends.
+func (Local) Authenticate(_ context.Context, repos *db.Repositories, username, password string) (*Identity, error) {
+	user, err := repos.Users.GetByUsername(username)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUnknownUser
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.AuthSource != ProviderLocal {
+		return nil, ErrUnknownUser
+	}
+
+	if !utils.CheckPasswordHash(password, user.Password) {
+		return nil, ErrInvalidCredentials
+	}
+	return &Identity{UserID: user.ID, Username: user.Username}, nil
+}
@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"to-mrz/db"
+	"to-mrz/models"
+	"to-mrz/utils"
+)
+
+// fakeUsers holds accounts by username
+type fakeUsers struct {
+	db.UserRepository
+	users map[string]*models.User
+}
+
+func (f fakeUsers) GetByUsername(username string) (*models.User, error) {
+	user, ok := f.users[username]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return user, nil
+}
+
+func TestLocalAuthenticate(t *testing.T) {
+	hash, err := utils.HashPassword("secret-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repos := &db.Repositories{Users: fakeUsers{users: map[string]*models.User{
+		"local": {ID: 1, Username: "local", Password: hash, AuthSource: ProviderLocal},
+		"cas":   {ID: 2, Username: "cas", Password: hash, AuthSource: ProviderCAS},
+	}}}
+
+	for _, tc := range []struct {
+		name, username, password string
+		err                      error
+	}{
+		{"right password", "local", "secret-1", nil},
+		{"wrong password", "local", "secret-2", ErrInvalidCredentials},
+		{"unknown username", "nobody", "secret-1", ErrUnknownUser},
+		{"account of an external backend", "cas", "secret-1", ErrUnknownUser},
+	} {
+		identity, err := Local{}.Authenticate(context.Background(), repos, tc.username, tc.password)
+		if !errors.Is(err, tc.err) {
+			t.Errorf("%s: %v, want %v", tc.name, err, tc.err)
+			continue
+		}
+		if err == nil && (identity.UserID != 1 || identity.Username != "local") {
+			t.Errorf("%s: identity %+v", tc.name, identity)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// OIDC signs users in through an OpenID Connect provider with the authorization code
+// flow. The code is exchanged with the client secret, and the user's claims are read
+// from the userinfo endpoint over the same authenticated channel.
+type OIDC struct {
+	Issuer        string // endpoints are discovered from {Issuer}/.well-known/openid-configuration
+	ClientID      string
+	ClientSecret  string
+	RedirectURL   string
+	Scopes        []string // defaults to openid, profile and email
+	UsernameClaim string   // defaults to preferred_username
+	DisplayName   string
+	Claims        SSOAttributes
+	Policy        ProvisioningPolicy
+
+	mu        sync.Mutex
+	endpoints *oidcEndpoints
+}
+
+// oidcEndpoints holds the discovered provider endpoints
+type oidcEndpoints struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+}
+
+// Name returns the backend name
+func (o *OIDC) Name() string { return ProviderOIDC }
+
+// Label returns the login button text
+func (o *OIDC) Label() string { return o.DisplayName }
+
+// Provisioning returns how OIDC users get local accounts
+func (o *OIDC) Provisioning() ProvisioningPolicy { return o.Policy }
+
+// LoginURL returns the provider's authorization page
+func (o *OIDC) LoginURL(ctx context.Context, state string) (string, error) {
+	endpoints, err := o.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	scopes := o.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "profile", "email"}
+	}
+	params := url.Values{
+		"response_type": {"code"},
+		"client_id":     {o.ClientID},
+		"redirect_uri":  {o.RedirectURL},
+		"scope":         {strings.Join(scopes, " ")},
+		"state":         {state},
+	}
+	separator := "?"
+	if strings.Contains(endpoints.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return endpoints.AuthorizationEndpoint + separator + params.Encode(), nil
+}
+
+// Validate exchanges an authorization code and reads the user's claims
+func (o *OIDC) Validate(ctx context.Context, code string) (*Identity, error) {
+	endpoints, err := o.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Exchange the code for an access token
+	form := url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {o.RedirectURL},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoints.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(o.ClientID), url.QueryEscape(o.ClientSecret))
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		Error       string `json:"error"`
+	}
+	status, err := doJSON(req, &token)
+	if err != nil {
+		return nil, err
+	}
+	if status == http.StatusBadRequest && token.Error == "invalid_grant" {
+		// Unknown, expired or already used code
+		return nil, ErrInvalidTicket
+	}
+	if status != http.StatusOK || token.AccessToken == "" {
+		return nil, fmt.Errorf("%w: token endpoint returned %d %s", ErrUnavailable, status, token.Error)
+	}
+
+	// Read the claims
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, endpoints.UserinfoEndpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+
+	claims := map[string]interface{}{}
+	status, err = doJSON(req, &claims)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("%w: userinfo endpoint returned %d", ErrUnavailable, status)
+	}
+
+	usernameClaim := o.UsernameClaim
+	if usernameClaim == "" {
+		usernameClaim = "preferred_username"
+	}
+	identity := &Identity{
+		Username: claimString(claims, usernameClaim),
+		Name:     claimString(claims, o.Claims.Name),
+		Email:    claimString(claims, o.Claims.Email),
+		Phone:    claimString(claims, o.Claims.Phone),
+		Groups:   claimStrings(claims, o.Claims.Groups),
+	}
+	if identity.Username == "" {
+		return nil, fmt.Errorf("userinfo has no %q claim", usernameClaim)
+	}
+	return identity, nil
+}
+
+// discover fetches the provider's endpoints once. Failures are retried on the next call.
+func (o *OIDC) discover(ctx context.Context) (*oidcEndpoints, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.endpoints != nil {
+		return o.endpoints, nil
+	}
+
+	issuer := strings.TrimRight(o.Issuer, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	endpoints := &oidcEndpoints{}
+	status, err := doJSON(req, endpoints)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("%w: OIDC discovery returned %d", ErrUnavailable, status)
+	}
+	if strings.TrimRight(endpoints.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("OIDC discovery returned issuer %q, want %q", endpoints.Issuer, o.Issuer)
+	}
+	if endpoints.AuthorizationEndpoint == "" || endpoints.TokenEndpoint == "" || endpoints.UserinfoEndpoint == "" {
+		return nil, errors.New("OIDC discovery is missing the authorization, token or userinfo endpoint")
+	}
+
+	o.endpoints = endpoints
+	return endpoints, nil
+}
+
+// doJSON sends a request and decodes its JSON response, returning the status code
+func doJSON(req *http.Request, dest interface{}) (int, error) {
+	req.Header.Set("Accept", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(dest); err != nil {
+		return resp.StatusCode, fmt.Errorf("invalid response from %s: %w", req.URL.Host, err)
+	}
+	return resp.StatusCode, nil
+}
+
+// claimString returns a string claim, or "" if it is missing or not a string
+func claimString(claims map[string]interface{}, name string) string {
+	value, _ := claims[name].(string)
+	return value
+}
+
+// claimStrings returns a claim that is a string or a list of strings
+func claimStrings(claims map[string]interface{}, name string) []string {
+	switch value := claims[name].(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		var values []string
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
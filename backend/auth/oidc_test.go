@@ -0,0 +1,189 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"to-mrz/models"
+)
+
+// fakeOIDC is a stand-in OpenID Connect provider. The code "good" is exchanged for
+// an access token whose userinfo holds claims; any other code is an invalid grant.
+type fakeOIDC struct {
+	*httptest.Server
+	issuer      string // issuer reported by discovery, the server URL by default
+	claims      map[string]interface{}
+	discoveries int
+}
+
+func newFakeOIDC(t *testing.T, claims map[string]interface{}) *fakeOIDC {
+	f := &fakeOIDC{claims: claims}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		f.discoveries++
+		issuer := f.issuer
+		if issuer == "" {
+			issuer = f.URL
+		}
+		writeJSON(w, http.StatusOK, map[string]string{
+			"issuer":                 issuer,
+			"authorization_endpoint": f.URL + "/authorize?tenant=school",
+			"token_endpoint":         f.URL + "/token",
+			"userinfo_endpoint":      f.URL + "/userinfo",
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != "school" || secret != "s3cret" {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+			return
+		}
+		if r.PostFormValue("grant_type") != "authorization_code" || r.PostFormValue("redirect_uri") != "https://school.example.edu/login/sso/oidc" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+			return
+		}
+		if r.PostFormValue("code") != "good" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"access_token": "token-1", "token_type": "Bearer"})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token-1" {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_token"})
+			return
+		}
+		writeJSON(w, http.StatusOK, f.claims)
+	})
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+	return f
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func (f *fakeOIDC) backend() *OIDC {
+	return &OIDC{
+		Issuer:       f.URL + "/",
+		ClientID:     "school",
+		ClientSecret: "s3cret",
+		RedirectURL:  "https://school.example.edu/login/sso/oidc",
+		Claims:       SSOAttributes{Name: "name", Email: "email", Groups: "groups"},
+		Policy:       ProvisioningPolicy{RoleMapping: map[string]models.Role{"teachers": models.RoleTeacher}},
+	}
+}
+
+func TestOIDCLoginURL(t *testing.T) {
+	f := newFakeOIDC(t, nil)
+	o := f.backend()
+
+	loginURL, err := o.LoginURL(context.Background(), "state-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(loginURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := u.Query()
+	if u.Path != "/authorize" || query.Get("tenant") != "school" {
+		t.Errorf("login URL %s does not keep the authorization endpoint", loginURL)
+	}
+	for name, want := range map[string]string{
+		"response_type": "code",
+		"client_id":     "school",
+		"redirect_uri":  "https://school.example.edu/login/sso/oidc",
+		"scope":         "openid profile email",
+		"state":         "state-1",
+	} {
+		if got := query.Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+
+	// the endpoints are discovered once
+	if _, err := o.LoginURL(context.Background(), "state-2"); err != nil {
+		t.Fatal(err)
+	}
+	if f.discoveries != 1 {
+		t.Errorf("discovery fetched %d times", f.discoveries)
+	}
+}
+
+func TestOIDCValidate(t *testing.T) {
+	f := newFakeOIDC(t, map[string]interface{}{
+		"sub":                "f81d4fae",
+		"preferred_username": "wang",
+		"name":               "王老师",
+		"email":              "wang@example.edu",
+		"groups":             []string{"teachers", "staff"},
+	})
+	o := f.backend()
+
+	identity, err := o.Validate(context.Background(), "good")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.Username != "wang" || identity.Name != "王老师" || identity.Email != "wang@example.edu" ||
+		strings.Join(identity.Groups, ",") != "teachers,staff" {
+		t.Errorf("identity = %+v", identity)
+	}
+
+	if _, err := o.Validate(context.Background(), "used"); !errors.Is(err, ErrInvalidTicket) {
+		t.Errorf("used code: %v, want ErrInvalidTicket", err)
+	}
+
+	o.ClientSecret = "wrong"
+	if _, err := o.Validate(context.Background(), "good"); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("rejected client: %v, want ErrUnavailable", err)
+	}
+
+	o = f.backend()
+	o.UsernameClaim = "employee_id"
+	if _, err := o.Validate(context.Background(), "good"); err == nil {
+		t.Error("userinfo without the username claim was accepted")
+	}
+}
+
+func TestOIDCDiscoveryChecksIssuer(t *testing.T) {
+	f := newFakeOIDC(t, nil)
+	f.issuer = "https://attacker.example.com"
+
+	if _, err := f.backend().LoginURL(context.Background(), "state"); err == nil {
+		t.Error("discovery for another issuer was accepted")
+	}
+}
+
+func TestLoginSSOWithOIDC(t *testing.T) {
+	repos := openTestDB(t)
+	f := newFakeOIDC(t, map[string]interface{}{
+		"preferred_username": "wang",
+		"name":               "王老师",
+		"groups":             "teachers",
+	})
+	saved := SSO
+	t.Cleanup(func() { SSO = saved })
+	SSO = map[string]SSOAuthenticator{ProviderOIDC: f.backend()}
+
+	user, err := LoginSSO(context.Background(), repos, ProviderOIDC, "good")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Username != "wang" || user.Role != models.RoleTeacher || user.AuthSource != ProviderOIDC {
+		t.Errorf("provisioned %+v", user)
+	}
+
+	if _, err := LoginSSO(context.Background(), repos, ProviderCAS, "good"); !errors.Is(err, ErrUnknownProvider) {
+		t.Errorf("unconfigured provider: %v, want ErrUnknownProvider", err)
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"to-mrz/api"
+	"to-mrz/config"
+	"to-mrz/controllers"
+	"to-mrz/db"
+	"to-mrz/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// runCommand runs a one-off administrative command instead of starting the server
+func runCommand(cfg *config.Config, args []string) error {
+	switch args[0] {
+	case "bootstrap-admin":
+		return bootstrapAdmin(cfg, args[1:])
+	case "migrate":
+		return migrate(cfg, args[1:])
+	case "rollback":
+		return rollback(cfg, args[1:])
+	case "migrate-status":
+		return migrateStatus(cfg, args[1:])
+	case "restore":
+		return restore(cfg, args[1:])
+	case "openapi":
+		return openAPI(args[1:])
+	default:
+		return fmt.Errorf("unknown command %q (available: bootstrap-admin, migrate, rollback, migrate-status, restore, openapi)", args[0])
+	}
+}
+
+// bootstrapAdmin creates the first administrator account with an operator-chosen password.
+// The password is read from ADMIN_PASSWORD or, if unset, from the first line of stdin.
+func bootstrapAdmin(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("bootstrap-admin", flag.ContinueOnError)
+	username := fs.String("username", "admin", "administrator username")
+	name := fs.String("name", "系统管理员", "administrator display name")
+	email := fs.String("email", "", "administrator email")
+	phone := fs.String("phone", "", "administrator phone")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	password := os.Getenv("ADMIN_PASSWORD")
+	if password == "" {
+		fmt.Fprint(os.Stderr, "Administrator password: ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return fmt.Errorf("failed to read password: %w", err)
+		}
+		password = strings.TrimRight(line, "\r\n")
+	}
+
+	if err := db.InitDB(cfg.Database.Driver, cfg.Database.Source()); err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	defer db.CloseDB()
+
+	user := &models.User{Username: *username, Name: *name, Email: *email, Phone: *phone}
+	if _, err := controllers.NewServer(db.NewSQLRepositories()).BootstrapAdmin(user, password); err != nil {
+		return err
+	}
+
+	fmt.Printf("Administrator %q created\n", *username)
+	return nil
+}
+
+// migrate applies pending schema migrations, all of them unless -to is given
+func migrate(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	to := fs.Int("to", 0, "migrate up to this version (default: latest)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if err := db.OpenDB(cfg.Database.Driver, cfg.Database.Source()); err != nil {
+		return err
+	}
+	defer db.CloseDB()
+
+	count, err := db.Migrate(*to)
+	if err != nil {
+		return err
+	}
+	version, _, err := db.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Applied %d migration(s); schema is at version %d\n", count, version)
+	return nil
+}
+
+// rollback reverts the newest schema migration, or several with -steps or -to
+func rollback(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("rollback", flag.ContinueOnError)
+	steps := fs.Int("steps", 1, "number of migrations to roll back")
+	to := fs.Int("to", -1, "roll back to this version instead (0 drops the whole schema)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if err := db.OpenDB(cfg.Database.Driver, cfg.Database.Source()); err != nil {
+		return err
+	}
+	defer db.CloseDB()
+
+	version, _, err := db.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	target := *to
+	if target < 0 {
+		if *steps < 1 {
+			return fmt.Errorf("-steps must be at least 1")
+		}
+		target = version - *steps
+		if target < 0 {
+			target = 0
+		}
+	}
+
+	count, err := db.Rollback(target)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Rolled back %d migration(s); schema is at version %d\n", count, target)
+	return nil
+}
+
+// migrateStatus lists the schema migrations and whether each has been applied
+func migrateStatus(cfg *config.Config, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("unexpected arguments %v", args)
+	}
+
+	if err := db.OpenDB(cfg.Database.Driver, cfg.Database.Source()); err != nil {
+		return err
+	}
+	defer db.CloseDB()
+
+	statuses, err := db.MigrationStatuses()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+	for _, s := range statuses {
+		state, appliedAt := "pending", ""
+		if s.AppliedAt != nil {
+			state, appliedAt = "applied", s.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		switch {
+		case s.Unknown:
+			state = "unknown (newer program)"
+		case s.Modified:
+			state = "modified since applied"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", s.Version, s.Name, state, appliedAt)
+	}
+	return w.Flush()
+}
+
+// restore replaces the SQLite database with a backup, given as a file or as the name
+// of a backup in the backup directory. The backup is checked first, and the current
+// database is backed up unless -no-backup is given. Stop the server before restoring.
+func restore(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	noBackup := fs.Bool("no-backup", false, "do not back up the current database first")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: restore [-no-backup] <backup file or name>")
+	}
+	if cfg.Database.Driver != config.DriverSQLite {
+		return db.ErrBackupUnsupported
+	}
+
+	source := fs.Arg(0)
+	if _, err := os.Stat(source); errors.Is(err, os.ErrNotExist) && filepath.Base(source) == source {
+		source = filepath.Join(cfg.Backup.Dir, source)
+	}
+	backup, err := db.CheckBackup(source)
+	if err != nil {
+		return err
+	}
+	if backup.SchemaVersion == 0 {
+		return fmt.Errorf("%s has no schema migrations applied", source)
+	}
+
+	if _, err := os.Stat(cfg.Database.Path); err == nil && !*noBackup {
+		if err := db.OpenDB(cfg.Database.Driver, cfg.Database.Source()); err != nil {
+			return err
+		}
+		current, err := db.CreateBackup(models.BackupPreRestore)
+		db.CloseDB()
+		if err != nil {
+			return fmt.Errorf("failed to back up the current database (use -no-backup to skip): %w", err)
+		}
+		fmt.Printf("Current database backed up to %s\n", filepath.Join(cfg.Backup.Dir, current.Name))
+	}
+
+	if _, err := db.RestoreBackup(source, cfg.Database.Path); err != nil {
+		return err
+	}
+	fmt.Printf("Restored %s (schema version %d)\n", source, backup.SchemaVersion)
+	if pending := db.LatestVersion() - backup.SchemaVersion; pending > 0 {
+		fmt.Printf("%d migration(s) are pending; run migrate or start the server with auto_migrate\n", pending)
+	}
+	return nil
+}
+
+// openAPI writes the OpenAPI document of the API as JSON and, with -client, the
+// JavaScript client the frontend calls the API with
+func openAPI(args []string) error {
+	fs := flag.NewFlagSet("openapi", flag.ContinueOnError)
+	output := fs.String("o", "", "write the document to this file instead of stdout")
+	client := fs.String("client", "", "also write the JavaScript client to this file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	gin.SetMode(gin.ReleaseMode)
+	doc := api.Document(api.SetupRouter(config.ServerConfig{}, db.NewSQLRepositories()).Routes())
+
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	out = append(out, '\n')
+	if *output == "" {
+		if _, err := os.Stdout.Write(out); err != nil {
+			return err
+		}
+	} else if err := os.WriteFile(*output, out, 0o644); err != nil {
+		return err
+	}
+
+	if *client != "" {
+		f, err := os.Create(*client)
+		if err != nil {
+			return err
+		}
+		if err := doc.WriteClient(f, "`go run . openapi -client` in backend/"); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Client written to %s\n", *client)
+	}
+	return nil
+}
@@ -0,0 +1,698 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"to-mrz/models"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Run modes
+const (
+	EnvDevelopment = "development"
+	EnvProduction  = "production"
+)
+
+// DefaultJWTSecret is the development-only signing secret; production refuses to start with it
+const DefaultJWTSecret = "your-secret-key"
+
+// MinJWTSecretLength is the minimum secret length required in production
+const MinJWTSecretLength = 32
+
+// Duration is a time.Duration that is written as "24h" or "30m" in config files
+type Duration time.Duration
+
+// UnmarshalText parses a duration string such as "24h"
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// MarshalText formats the duration as a string
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// Config holds all runtime settings of the server
+type Config struct {
+	Env        string           `yaml:"env" toml:"env"`
+	Server     ServerConfig     `yaml:"server" toml:"server"`
+	Database   DatabaseConfig   `yaml:"database" toml:"database"`
+	JWT        JWTConfig        `yaml:"jwt" toml:"jwt"`
+	Log        LogConfig        `yaml:"log" toml:"log"`
+	Grades     GradesConfig     `yaml:"grades" toml:"grades"`
+	Graduation GraduationConfig `yaml:"graduation" toml:"graduation"`
+	Login      LoginConfig      `yaml:"login" toml:"login"`
+	Password   PasswordConfig   `yaml:"password" toml:"password"`
+	TwoFactor  TwoFactorConfig  `yaml:"two_factor" toml:"two_factor"`
+	Auth       AuthConfig       `yaml:"auth" toml:"auth"`
+	Backup     BackupConfig     `yaml:"backup" toml:"backup"`
+}
+
+// ServerConfig holds HTTP server settings
+type ServerConfig struct {
+	ListenAddr  string   `yaml:"listen_addr" toml:"listen_addr"`
+	CORSOrigins []string `yaml:"cors_origins" toml:"cors_origins"`
+	// TrustedProxies lists the IPs or CIDR ranges of reverse proxies whose
+	// X-Forwarded-For header gives the client IP; by default no proxy is trusted
+	TrustedProxies []string `yaml:"trusted_proxies" toml:"trusted_proxies"`
+}
+
+// Database drivers
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+	DriverMySQL    = "mysql"
+)
+
+// DatabaseConfig holds database settings
+type DatabaseConfig struct {
+	Driver      string `yaml:"driver" toml:"driver"`             // sqlite, postgres or mysql
+	Path        string `yaml:"path" toml:"path"`                 // database file, for sqlite
+	DSN         string `yaml:"dsn" toml:"dsn"`                   // data source name, for postgres and mysql
+	AutoMigrate bool   `yaml:"auto_migrate" toml:"auto_migrate"` // apply pending migrations at startup
+}
+
+// Source returns the database file for SQLite and the data source name otherwise
+func (d DatabaseConfig) Source() string {
+	if d.Driver == DriverSQLite {
+		return d.Path
+	}
+	return d.DSN
+}
+
+// JWTConfig holds token signing settings
+type JWTConfig struct {
+	Secret        string   `yaml:"secret" toml:"secret"`
+	Expiry        Duration `yaml:"expiry" toml:"expiry"`                 // access token lifetime
+	RefreshExpiry Duration `yaml:"refresh_expiry" toml:"refresh_expiry"` // refresh token (session) lifetime
+}
+
+// LogConfig holds logging settings
+type LogConfig struct {
+	Level string `yaml:"level" toml:"level"` // debug, info, warn or error
+}
+
+// GradesConfig holds grade calculation settings
+type GradesConfig struct {
+	Policy string `yaml:"policy" toml:"policy"` // best, latest or all
+}
+
+// GraduationConfig selects the graduation requirements that are checked besides the
+// training plan. Theses, internships and fees are not recorded by this system, so these
+// checks are off unless the school fills in the theses, internships and student_fees tables.
+type GraduationConfig struct {
+	RequireThesis     bool `yaml:"require_thesis" toml:"require_thesis"`         // a graded thesis
+	RequireInternship bool `yaml:"require_internship" toml:"require_internship"` // a completed internship
+	RequireFeesPaid   bool `yaml:"require_fees_paid" toml:"require_fees_paid"`   // no outstanding fees
+}
+
+// LoginConfig holds the brute-force protection thresholds for logins
+type LoginConfig struct {
+	MaxUserFailures int      `yaml:"max_user_failures" toml:"max_user_failures"` // failures per username before lockout
+	MaxIPFailures   int      `yaml:"max_ip_failures" toml:"max_ip_failures"`     // failures per client IP before lockout
+	FailureWindow   Duration `yaml:"failure_window" toml:"failure_window"`       // failures older than this are forgotten
+	LockoutDuration Duration `yaml:"lockout_duration" toml:"lockout_duration"`
+	BaseDelay       Duration `yaml:"base_delay" toml:"base_delay"` // wait after the first failure, doubled after each further one
+	MaxDelay        Duration `yaml:"max_delay" toml:"max_delay"`
+}
+
+// PasswordConfig holds the password policy for user accounts
+type PasswordConfig struct {
+	MinLength           int      `yaml:"min_length" toml:"min_length"`
+	MinCharacterClasses int      `yaml:"min_character_classes" toml:"min_character_classes"` // of lowercase, uppercase, digits and symbols
+	History             int      `yaml:"history" toml:"history"`                             // recent passwords that may not be reused
+	MaxAge              Duration `yaml:"max_age" toml:"max_age"`                             // 0 means passwords never expire
+}
+
+// TwoFactorConfig holds TOTP two-factor authentication settings
+type TwoFactorConfig struct {
+	Issuer        string   `yaml:"issuer" toml:"issuer"`                 // name shown in authenticator apps
+	RequiredRoles []string `yaml:"required_roles" toml:"required_roles"` // roles that must use two-factor authentication
+}
+
+// BackupConfig holds database backup settings; backups are only taken of SQLite databases
+type BackupConfig struct {
+	Dir      string   `yaml:"dir" toml:"dir"`
+	Interval Duration `yaml:"interval" toml:"interval"` // time between scheduled backups; 0 disables them
+	Keep     int      `yaml:"keep" toml:"keep"`         // newest backups kept, older ones are deleted
+}
+
+// Password authentication backends
+const (
+	ProviderLocal = "local"
+	ProviderLDAP  = "ldap"
+)
+
+// AuthConfig holds the authentication backends
+type AuthConfig struct {
+	Providers []string   `yaml:"providers" toml:"providers"` // password backends in the order they are tried: local, ldap
+	LDAP      LDAPConfig `yaml:"ldap" toml:"ldap"`
+	CAS       CASConfig  `yaml:"cas" toml:"cas"`   // enabled when url is set
+	OIDC      OIDCConfig `yaml:"oidc" toml:"oidc"` // enabled when issuer is set
+}
+
+// ProvisioningConfig decides how users of an external backend get accounts on first login
+type ProvisioningConfig struct {
+	RoleMapping       map[string]string `yaml:"role_mapping" toml:"role_mapping"`               // group → role
+	DefaultRole       string            `yaml:"default_role" toml:"default_role"`               // role for unmapped users; empty refuses them
+	LinkExistingUsers bool              `yaml:"link_existing_users" toml:"link_existing_users"` // take over local accounts with the same username
+}
+
+// LDAPConfig holds LDAP directory settings
+type LDAPConfig struct {
+	URL                string   `yaml:"url" toml:"url"` // ldap://host:389 or ldaps://host:636
+	StartTLS           bool     `yaml:"start_tls" toml:"start_tls"`
+	InsecureSkipVerify bool     `yaml:"insecure_skip_verify" toml:"insecure_skip_verify"`
+	BindDN             string   `yaml:"bind_dn" toml:"bind_dn"` // service account for the user search; anonymous if empty
+	BindPassword       string   `yaml:"bind_password" toml:"bind_password"`
+	BaseDN             string   `yaml:"base_dn" toml:"base_dn"`
+	UserFilter         string   `yaml:"user_filter" toml:"user_filter"` // %s is replaced by the username
+	UsernameAttribute  string   `yaml:"username_attribute" toml:"username_attribute"`
+	NameAttribute      string   `yaml:"name_attribute" toml:"name_attribute"`
+	EmailAttribute     string   `yaml:"email_attribute" toml:"email_attribute"`
+	PhoneAttribute     string   `yaml:"phone_attribute" toml:"phone_attribute"`
+	GroupAttribute     string   `yaml:"group_attribute" toml:"group_attribute"`
+	Timeout            Duration `yaml:"timeout" toml:"timeout"`
+
+	ProvisioningConfig `yaml:",inline"`
+}
+
+// CASConfig holds CAS single sign-on settings
+type CASConfig struct {
+	URL            string `yaml:"url" toml:"url"`                 // CAS server prefix, e.g. https://cas.example.edu/cas
+	ServiceURL     string `yaml:"service_url" toml:"service_url"` // the frontend's /login/sso/cas page
+	ValidatePath   string `yaml:"validate_path" toml:"validate_path"`
+	Label          string `yaml:"label" toml:"label"`
+	NameAttribute  string `yaml:"name_attribute" toml:"name_attribute"`
+	EmailAttribute string `yaml:"email_attribute" toml:"email_attribute"`
+	PhoneAttribute string `yaml:"phone_attribute" toml:"phone_attribute"`
+	GroupAttribute string `yaml:"group_attribute" toml:"group_attribute"`
+
+	ProvisioningConfig `yaml:",inline"`
+}
+
+// OIDCConfig holds OpenID Connect single sign-on settings
+type OIDCConfig struct {
+	Issuer        string   `yaml:"issuer" toml:"issuer"`
+	ClientID      string   `yaml:"client_id" toml:"client_id"`
+	ClientSecret  string   `yaml:"client_secret" toml:"client_secret"`
+	RedirectURL   string   `yaml:"redirect_url" toml:"redirect_url"` // the frontend's /login/sso/oidc page
+	Scopes        []string `yaml:"scopes" toml:"scopes"`
+	Label         string   `yaml:"label" toml:"label"`
+	UsernameClaim string   `yaml:"username_claim" toml:"username_claim"`
+	NameClaim     string   `yaml:"name_claim" toml:"name_claim"`
+	EmailClaim    string   `yaml:"email_claim" toml:"email_claim"`
+	PhoneClaim    string   `yaml:"phone_claim" toml:"phone_claim"`
+	GroupsClaim   string   `yaml:"groups_claim" toml:"groups_claim"`
+
+	ProvisioningConfig `yaml:",inline"`
+}
+
+// Default returns the built-in development configuration
+func Default() *Config {
+	return &Config{
+		Env: EnvDevelopment,
+		Server: ServerConfig{
+			ListenAddr:  ":8080",
+			CORSOrigins: []string{"*"},
+		},
+		Database: DatabaseConfig{Driver: DriverSQLite, Path: "./data/university.db", AutoMigrate: true},
+		JWT: JWTConfig{
+			Secret:        DefaultJWTSecret,
+			Expiry:        Duration(15 * time.Minute),
+			RefreshExpiry: Duration(7 * 24 * time.Hour),
+		},
+		Log:    LogConfig{Level: "info"},
+		Grades: GradesConfig{Policy: "best"},
+		Login: LoginConfig{
+			MaxUserFailures: 5,
+			MaxIPFailures:   20,
+			FailureWindow:   Duration(15 * time.Minute),
+			LockoutDuration: Duration(15 * time.Minute),
+			BaseDelay:       Duration(time.Second),
+			MaxDelay:        Duration(30 * time.Second),
+		},
+		Password: PasswordConfig{
+			MinLength:           8,
+			MinCharacterClasses: 2,
+			History:             5,
+		},
+		TwoFactor: TwoFactorConfig{Issuer: "University System"},
+		Auth: AuthConfig{
+			Providers: []string{ProviderLocal},
+			LDAP: LDAPConfig{
+				UserFilter:     "(uid=%s)",
+				NameAttribute:  "cn",
+				EmailAttribute: "mail",
+				PhoneAttribute: "telephoneNumber",
+				GroupAttribute: "memberOf",
+				Timeout:        Duration(10 * time.Second),
+			},
+			CAS: CASConfig{
+				ValidatePath:   "/p3/serviceValidate",
+				Label:          "统一身份认证",
+				NameAttribute:  "cn",
+				EmailAttribute: "mail",
+				GroupAttribute: "memberOf",
+			},
+			OIDC: OIDCConfig{
+				Scopes:        []string{"openid", "profile", "email"},
+				Label:         "统一身份认证 (OIDC)",
+				UsernameClaim: "preferred_username",
+				NameClaim:     "name",
+				EmailClaim:    "email",
+				PhoneClaim:    "phone_number",
+				GroupsClaim:   "groups",
+			},
+		},
+		Backup: BackupConfig{
+			Dir:      "./data/backups",
+			Interval: Duration(24 * time.Hour),
+			Keep:     7,
+		},
+	}
+}
+
+// Load reads the configuration. The file is taken from APP_CONFIG, or config.yaml /
+// config.toml in the working directory if present; environment variables override it.
+func Load() (*Config, error) {
+	cfg := Default()
+
+	path := os.Getenv("APP_CONFIG")
+	if path == "" {
+		for _, candidate := range []string{"config.yaml", "config.yml", "config.toml"} {
+			if _, err := os.Stat(candidate); err == nil {
+				path = candidate
+				break
+			}
+		}
+	}
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, fmt.Errorf("failed to load config file %s: %w", path, err)
+		}
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// loadFile decodes a YAML or TOML file over the current values
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return yaml.Unmarshal(data, c)
+	case ".toml":
+		return toml.Unmarshal(data, c)
+	default:
+		return fmt.Errorf("unsupported config format %q (use .yaml or .toml)", filepath.Ext(path))
+	}
+}
+
+// applyEnv overrides settings from APP_* environment variables
+func (c *Config) applyEnv() error {
+	if v, ok := os.LookupEnv("APP_ENV"); ok {
+		c.Env = v
+	}
+	if v, ok := os.LookupEnv("APP_LISTEN_ADDR"); ok {
+		c.Server.ListenAddr = v
+	}
+	envList("APP_CORS_ORIGINS", &c.Server.CORSOrigins)
+	envList("APP_TRUSTED_PROXIES", &c.Server.TrustedProxies)
+	envString("APP_DB_DRIVER", &c.Database.Driver)
+	if v, ok := os.LookupEnv("APP_DB_PATH"); ok {
+		c.Database.Path = v
+	}
+	envString("APP_DB_DSN", &c.Database.DSN)
+	if err := envBool("APP_DB_AUTO_MIGRATE", &c.Database.AutoMigrate); err != nil {
+		return err
+	}
+	if v, ok := os.LookupEnv("APP_JWT_SECRET"); ok {
+		c.JWT.Secret = v
+	}
+	if err := envDuration("APP_JWT_EXPIRY", &c.JWT.Expiry); err != nil {
+		return err
+	}
+	if err := envDuration("APP_JWT_REFRESH_EXPIRY", &c.JWT.RefreshExpiry); err != nil {
+		return err
+	}
+	if v, ok := os.LookupEnv("APP_LOG_LEVEL"); ok {
+		c.Log.Level = v
+	}
+	if v, ok := os.LookupEnv("APP_GRADE_POLICY"); ok {
+		c.Grades.Policy = v
+	}
+	if err := envBool("APP_GRADUATION_REQUIRE_THESIS", &c.Graduation.RequireThesis); err != nil {
+		return err
+	}
+	if err := envBool("APP_GRADUATION_REQUIRE_INTERNSHIP", &c.Graduation.RequireInternship); err != nil {
+		return err
+	}
+	if err := envBool("APP_GRADUATION_REQUIRE_FEES_PAID", &c.Graduation.RequireFeesPaid); err != nil {
+		return err
+	}
+	if err := envInt("APP_LOGIN_MAX_USER_FAILURES", &c.Login.MaxUserFailures); err != nil {
+		return err
+	}
+	if err := envInt("APP_LOGIN_MAX_IP_FAILURES", &c.Login.MaxIPFailures); err != nil {
+		return err
+	}
+	if err := envDuration("APP_LOGIN_FAILURE_WINDOW", &c.Login.FailureWindow); err != nil {
+		return err
+	}
+	if err := envDuration("APP_LOGIN_LOCKOUT_DURATION", &c.Login.LockoutDuration); err != nil {
+		return err
+	}
+	if err := envDuration("APP_LOGIN_BASE_DELAY", &c.Login.BaseDelay); err != nil {
+		return err
+	}
+	if err := envDuration("APP_LOGIN_MAX_DELAY", &c.Login.MaxDelay); err != nil {
+		return err
+	}
+	if err := envInt("APP_PASSWORD_MIN_LENGTH", &c.Password.MinLength); err != nil {
+		return err
+	}
+	if err := envInt("APP_PASSWORD_MIN_CHARACTER_CLASSES", &c.Password.MinCharacterClasses); err != nil {
+		return err
+	}
+	if err := envInt("APP_PASSWORD_HISTORY", &c.Password.History); err != nil {
+		return err
+	}
+	if err := envDuration("APP_PASSWORD_MAX_AGE", &c.Password.MaxAge); err != nil {
+		return err
+	}
+	if v, ok := os.LookupEnv("APP_2FA_ISSUER"); ok {
+		c.TwoFactor.Issuer = v
+	}
+	envList("APP_2FA_REQUIRED_ROLES", &c.TwoFactor.RequiredRoles)
+	envList("APP_AUTH_PROVIDERS", &c.Auth.Providers)
+	envString("APP_LDAP_URL", &c.Auth.LDAP.URL)
+	envString("APP_LDAP_BIND_DN", &c.Auth.LDAP.BindDN)
+	envString("APP_LDAP_BIND_PASSWORD", &c.Auth.LDAP.BindPassword)
+	envString("APP_LDAP_BASE_DN", &c.Auth.LDAP.BaseDN)
+	envString("APP_CAS_URL", &c.Auth.CAS.URL)
+	envString("APP_CAS_SERVICE_URL", &c.Auth.CAS.ServiceURL)
+	envString("APP_OIDC_ISSUER", &c.Auth.OIDC.Issuer)
+	envString("APP_OIDC_CLIENT_ID", &c.Auth.OIDC.ClientID)
+	envString("APP_OIDC_CLIENT_SECRET", &c.Auth.OIDC.ClientSecret)
+	envString("APP_OIDC_REDIRECT_URL", &c.Auth.OIDC.RedirectURL)
+	envString("APP_BACKUP_DIR", &c.Backup.Dir)
+	if err := envDuration("APP_BACKUP_INTERVAL", &c.Backup.Interval); err != nil {
+		return err
+	}
+	if err := envInt("APP_BACKUP_KEEP", &c.Backup.Keep); err != nil {
+		return err
+	}
+	return nil
+}
+
+// envString overrides dest from an environment variable
+func envString(name string, dest *string) {
+	if v, ok := os.LookupEnv(name); ok {
+		*dest = v
+	}
+}
+
+// envList overrides dest from a comma-separated environment variable
+func envList(name string, dest *[]string) {
+	if v, ok := os.LookupEnv(name); ok {
+		*dest = nil
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*dest = append(*dest, item)
+			}
+		}
+	}
+}
+
+// envDuration overrides dest from a duration environment variable such as "15m"
+func envDuration(name string, dest *Duration) error {
+	if v, ok := os.LookupEnv(name); ok {
+		if err := dest.UnmarshalText([]byte(v)); err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// envInt overrides dest from an integer environment variable
+func envInt(name string, dest *int) error {
+	if v, ok := os.LookupEnv(name); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+		*dest = n
+	}
+	return nil
+}
+
+// envBool overrides dest from a boolean environment variable such as "true" or "0"
+func envBool(name string, dest *bool) error {
+	if v, ok := os.LookupEnv(name); ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+		*dest = b
+	}
+	return nil
+}
+
+// Validate checks every setting and returns all problems at once
+func (c *Config) Validate() error {
+	var problems []string
+
+	switch c.Env {
+	case EnvDevelopment, EnvProduction:
+	default:
+		problems = append(problems, fmt.Sprintf("env must be %q or %q, got %q", EnvDevelopment, EnvProduction, c.Env))
+	}
+
+	if _, _, err := net.SplitHostPort(c.Server.ListenAddr); err != nil {
+		problems = append(problems, fmt.Sprintf("server.listen_addr %q is not a valid host:port", c.Server.ListenAddr))
+	}
+	if len(c.Server.CORSOrigins) == 0 {
+		problems = append(problems, "server.cors_origins must list at least one origin")
+	}
+	for _, proxy := range c.Server.TrustedProxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				problems = append(problems, fmt.Sprintf("server.trusted_proxies: %q is not an IP address or CIDR range", proxy))
+			}
+		}
+	}
+
+	switch c.Database.Driver {
+	case DriverSQLite:
+		if strings.TrimSpace(c.Database.Path) == "" {
+			problems = append(problems, "database.path is required")
+		}
+	case DriverPostgres, DriverMySQL:
+		if strings.TrimSpace(c.Database.DSN) == "" {
+			problems = append(problems, fmt.Sprintf("database.dsn is required for the %s driver", c.Database.Driver))
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("database.driver must be %q, %q or %q, got %q",
+			DriverSQLite, DriverPostgres, DriverMySQL, c.Database.Driver))
+	}
+
+	if c.JWT.Secret == "" {
+		problems = append(problems, "jwt.secret is required")
+	}
+	if c.JWT.Expiry <= 0 {
+		problems = append(problems, "jwt.expiry must be positive")
+	}
+	if c.JWT.RefreshExpiry <= c.JWT.Expiry {
+		problems = append(problems, "jwt.refresh_expiry must be longer than jwt.expiry")
+	}
+	if c.Env == EnvProduction {
+		if c.JWT.Secret == DefaultJWTSecret {
+			problems = append(problems, "jwt.secret must be changed from the default in production")
+		} else if len(c.JWT.Secret) < MinJWTSecretLength {
+			problems = append(problems, fmt.Sprintf("jwt.secret must be at least %d characters in production", MinJWTSecretLength))
+		}
+	}
+
+	switch c.Log.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		problems = append(problems, fmt.Sprintf("log.level must be debug, info, warn or error, got %q", c.Log.Level))
+	}
+
+	switch c.Grades.Policy {
+	case "best", "latest", "all":
+	default:
+		problems = append(problems, fmt.Sprintf("grades.policy must be best, latest or all, got %q", c.Grades.Policy))
+	}
+
+	if c.Login.MaxUserFailures <= 0 || c.Login.MaxIPFailures <= 0 {
+		problems = append(problems, "login.max_user_failures and login.max_ip_failures must be positive")
+	}
+	if c.Login.FailureWindow <= 0 || c.Login.LockoutDuration <= 0 {
+		problems = append(problems, "login.failure_window and login.lockout_duration must be positive")
+	}
+	if c.Login.BaseDelay < 0 || c.Login.MaxDelay < c.Login.BaseDelay {
+		problems = append(problems, "login.base_delay must not be negative or exceed login.max_delay")
+	}
+
+	if c.Password.MinLength < 6 || c.Password.MinLength > 72 {
+		// bcrypt ignores everything after 72 bytes
+		problems = append(problems, fmt.Sprintf("password.min_length must be between 6 and 72, got %d", c.Password.MinLength))
+	}
+	if c.Password.MinCharacterClasses < 0 || c.Password.MinCharacterClasses > 4 {
+		problems = append(problems, fmt.Sprintf("password.min_character_classes must be between 0 and 4, got %d", c.Password.MinCharacterClasses))
+	}
+	if c.Password.History < 0 || c.Password.MaxAge < 0 {
+		problems = append(problems, "password.history and password.max_age must not be negative")
+	}
+
+	if strings.TrimSpace(c.TwoFactor.Issuer) == "" || strings.Contains(c.TwoFactor.Issuer, ":") {
+		problems = append(problems, "two_factor.issuer is required and must not contain a colon")
+	}
+	for _, role := range c.TwoFactor.RequiredRoles {
+		if !slices.Contains(models.AllRoles, models.Role(role)) {
+			problems = append(problems, fmt.Sprintf("two_factor.required_roles: unknown role %q", role))
+		}
+	}
+
+	problems = append(problems, c.Auth.validate(c.Env)...)
+
+	if strings.TrimSpace(c.Backup.Dir) == "" {
+		problems = append(problems, "backup.dir is required")
+	}
+	if c.Backup.Interval < 0 {
+		problems = append(problems, "backup.interval must not be negative")
+	}
+	if c.Backup.Keep < 1 {
+		problems = append(problems, fmt.Sprintf("backup.keep must be at least 1, got %d", c.Backup.Keep))
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid configuration: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// validate checks the authentication backends
+func (a *AuthConfig) validate(env string) []string {
+	var problems []string
+
+	if len(a.Providers) == 0 {
+		problems = append(problems, "auth.providers must list at least one password backend")
+	}
+	for i, provider := range a.Providers {
+		switch {
+		case provider != ProviderLocal && provider != ProviderLDAP:
+			problems = append(problems, fmt.Sprintf("auth.providers: unknown provider %q (use local or ldap)", provider))
+		case slices.Contains(a.Providers[:i], provider):
+			problems = append(problems, fmt.Sprintf("auth.providers: %q is listed twice", provider))
+		}
+	}
+
+	if slices.Contains(a.Providers, ProviderLDAP) {
+		ldap := a.LDAP
+		if u, err := url.Parse(ldap.URL); err != nil || (u.Scheme != "ldap" && u.Scheme != "ldaps") || u.Host == "" {
+			problems = append(problems, fmt.Sprintf("auth.ldap.url must be an ldap:// or ldaps:// URL, got %q", ldap.URL))
+		}
+		if ldap.BaseDN == "" {
+			problems = append(problems, "auth.ldap.base_dn is required")
+		}
+		if strings.Count(ldap.UserFilter, "%s") != 1 {
+			problems = append(problems, "auth.ldap.user_filter must contain %s exactly once")
+		}
+		if ldap.Timeout <= 0 {
+			problems = append(problems, "auth.ldap.timeout must be positive")
+		}
+		if ldap.InsecureSkipVerify && env == EnvProduction {
+			problems = append(problems, "auth.ldap.insecure_skip_verify must not be used in production")
+		}
+		problems = append(problems, ldap.ProvisioningConfig.validate("auth.ldap")...)
+	}
+
+	if a.CAS.URL != "" {
+		if !isHTTPURL(a.CAS.URL) {
+			problems = append(problems, fmt.Sprintf("auth.cas.url must be an http(s) URL, got %q", a.CAS.URL))
+		}
+		if !isHTTPURL(a.CAS.ServiceURL) {
+			problems = append(problems, fmt.Sprintf("auth.cas.service_url must be an http(s) URL, got %q", a.CAS.ServiceURL))
+		}
+		problems = append(problems, a.CAS.ProvisioningConfig.validate("auth.cas")...)
+	}
+
+	if a.OIDC.Issuer != "" {
+		if !isHTTPURL(a.OIDC.Issuer) {
+			problems = append(problems, fmt.Sprintf("auth.oidc.issuer must be an http(s) URL, got %q", a.OIDC.Issuer))
+		}
+		if a.OIDC.ClientID == "" {
+			problems = append(problems, "auth.oidc.client_id is required")
+		}
+		if !isHTTPURL(a.OIDC.RedirectURL) {
+			problems = append(problems, fmt.Sprintf("auth.oidc.redirect_url must be an http(s) URL, got %q", a.OIDC.RedirectURL))
+		}
+		problems = append(problems, a.OIDC.ProvisioningConfig.validate("auth.oidc")...)
+	}
+
+	return problems
+}
+
+// validate checks that the mapped roles exist and can be given without further setup
+func (p *ProvisioningConfig) validate(section string) []string {
+	var problems []string
+	check := func(key, role string) {
+		switch {
+		case !slices.Contains(models.AllRoles, models.Role(role)):
+			problems = append(problems, fmt.Sprintf("%s.%s: unknown role %q", section, key, role))
+		case models.Role(role) == models.RoleDepartment:
+			// Department admins need a department, which a directory cannot tell us
+			problems = append(problems, fmt.Sprintf("%s.%s: department admins cannot be provisioned automatically", section, key))
+		}
+	}
+	groups := make([]string, 0, len(p.RoleMapping))
+	for group := range p.RoleMapping {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	for _, group := range groups {
+		check("role_mapping["+group+"]", p.RoleMapping[group])
+	}
+	if p.DefaultRole != "" {
+		check("default_role", p.DefaultRole)
+	}
+	return problems
+}
+
+// isHTTPURL reports whether s is an absolute http or https URL
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
@@ -1,10 +1,16 @@
 package controllers
 
 import (
+	"database/sql"
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
+	"to-mrz/apierr"
+	"to-mrz/auth"
 	"to-mrz/db"
-	"to-mrz/middleware"
 	"to-mrz/models"
 	"to-mrz/utils"
 
@@ -17,90 +23,288 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
+// TwoFactorLoginRequest answers a login challenge with a TOTP code or a recovery code
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code"`
+	RecoveryCode   string `json:"recovery_code"`
+}
+
+// TwoFactorChallengeResponse is returned by Login instead of tokens when the user
+// has two-factor authentication enabled
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token"`
+	ExpiresIn         int    `json:"expires_in"` // challenge lifetime in seconds
+}
+
 // LoginResponse contains the login response data
 type LoginResponse struct {
-	Token string       `json:"token"`
-	User  *models.User `json:"user"`
+	TokenResponse
+	User *models.User `json:"user"`
 }
-// here
+
 // Login handles user login
-func Login(c *gin.Context) {
+func (s *Server) Login(c *gin.Context) {
 	var request LoginRequest
-	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	// Look up the account, if there already is one, so refused attempts are logged against it
+	user, err := s.Users.GetByUsername(request.Username)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		apierr.Abort(c, apierr.Internal(err, "Failed to get user"))
+		return
+	}
+	var userID *uint
+	if user != nil {
+		userID = &user.ID
+	}
+
+	ip := c.ClientIP()
+	if !s.allowLoginAttempt(c, userID, request.Username, ip) {
 		return
 	}
 
-	// 特殊处理：如果用户名是admin，密码是admin，自动创建一个admin用户
-	if request.Username == "admin" && request.Password == "admin" {
-		// 使用默认管理员账户
-		user := &models.User{
-			ID:       1,
-			Username: "admin",
-			Name:     "系统管理员",
-			Role:     "admin",
-			Email:    "admin@university.edu",
-			Phone:    "13800138000",
+	// Check the password against the configured backends (local accounts, LDAP)
+	user, err = auth.Login(c.Request.Context(), s.Repositories, request.Username, request.Password)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidCredentials) {
+			s.rejectCredentials(c, userID, request.Username, ip, models.LoginReasonInvalidCredentials)
+			return
 		}
+		s.releaseLoginAttempt(request.Username, ip)
+		s.rejectExternalLogin(c, userID, request.Username, err)
+		return
+	}
+
+	// A disabled account is refused like a wrong password, so that the response does
+	// not confirm the password
+	if user.Disabled {
+		s.rejectCredentials(c, &user.ID, request.Username, ip, models.LoginReasonDisabled)
+		return
+	}
+	s.releaseLoginAttempt(request.Username, ip)
+
+	s.continueLogin(c, user)
+}
+
+// rejectCredentials responds to a login attempt with wrong or unusable credentials,
+// leaving the attempt counted against the brute-force limits
+func (s *Server) rejectCredentials(c *gin.Context, userID *uint, username, ip, reason string) {
+	if err := s.Logins.RecordFailure(username, ip); err != nil {
+		utils.Errorf("recording failed login for %q: %v", username, err)
+	}
+	s.recordLoginEvent(c, userID, username, reason)
+	apierr.Abort(c, apierr.New(http.StatusUnauthorized, apierr.CodeInvalidCredentials, "Invalid username or password"))
+}
+
+// releaseLoginAttempt takes an attempt whose credentials were right, or could not be
+// checked, back off the brute-force limits
+func (s *Server) releaseLoginAttempt(username, ip string) {
+	if err := s.Logins.ReleaseAttempt(username, ip); err != nil {
+		utils.Errorf("releasing login attempt for %q: %v", username, err)
+	}
+}
+
+// continueLogin takes an authenticated user on to the second factor, if they have
+// one, or straight to their session. Password logins have already refused disabled
+// accounts; single sign-on reports them.
+func (s *Server) continueLogin(c *gin.Context, user *models.User) {
+	if user.Disabled {
+		s.recordLoginEvent(c, &user.ID, user.Username, models.LoginReasonDisabled)
+		apierr.Abort(c, apierr.New(http.StatusForbidden, apierr.CodeAccountDisabled, "User account is disabled"))
+		return
+	}
 
-		// 生成token
-		token, err := middleware.GenerateToken(user)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+	// Users with two-factor authentication get their tokens from LoginTwoFactor
+	if user.TwoFactorEnabled {
+		s.startTwoFactorLogin(c, user)
+		return
+	}
+
+	s.completeLogin(c, user)
+}
+
+// rejectExternalLogin responds to a login that an authentication backend could not
+// complete for reasons other than a wrong password
+func (s *Server) rejectExternalLogin(c *gin.Context, userID *uint, username string, err error) {
+	var refused *auth.ProvisioningError
+	switch {
+	case errors.Is(err, auth.ErrUnavailable):
+		utils.Errorf("authentication backend failed for %q: %v", username, err)
+		apierr.Abort(c, apierr.Unavailable(err, "Authentication service is unavailable, please try again later"))
+	case errors.Is(err, auth.ErrInvalidTicket):
+		apierr.Abort(c, apierr.Unauthorized("Sign-in ticket is invalid or has expired, please sign in again"))
+	case errors.Is(err, auth.ErrUnknownProvider):
+		apierr.Abort(c, apierr.NotFound("Unknown sign-in provider"))
+	case errors.As(err, &refused):
+		s.recordLoginEvent(c, userID, refused.Username, models.LoginReasonNotProvisioned)
+		if errors.Is(err, auth.ErrAccountConflict) {
+			apierr.Abort(c, apierr.Conflict("An account with this username already exists, please contact an administrator"))
 			return
 		}
+		apierr.Abort(c, apierr.Forbidden("Your account has no access to this system, please contact an administrator"))
+	default:
+		utils.Errorf("authenticating %q: %v", username, err)
+		apierr.Abort(c, apierr.Internal(err, "Failed to authenticate"))
+	}
+}
 
-		c.JSON(http.StatusOK, LoginResponse{
-			Token: token,
-			User:  user,
-		})
+// LoginTwoFactor completes a login started by Login with a TOTP code or a recovery code
+func (s *Server) LoginTwoFactor(c *gin.Context) {
+	var request TwoFactorLoginRequest
+	if !bindJSON(c, &request) || !checkSecondFactor(c, request.Code, request.RecoveryCode) {
 		return
 	}
 
-	// 正常的登录逻辑
-	// Get user from database
-	user, err := db.GetUserByUsername(request.Username)
+	challengeHash := utils.HashToken(request.ChallengeToken)
+	userID, err := s.TwoFactor.GetChallenge(challengeHash)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid username or password"})
+		if errors.Is(err, sql.ErrNoRows) {
+			apierr.Abort(c, apierr.New(http.StatusUnauthorized, apierr.CodeChallengeExpired,
+				"Login challenge is invalid or has expired, please log in again").With("challenge_expired", true))
+			return
+		}
+		apierr.Abort(c, apierr.Internal(err, "Failed to check login challenge"))
 		return
 	}
 
-	// Check password
-	if !utils.CheckPasswordHash(request.Password, user.Password) {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid username or password"})
+	user, err := s.Users.Get(userID)
+	if err != nil {
+		apierr.Abort(c, apierr.Internal(err, "Failed to get user"))
 		return
 	}
 
-	// Generate token
-	token, err := middleware.GenerateToken(user)
+	// Code guesses count towards the same lockout as password guesses
+	ip := c.ClientIP()
+	if !s.allowLoginAttempt(c, &user.ID, user.Username, ip) {
+		return
+	}
+
+	// Disabled after the password step: refused like a wrong password
+	if user.Disabled {
+		s.rejectCredentials(c, &user.ID, user.Username, ip, models.LoginReasonDisabled)
+		return
+	}
+
+	if err := s.verifySecondFactor(user.ID, request.Code, request.RecoveryCode); err != nil {
+		if !errors.Is(err, errInvalidSecondFactor) {
+			s.releaseLoginAttempt(user.Username, ip)
+			apierr.Abort(c, apierr.Internal(err, "Failed to verify two-factor code"))
+			return
+		}
+		if err := s.Logins.RecordFailure(user.Username, ip); err != nil {
+			utils.Errorf("recording failed login for %q: %v", user.Username, err)
+		}
+		s.recordLoginEvent(c, &user.ID, user.Username, models.LoginReasonInvalidTwoFactor)
+		apierr.Abort(c, apierr.New(http.StatusUnauthorized, apierr.CodeInvalidTwoFactor, "Invalid two-factor code"))
+		return
+	}
+	s.releaseLoginAttempt(user.Username, ip)
+
+	if err := s.TwoFactor.DeleteChallenge(challengeHash); err != nil {
+		apierr.Abort(c, apierr.Internal(err, "Failed to complete login"))
+		return
+	}
+
+	s.completeLogin(c, user)
+}
+
+// startTwoFactorLogin answers a correct password for a user with two-factor
+// authentication with a short-lived challenge to present to LoginTwoFactor
+func (s *Server) startTwoFactorLogin(c *gin.Context, user *models.User) {
+	challenge, err := utils.RandomToken(32)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		apierr.Abort(c, apierr.Internal(err, "Failed to start two-factor login"))
+		return
+	}
+
+	expiresAt := clock().Add(loginChallengeExpiry)
+	if err := s.TwoFactor.CreateChallenge(user.ID, utils.HashToken(challenge), expiresAt); err != nil {
+		apierr.Abort(c, apierr.Internal(err, "Failed to start two-factor login"))
+		return
+	}
+
+	c.JSON(http.StatusOK, TwoFactorChallengeResponse{
+		TwoFactorRequired: true,
+		ChallengeToken:    challenge,
+		ExpiresIn:         int(loginChallengeExpiry.Seconds()),
+	})
+}
+
+// completeLogin records a successful login and starts a session for the user
+func (s *Server) completeLogin(c *gin.Context, user *models.User) {
+	if err := s.Logins.RecordSuccess(user.Username); err != nil {
+		utils.Errorf("clearing failed logins for %q: %v", user.Username, err)
+	}
+	s.recordLoginEvent(c, &user.ID, user.Username, "")
+
+	// Start a session and generate its tokens
+	tokens, err := s.issueTokens(c, user)
+	if err != nil {
+		apierr.Abort(c, apierr.Internal(err, "Failed to generate token"))
 		return
 	}
 
 	// Remove password from user object before returning
 	user.Password = ""
 
+	// The client should send the user to change their password; until then the auth
+	// middleware only lets them reach the password change routes
+	if user.AuthSource == models.AuthSourceLocal && utils.CurrentPasswordPolicy.Expired(user.PasswordChangedAt) {
+		user.MustChangePassword = true
+	}
+	// Likewise the client should send users whose role requires 2FA to set it up
+	if err := s.flagTwoFactorSetup(user); err != nil {
+		utils.Errorf("checking two-factor requirement for %q: %v", user.Username, err)
+	}
+
 	c.JSON(http.StatusOK, LoginResponse{
-		Token: token,
-		User:  user,
+		TokenResponse: *tokens,
+		User:          user,
 	})
 }
 
-// GetCurrentUser returns the current user information
-func GetCurrentUser(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
+// recordLoginEvent adds the attempt to the login history; an empty reason means success
+func (s *Server) recordLoginEvent(c *gin.Context, userID *uint, username, reason string) {
+	event := &models.LoginEvent{
+		UserID:    userID,
+		Username:  username,
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Success:   reason == "",
+		Reason:    reason,
 	}
+	if err := s.Logins.RecordEvent(event); err != nil {
+		utils.Errorf("recording login event for %q: %v", username, err)
+	}
+}
 
-	// Get user from database (implement this method in db.go)
-	// user, err := db.GetUserByID(userID.(uint))
-	// For now, just return the user ID and role
-	role, _ := c.Get("role")
-	c.JSON(http.StatusOK, gin.H{
-		"user_id": userID,
-		"role":    role,
-	})
+// allowLoginAttempt checks the brute-force limits for a login attempt and counts it.
+// When the attempt is refused it records the event, responds and returns false; an
+// allowed attempt ends with rejectCredentials, RecordFailure or releaseLoginAttempt.
+func (s *Server) allowLoginAttempt(c *gin.Context, userID *uint, username, ip string) bool {
+	retryAfter, err := s.Logins.BeginAttempt(username, ip)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, db.ErrLoginLocked):
+		s.recordLoginEvent(c, userID, username, models.LoginReasonLocked)
+		rejectThrottledLogin(c, retryAfter, "Too many failed login attempts, please try again later")
+	case errors.Is(err, db.ErrLoginTooSoon):
+		s.recordLoginEvent(c, userID, username, models.LoginReasonThrottled)
+		rejectThrottledLogin(c, retryAfter, "Please wait before trying to log in again")
+	default:
+		apierr.Abort(c, apierr.Internal(err, "Failed to check login attempts"))
+	}
+	return false
+}
+
+// rejectThrottledLogin responds with 429 and tells the client how long to wait
+func rejectThrottledLogin(c *gin.Context, retryAfter time.Duration, message string) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	apierr.Abort(c, apierr.New(http.StatusTooManyRequests, apierr.CodeLoginThrottled, message).With("retry_after", seconds))
 }
@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"to-mrz/apierr"
+	"to-mrz/db"
+	"to-mrz/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetBackups lists the database backups, newest first
+func (s *Server) GetBackups(c *gin.Context) {
+	query, ok := listQuery(c)
+	if !ok {
+		return
+	}
+
+	page, err := s.Backups.List(query)
+	if errors.Is(err, db.ErrBackupUnsupported) {
+		respondBackupError(c, err, "Failed to list backups")
+		return
+	}
+	writeList(c, page, err, "Failed to list backups")
+}
+
+// CreateBackup takes a database backup now
+func (s *Server) CreateBackup(c *gin.Context) {
+	backup, err := s.Backups.Create(models.BackupManual)
+	if err != nil {
+		respondBackupError(c, err, "Failed to create backup")
+		return
+	}
+
+	c.JSON(http.StatusCreated, backup)
+}
+
+// DownloadBackup sends a backup file. Its checksum is in the X-Checksum-SHA256 header.
+func (s *Server) DownloadBackup(c *gin.Context) {
+	backup, path, err := s.Backups.Get(c.Param("name"))
+	if err != nil {
+		respondBackupError(c, err, "Failed to get backup")
+		return
+	}
+
+	if backup.SHA256 != "" {
+		c.Header("X-Checksum-SHA256", backup.SHA256)
+	}
+	c.FileAttachment(path, backup.Name)
+}
+
+// respondBackupError maps the backup errors to responses
+func respondBackupError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, db.ErrBackupUnsupported):
+		apierr.Abort(c, apierr.New(http.StatusNotImplemented, apierr.CodeNotImplemented, "Backups are only supported for SQLite databases"))
+	case errors.Is(err, db.ErrBackupNotFound):
+		apierr.Abort(c, apierr.NotFound("Backup not found"))
+	default:
+		apierr.Abort(c, apierr.Internal(err, message))
+	}
+}
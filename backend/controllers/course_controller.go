@@ -1,35 +1,95 @@
 package controllers
 
 import (
+	"database/sql"
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
+
+	"to-mrz/apierr"
 	"to-mrz/db"
 	"to-mrz/models"
 
 	"github.com/gin-gonic/gin"
 )
 
-// GetCourses returns all courses
-func GetCourses(c *gin.Context) {
-	courses, err := db.GetAllCourses()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get courses"})
+// CourseRequest contains the course request data
+type CourseRequest struct {
+	Name         string  `json:"name" binding:"required,max=100"`
+	Code         string  `json:"code" binding:"required,max=20"`
+	Credits      float64 `json:"credits" binding:"gt=0,lte=30"`
+	Hours        int     `json:"hours" binding:"gte=0,lte=1000"`
+	Type         string  `json:"type" binding:"max=20"`
+	DepartmentID uint    `json:"department_id" binding:"required"`
+	Description  string  `json:"description" binding:"max=2000"`
+}
+
+// toCourse converts the request to a course
+func (r *CourseRequest) toCourse() *models.Course {
+	return &models.Course{
+		Name:         strings.TrimSpace(r.Name),
+		Code:         strings.TrimSpace(r.Code),
+		Credits:      r.Credits,
+		Hours:        r.Hours,
+		Type:         r.Type,
+		DepartmentID: r.DepartmentID,
+		Description:  r.Description,
+	}
+}
+
+// GetCourses returns a page of the courses, filtered by department_id, type and
+// min_credits/max_credits and searched by name, code and description with q, or of
+// the deleted ones with ?deleted=true
+func (s *Server) GetCourses(c *gin.Context) {
+	filter := db.CourseFilter{Type: c.Query("type")}
+	var ok bool
+	if filter.ListQuery, ok = listQuery(c); !ok {
+		return
+	}
+	var err error
+	if filter.DepartmentID, err = queryUint(c, "department_id"); err != nil {
+		apierr.Abort(c, apierr.BadRequest("Invalid department ID"))
 		return
 	}
-	c.JSON(http.StatusOK, courses)
+	for _, param := range []struct {
+		key     string
+		credits **float64
+	}{{"min_credits", &filter.MinCredits}, {"max_credits", &filter.MaxCredits}} {
+		value := c.Query(param.key)
+		if value == "" {
+			continue
+		}
+		credits, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			apierr.Abort(c, apierr.BadRequest("Invalid %s", param.key))
+			return
+		}
+		*param.credits = &credits
+	}
+	if filter.Deleted, ok = deletedFilter(c, models.PermCourseDelete); !ok {
+		return
+	}
+
+	page, err := s.Courses.List(filter)
+	writeList(c, page, err, "Failed to get courses")
 }
 
 // GetCourse returns a specific course by ID
-func GetCourse(c *gin.Context) {
+func (s *Server) GetCourse(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid course ID"})
+		apierr.Abort(c, apierr.BadRequest("Invalid course ID"))
 		return
 	}
 
-	course, err := db.GetCourseByID(uint(id))
+	course, err := s.Courses.Get(uint(id))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Course not found"})
+		if errors.Is(err, sql.ErrNoRows) {
+			apierr.Abort(c, apierr.NotFound("Course not found"))
+			return
+		}
+		apierr.Abort(c, apierr.Internal(err, "Failed to get course"))
 		return
 	}
 
@@ -37,16 +97,16 @@ func GetCourse(c *gin.Context) {
 }
 
 // CreateCourse creates a new course
-func CreateCourse(c *gin.Context) {
-	var course models.Course
-	if err := c.ShouldBindJSON(&course); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+func (s *Server) CreateCourse(c *gin.Context) {
+	var request CourseRequest
+	if !bindJSON(c, &request) || !s.checkReferences(c, reference{"department_id", "departments", request.DepartmentID}) {
 		return
 	}
 
-	id, err := db.CreateCourse(&course)
+	course := request.toCourse()
+	id, err := s.Courses.Create(course)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create course"})
+		apierr.Abort(c, apierr.Internal(err, "Failed to create course"))
 		return
 	}
 
@@ -55,42 +115,82 @@ func CreateCourse(c *gin.Context) {
 }
 
 // UpdateCourse updates an existing course
-func UpdateCourse(c *gin.Context) {
+func (s *Server) UpdateCourse(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid course ID"})
+		apierr.Abort(c, apierr.BadRequest("Invalid course ID"))
 		return
 	}
 
-	var course models.Course
-	if err := c.ShouldBindJSON(&course); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+	var request CourseRequest
+	if !bindJSON(c, &request) || !s.checkReferences(c, reference{"department_id", "departments", request.DepartmentID}) {
 		return
 	}
 
+	course := request.toCourse()
 	course.ID = uint(id)
-	err = db.UpdateCourse(&course)
+	err = s.Courses.Update(course)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update course"})
+		if errors.Is(err, sql.ErrNoRows) {
+			apierr.Abort(c, apierr.NotFound("Course not found"))
+			return
+		}
+		apierr.Abort(c, apierr.Internal(err, "Failed to update course"))
 		return
 	}
 
 	c.JSON(http.StatusOK, course)
 }
 
-// DeleteCourse deletes a course
-func DeleteCourse(c *gin.Context) {
+// DeleteCourse deletes a course. It can be restored until it is purged.
+func (s *Server) DeleteCourse(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid course ID"})
+		apierr.Abort(c, apierr.BadRequest("Invalid course ID"))
 		return
 	}
 
-	err = db.DeleteCourse(uint(id))
+	err = s.Courses.Delete(uint(id))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete course"})
+		if errors.Is(err, sql.ErrNoRows) {
+			apierr.Abort(c, apierr.NotFound("Course not found"))
+			return
+		}
+		apierr.Abort(c, apierr.Internal(err, "Failed to delete course"))
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Course deleted successfully"})
 }
+
+// RestoreCourse restores a deleted course
+func (s *Server) RestoreCourse(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierr.Abort(c, apierr.BadRequest("Invalid course ID"))
+		return
+	}
+
+	if err := s.Courses.Restore(uint(id)); err != nil {
+		writeRestoreError(c, err, "Deleted course not found", "Failed to restore course")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Course restored successfully"})
+}
+
+// PurgeCourse removes a deleted course for good, unless other records still refer to it
+func (s *Server) PurgeCourse(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierr.Abort(c, apierr.BadRequest("Invalid course ID"))
+		return
+	}
+
+	if err := s.Courses.Purge(uint(id)); err != nil {
+		writePurgeError(c, err, "Course not found", "Failed to purge course")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Course purged successfully"})
+}
@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"to-mrz/apierr"
+	"to-mrz/db"
+	"to-mrz/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetCourseOfferings returns a page of the course offerings, filtered by semester_id,
+// course_id, teacher_id and status and searched by course and location with q, or of
+// the deleted ones with ?deleted=true
+func (s *Server) GetCourseOfferings(c *gin.Context) {
+	filter := db.CourseOfferingFilter{Status: c.Query("status")}
+	var ok bool
+	if filter.ListQuery, ok = listQuery(c); !ok {
+		return
+	}
+	for _, param := range []struct {
+		key, message string
+		id           *uint
+	}{
+		{"semester_id", "Invalid semester ID", &filter.SemesterID},
+		{"course_id", "Invalid course ID", &filter.CourseID},
+		{"teacher_id", "Invalid teacher ID", &filter.TeacherID},
+	} {
+		id, err := queryUint(c, param.key)
+		if err != nil {
+			apierr.Abort(c, apierr.BadRequest(param.message))
+			return
+		}
+		*param.id = id
+	}
+	if filter.Deleted, ok = deletedFilter(c, models.PermCourseDelete); !ok {
+		return
+	}
+
+	page, err := s.CourseOfferings.List(filter)
+	writeList(c, page, err, "Failed to get course offerings")
+}
+
+// DeleteCourseOffering deletes a course offering. Its enrollments and grades are kept,
+// and it can be restored until it is purged.
+func (s *Server) DeleteCourseOffering(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierr.Abort(c, apierr.BadRequest("Invalid course offering ID"))
+		return
+	}
+
+	if err := s.CourseOfferings.Delete(uint(id)); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			apierr.Abort(c, apierr.NotFound("Course offering not found"))
+			return
+		}
+		apierr.Abort(c, apierr.Internal(err, "Failed to delete course offering"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Course offering deleted successfully"})
+}
+
+// RestoreCourseOffering restores a deleted course offering
+func (s *Server) RestoreCourseOffering(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierr.Abort(c, apierr.BadRequest("Invalid course offering ID"))
+		return
+	}
+
+	if err := s.CourseOfferings.Restore(uint(id)); err != nil {
+		writeRestoreError(c, err, "Deleted course offering not found", "Failed to restore course offering")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Course offering restored successfully"})
+}
+
+// PurgeCourseOffering removes a deleted course offering for good, unless other records
+// still refer to it
+func (s *Server) PurgeCourseOffering(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierr.Abort(c, apierr.BadRequest("Invalid course offering ID"))
+		return
+	}
+
+	if err := s.CourseOfferings.Purge(uint(id)); err != nil {
+		writePurgeError(c, err, "Course offering not found", "Failed to purge course offering")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Course offering purged successfully"})
+}
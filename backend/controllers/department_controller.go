@@ -2,9 +2,11 @@ package controllers
 
 import (
 	"database/sql"
+	"errors"
 	"net/http"
 	"strconv"
 
+	"to-mrz/apierr"
 	"to-mrz/db"
 	"to-mrz/models"
 
@@ -13,50 +15,42 @@ import (
 
 // DepartmentRequest contains the department request data
 type DepartmentRequest struct {
-	Name string `json:"name" binding:"required"`
-	Code string `json:"code" binding:"required"`
+	Name string `json:"name" binding:"required,max=100"`
+	Code string `json:"code" binding:"required,max=20"`
 }
 
-// GetDepartments returns a list of all departments
-func GetDepartments(c *gin.Context) {
-	rows, err := db.DB.Query("SELECT id, name, code FROM departments")
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve departments"})
+// GetDepartments returns a page of the departments, searched by name and code with q,
+// or of the deleted ones with ?deleted=true
+func (s *Server) GetDepartments(c *gin.Context) {
+	filter := db.DepartmentFilter{}
+	var ok bool
+	if filter.ListQuery, ok = listQuery(c); !ok {
 		return
 	}
-	defer rows.Close()
-
-	departments := []models.Department{}
-	for rows.Next() {
-		var department models.Department
-		if err := rows.Scan(&department.ID, &department.Name, &department.Code); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan department data"})
-			return
-		}
-		departments = append(departments, department)
+	if filter.Deleted, ok = deletedFilter(c, models.PermDepartmentDelete); !ok {
+		return
 	}
 
-	c.JSON(http.StatusOK, departments)
+	page, err := s.Departments.List(filter)
+	writeList(c, page, err, "Failed to retrieve departments")
 }
 
 // GetDepartment returns a specific department by ID
-func GetDepartment(c *gin.Context) {
+func (s *Server) GetDepartment(c *gin.Context) {
 	id := c.Param("id")
 	departmentID, err := strconv.ParseUint(id, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid department ID"})
+		apierr.Abort(c, apierr.BadRequest("Invalid department ID"))
 		return
 	}
 
-	var department models.Department
-	err = db.DB.QueryRow("SELECT id, name, code FROM departments WHERE id = ?", departmentID).
-		Scan(&department.ID, &department.Name, &department.Code)
+	department, err := s.Departments.Get(uint(departmentID))
 	if err != nil {
-		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Department not found"})
+		if errors.Is(err, sql.ErrNoRows) {
+			apierr.Abort(c, apierr.NotFound("Department not found"))
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve department"})
+		apierr.Abort(c, apierr.Internal(err, "Failed to retrieve department"))
 		return
 	}
 
@@ -64,39 +58,19 @@ func GetDepartment(c *gin.Context) {
 }
 
 // CreateDepartment creates a new department
-func CreateDepartment(c *gin.Context) {
+func (s *Server) CreateDepartment(c *gin.Context) {
 	var request DepartmentRequest
-	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+	if !bindJSON(c, &request) {
 		return
 	}
 
-	// Check if code already exists
-	var count int
-	err := db.DB.QueryRow("SELECT COUNT(*) FROM departments WHERE code = ?", request.Code).Scan(&count)
+	id, err := s.Departments.Create(&models.Department{Name: request.Name, Code: request.Code})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check department code"})
-		return
-	}
-
-	if count > 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Department code already exists"})
-		return
-	}
-
-	// Insert new department
-	result, err := db.DB.Exec(
-		"INSERT INTO departments (name, code) VALUES (?, ?)",
-		request.Name, request.Code,
-	)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create department"})
-		return
-	}
-
-	id, err := result.LastInsertId()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get new department ID"})
+		if errors.Is(err, db.ErrDepartmentCodeTaken) {
+			apierr.Abort(c, apierr.BadRequest("Department code already exists"))
+			return
+		}
+		apierr.Abort(c, apierr.Internal(err, "Failed to create department"))
 		return
 	}
 
@@ -108,52 +82,29 @@ func CreateDepartment(c *gin.Context) {
 }
 
 // UpdateDepartment updates an existing department
-func UpdateDepartment(c *gin.Context) {
+func (s *Server) UpdateDepartment(c *gin.Context) {
 	id := c.Param("id")
 	departmentID, err := strconv.ParseUint(id, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid department ID"})
+		apierr.Abort(c, apierr.BadRequest("Invalid department ID"))
 		return
 	}
 
 	var request DepartmentRequest
-	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
-		return
-	}
-
-	// Check if department exists
-	var count int
-	err = db.DB.QueryRow("SELECT COUNT(*) FROM departments WHERE id = ?", departmentID).Scan(&count)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check department"})
-		return
-	}
-
-	if count == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Department not found"})
+	if !bindJSON(c, &request) {
 		return
 	}
 
-	// Check if code is already used by another department
-	err = db.DB.QueryRow("SELECT COUNT(*) FROM departments WHERE code = ? AND id != ?", request.Code, departmentID).Scan(&count)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check department code"})
-		return
-	}
-
-	if count > 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Department code already exists"})
-		return
-	}
-
-	// Update department
-	_, err = db.DB.Exec(
-		"UPDATE departments SET name = ?, code = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
-		request.Name, request.Code, departmentID,
-	)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update department"})
+	department := &models.Department{ID: uint(departmentID), Name: request.Name, Code: request.Code}
+	if err := s.Departments.Update(department); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			apierr.Abort(c, apierr.NotFound("Department not found"))
+		case errors.Is(err, db.ErrDepartmentCodeTaken):
+			apierr.Abort(c, apierr.BadRequest("Department code already exists"))
+		default:
+			apierr.Abort(c, apierr.Internal(err, "Failed to update department"))
+		}
 		return
 	}
 
@@ -164,46 +115,60 @@ func UpdateDepartment(c *gin.Context) {
 	})
 }
 
-// DeleteDepartment deletes a department
-func DeleteDepartment(c *gin.Context) {
+// DeleteDepartment deletes a department. It can be restored until it is purged.
+func (s *Server) DeleteDepartment(c *gin.Context) {
 	id := c.Param("id")
 	departmentID, err := strconv.ParseUint(id, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid department ID"})
+		apierr.Abort(c, apierr.BadRequest("Invalid department ID"))
 		return
 	}
 
-	// Check if department exists
-	var count int
-	err = db.DB.QueryRow("SELECT COUNT(*) FROM departments WHERE id = ?", departmentID).Scan(&count)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check department"})
+	if err := s.Departments.Delete(uint(departmentID)); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			apierr.Abort(c, apierr.NotFound("Department not found"))
+		case errors.Is(err, db.ErrDepartmentHasMajors):
+			apierr.Abort(c, apierr.BadRequest("Cannot delete department with related majors"))
+		default:
+			apierr.Abort(c, apierr.Internal(err, "Failed to delete department"))
+		}
 		return
 	}
 
-	if count == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Department not found"})
-		return
-	}
+	c.JSON(http.StatusOK, gin.H{"message": "Department deleted successfully"})
+}
 
-	// Check if department has related majors
-	err = db.DB.QueryRow("SELECT COUNT(*) FROM majors WHERE department_id = ?", departmentID).Scan(&count)
+// RestoreDepartment restores a deleted department
+func (s *Server) RestoreDepartment(c *gin.Context) {
+	id := c.Param("id")
+	departmentID, err := strconv.ParseUint(id, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check related majors"})
+		apierr.Abort(c, apierr.BadRequest("Invalid department ID"))
 		return
 	}
 
-	if count > 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot delete department with related majors"})
+	if err := s.Departments.Restore(uint(departmentID)); err != nil {
+		writeRestoreError(c, err, "Deleted department not found", "Failed to restore department")
 		return
 	}
 
-	// Delete department
-	_, err = db.DB.Exec("DELETE FROM departments WHERE id = ?", departmentID)
+	c.JSON(http.StatusOK, gin.H{"message": "Department restored successfully"})
+}
+
+// PurgeDepartment removes a deleted department for good, unless other records still refer to it
+func (s *Server) PurgeDepartment(c *gin.Context) {
+	id := c.Param("id")
+	departmentID, err := strconv.ParseUint(id, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete department"})
+		apierr.Abort(c, apierr.BadRequest("Invalid department ID"))
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Department deleted successfully"})
+	if err := s.Departments.Purge(uint(departmentID)); err != nil {
+		writePurgeError(c, err, "Department not found", "Failed to purge department")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Department purged successfully"})
 }
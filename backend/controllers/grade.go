@@ -1,227 +1,106 @@
 package controllers
 
 import (
+	"database/sql"
+	"errors"
 	"net/http"
 	"strconv"
-	"time"
 
+	"to-mrz/apierr"
 	"to-mrz/db"
+	"to-mrz/middleware"
 	"to-mrz/models"
 
 	"github.com/gin-gonic/gin"
 )
 
-// Sample data - This would normally come from a database
-var sampleGrades = []models.Enrollment{
-	{
-		ID:        1,
-		StudentID: 1,
-		Student: models.Student{
-			ID:        1,
-			StudentID: "2023001",
-			User: models.User{
-				ID:   1,
-				Name: "张三",
-			},
-		},
-		CourseOfferingID: 1,
-		CourseOffering: models.CourseOffering{
-			ID: 1,
-			Course: models.Course{
-				ID:   1,
-				Name: "数据结构",
-				Code: "CS101",
-			},
-			Teacher: models.Teacher{
-				ID: 1,
-				User: models.User{
-					ID:   4,
-					Name: "王同学",
-				},
-			},
-			Semester: models.Semester{
-				ID:   1,
-				Name: "2023-2024学年第一学期",
-			},
-		},
-		Grade:     85.5,
-		Status:    "已完成",
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-	},
-	{
-		ID:        2,
-		StudentID: 1,
-		Student: models.Student{
-			ID:        1,
-			StudentID: "2023001",
-			User: models.User{
-				ID:   1,
-				Name: "张三",
-			},
-		},
-		CourseOfferingID: 2,
-		CourseOffering: models.CourseOffering{
-			ID: 2,
-			Course: models.Course{
-				ID:   2,
-				Name: "计算机网络",
-				Code: "CS201",
-			},
-			Teacher: models.Teacher{
-				ID: 2,
-				User: models.User{
-					ID:   5,
-					Name: "李同学",
-				},
-			},
-			Semester: models.Semester{
-				ID:   1,
-				Name: "2023-2024学年第一学期",
-			},
-		},
-		Grade:     92.0,
-		Status:    "已完成",
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-	},
-	{
-		ID:        3,
-		StudentID: 2,
-		Student: models.Student{
-			ID:        2,
-			StudentID: "2023002",
-			User: models.User{
-				ID:   2,
-				Name: "李四",
-			},
-		},
-		CourseOfferingID: 1,
-		CourseOffering: models.CourseOffering{
-			ID: 1,
-			Course: models.Course{
-				ID:   1,
-				Name: "数据结构",
-				Code: "CS101",
-			},
-			Teacher: models.Teacher{
-				ID: 1,
-				User: models.User{
-					ID:   4,
-					Name: "王同学",
-				},
-			},
-			Semester: models.Semester{
-				ID:   1,
-				Name: "2023-2024学年第一学期",
-			},
-		},
-		Grade:     78.5,
-		Status:    "已完成",
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-	},
+// GradeRequest 创建成绩的请求参数
+type GradeRequest struct {
+	StudentID        uint     `json:"student_id" binding:"required"`
+	CourseOfferingID uint     `json:"course_offering_id" binding:"required"`
+	Grade            *float64 `json:"grade" binding:"required,gte=0,lte=100"`
 }
 
 // GetStudentGrades 获取学生成绩
-func GetStudentGrades(c *gin.Context) {
-	// 从请求中获取学生ID或从token中提取当前登录的学生用户
-	studentIDStr := c.Query("student_id")
-
-	// 如果没有提供student_id，使用当前登录用户
-	if studentIDStr == "" {
-		// 获取当前登录用户
-		userID, exists := c.Get("userID")
-		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "用户未授权"})
-			return
-		}
-
-		// 这里简单地将userID转为字符串作为studentID
-		studentIDStr = strconv.Itoa(userID.(int))
-	}
-
-	studentID, err := strconv.Atoi(studentIDStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的学生ID"})
+func (s *Server) GetStudentGrades(c *gin.Context) {
+	// 从请求中获取学生ID，未提供时使用当前登录学生本人
+	studentID, ok := requestStudentID(c)
+	if !ok {
+		apierr.Abort(c, apierr.BadRequest("Invalid student ID"))
 		return
 	}
 
-	// 获取学生成绩
-	studentGrades, err := db.GetStudentGrades(uint(studentID))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取成绩失败: " + err.Error()})
+	query, ok := listQuery(c)
+	if !ok {
 		return
 	}
 
-	// 如果数据库没有数据，使用示例数据（仅用于演示）
-	if len(studentGrades) == 0 {
-		studentGrades = getExampleStudentGrades(uint(studentID))
+	page, err := s.Enrollments.StudentGrades(db.StudentGradeFilter{ListQuery: query, StudentID: studentID})
+	writeList(c, page, err, "Failed to get grades")
+}
+
+// requestStudentID 返回 student_id 查询参数；未提供时返回当前登录学生本人的ID
+func requestStudentID(c *gin.Context) (uint, bool) {
+	if value := c.Query("student_id"); value != "" {
+		studentID, err := strconv.ParseUint(value, 10, 64)
+		return uint(studentID), err == nil && studentID > 0
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"data": studentGrades,
-	})
+	scope, err := middleware.CurrentScope(c)
+	if err != nil || scope.StudentID == 0 {
+		return 0, false
+	}
+	return scope.StudentID, true
 }
 
 // GetCourseGrades 获取课程的所有学生成绩（教师用）
-func GetCourseGrades(c *gin.Context) {
+func (s *Server) GetCourseGrades(c *gin.Context) {
 	courseOfferingIDStr := c.Query("course_offering_id")
 	if courseOfferingIDStr == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "必须提供课程开设ID"})
+		apierr.Abort(c, apierr.BadRequest("Course offering ID is required"))
 		return
 	}
 
 	courseOfferingID, err := strconv.Atoi(courseOfferingIDStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的课程开设ID"})
+		apierr.Abort(c, apierr.BadRequest("Invalid course offering ID"))
 		return
 	}
 
-	// 获取课程成绩
-	courseGrades, err := db.GetCourseGrades(uint(courseOfferingID))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取成绩失败: " + err.Error()})
+	query, ok := listQuery(c)
+	if !ok {
 		return
 	}
 
-	// 如果数据库没有数据，使用示例数据（仅用于演示）
-	if len(courseGrades) == 0 {
-		courseGrades = getExampleCourseGrades(uint(courseOfferingID))
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"data": courseGrades,
-	})
+	page, err := s.Enrollments.CourseGrades(db.CourseGradeFilter{ListQuery: query, CourseOfferingID: uint(courseOfferingID)})
+	writeList(c, page, err, "Failed to get grades")
 }
 
 // UpdateGrade 更新学生成绩（教师用）
-func UpdateGrade(c *gin.Context) {
+func (s *Server) UpdateGrade(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的成绩记录ID"})
+		apierr.Abort(c, apierr.BadRequest("Invalid grade record ID"))
 		return
 	}
 
 	var gradeUpdate struct {
-		Grade float64 `json:"grade" binding:"required"`
+		Grade *float64 `json:"grade" binding:"required,gte=0,lte=100"`
 	}
-
-	if err := c.ShouldBindJSON(&gradeUpdate); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &gradeUpdate) {
 		return
 	}
 
 	// 更新成绩
-	err = db.UpdateGrade(uint(id), gradeUpdate.Grade)
+	err = s.Enrollments.UpdateGrade(uint(id), *gradeUpdate.Grade)
+	if errors.Is(err, sql.ErrNoRows) {
+		apierr.Abort(c, apierr.NotFound("Grade record not found"))
+		return
+	}
 	if err != nil {
-		// 如果数据库更新失败，我们使用示例数据（仅用于演示）
-		found := updateExampleGrade(uint(id), gradeUpdate.Grade)
-		if !found {
-			c.JSON(http.StatusNotFound, gin.H{"error": "未找到成绩记录"})
-			return
-		}
+		apierr.Abort(c, apierr.Internal(err, "Failed to update grade"))
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -230,63 +109,38 @@ func UpdateGrade(c *gin.Context) {
 }
 
 // CreateGrade 创建学生成绩（教师用）
-func CreateGrade(c *gin.Context) {
-	var newGrade models.Enrollment
-
-	if err := c.ShouldBindJSON(&newGrade); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+func (s *Server) CreateGrade(c *gin.Context) {
+	var request GradeRequest
+	if !bindJSON(c, &request) || !s.checkReferences(c,
+		reference{"student_id", "students", request.StudentID},
+		reference{"course_offering_id", "course_offerings", request.CourseOfferingID}) {
 		return
 	}
 
+	newGrade := models.Enrollment{
+		StudentID:        request.StudentID,
+		CourseOfferingID: request.CourseOfferingID,
+		Grade:            *request.Grade,
+	}
+
 	// 创建成绩记录
-	id, err := db.CreateGrade(newGrade)
+	id, err := s.Enrollments.CreateGrade(newGrade)
+	if errors.Is(err, db.ErrGradeExists) {
+		apierr.Abort(c, apierr.Conflict("The student already has a grade for this offering"))
+		return
+	}
 	if err != nil {
-		// 如果数据库创建失败，我们使用示例数据（仅用于演示）
-		newGrade.ID = uint(len(sampleGrades) + 1)
-		newGrade.CreatedAt = time.Now()
-		newGrade.UpdatedAt = time.Now()
-		sampleGrades = append(sampleGrades, newGrade)
-	} else {
-		newGrade.ID = id
+		apierr.Abort(c, apierr.Internal(err, "Failed to create grade"))
+		return
+	}
+	created, err := s.Enrollments.Grade(id)
+	if err != nil {
+		apierr.Abort(c, apierr.Internal(err, "Failed to load grade"))
+		return
 	}
 
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "成绩创建成功",
-		"data":    newGrade,
+		"data":    created,
 	})
 }
-
-// 以下是用于演示的辅助函数，实际应用中可以删除
-
-func getExampleStudentGrades(studentID uint) []models.Enrollment {
-	var studentGrades []models.Enrollment
-	for _, grade := range sampleGrades {
-		if grade.StudentID == studentID {
-			studentGrades = append(studentGrades, grade)
-		}
-	}
-	return studentGrades
-}
-
-func getExampleCourseGrades(courseOfferingID uint) []models.Enrollment {
-	var courseGrades []models.Enrollment
-	for _, grade := range sampleGrades {
-		if grade.CourseOfferingID == courseOfferingID {
-			courseGrades = append(courseGrades, grade)
-		}
-	}
-	return courseGrades
-}
-
-func updateExampleGrade(id uint, grade float64) bool {
-	found := false
-	for i, g := range sampleGrades {
-		if g.ID == id {
-			sampleGrades[i].Grade = grade
-			sampleGrades[i].UpdatedAt = time.Now()
-			found = true
-			break
-		}
-	}
-	return found
-}
@@ -0,0 +1,153 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"to-mrz/apierr"
+	"to-mrz/db"
+	"to-mrz/models"
+	"to-mrz/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExportGrades 导出成绩表（学号、姓名、班级、各成绩组成、总评、状态）
+// 可按课程开设、学期或院系导出；多门课程时每门课程一个工作表（仅 xlsx）
+func (s *Server) ExportGrades(c *gin.Context) {
+	courseOfferingID, err := queryUint(c, "course_offering_id")
+	if err != nil {
+		apierr.Abort(c, apierr.BadRequest("Invalid course offering ID"))
+		return
+	}
+	semesterID, err := queryUint(c, "semester_id")
+	if err != nil {
+		apierr.Abort(c, apierr.BadRequest("Invalid semester ID"))
+		return
+	}
+	departmentID, err := queryUint(c, "department_id")
+	if err != nil {
+		apierr.Abort(c, apierr.BadRequest("Invalid department ID"))
+		return
+	}
+
+	if courseOfferingID == 0 && semesterID == 0 && departmentID == 0 {
+		apierr.Abort(c, apierr.BadRequest("A course offering, semester or department ID is required"))
+		return
+	}
+
+	format := c.DefaultQuery("format", utils.FormatXLSX)
+	if format != utils.FormatXLSX && format != utils.FormatCSV {
+		apierr.Abort(c, apierr.BadRequest("Only the xlsx and csv formats are supported"))
+		return
+	}
+
+	offerings, err := s.Enrollments.ExportOfferings(courseOfferingID, semesterID, departmentID)
+	if err != nil {
+		apierr.Abort(c, apierr.Internal(err, "Failed to get course offerings"))
+		return
+	}
+	if len(offerings) == 0 {
+		apierr.Abort(c, apierr.NotFound("No courses to export"))
+		return
+	}
+	if format == utils.FormatCSV && len(offerings) > 1 {
+		apierr.Abort(c, apierr.BadRequest("Grades of several courses can only be exported as xlsx"))
+		return
+	}
+
+	// Load all component definitions before streaming so failures can still be reported as JSON
+	components := make([][]models.GradeComponent, len(offerings))
+	for i, co := range offerings {
+		components[i], err = s.Enrollments.GradeComponents(co.ID)
+		if err != nil {
+			apierr.Abort(c, apierr.Internal(err, "Failed to get grade components"))
+			return
+		}
+	}
+
+	filename := fmt.Sprintf("grades_%s.%s", time.Now().Format("20060102150405"), format)
+	if len(offerings) == 1 {
+		filename = fmt.Sprintf("grades_%s_%d.%s", offerings[0].Course.Code, offerings[0].ID, format)
+	}
+	out := &exportResponse{c: c, filename: filename, contentType: contentTypes[format]}
+
+	sw, err := utils.NewSheetWriter(out, format)
+	if err == nil {
+		used := map[string]bool{}
+		for i, co := range offerings {
+			name := utils.SheetName(fmt.Sprintf("%s-%d %s", co.Course.Code, co.ID, co.Course.Name), used)
+			if err = s.writeGradeSheet(sw, name, co.ID, components[i]); err != nil {
+				err = fmt.Errorf("offering %d: %w", co.ID, err)
+				break
+			}
+		}
+		if err == nil {
+			err = sw.Close()
+		}
+	}
+	if err == nil {
+		return
+	}
+	if !out.started {
+		apierr.Abort(c, apierr.Internal(err, "Failed to export grades"))
+		return
+	}
+	// 文件已开始发送，只能中断输出并记录原因
+	utils.Errorf("grade export failed: %v", err)
+}
+
+// exportResponse sends the headers of a successful export together with the first
+// bytes of the file. Rows are buffered before they are written, so a failure on the
+// first rows can still be reported as a JSON error instead of an empty 200 response.
+type exportResponse struct {
+	c           *gin.Context
+	filename    string
+	contentType string
+	started     bool
+}
+
+func (r *exportResponse) Write(p []byte) (int, error) {
+	if !r.started {
+		r.started = true
+		r.c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, r.filename))
+		r.c.Header("Content-Type", r.contentType)
+		r.c.Status(http.StatusOK)
+	}
+	return r.c.Writer.Write(p)
+}
+
+// writeGradeSheet streams one course offering's grade sheet into a new worksheet
+func (s *Server) writeGradeSheet(sw utils.SheetWriter, name string, courseOfferingID uint, components []models.GradeComponent) error {
+	if err := sw.NewSheet(name); err != nil {
+		return err
+	}
+
+	header := []interface{}{db.ColumnStudentNo, db.ColumnStudentName, db.ColumnClassName}
+	for _, gc := range components {
+		header = append(header, gc.Name)
+	}
+	header = append(header, "总评", "状态")
+	if err := sw.WriteRow(header); err != nil {
+		return err
+	}
+
+	return s.Enrollments.EachGradeSheetRow(courseOfferingID, func(row models.GradeSheetRow) error {
+		values := []interface{}{row.StudentNo, row.StudentName, row.ClassName}
+		for _, gc := range components {
+			if score, ok := row.Scores[gc.ID]; ok {
+				values = append(values, score)
+			} else {
+				values = append(values, nil)
+			}
+		}
+		if row.Total != nil {
+			values = append(values, *row.Total)
+		} else {
+			values = append(values, nil)
+		}
+		values = append(values, row.Status)
+		return sw.WriteRow(values)
+	})
+}
@@ -0,0 +1,125 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"to-mrz/apierr"
+	"to-mrz/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxGradeImportSize 成绩导入文件大小上限
+const maxGradeImportSize = 10 << 20
+
+// ImportGrades 批量导入成绩（.xlsx 或 .csv，按学号对应，每个成绩组成一列）
+// 所有行校验通过后在同一事务中写入；dry_run=true 时只返回预览
+func (s *Server) ImportGrades(c *gin.Context) {
+	courseOfferingID, err := strconv.Atoi(c.Query("course_offering_id"))
+	if err != nil {
+		apierr.Abort(c, apierr.BadRequest("Invalid course offering ID"))
+		return
+	}
+	dryRun := c.Query("dry_run") == "true"
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		apierr.Abort(c, apierr.BadRequest("A grade file is required"))
+		return
+	}
+	if fileHeader.Size > maxGradeImportSize {
+		apierr.Abort(c, apierr.BadRequest("The grade file is too large"))
+		return
+	}
+
+	format, err := utils.SheetFormat(fileHeader.Filename)
+	if err != nil {
+		apierr.Abort(c, apierr.BadRequest("Only .xlsx and .csv files are supported"))
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		apierr.Abort(c, apierr.BadRequest("Cannot read the grade file"))
+		return
+	}
+	defer file.Close()
+
+	sheet, err := utils.ReadSheet(format, file)
+	if err != nil {
+		apierr.Abort(c, apierr.BadRequest("Cannot parse the grade file"))
+		return
+	}
+
+	rows, problems, err := s.Enrollments.ValidateGradeImport(uint(courseOfferingID), sheet)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			apierr.Abort(c, apierr.NotFound("Course offering not found"))
+			return
+		}
+		apierr.Abort(c, apierr.Internal(err, "Failed to check the grade file"))
+		return
+	}
+
+	if len(problems) > 0 {
+		apierr.Abort(c, apierr.New(http.StatusUnprocessableEntity, apierr.CodeValidation, "The grade file has errors").With("errors", problems))
+		return
+	}
+
+	if dryRun {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "校验通过（预览，未写入）",
+			"dry_run": true,
+			"data":    rows,
+		})
+		return
+	}
+
+	if err := s.Enrollments.ApplyGradeImport(uint(courseOfferingID), rows); err != nil {
+		apierr.Abort(c, apierr.Internal(err, "Failed to import grades"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "成绩导入成功",
+		"imported": len(rows),
+		"data":     rows,
+	})
+}
+
+// GetGradeImportTemplate 下载预填学生名单的成绩导入模板
+func (s *Server) GetGradeImportTemplate(c *gin.Context) {
+	courseOfferingID, err := strconv.Atoi(c.Query("course_offering_id"))
+	if err != nil {
+		apierr.Abort(c, apierr.BadRequest("Invalid course offering ID"))
+		return
+	}
+
+	format := c.DefaultQuery("format", utils.FormatXLSX)
+	if format != utils.FormatXLSX && format != utils.FormatCSV {
+		apierr.Abort(c, apierr.BadRequest("Only the xlsx and csv formats are supported"))
+		return
+	}
+
+	sheet, err := s.Enrollments.GradeImportTemplate(uint(courseOfferingID))
+	if err != nil {
+		apierr.Abort(c, apierr.Internal(err, "Failed to generate the import template"))
+		return
+	}
+
+	filename := fmt.Sprintf("grades_%d_template.%s", courseOfferingID, format)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Header("Content-Type", contentTypes[format])
+	if err := utils.WriteSheet(c.Writer, format, "成绩", sheet); err != nil {
+		c.Status(http.StatusInternalServerError)
+	}
+}
+
+var contentTypes = map[string]string{
+	utils.FormatCSV:  "text/csv; charset=utf-8",
+	utils.FormatXLSX: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
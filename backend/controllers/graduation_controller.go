@@ -0,0 +1,174 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"to-mrz/apierr"
+	"to-mrz/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GraduationReviewRequest contains the graduation review request data
+type GraduationReviewRequest struct {
+	GraduationYear int  `json:"graduation_year" binding:"required,gte=1900,lte=2200"`
+	CohortYear     int  `json:"cohort_year" binding:"omitempty,gte=1900,lte=2200"` // defaults to four years before graduation
+	MajorID        uint `json:"major_id"`
+}
+
+// GraduationOverrideRequest contains a manual eligibility decision
+type GraduationOverrideRequest struct {
+	Eligible      *bool  `json:"eligible" binding:"required"`
+	Justification string `json:"justification" binding:"required,max=1000"`
+}
+
+// CreateGraduationReview evaluates all final-year students and stores a draft review
+func (s *Server) CreateGraduationReview(c *gin.Context) {
+	var request GraduationReviewRequest
+	if !bindJSON(c, &request) || !s.checkReferences(c, reference{"major_id", "majors", request.MajorID}) {
+		return
+	}
+
+	if request.CohortYear == 0 {
+		request.CohortYear = request.GraduationYear - 4
+	}
+
+	userID, _ := c.Get("user_id")
+	id, err := s.Graduation.Run(request.GraduationYear, request.CohortYear, request.MajorID, userID.(uint))
+	if err != nil {
+		apierr.Abort(c, apierr.Internal(err, "Failed to run graduation review"))
+		return
+	}
+
+	review, err := s.Graduation.Get(id)
+	if err != nil {
+		apierr.Abort(c, apierr.Internal(err, "Failed to get graduation review"))
+		return
+	}
+
+	c.JSON(http.StatusCreated, review)
+}
+
+// GetGraduationReviews returns a page of the graduation reviews with their result
+// counts, newest first, filtered by graduation_year, cohort_year, major_id and status
+func (s *Server) GetGraduationReviews(c *gin.Context) {
+	filter := db.GraduationReviewFilter{Status: c.Query("status")}
+	var ok bool
+	if filter.ListQuery, ok = listQuery(c); !ok {
+		return
+	}
+	for _, param := range []struct {
+		key, message string
+		n            *int
+	}{
+		{"graduation_year", "Invalid graduation year", &filter.GraduationYear},
+		{"cohort_year", "Invalid cohort year", &filter.CohortYear},
+	} {
+		n, err := queryUint(c, param.key)
+		if err != nil {
+			apierr.Abort(c, apierr.BadRequest(param.message))
+			return
+		}
+		*param.n = int(n)
+	}
+	var err error
+	if filter.MajorID, err = queryUint(c, "major_id"); err != nil {
+		apierr.Abort(c, apierr.BadRequest("Invalid major ID"))
+		return
+	}
+
+	page, err := s.Graduation.List(filter)
+	writeList(c, page, err, "Failed to get graduation reviews")
+}
+
+// GetGraduationReview returns a graduation review with every student's result and reasons
+func (s *Server) GetGraduationReview(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierr.Abort(c, apierr.BadRequest("Invalid graduation review ID"))
+		return
+	}
+
+	review, err := s.Graduation.Get(uint(id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			apierr.Abort(c, apierr.NotFound("Graduation review not found"))
+			return
+		}
+		apierr.Abort(c, apierr.Internal(err, "Failed to get graduation review"))
+		return
+	}
+
+	c.JSON(http.StatusOK, review)
+}
+
+// OverrideGraduationResult manually sets a student's eligibility with a justification
+func (s *Server) OverrideGraduationResult(c *gin.Context) {
+	reviewID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierr.Abort(c, apierr.BadRequest("Invalid graduation review ID"))
+		return
+	}
+
+	studentID, err := strconv.Atoi(c.Param("student_id"))
+	if err != nil {
+		apierr.Abort(c, apierr.BadRequest("Invalid student ID"))
+		return
+	}
+
+	var request GraduationOverrideRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+	if strings.TrimSpace(request.Justification) == "" {
+		apierr.Abort(c, apierr.InvalidField("justification", "required", "This field is required"))
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	err = s.Graduation.Override(uint(reviewID), uint(studentID), *request.Eligible, strings.TrimSpace(request.Justification), userID.(uint))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			apierr.Abort(c, apierr.NotFound("Graduation result not found"))
+		case errors.Is(err, db.ErrReviewConfirmed):
+			apierr.Abort(c, apierr.Conflict("Graduation review already confirmed"))
+		default:
+			apierr.Abort(c, apierr.Internal(err, "Failed to override graduation result"))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Graduation result overridden successfully"})
+}
+
+// ConfirmGraduationReview marks all eligible students of a review as graduated
+func (s *Server) ConfirmGraduationReview(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierr.Abort(c, apierr.BadRequest("Invalid graduation review ID"))
+		return
+	}
+
+	graduated, err := s.Graduation.Confirm(uint(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			apierr.Abort(c, apierr.NotFound("Graduation review not found"))
+		case errors.Is(err, db.ErrReviewConfirmed):
+			apierr.Abort(c, apierr.Conflict("Graduation review already confirmed"))
+		default:
+			apierr.Abort(c, apierr.Internal(err, "Failed to confirm graduation review"))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Graduation review confirmed successfully",
+		"graduated": graduated,
+	})
+}
@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"to-mrz/apierr"
+	"to-mrz/db"
+	"to-mrz/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// listQuery reads the query parameters shared by the list endpoints: page and
+// page_size, or cursor, sort (comma separated fields, "-" for descending) and q. It
+// reports false after writing an error response.
+func listQuery(c *gin.Context) (db.ListQuery, bool) {
+	query := db.ListQuery{
+		Cursor: c.Query("cursor"),
+		Search: c.Query("q"),
+	}
+
+	for _, param := range []struct {
+		key string
+		n   *int
+	}{{"page", &query.Page}, {"page_size", &query.PageSize}} {
+		value := c.Query(param.key)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			apierr.Abort(c, apierr.BadRequest("Invalid %s", param.key))
+			return query, false
+		}
+		*param.n = n
+	}
+	if query.Page != 0 && query.Cursor != "" {
+		apierr.Abort(c, apierr.BadRequest("Use either page or cursor, not both"))
+		return query, false
+	}
+
+	if value := c.Query("sort"); value != "" {
+		query.Sort = strings.Split(value, ",")
+	}
+	return query, true
+}
+
+// writeList writes a page of a list, or maps the error of a List method to a
+// response. message is the error for failures other than a rejected list query.
+func writeList(c *gin.Context, page *models.Page, err error, message string) {
+	var invalid *db.ListQueryError
+	switch {
+	case errors.As(err, &invalid):
+		apierr.Abort(c, apierr.BadRequest(invalid.Reason, invalid.Args...))
+	case err != nil:
+		apierr.Abort(c, apierr.Internal(err, message))
+	default:
+		c.JSON(http.StatusOK, page)
+	}
+}
@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"to-mrz/apierr"
+	"to-mrz/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UnlockUser clears a user's failed login counter, lifting any lockout
+func (s *Server) UnlockUser(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierr.Abort(c, apierr.BadRequest("Invalid user ID"))
+		return
+	}
+
+	if err := s.Logins.UnlockUser(uint(id)); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			apierr.Abort(c, apierr.NotFound("User not found"))
+			return
+		}
+		apierr.Abort(c, apierr.Internal(err, "Failed to unlock user"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User unlocked successfully"})
+}
+
+// GetLoginLockouts returns a page of the usernames and client IPs that are currently
+// locked out, optionally only of one kind ("user" or "ip")
+func (s *Server) GetLoginLockouts(c *gin.Context) {
+	filter := db.LockoutFilter{Kind: c.Query("kind")}
+	if filter.Kind != "" && filter.Kind != "user" && filter.Kind != "ip" {
+		apierr.Abort(c, apierr.BadRequest("Invalid lockout kind"))
+		return
+	}
+	var ok bool
+	if filter.ListQuery, ok = listQuery(c); !ok {
+		return
+	}
+
+	page, err := s.Logins.ActiveLockouts(filter)
+	writeList(c, page, err, "Failed to get lockouts")
+}
+
+// UnlockIP clears a client IP's failed login counter, lifting any lockout
+func (s *Server) UnlockIP(c *gin.Context) {
+	if err := s.Logins.UnlockIP(c.Param("ip")); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			apierr.Abort(c, apierr.NotFound("No failed logins recorded for this IP"))
+			return
+		}
+		apierr.Abort(c, apierr.Internal(err, "Failed to unlock IP"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "IP unlocked successfully"})
+}
+
+// GetLoginEvents returns a page of the sign-in attempts, newest first, filtered by
+// username, ip and success
+func (s *Server) GetLoginEvents(c *gin.Context) {
+	filter := db.LoginEventFilter{
+		Username:  c.Query("username"),
+		IPAddress: c.Query("ip"),
+	}
+	if value := c.Query("success"); value != "" {
+		success, err := strconv.ParseBool(value)
+		if err != nil {
+			apierr.Abort(c, apierr.BadRequest("Invalid success filter"))
+			return
+		}
+		filter.Success = &success
+	}
+
+	s.respondLoginEvents(c, filter)
+}
+
+// GetMyLoginEvents returns a page of the current user's sign-in attempts, newest first
+func (s *Server) GetMyLoginEvents(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	id := userID.(uint)
+
+	s.respondLoginEvents(c, db.LoginEventFilter{UserID: &id})
+}
+
+// respondLoginEvents applies the list query parameters and writes the matching events
+func (s *Server) respondLoginEvents(c *gin.Context, filter db.LoginEventFilter) {
+	var ok bool
+	if filter.ListQuery, ok = listQuery(c); !ok {
+		return
+	}
+
+	page, err := s.Logins.Events(filter)
+	writeList(c, page, err, "Failed to get login events")
+}
@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"to-mrz/apierr"
+	"to-mrz/db"
+	"to-mrz/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetMajors returns a page of the majors, optionally of one department_id and
+// searched by name and code with q, or of the deleted ones with ?deleted=true
+func (s *Server) GetMajors(c *gin.Context) {
+	filter := db.MajorFilter{}
+	var ok bool
+	if filter.ListQuery, ok = listQuery(c); !ok {
+		return
+	}
+	var err error
+	if filter.DepartmentID, err = queryUint(c, "department_id"); err != nil {
+		apierr.Abort(c, apierr.BadRequest("Invalid department ID"))
+		return
+	}
+	if filter.Deleted, ok = deletedFilter(c, models.PermDepartmentDelete); !ok {
+		return
+	}
+
+	page, err := s.Majors.List(filter)
+	writeList(c, page, err, "Failed to retrieve majors")
+}
+
+// DeleteMajor deletes a major. It can be restored until it is purged.
+func (s *Server) DeleteMajor(c *gin.Context) {
+	majorID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		apierr.Abort(c, apierr.BadRequest("Invalid major ID"))
+		return
+	}
+
+	if err := s.Majors.Delete(uint(majorID)); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			apierr.Abort(c, apierr.NotFound("Major not found"))
+			return
+		}
+		apierr.Abort(c, apierr.Internal(err, "Failed to delete major"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Major deleted successfully"})
+}
+
+// RestoreMajor restores a deleted major. Its department must not be deleted.
+func (s *Server) RestoreMajor(c *gin.Context) {
+	majorID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		apierr.Abort(c, apierr.BadRequest("Invalid major ID"))
+		return
+	}
+
+	if err := s.Majors.Restore(uint(majorID)); err != nil {
+		if errors.Is(err, db.ErrDepartmentDeleted) {
+			apierr.Abort(c, apierr.Conflict("The major's department is deleted, restore it first"))
+			return
+		}
+		writeRestoreError(c, err, "Deleted major not found", "Failed to restore major")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Major restored successfully"})
+}
+
+// PurgeMajor removes a deleted major for good, unless other records still refer to it
+func (s *Server) PurgeMajor(c *gin.Context) {
+	majorID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		apierr.Abort(c, apierr.BadRequest("Invalid major ID"))
+		return
+	}
+
+	if err := s.Majors.Purge(uint(majorID)); err != nil {
+		writePurgeError(c, err, "Major not found", "Failed to purge major")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Major purged successfully"})
+}
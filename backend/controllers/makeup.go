@@ -0,0 +1,172 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"to-mrz/apierr"
+	"to-mrz/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MakeupRosterRequest 生成补考名单的请求参数
+type MakeupRosterRequest struct {
+	SemesterID       uint `json:"semester_id"`
+	CourseOfferingID uint `json:"course_offering_id"`
+}
+
+// CreateRetake 为未通过的选课记录登记重修（在之后学期的开课中）
+func (s *Server) CreateRetake(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierr.Abort(c, apierr.BadRequest("Invalid grade record ID"))
+		return
+	}
+
+	var request struct {
+		CourseOfferingID uint `json:"course_offering_id" binding:"required"`
+	}
+	if !bindJSON(c, &request) || !s.checkReferences(c, reference{"course_offering_id", "course_offerings", request.CourseOfferingID}) {
+		return
+	}
+
+	retakeID, err := s.Enrollments.CreateRetake(uint(id), request.CourseOfferingID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			apierr.Abort(c, apierr.NotFound("Grade record or course offering not found"))
+		case errors.Is(err, db.ErrEnrollmentNotFailed):
+			apierr.Abort(c, apierr.BadRequest("Only failed courses can be retaken"))
+		case errors.Is(err, db.ErrRetakeCourseMismatch):
+			apierr.Abort(c, apierr.BadRequest("The retake offering is for a different course"))
+		case errors.Is(err, db.ErrRetakeNotLater):
+			apierr.Abort(c, apierr.BadRequest("A retake must be in a later semester"))
+		case errors.Is(err, db.ErrRetakeExists):
+			apierr.Abort(c, apierr.Conflict("A retake is already registered"))
+		default:
+			apierr.Abort(c, apierr.Internal(err, "Failed to register the retake"))
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "重修登记成功",
+		"id":      retakeID,
+	})
+}
+
+// GetTranscript 获取学生成绩单（按学校的补考/重修成绩认定策略）
+func (s *Server) GetTranscript(c *gin.Context) {
+	studentID, ok := requestStudentID(c)
+	if !ok {
+		apierr.Abort(c, apierr.BadRequest("Invalid student ID"))
+		return
+	}
+
+	transcript, err := s.Enrollments.Transcript(studentID, db.GradePolicy)
+	if err != nil {
+		apierr.Abort(c, apierr.Internal(err, "Failed to get transcript"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": transcript,
+	})
+}
+
+// GetMakeupRoster 获取补考名单
+func (s *Server) GetMakeupRoster(c *gin.Context) {
+	semesterID, err := queryUint(c, "semester_id")
+	if err != nil {
+		apierr.Abort(c, apierr.BadRequest("Invalid semester ID"))
+		return
+	}
+
+	courseOfferingID, err := queryUint(c, "course_offering_id")
+	if err != nil {
+		apierr.Abort(c, apierr.BadRequest("Invalid course offering ID"))
+		return
+	}
+
+	roster, err := s.Enrollments.MakeupRoster(semesterID, courseOfferingID)
+	if err != nil {
+		apierr.Abort(c, apierr.Internal(err, "Failed to get makeup exam roster"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": roster,
+	})
+}
+
+// GenerateMakeupRoster 根据未通过的选课记录自动生成补考名单
+func (s *Server) GenerateMakeupRoster(c *gin.Context) {
+	var request MakeupRosterRequest
+	if !bindJSON(c, &request) || !s.checkReferences(c,
+		reference{"semester_id", "semesters", request.SemesterID},
+		reference{"course_offering_id", "course_offerings", request.CourseOfferingID}) {
+		return
+	}
+
+	created, err := s.Enrollments.GenerateMakeupRoster(request.SemesterID, request.CourseOfferingID)
+	if err != nil {
+		apierr.Abort(c, apierr.Internal(err, "Failed to generate makeup exam roster"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "补考名单生成成功",
+		"created": created,
+	})
+}
+
+// RecordMakeupScore 录入补考成绩，通过的补考按60分记载
+func (s *Server) RecordMakeupScore(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierr.Abort(c, apierr.BadRequest("Invalid makeup exam ID"))
+		return
+	}
+
+	var request struct {
+		Score *float64 `json:"score" binding:"required,gte=0,lte=100"`
+	}
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	err = s.Enrollments.RecordMakeupScore(uint(id), *request.Score)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			apierr.Abort(c, apierr.NotFound("Makeup exam not found"))
+		case errors.Is(err, db.ErrMakeupAlreadyScored):
+			apierr.Abort(c, apierr.BadRequest("The makeup exam score has already been recorded"))
+		default:
+			apierr.Abort(c, apierr.Internal(err, "Failed to record makeup exam score"))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "补考成绩录入成功",
+	})
+}
+
+// queryUint parses an optional unsigned integer query parameter, returning 0 when absent
+func queryUint(c *gin.Context, key string) (uint, error) {
+	value := c.Query(key)
+	if value == "" {
+		return 0, nil
+	}
+
+	n, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	return uint(n), nil
+}
@@ -0,0 +1,174 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"to-mrz/apierr"
+	"to-mrz/db"
+	"to-mrz/middleware"
+	"to-mrz/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RolePermissionsRequest contains the permissions to grant a role
+type RolePermissionsRequest struct {
+	Permissions []models.Permission `json:"permissions" binding:"required,max=100"`
+}
+
+// UserRolesRequest contains the additional roles to give a user
+type UserRolesRequest struct {
+	Roles []models.Role `json:"roles" binding:"required,max=20"`
+}
+
+// GetPermissions returns every permission known to the system
+func (s *Server) GetPermissions(c *gin.Context) {
+	c.JSON(http.StatusOK, models.AllPermissions)
+}
+
+// GetRoles returns every role with the permissions it grants
+func (s *Server) GetRoles(c *gin.Context) {
+	query, ok := listQuery(c)
+	if !ok {
+		return
+	}
+
+	page, err := s.Roles.List(query)
+	writeList(c, page, err, "Failed to get roles")
+}
+
+// UpdateRolePermissions replaces the permissions granted to a role
+func (s *Server) UpdateRolePermissions(c *gin.Context) {
+	role := models.Role(c.Param("role"))
+	if !db.ValidRole(role) {
+		apierr.Abort(c, apierr.NotFound("Role not found"))
+		return
+	}
+
+	var request RolePermissionsRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	for _, p := range request.Permissions {
+		if !db.ValidPermission(p) {
+			apierr.Abort(c, apierr.InvalidField("permissions", apierr.FieldInvalid, "Unknown permission: %s", p))
+			return
+		}
+	}
+
+	if err := s.Roles.SetPermissions(role, request.Permissions); err != nil {
+		switch {
+		case errors.Is(err, db.ErrAdminPermissions):
+			apierr.Abort(c, apierr.BadRequest("The admin role always has every permission"))
+		case errors.Is(err, db.ErrAdminOnlyPermission):
+			apierr.Abort(c, apierr.BadRequest("Only the admin role can hold this permission"))
+		default:
+			apierr.Abort(c, apierr.Internal(err, "Failed to update role permissions"))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role permissions updated successfully"})
+}
+
+// GetUserRoles returns a user's primary role followed by their additional roles
+func (s *Server) GetUserRoles(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierr.Abort(c, apierr.BadRequest("Invalid user ID"))
+		return
+	}
+
+	roles, err := s.Roles.UserRoles(uint(id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			apierr.Abort(c, apierr.NotFound("User not found"))
+			return
+		}
+		apierr.Abort(c, apierr.Internal(err, "Failed to get user roles"))
+		return
+	}
+
+	c.JSON(http.StatusOK, roles)
+}
+
+// UpdateUserRoles replaces the additional roles a user holds besides their primary role
+func (s *Server) UpdateUserRoles(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierr.Abort(c, apierr.BadRequest("Invalid user ID"))
+		return
+	}
+
+	var request UserRolesRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	for _, role := range request.Roles {
+		if !db.ValidRole(role) {
+			apierr.Abort(c, apierr.InvalidField("roles", apierr.FieldInvalid, "Unknown role: %s", role))
+			return
+		}
+	}
+	if !s.checkAdminChange(c, uint(id), request.Roles...) {
+		return
+	}
+
+	if err := s.Roles.SetUserRoles(uint(id), request.Roles); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			apierr.Abort(c, apierr.NotFound("User not found"))
+			return
+		}
+		apierr.Abort(c, apierr.Internal(err, "Failed to update user roles"))
+		return
+	}
+
+	roles, err := s.Roles.UserRoles(uint(id))
+	if err != nil {
+		apierr.Abort(c, apierr.Internal(err, "Failed to get user roles"))
+		return
+	}
+
+	c.JSON(http.StatusOK, roles)
+}
+
+// checkAdminChange stops users who are not administrators from giving the admin role
+// or from changing an administrator's account, which would make them administrators
+// and give them the admin-only permissions. userID is the account being changed, or 0
+// for a new one, and roles are the roles it is being given. It reports whether the
+// request may go on.
+func (s *Server) checkAdminChange(c *gin.Context, userID uint, roles ...models.Role) bool {
+	if middleware.IsAdmin(c) {
+		return true
+	}
+	for _, role := range roles {
+		if role == models.RoleAdmin {
+			apierr.Abort(c, apierr.Forbidden("Only administrators can give the admin role"))
+			return false
+		}
+	}
+	if userID == 0 {
+		return true
+	}
+
+	current, err := s.Roles.UserRoles(userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return true // the handler reports the missing user
+		}
+		apierr.Abort(c, apierr.Internal(err, "Failed to get user roles"))
+		return false
+	}
+	for _, role := range current {
+		if role == models.RoleAdmin {
+			apierr.Abort(c, apierr.Forbidden("Only administrators can change administrator accounts"))
+			return false
+		}
+	}
+	return true
+}
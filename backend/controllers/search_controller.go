@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"to-mrz/apierr"
+	"to-mrz/db"
+	"to-mrz/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SearchAll finds the courses, teachers and students matching every word of q, grouped
+// by type. types (comma separated course, teacher and student) narrows the groups and
+// limit sets the hits per group. Students are limited to the caller's data scope.
+func (s *Server) SearchAll(c *gin.Context) {
+	query := db.SearchQuery{Keyword: strings.TrimSpace(c.Query("q"))}
+	if query.Keyword == "" {
+		apierr.Abort(c, apierr.BadRequest("Search keyword (q) is required"))
+		return
+	}
+
+	if value := c.Query("types"); value != "" {
+		for _, kind := range strings.Split(value, ",") {
+			kind = strings.TrimSpace(kind)
+			if !containsType(kind) {
+				apierr.Abort(c, apierr.BadRequest("Invalid search type %q, expected one of %s", kind, strings.Join(db.SearchTypes, ", ")))
+				return
+			}
+			query.Types = append(query.Types, kind)
+		}
+	}
+
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			apierr.Abort(c, apierr.BadRequest("Invalid limit"))
+			return
+		}
+		query.Limit = limit
+	}
+
+	scope, err := middleware.CurrentScope(c)
+	if err != nil {
+		apierr.Abort(c, apierr.Internal(err, "Failed to load data scope"))
+		return
+	}
+	query.Scope = scope
+
+	result, err := s.Search.Search(query)
+	if err != nil {
+		apierr.Abort(c, apierr.Internal(err, "Failed to search"))
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// containsType reports whether kind is one of db.SearchTypes
+func containsType(kind string) bool {
+	for _, t := range db.SearchTypes {
+		if t == kind {
+			return true
+		}
+	}
+	return false
+}
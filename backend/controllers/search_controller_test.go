@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"to-mrz/apierr"
+	"to-mrz/config"
+	"to-mrz/db"
+	"to-mrz/middleware"
+	"to-mrz/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// searchFixtures has an administrator (user 1), two students in one class and a
+// course whose name needs escaping
+const searchFixtures = `
+INSERT INTO users (username, password, name, role) VALUES
+	('admin', 'x', '管理员', 'admin'), ('2023001', 'x', '张晓明', 'student'), ('2023002', 'x', '张晓红', 'student');
+INSERT INTO departments (name, code) VALUES ('计算机学院', 'CS');
+INSERT INTO majors (name, code, department_id) VALUES ('计算机科学与技术', 'CS01', 1);
+INSERT INTO classes (name, code, major_id, year) VALUES ('计科2023-1班', 'CS2023-1', 1, 2023);
+INSERT INTO students (user_id, student_id, class_id, enroll_year) VALUES (2, '2023001', 1, 2023), (3, '2023002', 1, 2023);
+INSERT INTO courses (name, code, credits, hours, type, department_id) VALUES ('<b>算法</b>设计', 'CS301', 3, 48, '必修', 1);
+`
+
+// fakeSessions accepts every session
+type fakeSessions struct {
+	db.SessionRepository
+}
+
+func (fakeSessions) Check(sessionID, userID uint, role string) error {
+	return nil
+}
+
+// newSearchRouter serves GET /api/search on a new SQLite database with searchFixtures,
+// through the real authentication and data scope
+func newSearchRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	if err := db.InitDB(config.DriverSQLite, filepath.Join(t.TempDir(), "school.db")); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.CloseDB() })
+	if _, err := db.DB.Exec(searchFixtures); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.InitSearchIndex(); err != nil {
+		t.Fatal(err)
+	}
+
+	repos := db.NewSQLRepositories()
+	repos.Sessions = fakeSessions{}
+	s := NewServer(repos)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.Errors())
+	r.GET("/api/search", middleware.AuthMiddleware(repos), s.SearchAll)
+	return r
+}
+
+// getAs sends a GET request as the user and decodes the response into out
+func getAs(t *testing.T, r *gin.Engine, user *models.User, path string, out interface{}) int {
+	t.Helper()
+	token, err := middleware.GenerateToken(user, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+		t.Fatalf("GET %s: %v: %s", path, err, w.Body)
+	}
+	return w.Code
+}
+
+func TestSearchAll(t *testing.T) {
+	r := newSearchRouter(t)
+	admin := &models.User{ID: 1, Role: models.RoleAdmin}
+	student := &models.User{ID: 2, Role: models.RoleStudent}
+
+	for _, tc := range []struct {
+		name string
+		user *models.User
+		want []string
+	}{
+		{"admin", admin, []string{"张晓明", "张晓红"}},
+		{"student", student, []string{"张晓明"}},
+	} {
+		var result models.SearchResult
+		if status := getAs(t, r, tc.user, "/api/search?q="+url.QueryEscape("张晓")+"&types=student", &result); status != http.StatusOK {
+			t.Fatalf("%s: status %d", tc.name, status)
+		}
+		if len(result.Groups) != 1 || result.Groups[0].Type != db.SearchStudent {
+			t.Fatalf("%s: groups %+v, want only students", tc.name, result.Groups)
+		}
+		var names []string
+		for _, hit := range result.Groups[0].Items {
+			names = append(names, hit.Title)
+		}
+		slices.Sort(names)
+		if !slices.Equal(names, tc.want) {
+			t.Errorf("%s finds students %v, want %v", tc.name, names, tc.want)
+		}
+	}
+
+	var result models.SearchResult
+	if status := getAs(t, r, student, "/api/search?q="+url.QueryEscape("算法"), &result); status != http.StatusOK {
+		t.Fatalf("course search: status %d", status)
+	}
+	if len(result.Groups) != len(db.SearchTypes) || len(result.Groups[0].Items) != 1 {
+		t.Fatalf("course search: %+v", result.Groups)
+	}
+	if got, want := result.Groups[0].Items[0].Highlight["title"], "&lt;b&gt;<mark>算法</mark>&lt;/b&gt;设计"; got != want {
+		t.Errorf("title highlight %q, want %q", got, want)
+	}
+
+	for _, path := range []string{
+		"/api/search",
+		"/api/search?q=%20",
+		"/api/search?q=x&types=course,room",
+		"/api/search?q=x&limit=0",
+		"/api/search?q=x&limit=many",
+	} {
+		var body struct {
+			Code string `json:"code"`
+		}
+		if status := getAs(t, r, admin, path, &body); status != http.StatusBadRequest || body.Code != apierr.CodeBadRequest {
+			t.Errorf("%s: status %d, code %q, want 400 %s", path, status, body.Code, apierr.CodeBadRequest)
+		}
+	}
+}
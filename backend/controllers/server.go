@@ -0,0 +1,14 @@
+package controllers
+
+import "to-mrz/db"
+
+// Server holds the dependencies of the HTTP handlers. Handlers are methods on it so
+// that they reach the database only through the repositories it was built with.
+type Server struct {
+	*db.Repositories
+}
+
+// NewServer creates a server using the given repositories
+func NewServer(repos *db.Repositories) *Server {
+	return &Server{Repositories: repos}
+}
@@ -0,0 +1,435 @@
+package controllers_test
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"to-mrz/api"
+	"to-mrz/apierr"
+	"to-mrz/config"
+	"to-mrz/db"
+	"to-mrz/models"
+	"to-mrz/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The handlers are tested on the real routes, with in-memory repositories standing in
+// for the database. Each fake implements the methods the tested routes call; the
+// embedded interface makes any other call panic.
+
+// fakeUsers holds the accounts by ID
+type fakeUsers struct {
+	db.UserRepository
+	users map[uint]*models.User
+}
+
+func (f fakeUsers) Get(id uint) (*models.User, error) {
+	user, ok := f.users[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (f fakeUsers) GetByUsername(username string) (*models.User, error) {
+	for id, user := range f.users {
+		if user.Username == username {
+			return f.Get(id)
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// fakeLogins counts login attempts per username and records the sign-in history.
+// Usernames in locked are refused.
+type fakeLogins struct {
+	db.LoginRepository
+	locked   map[string]bool
+	pending  map[string]int // attempts begun and not yet released
+	failures map[string]int
+	events   []models.LoginEvent
+}
+
+func (f *fakeLogins) BeginAttempt(username, ip string) (time.Duration, error) {
+	if f.locked[username] {
+		return time.Minute, db.ErrLoginLocked
+	}
+	f.pending[username]++
+	return 0, nil
+}
+
+func (f *fakeLogins) RecordFailure(username, ip string) error {
+	f.failures[username]++
+	return nil
+}
+
+func (f *fakeLogins) ReleaseAttempt(username, ip string) error {
+	f.pending[username]--
+	return nil
+}
+
+func (f *fakeLogins) RecordSuccess(username string) error {
+	f.failures[username] = 0
+	return nil
+}
+
+func (f *fakeLogins) RecordEvent(event *models.LoginEvent) error {
+	f.events = append(f.events, *event)
+	return nil
+}
+
+// fakeSessions holds the sessions started by logins; all of them stay active
+type fakeSessions struct {
+	db.SessionRepository
+	sessions map[uint]*models.Session
+}
+
+func (f fakeSessions) Create(session *models.Session, tokenHash string) (uint, error) {
+	id := uint(len(f.sessions) + 1)
+	f.sessions[id] = session
+	return id, nil
+}
+
+func (f fakeSessions) Check(sessionID, userID uint, role string) error {
+	session, ok := f.sessions[sessionID]
+	if !ok || session.UserID != userID {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// fakeRoles grants each user the default permissions of their role, and the
+// administrator every permission
+type fakeRoles struct {
+	db.RoleRepository
+	users fakeUsers
+}
+
+func (f fakeRoles) UserPermissions(userID uint) (map[models.Permission]bool, error) {
+	user, err := f.users.Get(userID)
+	if err != nil {
+		return nil, err
+	}
+	granted := map[models.Permission]bool{}
+	if user.Role == models.RoleAdmin {
+		for _, p := range models.AllPermissions {
+			granted[p.Name] = true
+		}
+		return granted, nil
+	}
+	for _, p := range models.DefaultRolePermissions[user.Role] {
+		granted[p] = true
+	}
+	return granted, nil
+}
+
+// fakeScopes holds the data scope of each user and the owners of students and offerings
+type fakeScopes struct {
+	db.ScopeRepository
+	scopes    map[uint]*models.DataScope
+	students  map[uint]*models.RowOwner
+	offerings map[uint]*models.RowOwner
+}
+
+func (f fakeScopes) DataScope(userID uint) (*models.DataScope, error) {
+	return f.scopes[userID], nil
+}
+
+func (f fakeScopes) StudentOwner(id uint) (*models.RowOwner, error) {
+	return owner(f.students, id)
+}
+
+func (f fakeScopes) CourseOfferingOwner(id uint) (*models.RowOwner, error) {
+	return owner(f.offerings, id)
+}
+
+func owner(owners map[uint]*models.RowOwner, id uint) (*models.RowOwner, error) {
+	o, ok := owners[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return o, nil
+}
+
+// fakeTwoFactor requires two-factor authentication of nobody
+type fakeTwoFactor struct {
+	db.TwoFactorRepository
+}
+
+func (fakeTwoFactor) Required(userID uint) (bool, error) { return false, nil }
+
+// fakeEnrollments holds the grades by student ID and offering ID
+type fakeEnrollments struct {
+	db.EnrollmentRepository
+	grades []models.Enrollment
+}
+
+func (f fakeEnrollments) StudentGrades(filter db.StudentGradeFilter) (*models.Page, error) {
+	return f.page(func(e models.Enrollment) bool { return e.StudentID == filter.StudentID })
+}
+
+func (f fakeEnrollments) CourseGrades(filter db.CourseGradeFilter) (*models.Page, error) {
+	return f.page(func(e models.Enrollment) bool { return e.CourseOfferingID == filter.CourseOfferingID })
+}
+
+func (f fakeEnrollments) page(match func(models.Enrollment) bool) (*models.Page, error) {
+	items := []models.Enrollment{}
+	for _, e := range f.grades {
+		if match(e) {
+			items = append(items, e)
+		}
+	}
+	return &models.Page{Items: items, Total: len(items), Page: 1, PageSize: len(items)}, nil
+}
+
+// Users of the test school: an administrator, a teacher of offering 10 and two
+// students of it, one of them disabled
+const (
+	adminID uint = iota + 1
+	teacherID
+	studentID
+	disabledID
+)
+
+// testPassword is the password of every test user
+const testPassword = "Secret-2024"
+
+// testSchool is the state behind the fakes
+type testSchool struct {
+	router   *gin.Engine
+	logins   *fakeLogins
+	sessions fakeSessions
+}
+
+func newTestSchool(t *testing.T) *testSchool {
+	t.Helper()
+	hash, err := utils.HashPassword(testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := func(id uint, username string, role models.Role) *models.User {
+		return &models.User{ID: id, Username: username, Password: hash, Name: username, Role: role, AuthSource: models.AuthSourceLocal}
+	}
+	users := fakeUsers{users: map[uint]*models.User{
+		adminID:    user(adminID, "admin", models.RoleAdmin),
+		teacherID:  user(teacherID, "t1001", models.RoleTeacher),
+		studentID:  user(studentID, "2023001", models.RoleStudent),
+		disabledID: user(disabledID, "2023002", models.RoleStudent),
+	}}
+	users.users[disabledID].Disabled = true
+
+	school := &testSchool{
+		logins:   &fakeLogins{locked: map[string]bool{"locked": true}, pending: map[string]int{}, failures: map[string]int{}},
+		sessions: fakeSessions{sessions: map[uint]*models.Session{}},
+	}
+	repos := &db.Repositories{
+		Users:     users,
+		Logins:    school.logins,
+		Sessions:  school.sessions,
+		Roles:     fakeRoles{users: users},
+		TwoFactor: fakeTwoFactor{},
+		Scopes: fakeScopes{
+			scopes: map[uint]*models.DataScope{
+				adminID:   {Unrestricted: true},
+				teacherID: {TeacherID: 1},
+				studentID: {StudentID: 1},
+			},
+			students: map[uint]*models.RowOwner{
+				1: {DepartmentID: 1, StudentID: 1},
+				2: {DepartmentID: 1, StudentID: 2},
+			},
+			offerings: map[uint]*models.RowOwner{
+				10: {DepartmentID: 1, TeacherID: 1},
+				11: {DepartmentID: 1, TeacherID: 2},
+			},
+		},
+		Enrollments: fakeEnrollments{grades: []models.Enrollment{
+			{ID: 1, StudentID: 1, CourseOfferingID: 10, Grade: 88, Status: "已完成"},
+			{ID: 2, StudentID: 2, CourseOfferingID: 10, Grade: 45, Status: "未通过"},
+			{ID: 3, StudentID: 1, CourseOfferingID: 11, Grade: 91, Status: "已完成"},
+		}},
+	}
+
+	gin.SetMode(gin.TestMode)
+	savedWriter := gin.DefaultWriter
+	t.Cleanup(func() { gin.DefaultWriter = savedWriter })
+	gin.DefaultWriter = io.Discard
+	school.router = api.SetupRouter(config.ServerConfig{}, repos)
+	return school
+}
+
+// do sends a request with an optional JSON body and bearer token and decodes the
+// JSON response into out
+func (s *testSchool) do(t *testing.T, method, path, token string, body, out interface{}) int {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+		t.Fatalf("%s %s: %v: %s", method, path, err, w.Body)
+	}
+	return w.Code
+}
+
+// login signs a user in with testPassword and returns the access token
+func (s *testSchool) login(t *testing.T, username string) string {
+	t.Helper()
+	var response struct {
+		Token string `json:"token"`
+	}
+	if status := s.do(t, http.MethodPost, "/api/login", "", gin.H{"username": username, "password": testPassword}, &response); status != http.StatusOK {
+		t.Fatalf("logging in as %s: status %d", username, status)
+	}
+	return response.Token
+}
+
+// errorBody is the body of an error response
+type errorBody struct {
+	Code string `json:"code"`
+}
+
+func TestLogin(t *testing.T) {
+	school := newTestSchool(t)
+
+	for _, tc := range []struct {
+		name, username, password string
+		status                   int
+		code                     string
+		failed                   bool // counted against the brute-force limits
+	}{
+		{"wrong password", "2023001", "wrong", http.StatusUnauthorized, apierr.CodeInvalidCredentials, true},
+		{"unknown user", "nobody", testPassword, http.StatusUnauthorized, apierr.CodeInvalidCredentials, true},
+		{"disabled account with the right password", "2023002", testPassword, http.StatusUnauthorized, apierr.CodeInvalidCredentials, true},
+		{"locked out", "locked", testPassword, http.StatusTooManyRequests, apierr.CodeLoginThrottled, false},
+		{"empty password", "admin", "", http.StatusBadRequest, apierr.CodeValidation, false},
+	} {
+		var body errorBody
+		status := school.do(t, http.MethodPost, "/api/login", "", gin.H{"username": tc.username, "password": tc.password}, &body)
+		if status != tc.status || body.Code != tc.code {
+			t.Errorf("%s: status %d, code %q, want %d %q", tc.name, status, body.Code, tc.status, tc.code)
+		}
+		if failed := school.logins.failures[tc.username] > 0; failed != tc.failed {
+			t.Errorf("%s: counted as a failure = %v, want %v", tc.name, failed, tc.failed)
+		}
+	}
+	if len(school.sessions.sessions) != 0 {
+		t.Errorf("refused logins started %d sessions", len(school.sessions.sessions))
+	}
+
+	var response struct {
+		Token        string       `json:"token"`
+		RefreshToken string       `json:"refresh_token"`
+		User         *models.User `json:"user"`
+	}
+	status := school.do(t, http.MethodPost, "/api/login", "", gin.H{"username": "2023001", "password": testPassword}, &response)
+	if status != http.StatusOK {
+		t.Fatalf("right password: status %d", status)
+	}
+	if response.Token == "" || response.RefreshToken == "" || response.User == nil || response.User.ID != studentID {
+		t.Errorf("right password: response %+v", response)
+	}
+	if school.logins.failures["2023001"] != 0 || school.logins.pending["2023001"] != 1 {
+		t.Errorf("after a successful login: %d failures and %d pending attempts, want the failures cleared and the attempt released",
+			school.logins.failures["2023001"], school.logins.pending["2023001"])
+	}
+	if session := school.sessions.sessions[1]; session == nil || session.UserID != studentID {
+		t.Errorf("session %+v, want one for the student", session)
+	}
+
+	last := school.logins.events[len(school.logins.events)-1]
+	if !last.Success || last.Username != "2023001" {
+		t.Errorf("last login event %+v, want a success", last)
+	}
+}
+
+func TestGrades(t *testing.T) {
+	school := newTestSchool(t)
+	student := school.login(t, "2023001")
+	teacher := school.login(t, "t1001")
+	admin := school.login(t, "admin")
+
+	for _, tc := range []struct {
+		name, token, path string
+		status            int
+		code              string
+		grades            []uint // enrollment IDs
+	}{
+		{"own grades", student, "/api/grades/student", http.StatusOK, "", []uint{1, 3}},
+		{"own grades by ID", student, "/api/grades/student?student_id=1", http.StatusOK, "", []uint{1, 3}},
+		{"another student's grades", student, "/api/grades/student?student_id=2", http.StatusForbidden, apierr.CodeOutOfScope, nil},
+		{"offering of the teacher", teacher, "/api/grades/course?course_offering_id=10", http.StatusOK, "", []uint{1, 2}},
+		{"offering of another teacher", teacher, "/api/grades/course?course_offering_id=11", http.StatusForbidden, apierr.CodeOutOfScope, nil},
+		{"missing offering", teacher, "/api/grades/course?course_offering_id=404", http.StatusNotFound, apierr.CodeNotFound, nil},
+		{"student grades without a student", teacher, "/api/grades/student", http.StatusForbidden, apierr.CodeOutOfScope, nil},
+		{"any student for the administrator", admin, "/api/grades/student?student_id=2", http.StatusOK, "", []uint{2}},
+		{"no token", "", "/api/grades/student", http.StatusUnauthorized, apierr.CodeUnauthorized, nil},
+	} {
+		var body struct {
+			Code  string              `json:"code"`
+			Items []models.Enrollment `json:"items"`
+		}
+		status := school.do(t, http.MethodGet, tc.path, tc.token, nil, &body)
+		if status != tc.status || body.Code != tc.code {
+			t.Errorf("%s: status %d, code %q, want %d %q", tc.name, status, body.Code, tc.status, tc.code)
+			continue
+		}
+		var ids []uint
+		for _, e := range body.Items {
+			ids = append(ids, e.ID)
+		}
+		if len(ids) != len(tc.grades) {
+			t.Errorf("%s: grades %v, want %v", tc.name, ids, tc.grades)
+			continue
+		}
+		for i := range ids {
+			if ids[i] != tc.grades[i] {
+				t.Errorf("%s: grades %v, want %v", tc.name, ids, tc.grades)
+				break
+			}
+		}
+	}
+}
+
+func TestPermissionDenied(t *testing.T) {
+	school := newTestSchool(t)
+	student := school.login(t, "2023001")
+	teacher := school.login(t, "t1001")
+
+	// refused before the handlers reach the repositories, whose fakes lack these methods
+	for _, tc := range []struct {
+		name, token, method, path string
+		body                      interface{}
+	}{
+		{"student entering a grade", student, http.MethodPost, "/api/grades", gin.H{"student_id": 1, "course_offering_id": 10, "grade": 100}},
+		{"student changing a grade", student, http.MethodPut, "/api/grades/1", gin.H{"grade": 100}},
+		{"student exporting grades", student, http.MethodGet, "/api/grades/export?course_offering_id=10", nil},
+		{"teacher listing users", teacher, http.MethodGet, "/api/users", nil},
+		{"teacher running a graduation review", teacher, http.MethodPost, "/api/graduation-reviews", gin.H{"graduation_year": 2024, "cohort_year": 2020}},
+	} {
+		var body errorBody
+		status := school.do(t, tc.method, tc.path, tc.token, tc.body, &body)
+		if status != http.StatusForbidden || body.Code != apierr.CodePermissionDenied {
+			t.Errorf("%s: status %d, code %q, want 403 %s", tc.name, status, body.Code, apierr.CodePermissionDenied)
+		}
+	}
+}
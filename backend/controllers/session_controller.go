@@ -0,0 +1,195 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"to-mrz/apierr"
+	"to-mrz/db"
+	"to-mrz/middleware"
+	"to-mrz/models"
+	"to-mrz/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RefreshRequest contains the refresh token to exchange
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// TokenResponse contains a new access/refresh token pair
+type TokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"` // access token lifetime in seconds
+}
+
+// issueTokens starts a new login session for the user and returns its token pair
+func (s *Server) issueTokens(c *gin.Context, user *models.User) (*TokenResponse, error) {
+	refreshToken, err := utils.RandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	session := &models.Session{
+		UserID:    user.ID,
+		Role:      user.Role,
+		UserAgent: c.Request.UserAgent(),
+		IPAddress: c.ClientIP(),
+		ExpiresAt: time.Now().Add(middleware.RefreshTokenExpiry()),
+	}
+	sessionID, err := s.Sessions.Create(session, utils.HashToken(refreshToken))
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := middleware.GenerateToken(user, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(middleware.TokenExpiry().Seconds()),
+	}, nil
+}
+
+// RefreshToken exchanges a refresh token for a new access token and a new refresh token.
+// Each refresh token can only be used once.
+func (s *Server) RefreshToken(c *gin.Context) {
+	var request RefreshRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	refreshToken, err := utils.RandomToken(32)
+	if err != nil {
+		apierr.Abort(c, apierr.Internal(err, "Failed to generate token"))
+		return
+	}
+
+	session, err := s.Sessions.Rotate(
+		utils.HashToken(request.RefreshToken),
+		utils.HashToken(refreshToken),
+		time.Now().Add(middleware.RefreshTokenExpiry()),
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrRefreshTokenReused):
+			apierr.Abort(c, apierr.Unauthorized("Refresh token already used, session has been revoked"))
+		case errors.Is(err, db.ErrUserDisabled):
+			apierr.Abort(c, apierr.Unauthorized("User account is disabled"))
+		case errors.Is(err, db.ErrRoleChanged):
+			apierr.Abort(c, apierr.Unauthorized("User role has changed, please log in again"))
+		case errors.Is(err, sql.ErrNoRows), errors.Is(err, db.ErrSessionRevoked), errors.Is(err, db.ErrSessionExpired):
+			apierr.Abort(c, apierr.Unauthorized("Invalid or expired refresh token"))
+		default:
+			apierr.Abort(c, apierr.Internal(err, "Failed to refresh token"))
+		}
+		return
+	}
+
+	user := &models.User{ID: session.UserID, Role: session.Role}
+	token, err := middleware.GenerateToken(user, session.ID)
+	if err != nil {
+		apierr.Abort(c, apierr.Internal(err, "Failed to generate token"))
+		return
+	}
+
+	c.JSON(http.StatusOK, TokenResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(middleware.TokenExpiry().Seconds()),
+	})
+}
+
+// Logout revokes the current session
+func (s *Server) Logout(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	sessionID, _ := c.Get("session_id")
+
+	if err := s.Sessions.Revoke(sessionID.(uint), userID.(uint)); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		apierr.Abort(c, apierr.Internal(err, "Failed to log out"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// LogoutAll revokes every session of the current user, logging out all devices
+func (s *Server) LogoutAll(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	revoked, err := s.Sessions.RevokeAllForUser(userID.(uint))
+	if err != nil {
+		apierr.Abort(c, apierr.Internal(err, "Failed to log out"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Logged out of all devices successfully",
+		"revoked": revoked,
+	})
+}
+
+// GetSessions returns a page of the active sessions (devices) of the current user,
+// newest first
+func (s *Server) GetSessions(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	sessionID, _ := c.Get("session_id")
+
+	filter := db.SessionFilter{UserID: userID.(uint), CurrentID: sessionID.(uint)}
+	var ok bool
+	if filter.ListQuery, ok = listQuery(c); !ok {
+		return
+	}
+
+	page, err := s.Sessions.List(filter)
+	writeList(c, page, err, "Failed to get sessions")
+}
+
+// RevokeSession logs out one of the current user's sessions
+func (s *Server) RevokeSession(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierr.Abort(c, apierr.BadRequest("Invalid session ID"))
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	if err := s.Sessions.Revoke(uint(id), userID.(uint)); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			apierr.Abort(c, apierr.NotFound("Session not found"))
+			return
+		}
+		apierr.Abort(c, apierr.Internal(err, "Failed to revoke session"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
+
+// ForceLogoutUser revokes every session of another user (admin only)
+func (s *Server) ForceLogoutUser(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierr.Abort(c, apierr.BadRequest("Invalid user ID"))
+		return
+	}
+
+	revoked, err := s.Sessions.RevokeAllForUser(uint(id))
+	if err != nil {
+		apierr.Abort(c, apierr.Internal(err, "Failed to log out user"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "User logged out of all devices",
+		"revoked": revoked,
+	})
+}
@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"sync"
+
+	"to-mrz/apierr"
+	"to-mrz/db"
+	"to-mrz/models"
+	"to-mrz/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupRequest contains the first-run administrator data
+type SetupRequest struct {
+	SetupToken string `json:"setup_token" binding:"required"`
+	Username   string `json:"username" binding:"required,max=50"`
+	Password   string `json:"password" binding:"required"`
+	Name       string `json:"name" binding:"required,max=100"`
+	Email      string `json:"email" binding:"omitempty,email,max=100"`
+	Phone      string `json:"phone" binding:"max=20"`
+}
+
+var (
+	setupMu    sync.Mutex
+	setupToken string
+)
+
+// EnableSetup allows POST /api/setup to create the first administrator with the given one-time token
+func EnableSetup(token string) {
+	setupMu.Lock()
+	defer setupMu.Unlock()
+	setupToken = token
+}
+
+// BootstrapAdmin validates the password against the password policy and creates the
+// first administrator account. It returns a *utils.WeakPasswordError for a weak password.
+func (s *Server) BootstrapAdmin(user *models.User, password string) (uint, error) {
+	if err := utils.CurrentPasswordPolicy.Validate(password); err != nil {
+		return 0, err
+	}
+
+	hash, err := utils.HashPassword(password)
+	if err != nil {
+		return 0, err
+	}
+	user.Password = hash
+
+	return s.Users.CreateFirstAdmin(user)
+}
+
+// Setup creates the first administrator on a fresh installation. It requires the
+// one-time setup token logged at startup and refuses to run once an admin exists.
+func (s *Server) Setup(c *gin.Context) {
+	var request SetupRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	setupMu.Lock()
+	defer setupMu.Unlock()
+
+	if setupToken == "" {
+		apierr.Abort(c, apierr.Forbidden("Setup has already been completed"))
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(request.SetupToken), []byte(setupToken)) != 1 {
+		apierr.Abort(c, apierr.Forbidden("Invalid setup token"))
+		return
+	}
+
+	user := &models.User{
+		Username: request.Username,
+		Name:     request.Name,
+		Email:    request.Email,
+		Phone:    request.Phone,
+	}
+	id, err := s.BootstrapAdmin(user, request.Password)
+	if err != nil {
+		var weak *utils.WeakPasswordError
+		switch {
+		case errors.Is(err, db.ErrAdminExists):
+			setupToken = ""
+			apierr.Abort(c, apierr.Forbidden("Setup has already been completed"))
+		case errors.Is(err, db.ErrUsernameTaken):
+			apierr.Abort(c, apierr.Conflict("Username already exists"))
+		case errors.As(err, &weak):
+			apierr.Abort(c, apierr.InvalidField("password", "weak", weak.Reason, weak.Args...))
+		default:
+			apierr.Abort(c, apierr.Internal(err, "Failed to create administrator"))
+		}
+		return
+	}
+
+	setupToken = ""
+	user.ID = id
+	user.Role = models.RoleAdmin
+	user.Password = ""
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Administrator created successfully",
+		"user":    user,
+	})
+}
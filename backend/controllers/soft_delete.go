@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+
+	"to-mrz/apierr"
+	"to-mrz/db"
+	"to-mrz/middleware"
+	"to-mrz/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// deletedFilter reads the deleted query parameter, which switches a list to the
+// deleted rows. Only users who may delete such rows can see them. It reports false
+// after writing an error response.
+func deletedFilter(c *gin.Context, permission models.Permission) (deleted, ok bool) {
+	value := c.Query("deleted")
+	if value == "" {
+		return false, true
+	}
+	deleted, err := strconv.ParseBool(value)
+	if err != nil {
+		apierr.Abort(c, apierr.BadRequest("Invalid deleted filter"))
+		return false, false
+	}
+	if deleted && !middleware.HasPermission(c, permission) {
+		apierr.Abort(c, apierr.Forbidden("User does not have the required permission"))
+		return false, false
+	}
+	return deleted, true
+}
+
+// writeRestoreError maps errors from the restore functions to responses. notFound is
+// the message for rows that do not exist or are not deleted.
+func writeRestoreError(c *gin.Context, err error, notFound, message string) {
+	if errors.Is(err, sql.ErrNoRows) {
+		apierr.Abort(c, apierr.NotFound(notFound))
+		return
+	}
+	apierr.Abort(c, apierr.Internal(err, message))
+}
+
+// writePurgeError maps errors from the purge functions to responses. A purge blocked
+// by other rows lists them under blocked_by.
+func writePurgeError(c *gin.Context, err error, notFound, message string) {
+	var dependencies *db.DependencyError
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		apierr.Abort(c, apierr.NotFound(notFound))
+	case errors.Is(err, db.ErrNotDeleted):
+		apierr.Abort(c, apierr.Conflict("Only deleted records can be purged, delete it first"))
+	case errors.As(err, &dependencies):
+		apierr.Abort(c, apierr.Conflict("Other records still refer to it").With("blocked_by", dependencies.Dependencies))
+	default:
+		apierr.Abort(c, apierr.Internal(err, message))
+	}
+}
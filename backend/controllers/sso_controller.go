@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"net/http"
+	"sort"
+
+	"to-mrz/auth"
+	"to-mrz/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SSOLoginRequest contains the ticket (CAS) or authorization code (OIDC) the
+// sign-on server sent the browser back with
+type SSOLoginRequest struct {
+	Provider string `json:"provider" binding:"required"`
+	Ticket   string `json:"ticket" binding:"required"`
+}
+
+// SSOProvider describes a single sign-on button on the login page
+type SSOProvider struct {
+	Name     string `json:"name"`
+	Label    string `json:"label"`
+	LoginURL string `json:"login_url"`
+}
+
+// GetAuthProviders lists the single sign-on providers with their login URLs. The
+// client passes a random state, which OIDC providers echo back for it to check.
+func (s *Server) GetAuthProviders(c *gin.Context) {
+	state := c.Query("state")
+
+	providers := []SSOProvider{}
+	for name, backend := range auth.SSO {
+		loginURL, err := backend.LoginURL(c.Request.Context(), state)
+		if err != nil {
+			// Leave out providers that cannot be reached rather than failing the login page
+			utils.Errorf("building %s login URL: %v", name, err)
+			continue
+		}
+		providers = append(providers, SSOProvider{Name: name, Label: backend.Label(), LoginURL: loginURL})
+	}
+	sort.Slice(providers, func(i, j int) bool { return providers[i].Name < providers[j].Name })
+
+	c.JSON(http.StatusOK, gin.H{"sso": providers})
+}
+
+// LoginSSO signs a user in with a ticket from a single sign-on provider. Users
+// signing in for the first time get an account with a role mapped from their groups.
+func (s *Server) LoginSSO(c *gin.Context) {
+	var request SSOLoginRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	user, err := auth.LoginSSO(c.Request.Context(), s.Repositories, request.Provider, request.Ticket)
+	if err != nil {
+		s.rejectExternalLogin(c, nil, "", err)
+		return
+	}
+
+	s.continueLogin(c, user)
+}
@@ -0,0 +1,233 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"to-mrz/apierr"
+	"to-mrz/db"
+	"to-mrz/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TrainingPlanRequest contains the training plan request data
+type TrainingPlanRequest struct {
+	MajorID    uint                     `json:"major_id" binding:"required"`
+	CohortYear int                      `json:"cohort_year" binding:"required,gte=1900,lte=2200"`
+	Name       string                   `json:"name" binding:"required,max=100"`
+	MinCredits float64                  `json:"min_credits" binding:"gte=0"`
+	Groups     []models.PlanCourseGroup `json:"groups"`
+}
+
+// toTrainingPlan validates the course groups of the request and converts it to a
+// training plan, or returns the problems with its fields
+func (r *TrainingPlanRequest) toTrainingPlan() (*models.TrainingPlan, []apierr.FieldError) {
+	var problems []apierr.FieldError
+	for i, g := range r.Groups {
+		field := fmt.Sprintf("groups[%d]", i)
+		if g.Name == "" {
+			problems = append(problems, apierr.Field(field+".name", "required", "This field is required"))
+		}
+		switch g.Category {
+		case models.GroupCoreRequired, models.GroupMajorElective, models.GroupGeneralEducation, models.GroupPractice:
+		default:
+			problems = append(problems, apierr.Field(field+".category", apierr.FieldInvalid, "Invalid course group category: %s", g.Category))
+		}
+		if g.MinCredits < 0 {
+			problems = append(problems, apierr.Field(field+".min_credits", "gte", "Must be at least %s", "0"))
+		}
+		seen := map[uint]bool{}
+		for j, pc := range g.Courses {
+			if pc.CourseID == 0 || seen[pc.CourseID] {
+				problems = append(problems, apierr.Field(fmt.Sprintf("%s.courses[%d].course_id", field, j), apierr.FieldInvalid,
+					"Invalid or duplicate course in group %s", g.Name))
+			}
+			seen[pc.CourseID] = true
+		}
+	}
+	if len(problems) > 0 {
+		return nil, problems
+	}
+
+	return &models.TrainingPlan{
+		MajorID:    r.MajorID,
+		CohortYear: r.CohortYear,
+		Name:       r.Name,
+		MinCredits: r.MinCredits,
+		Groups:     r.Groups,
+	}, nil
+}
+
+// references lists the major and courses of the request, which must exist
+func (r *TrainingPlanRequest) references() []reference {
+	refs := []reference{{"major_id", "majors", r.MajorID}}
+	for i, g := range r.Groups {
+		for j, pc := range g.Courses {
+			refs = append(refs, reference{fmt.Sprintf("groups[%d].courses[%d].course_id", i, j), "courses", pc.CourseID})
+		}
+	}
+	return refs
+}
+
+// GetTrainingPlans returns a page of the training plans, optionally filtered by major
+// and cohort year and searched by plan or major with q
+func (s *Server) GetTrainingPlans(c *gin.Context) {
+	majorID, err := queryUint(c, "major_id")
+	if err != nil {
+		apierr.Abort(c, apierr.BadRequest("Invalid major ID"))
+		return
+	}
+
+	cohortYear, err := queryUint(c, "cohort_year")
+	if err != nil {
+		apierr.Abort(c, apierr.BadRequest("Invalid cohort year"))
+		return
+	}
+
+	filter := db.TrainingPlanFilter{MajorID: majorID, CohortYear: int(cohortYear)}
+	var ok bool
+	if filter.ListQuery, ok = listQuery(c); !ok {
+		return
+	}
+
+	page, err := s.TrainingPlans.List(filter)
+	writeList(c, page, err, "Failed to get training plans")
+}
+
+// GetTrainingPlan returns a training plan with its course groups
+func (s *Server) GetTrainingPlan(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierr.Abort(c, apierr.BadRequest("Invalid training plan ID"))
+		return
+	}
+
+	plan, err := s.TrainingPlans.Get(uint(id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			apierr.Abort(c, apierr.NotFound("Training plan not found"))
+			return
+		}
+		apierr.Abort(c, apierr.Internal(err, "Failed to get training plan"))
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+// CreateTrainingPlan creates a training plan for a major and cohort year
+func (s *Server) CreateTrainingPlan(c *gin.Context) {
+	var request TrainingPlanRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	plan, problems := request.toTrainingPlan()
+	if problems != nil {
+		apierr.Abort(c, apierr.Invalid(problems...))
+		return
+	}
+	if !s.checkReferences(c, request.references()...) {
+		return
+	}
+
+	id, err := s.TrainingPlans.Create(plan)
+	if err != nil {
+		apierr.Abort(c, apierr.Internal(err, "Failed to create training plan"))
+		return
+	}
+
+	created, err := s.TrainingPlans.Get(id)
+	if err != nil {
+		apierr.Abort(c, apierr.Internal(err, "Failed to get training plan"))
+		return
+	}
+
+	c.JSON(http.StatusCreated, created)
+}
+
+// UpdateTrainingPlan updates a training plan and replaces its course groups
+func (s *Server) UpdateTrainingPlan(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierr.Abort(c, apierr.BadRequest("Invalid training plan ID"))
+		return
+	}
+
+	var request TrainingPlanRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	plan, problems := request.toTrainingPlan()
+	if problems != nil {
+		apierr.Abort(c, apierr.Invalid(problems...))
+		return
+	}
+	if !s.checkReferences(c, request.references()...) {
+		return
+	}
+
+	plan.ID = uint(id)
+	if err := s.TrainingPlans.Update(plan); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			apierr.Abort(c, apierr.NotFound("Training plan not found"))
+			return
+		}
+		apierr.Abort(c, apierr.Internal(err, "Failed to update training plan"))
+		return
+	}
+
+	updated, err := s.TrainingPlans.Get(plan.ID)
+	if err != nil {
+		apierr.Abort(c, apierr.Internal(err, "Failed to get training plan"))
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+// DeleteTrainingPlan deletes a training plan
+func (s *Server) DeleteTrainingPlan(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierr.Abort(c, apierr.BadRequest("Invalid training plan ID"))
+		return
+	}
+
+	if err := s.TrainingPlans.Delete(uint(id)); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			apierr.Abort(c, apierr.NotFound("Training plan not found"))
+			return
+		}
+		apierr.Abort(c, apierr.Internal(err, "Failed to delete training plan"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Training plan deleted successfully"})
+}
+
+// GetDegreeAudit compares a student's passed and in-progress courses against their training plan
+func (s *Server) GetDegreeAudit(c *gin.Context) {
+	studentID, ok := requestStudentID(c)
+	if !ok {
+		apierr.Abort(c, apierr.BadRequest("Invalid student ID"))
+		return
+	}
+
+	audit, err := s.TrainingPlans.DegreeAudit(studentID)
+	if err != nil {
+		if errors.Is(err, db.ErrNoTrainingPlan) {
+			apierr.Abort(c, apierr.NotFound("No training plan found for the student's major and cohort"))
+			return
+		}
+		apierr.Abort(c, apierr.Internal(err, "Failed to run degree audit"))
+		return
+	}
+
+	c.JSON(http.StatusOK, audit)
+}
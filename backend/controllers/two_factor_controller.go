@@ -0,0 +1,330 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"to-mrz/apierr"
+	"to-mrz/db"
+	"to-mrz/models"
+	"to-mrz/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TwoFactorIssuer is the name authenticator apps show for this system's codes
+var TwoFactorIssuer = "University System"
+
+// clock returns the time TOTP codes are checked against; tests can replace it with a fixed clock
+var clock = time.Now
+
+const (
+	// loginChallengeExpiry is how long a user has to enter their code after the password
+	loginChallengeExpiry = 5 * time.Minute
+
+	// recoveryCodeCount is how many one-time recovery codes a user gets
+	recoveryCodeCount = 10
+)
+
+// errInvalidSecondFactor is returned by verifySecondFactor for a wrong, reused or missing code
+var errInvalidSecondFactor = errors.New("invalid two-factor code")
+
+// TwoFactorCodeRequest contains a TOTP code from the user's authenticator app
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// DisableTwoFactorRequest confirms turning off two-factor authentication with the
+// password and either a TOTP code or a recovery code
+type DisableTwoFactorRequest struct {
+	Password     string `json:"password"` // required for local accounts
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// TwoFactorSetupResponse contains a new TOTP secret to add to an authenticator app
+type TwoFactorSetupResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"otpauth_uri"` // render as a QR code
+}
+
+// GetTwoFactorStatus returns whether the current user has two-factor authentication
+// enabled, whether their role requires it and how many recovery codes are left
+func (s *Server) GetTwoFactorStatus(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	status, err := s.TwoFactor.Status(userID.(uint))
+	if err != nil {
+		apierr.Abort(c, apierr.Internal(err, "Failed to get two-factor status"))
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// SetupTwoFactor generates a new TOTP secret for the current user. It takes effect
+// once confirmed with EnableTwoFactor.
+func (s *Server) SetupTwoFactor(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	user, err := s.Users.Get(userID.(uint))
+	if err != nil {
+		apierr.Abort(c, apierr.Internal(err, "Failed to get user"))
+		return
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		apierr.Abort(c, apierr.Internal(err, "Failed to generate secret"))
+		return
+	}
+
+	if err := s.TwoFactor.StartSetup(user.ID, secret); err != nil {
+		if errors.Is(err, db.ErrTwoFactorEnabled) {
+			apierr.Abort(c, apierr.Conflict("Two-factor authentication is already enabled"))
+			return
+		}
+		apierr.Abort(c, apierr.Internal(err, "Failed to start two-factor setup"))
+		return
+	}
+
+	c.JSON(http.StatusOK, TwoFactorSetupResponse{
+		Secret:          secret,
+		ProvisioningURI: utils.TOTPProvisioningURI(TwoFactorIssuer, user.Username, secret),
+	})
+}
+
+// EnableTwoFactor turns on two-factor authentication after checking a code from the
+// newly set up authenticator, and returns the user's recovery codes. They are shown only once.
+func (s *Server) EnableTwoFactor(c *gin.Context) {
+	var request TwoFactorCodeRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	secret, lastStep, enabled, err := s.TwoFactor.Secret(userID.(uint))
+	if err != nil {
+		apierr.Abort(c, apierr.Internal(err, "Failed to get two-factor settings"))
+		return
+	}
+	switch {
+	case enabled:
+		apierr.Abort(c, apierr.Conflict("Two-factor authentication is already enabled"))
+		return
+	case secret == "":
+		apierr.Abort(c, apierr.Conflict("Start two-factor setup first"))
+		return
+	}
+
+	step, ok := utils.ValidateTOTP(secret, request.Code, clock(), lastStep)
+	if !ok {
+		apierr.Abort(c, apierr.BadRequest("Invalid two-factor code"))
+		return
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		apierr.Abort(c, apierr.Internal(err, "Failed to generate recovery codes"))
+		return
+	}
+
+	if err := s.TwoFactor.Enable(userID.(uint), step, hashes); err != nil {
+		if errors.Is(err, db.ErrTwoFactorNotStarted) {
+			apierr.Abort(c, apierr.Conflict("Start two-factor setup first"))
+			return
+		}
+		apierr.Abort(c, apierr.Internal(err, "Failed to enable two-factor authentication"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Two-factor authentication enabled",
+		"recovery_codes": codes,
+	})
+}
+
+// DisableTwoFactor turns off two-factor authentication for the current user, unless
+// their role requires it
+func (s *Server) DisableTwoFactor(c *gin.Context) {
+	var request DisableTwoFactorRequest
+	if !bindJSON(c, &request) || !checkSecondFactor(c, request.Code, request.RecoveryCode) {
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	required, err := s.TwoFactor.Required(userID.(uint))
+	if err != nil {
+		apierr.Abort(c, apierr.Internal(err, "Failed to get two-factor settings"))
+		return
+	}
+	if required {
+		apierr.Abort(c, apierr.Forbidden("Two-factor authentication is required for your role"))
+		return
+	}
+
+	user, err := s.Users.Get(userID.(uint))
+	if err != nil {
+		apierr.Abort(c, apierr.Internal(err, "Failed to get user"))
+		return
+	}
+	// External accounts have no local password to confirm; the code alone has to do
+	if user.AuthSource == models.AuthSourceLocal && !utils.CheckPasswordHash(request.Password, user.Password) {
+		apierr.Abort(c, apierr.BadRequest("Current password is incorrect"))
+		return
+	}
+
+	if err := s.verifySecondFactor(user.ID, request.Code, request.RecoveryCode); err != nil {
+		if errors.Is(err, errInvalidSecondFactor) {
+			apierr.Abort(c, apierr.BadRequest("Invalid two-factor code"))
+			return
+		}
+		apierr.Abort(c, apierr.Internal(err, "Failed to verify two-factor code"))
+		return
+	}
+
+	if err := s.TwoFactor.Disable(user.ID); err != nil {
+		apierr.Abort(c, apierr.Internal(err, "Failed to disable two-factor authentication"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes replaces the current user's recovery codes after checking a
+// code from their authenticator
+func (s *Server) RegenerateRecoveryCodes(c *gin.Context) {
+	var request TwoFactorCodeRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	if err := s.verifySecondFactor(userID.(uint), request.Code, ""); err != nil {
+		if errors.Is(err, errInvalidSecondFactor) {
+			apierr.Abort(c, apierr.BadRequest("Invalid two-factor code"))
+			return
+		}
+		apierr.Abort(c, apierr.Internal(err, "Failed to verify two-factor code"))
+		return
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		apierr.Abort(c, apierr.Internal(err, "Failed to generate recovery codes"))
+		return
+	}
+
+	if err := s.TwoFactor.ReplaceRecoveryCodes(userID.(uint), hashes); err != nil {
+		apierr.Abort(c, apierr.Internal(err, "Failed to save recovery codes"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// ResetUserTwoFactor turns off another user's two-factor authentication, for example
+// after they lost their phone and recovery codes. Users whose role requires two-factor
+// authentication must set it up again at their next login.
+func (s *Server) ResetUserTwoFactor(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierr.Abort(c, apierr.BadRequest("Invalid user ID"))
+		return
+	}
+
+	if !s.checkAdminChange(c, uint(id)) {
+		return
+	}
+
+	if err := s.TwoFactor.Disable(uint(id)); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			apierr.Abort(c, apierr.NotFound("User not found"))
+			return
+		}
+		apierr.Abort(c, apierr.Internal(err, "Failed to reset two-factor authentication"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication reset successfully"})
+}
+
+// verifySecondFactor checks a TOTP code or, if given instead, consumes a recovery code.
+// It returns errInvalidSecondFactor when the code is wrong or was already used.
+func (s *Server) verifySecondFactor(userID uint, code, recoveryCode string) error {
+	if recoveryCode != "" {
+		err := s.TwoFactor.UseRecoveryCode(userID, utils.HashToken(normalizeRecoveryCode(recoveryCode)))
+		if errors.Is(err, sql.ErrNoRows) {
+			return errInvalidSecondFactor
+		}
+		return err
+	}
+
+	secret, lastStep, enabled, err := s.TwoFactor.Secret(userID)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return errInvalidSecondFactor
+	}
+
+	step, ok := utils.ValidateTOTP(secret, code, clock(), lastStep)
+	if !ok {
+		return errInvalidSecondFactor
+	}
+	// Accept each code only once, even within its validity window
+	if err := s.TwoFactor.RecordStep(userID, step); err != nil {
+		if errors.Is(err, db.ErrTOTPCodeReused) {
+			return errInvalidSecondFactor
+		}
+		return err
+	}
+	return nil
+}
+
+// flagTwoFactorSetup sets TwoFactorSetupRequired on a user whose role requires
+// two-factor authentication but who has not enabled it yet
+func (s *Server) flagTwoFactorSetup(user *models.User) error {
+	if user.TwoFactorEnabled {
+		return nil
+	}
+	required, err := s.TwoFactor.Required(user.ID)
+	if err != nil {
+		return err
+	}
+	user.TwoFactorSetupRequired = required
+	return nil
+}
+
+// generateRecoveryCodes returns new recovery codes formatted for display, and their hashes for storage
+func generateRecoveryCodes() (codes, hashes []string, err error) {
+	for i := 0; i < recoveryCodeCount; i++ {
+		token, err := utils.RandomToken(5)
+		if err != nil {
+			return nil, nil, err
+		}
+		codes = append(codes, token[:5]+"-"+token[5:])
+		hashes = append(hashes, utils.HashToken(token))
+	}
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode strips the formatting users may type with a recovery code
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// checkSecondFactor checks that a request carries exactly one of a TOTP code and a
+// recovery code. It reports false after recording the error.
+func checkSecondFactor(c *gin.Context, code, recoveryCode string) bool {
+	if (code == "") == (recoveryCode == "") {
+		apierr.Abort(c, apierr.Invalid(apierr.Field("code", apierr.FieldInvalid, "Enter either a code or a recovery code")))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,561 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"to-mrz/apierr"
+	"to-mrz/db"
+	"to-mrz/models"
+	"to-mrz/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrPasswordReused is returned when a new password matches a recently used one
+var ErrPasswordReused = errors.New("password was used recently")
+
+// CreateUserRequest contains the data for a new account. A random password is
+// generated and returned when Password is empty.
+type CreateUserRequest struct {
+	Username     string      `json:"username" binding:"required,max=50"`
+	Password     string      `json:"password"`
+	Name         string      `json:"name" binding:"required,max=100"`
+	Role         models.Role `json:"role" binding:"required"`
+	Email        string      `json:"email" binding:"omitempty,email,max=100"`
+	Phone        string      `json:"phone" binding:"max=20"`
+	DepartmentID *uint       `json:"department_id"`
+}
+
+// ProfileRequest contains the editable profile fields
+type ProfileRequest struct {
+	Name  string `json:"name" binding:"required,max=100"`
+	Email string `json:"email" binding:"omitempty,email,max=100"`
+	Phone string `json:"phone" binding:"max=20"`
+}
+
+// UserRoleRequest contains a new primary role
+type UserRoleRequest struct {
+	Role         models.Role `json:"role" binding:"required"`
+	DepartmentID *uint       `json:"department_id"`
+}
+
+// UserStatusRequest enables or disables an account
+type UserStatusRequest struct {
+	Disabled *bool `json:"disabled" binding:"required"`
+}
+
+// ResetPasswordRequest contains an optional new password; a random one is generated when empty
+type ResetPasswordRequest struct {
+	Password string `json:"password"`
+}
+
+// ChangePasswordRequest contains the current and new password
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// CurrentUserResponse is the full record of the logged-in user with their roles and permissions
+type CurrentUserResponse struct {
+	*models.User
+	Roles       []models.Role       `json:"roles"`
+	Permissions []models.Permission `json:"permissions"`
+}
+
+// GetUsers returns a page of the users, searched by q and filtered by role,
+// department_id and disabled state. ?deleted=true lists the deleted users instead.
+func (s *Server) GetUsers(c *gin.Context) {
+	filter := db.UserFilter{Role: models.Role(c.Query("role"))}
+	var ok bool
+	if filter.ListQuery, ok = listQuery(c); !ok {
+		return
+	}
+	if filter.Deleted, ok = deletedFilter(c, models.PermUserManage); !ok {
+		return
+	}
+	var err error
+	if filter.DepartmentID, err = queryUint(c, "department_id"); err != nil {
+		apierr.Abort(c, apierr.BadRequest("Invalid department ID"))
+		return
+	}
+	if value := c.Query("disabled"); value != "" {
+		disabled, err := strconv.ParseBool(value)
+		if err != nil {
+			apierr.Abort(c, apierr.BadRequest("Invalid disabled filter"))
+			return
+		}
+		filter.Disabled = &disabled
+	}
+
+	page, err := s.Users.List(filter)
+	writeList(c, page, err, "Failed to get users")
+}
+
+// GetUser returns a user by ID
+func (s *Server) GetUser(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierr.Abort(c, apierr.BadRequest("Invalid user ID"))
+		return
+	}
+
+	user, err := s.Users.Get(uint(id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			apierr.Abort(c, apierr.NotFound("User not found"))
+			return
+		}
+		apierr.Abort(c, apierr.Internal(err, "Failed to get user"))
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// CreateUser creates a user account
+func (s *Server) CreateUser(c *gin.Context) {
+	var request CreateUserRequest
+	if !bindJSON(c, &request) || !s.checkReferences(c, reference{"department_id", "departments", optionalID(request.DepartmentID)}) {
+		return
+	}
+
+	if !db.ValidRole(request.Role) {
+		apierr.Abort(c, apierr.InvalidField("role", apierr.FieldInvalid, "Unknown role: %s", request.Role))
+		return
+	}
+	if !s.checkAdminChange(c, 0, request.Role) {
+		return
+	}
+
+	password, generated, err := chooseNewPassword(request.Password)
+	if err != nil {
+		var weak *utils.WeakPasswordError
+		if errors.As(err, &weak) {
+			apierr.Abort(c, apierr.InvalidField("password", "weak", weak.Reason, weak.Args...))
+			return
+		}
+		apierr.Abort(c, apierr.Internal(err, "Failed to generate password"))
+		return
+	}
+
+	hash, err := utils.HashPassword(password)
+	if err != nil {
+		apierr.Abort(c, apierr.Internal(err, "Failed to hash password"))
+		return
+	}
+
+	// The administrator knows the initial password, so the user must replace it
+	user := &models.User{
+		Username:           strings.TrimSpace(request.Username),
+		Password:           hash,
+		Name:               request.Name,
+		Role:               request.Role,
+		Email:              request.Email,
+		Phone:              request.Phone,
+		DepartmentID:       request.DepartmentID,
+		MustChangePassword: true,
+	}
+	id, err := s.Users.Create(user)
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrUsernameTaken):
+			apierr.Abort(c, apierr.Conflict("Username already exists"))
+		case errors.Is(err, db.ErrMissingDeptID):
+			apierr.Abort(c, apierr.BadRequest("Department admins must have a department"))
+		default:
+			apierr.Abort(c, apierr.Internal(err, "Failed to create user"))
+		}
+		return
+	}
+
+	created, err := s.Users.Get(id)
+	if err != nil {
+		apierr.Abort(c, apierr.Internal(err, "Failed to get user"))
+		return
+	}
+
+	response := gin.H{"user": created}
+	if generated {
+		response["password"] = password
+	}
+	c.JSON(http.StatusCreated, response)
+}
+
+// UpdateUser updates a user's profile fields
+func (s *Server) UpdateUser(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierr.Abort(c, apierr.BadRequest("Invalid user ID"))
+		return
+	}
+
+	if !s.checkAdminChange(c, uint(id)) {
+		return
+	}
+
+	s.updateProfile(c, uint(id))
+}
+
+// UpdateUserRole changes a user's primary role
+func (s *Server) UpdateUserRole(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierr.Abort(c, apierr.BadRequest("Invalid user ID"))
+		return
+	}
+
+	var request UserRoleRequest
+	if !bindJSON(c, &request) || !s.checkReferences(c, reference{"department_id", "departments", optionalID(request.DepartmentID)}) {
+		return
+	}
+
+	if !db.ValidRole(request.Role) {
+		apierr.Abort(c, apierr.InvalidField("role", apierr.FieldInvalid, "Unknown role: %s", request.Role))
+		return
+	}
+	if !s.checkAdminChange(c, uint(id), request.Role) {
+		return
+	}
+
+	if err := s.Users.UpdateRole(uint(id), request.Role, request.DepartmentID); err != nil {
+		writeUserUpdateError(c, err, "Failed to update user role")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User role updated successfully"})
+}
+
+// UpdateUserStatus disables or enables a user account
+func (s *Server) UpdateUserStatus(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierr.Abort(c, apierr.BadRequest("Invalid user ID"))
+		return
+	}
+
+	if !s.checkAdminChange(c, uint(id)) {
+		return
+	}
+
+	var request UserStatusRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	if *request.Disabled && uint(id) == userID.(uint) {
+		apierr.Abort(c, apierr.BadRequest("You cannot disable your own account"))
+		return
+	}
+
+	if err := s.Users.SetDisabled(uint(id), *request.Disabled); err != nil {
+		writeUserUpdateError(c, err, "Failed to update user status")
+		return
+	}
+
+	if *request.Disabled {
+		c.JSON(http.StatusOK, gin.H{"message": "User disabled successfully"})
+	} else {
+		c.JSON(http.StatusOK, gin.H{"message": "User enabled successfully"})
+	}
+}
+
+// DeleteUser deletes a user and logs them out everywhere. The account can be restored
+// until it is purged.
+func (s *Server) DeleteUser(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierr.Abort(c, apierr.BadRequest("Invalid user ID"))
+		return
+	}
+
+	if !s.checkAdminChange(c, uint(id)) {
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	if uint(id) == userID.(uint) {
+		apierr.Abort(c, apierr.BadRequest("You cannot delete your own account"))
+		return
+	}
+
+	if err := s.Users.Delete(uint(id)); err != nil {
+		writeUserUpdateError(c, err, "Failed to delete user")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
+}
+
+// RestoreUser restores a deleted user. Their sessions stay revoked.
+func (s *Server) RestoreUser(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierr.Abort(c, apierr.BadRequest("Invalid user ID"))
+		return
+	}
+
+	if err := s.Users.Restore(uint(id)); err != nil {
+		writeRestoreError(c, err, "Deleted user not found", "Failed to restore user")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User restored successfully"})
+}
+
+// PurgeUser removes a deleted user for good, unless other records still refer to them
+func (s *Server) PurgeUser(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierr.Abort(c, apierr.BadRequest("Invalid user ID"))
+		return
+	}
+
+	if err := s.Users.Purge(uint(id)); err != nil {
+		writePurgeError(c, err, "User not found", "Failed to purge user")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User purged successfully"})
+}
+
+// ResetUserPassword sets a new password for a user and logs them out everywhere.
+// The user must change it at their next login.
+func (s *Server) ResetUserPassword(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		apierr.Abort(c, apierr.BadRequest("Invalid user ID"))
+		return
+	}
+
+	if !s.checkAdminChange(c, uint(id)) {
+		return
+	}
+
+	var request ResetPasswordRequest
+	// The body is optional: without one a password is generated
+	if c.Request.ContentLength != 0 && !bindJSON(c, &request) {
+		return
+	}
+
+	user, err := s.Users.Get(uint(id))
+	if err != nil {
+		writeUserUpdateError(c, err, "Failed to get user")
+		return
+	}
+	if user.AuthSource != models.AuthSourceLocal {
+		apierr.Abort(c, apierr.Conflict("This account signs in through %s; reset the password there", user.AuthSource))
+		return
+	}
+
+	password, generated, err := chooseNewPassword(request.Password)
+	if err != nil {
+		var weak *utils.WeakPasswordError
+		if errors.As(err, &weak) {
+			apierr.Abort(c, apierr.InvalidField("password", "weak", weak.Reason, weak.Args...))
+			return
+		}
+		apierr.Abort(c, apierr.Internal(err, "Failed to generate password"))
+		return
+	}
+
+	hash, err := utils.HashPassword(password)
+	if err != nil {
+		apierr.Abort(c, apierr.Internal(err, "Failed to hash password"))
+		return
+	}
+
+	if err := s.Users.UpdatePassword(uint(id), hash, 0, true); err != nil {
+		writeUserUpdateError(c, err, "Failed to reset password")
+		return
+	}
+
+	response := gin.H{"message": "Password reset successfully"}
+	if generated {
+		response["password"] = password
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetCurrentUser returns the current user's full record with their roles and permissions
+func (s *Server) GetCurrentUser(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierr.Abort(c, apierr.Unauthorized("User not authenticated"))
+		return
+	}
+
+	user, err := s.Users.Get(userID.(uint))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			apierr.Abort(c, apierr.NotFound("User not found"))
+			return
+		}
+		apierr.Abort(c, apierr.Internal(err, "Failed to get user"))
+		return
+	}
+
+	roles, err := s.Roles.UserRoles(user.ID)
+	if err != nil {
+		apierr.Abort(c, apierr.Internal(err, "Failed to get user roles"))
+		return
+	}
+
+	if err := s.flagTwoFactorSetup(user); err != nil {
+		apierr.Abort(c, apierr.Internal(err, "Failed to get two-factor settings"))
+		return
+	}
+
+	granted, err := s.Roles.UserPermissions(user.ID)
+	if err != nil {
+		apierr.Abort(c, apierr.Internal(err, "Failed to get user permissions"))
+		return
+	}
+	permissions := make([]models.Permission, 0, len(granted))
+	for _, p := range models.AllPermissions {
+		if granted[p.Name] {
+			permissions = append(permissions, p.Name)
+		}
+	}
+
+	c.JSON(http.StatusOK, CurrentUserResponse{
+		User:        user,
+		Roles:       roles,
+		Permissions: permissions,
+	})
+}
+
+// UpdateProfile lets the current user edit their own name, email and phone
+func (s *Server) UpdateProfile(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	s.updateProfile(c, userID.(uint))
+}
+
+// ChangePassword lets the current user change their password. Other sessions are logged out.
+// This is the only route open to users who must change their password.
+func (s *Server) ChangePassword(c *gin.Context) {
+	var request ChangePasswordRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	if err := utils.CurrentPasswordPolicy.Validate(request.NewPassword); err != nil {
+		var weak *utils.WeakPasswordError
+		if errors.As(err, &weak) {
+			apierr.Abort(c, apierr.InvalidField("new_password", "weak", weak.Reason, weak.Args...))
+			return
+		}
+		apierr.Abort(c, apierr.Internal(err, "Failed to check password"))
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	sessionID, _ := c.Get("session_id")
+
+	user, err := s.Users.Get(userID.(uint))
+	if err != nil {
+		apierr.Abort(c, apierr.Internal(err, "Failed to get user"))
+		return
+	}
+	if user.AuthSource != models.AuthSourceLocal {
+		apierr.Abort(c, apierr.BadRequest("Your password is managed by %s; change it there", user.AuthSource))
+		return
+	}
+	if !utils.CheckPasswordHash(request.OldPassword, user.Password) {
+		apierr.Abort(c, apierr.BadRequest("Current password is incorrect"))
+		return
+	}
+
+	if err := s.checkPasswordHistory(user.ID, request.NewPassword); err != nil {
+		if errors.Is(err, ErrPasswordReused) {
+			apierr.Abort(c, apierr.InvalidField("new_password", "reused",
+				"Password must differ from your last %d passwords", utils.CurrentPasswordPolicy.History))
+			return
+		}
+		apierr.Abort(c, apierr.Internal(err, "Failed to check password history"))
+		return
+	}
+
+	hash, err := utils.HashPassword(request.NewPassword)
+	if err != nil {
+		apierr.Abort(c, apierr.Internal(err, "Failed to hash password"))
+		return
+	}
+
+	if err := s.Users.UpdatePassword(user.ID, hash, sessionID.(uint), false); err != nil {
+		apierr.Abort(c, apierr.Internal(err, "Failed to change password"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully"})
+}
+
+// updateProfile binds a ProfileRequest and saves it for the given user
+func (s *Server) updateProfile(c *gin.Context, id uint) {
+	var request ProfileRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+	if strings.TrimSpace(request.Name) == "" {
+		apierr.Abort(c, apierr.InvalidField("name", "required", "This field is required"))
+		return
+	}
+
+	if err := s.Users.UpdateProfile(id, strings.TrimSpace(request.Name), request.Email, request.Phone); err != nil {
+		writeUserUpdateError(c, err, "Failed to update user")
+		return
+	}
+
+	user, err := s.Users.Get(id)
+	if err != nil {
+		apierr.Abort(c, apierr.Internal(err, "Failed to get user"))
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// chooseNewPassword validates a requested password, or generates one when it is empty
+func chooseNewPassword(requested string) (password string, generated bool, err error) {
+	if requested == "" {
+		password, err = utils.CurrentPasswordPolicy.Generate()
+		return password, true, err
+	}
+	if err := utils.CurrentPasswordPolicy.Validate(requested); err != nil {
+		return "", false, err
+	}
+	return requested, false, nil
+}
+
+// checkPasswordHistory returns ErrPasswordReused if password matches one of the
+// user's recent passwords
+func (s *Server) checkPasswordHistory(userID uint, password string) error {
+	hashes, err := s.Users.RecentPasswordHashes(userID, utils.CurrentPasswordPolicy.History)
+	if err != nil {
+		return err
+	}
+	for _, hash := range hashes {
+		if utils.CheckPasswordHash(password, hash) {
+			return ErrPasswordReused
+		}
+	}
+	return nil
+}
+
+// writeUserUpdateError maps errors from the user update functions to responses
+func writeUserUpdateError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		apierr.Abort(c, apierr.NotFound("User not found"))
+	case errors.Is(err, db.ErrLastAdmin):
+		apierr.Abort(c, apierr.Conflict("Cannot remove the last active administrator"))
+	case errors.Is(err, db.ErrMissingDeptID):
+		apierr.Abort(c, apierr.BadRequest("Department admins must have a department"))
+	default:
+		apierr.Abort(c, apierr.Internal(err, message))
+	}
+}
@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+
+	"to-mrz/apierr"
+	"to-mrz/db"
+	"to-mrz/models"
+	"to-mrz/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxUserImportSize 账号导入文件大小上限
+const maxUserImportSize = 10 << 20
+
+// ImportUsers 批量导入学生或教师账号（.xlsx 或 .csv，role=student|teacher）
+// 所有行校验通过后在同一事务中创建；未填写初始密码的账号由系统生成密码并在结果中返回。
+// 导入的账号首次登录时必须修改密码。dry_run=true 时只返回预览
+func (s *Server) ImportUsers(c *gin.Context) {
+	role := models.Role(c.Query("role"))
+	if !db.CanImportUsers(role) {
+		apierr.Abort(c, apierr.BadRequest("Only student and teacher accounts can be imported"))
+		return
+	}
+	dryRun := c.Query("dry_run") == "true"
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		apierr.Abort(c, apierr.BadRequest("An account file is required"))
+		return
+	}
+	if fileHeader.Size > maxUserImportSize {
+		apierr.Abort(c, apierr.BadRequest("The account file is too large"))
+		return
+	}
+
+	format, err := utils.SheetFormat(fileHeader.Filename)
+	if err != nil {
+		apierr.Abort(c, apierr.BadRequest("Only .xlsx and .csv files are supported"))
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		apierr.Abort(c, apierr.BadRequest("Cannot read the account file"))
+		return
+	}
+	defer file.Close()
+
+	sheet, err := utils.ReadSheet(format, file)
+	if err != nil {
+		apierr.Abort(c, apierr.BadRequest("Cannot parse the account file"))
+		return
+	}
+
+	rows, problems, err := s.Users.ValidateImport(role, sheet)
+	if err != nil {
+		apierr.Abort(c, apierr.Internal(err, "Failed to check the account file"))
+		return
+	}
+
+	if len(problems) > 0 {
+		apierr.Abort(c, apierr.New(http.StatusUnprocessableEntity, apierr.CodeValidation, "The account file has errors").With("errors", problems))
+		return
+	}
+
+	if dryRun {
+		for i := range rows {
+			rows[i].Password = ""
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"message": "校验通过（预览，未写入）",
+			"dry_run": true,
+			"data":    rows,
+		})
+		return
+	}
+
+	for i := range rows {
+		password, generated, err := chooseNewPassword(rows[i].Password)
+		if err != nil {
+			apierr.Abort(c, apierr.Internal(err, "Failed to generate initial passwords"))
+			return
+		}
+		rows[i].PasswordHash, err = utils.HashPassword(password)
+		if err != nil {
+			apierr.Abort(c, apierr.Internal(err, "Failed to generate initial passwords"))
+			return
+		}
+
+		// Only generated passwords are returned; the others are already in the file
+		rows[i].Password = ""
+		if generated {
+			rows[i].Password = password
+		}
+	}
+
+	if err := s.Users.ApplyImport(role, rows); err != nil {
+		apierr.Abort(c, apierr.Internal(err, "Failed to import accounts"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "账号导入成功",
+		"imported": len(rows),
+		"data":     rows,
+	})
+}
+
+// GetUserImportTemplate 下载学生或教师账号导入模板
+func (s *Server) GetUserImportTemplate(c *gin.Context) {
+	role := models.Role(c.Query("role"))
+	if !db.CanImportUsers(role) {
+		apierr.Abort(c, apierr.BadRequest("Only student and teacher accounts can be imported"))
+		return
+	}
+
+	format := c.DefaultQuery("format", utils.FormatXLSX)
+	if format != utils.FormatXLSX && format != utils.FormatCSV {
+		apierr.Abort(c, apierr.BadRequest("Only the xlsx and csv formats are supported"))
+		return
+	}
+
+	filename := fmt.Sprintf("%s_import_template.%s", role, format)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Header("Content-Type", contentTypes[format])
+	if err := utils.WriteSheet(c.Writer, format, "账号", db.GetUserImportTemplate(role)); err != nil {
+		c.Status(http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,172 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+
+	"to-mrz/apierr"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+func init() {
+	// Name fields in validation errors as the client sends them
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				return ""
+			}
+			return name
+		})
+	}
+}
+
+// bindJSON decodes the JSON request body into request and checks its binding rules.
+// It reports false after recording the error, with the problem of each field for
+// requests that break the rules.
+func bindJSON(c *gin.Context, request interface{}) bool {
+	err := c.ShouldBindJSON(request)
+	if err == nil {
+		return true
+	}
+
+	var invalid validator.ValidationErrors
+	var mistyped *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &invalid):
+		details := make([]apierr.FieldError, len(invalid))
+		for i, fe := range invalid {
+			details[i] = ruleError(fe)
+		}
+		apierr.Abort(c, apierr.Invalid(details...))
+	case errors.As(err, &mistyped):
+		apierr.Abort(c, apierr.Invalid(apierr.Field(mistyped.Field, apierr.FieldInvalid, "Must be a %s", jsonType(mistyped.Type))))
+	case errors.Is(err, io.EOF):
+		apierr.Abort(c, apierr.BadRequest("Request body is required"))
+	default:
+		apierr.Abort(c, apierr.BadRequest("Invalid request format"))
+	}
+	return false
+}
+
+// ruleError describes a broken binding rule
+func ruleError(fe validator.FieldError) apierr.FieldError {
+	// The namespace starts with the name of the request type
+	field := fe.Namespace()
+	if _, rest, ok := strings.Cut(field, "."); ok {
+		field = rest
+	}
+
+	kind := fe.Kind()
+	if kind == reflect.Ptr {
+		kind = fe.Type().Elem().Kind()
+	}
+	var message string
+	switch fe.Tag() {
+	case "required":
+		message = "This field is required"
+	case "min", "gte":
+		message = boundMessage(kind, "Must be at least %s characters", "Must have at least %s items", "Must be at least %s")
+	case "max", "lte":
+		message = boundMessage(kind, "Must be at most %s characters", "Must have at most %s items", "Must be at most %s")
+	case "gt":
+		message = "Must be greater than %s"
+	case "lt":
+		message = "Must be less than %s"
+	case "oneof":
+		return apierr.Field(field, fe.Tag(), "Must be one of: %s", strings.ReplaceAll(fe.Param(), " ", ", "))
+	case "email":
+		message = "Must be a valid email address"
+	default:
+		return apierr.Field(field, fe.Tag(), "Is invalid")
+	}
+	if strings.Contains(message, "%s") {
+		return apierr.Field(field, fe.Tag(), message, fe.Param())
+	}
+	return apierr.Field(field, fe.Tag(), message)
+}
+
+// boundMessage picks the message of a min or max rule, which bounds the length of
+// strings and lists and the value of numbers
+func boundMessage(kind reflect.Kind, text, list, number string) string {
+	switch kind {
+	case reflect.String:
+		return text
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return list
+	}
+	return number
+}
+
+// jsonType names the JSON type a Go type is decoded from
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Slice, reflect.Array:
+		return "list"
+	case reflect.Struct, reflect.Map:
+		return "object"
+	}
+	return "number"
+}
+
+// reference is an ID in a request that must name an existing row of a table
+type reference struct {
+	field string
+	table string
+	id    uint
+}
+
+// referenceMessages are the field errors of references to missing rows, by table
+var referenceMessages = map[string]string{
+	"departments":      "Department does not exist",
+	"majors":           "Major does not exist",
+	"classes":          "Class does not exist",
+	"courses":          "Course does not exist",
+	"course_offerings": "Course offering does not exist",
+	"semesters":        "Semester does not exist",
+	"students":         "Student does not exist",
+	"teachers":         "Teacher does not exist",
+}
+
+// optionalID is the ID of an optional reference, 0 when there is none
+func optionalID(id *uint) uint {
+	if id == nil {
+		return 0
+	}
+	return *id
+}
+
+// checkReferences checks that the IDs of a request name existing rows. It reports
+// false after recording an error listing the fields that do not; zero IDs are left
+// to the binding rules.
+func (s *Server) checkReferences(c *gin.Context, refs ...reference) bool {
+	var details []apierr.FieldError
+	for _, ref := range refs {
+		if ref.id == 0 {
+			continue
+		}
+		exists, err := s.References.Exists(ref.table, ref.id)
+		if err != nil {
+			apierr.Abort(c, apierr.Internal(err, "Failed to check references"))
+			return false
+		}
+		if !exists {
+			details = append(details, apierr.Field(ref.field, apierr.FieldNotFound, referenceMessages[ref.table]))
+		}
+	}
+	if len(details) > 0 {
+		apierr.Abort(c, apierr.Invalid(details...))
+		return false
+	}
+	return true
+}
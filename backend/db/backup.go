@@ -0,0 +1,373 @@
+package db
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"to-mrz/models"
+	"to-mrz/utils"
+)
+
+// Backup settings, set from the configuration
+var (
+	BackupDir  = "./data/backups"
+	BackupKeep = 7 // newest backups kept when a new one is taken
+)
+
+// Backup errors
+var (
+	ErrBackupUnsupported = errors.New("backups are only supported for SQLite databases")
+	ErrBackupNotFound    = errors.New("backup not found")
+	ErrBackupCorrupt     = errors.New("backup failed its integrity check")
+)
+
+// backupFileName matches the names CreateBackup gives backups
+var backupFileName = regexp.MustCompile(`^backup-\d{8}-\d{6}(-\d+)?\.db$`)
+
+// backupMu keeps backups from being taken or pruned concurrently
+var backupMu sync.Mutex
+
+// CreateBackup writes a snapshot of the database to BackupDir with VACUUM INTO, which
+// does not take the server offline, and checks it before keeping it. Unless trigger is
+// models.BackupPreRestore, backups beyond the newest BackupKeep are deleted afterwards.
+func CreateBackup(trigger string) (*models.Backup, error) {
+	if DB.dialect != SQLite {
+		return nil, ErrBackupUnsupported
+	}
+
+	backupMu.Lock()
+	defer backupMu.Unlock()
+
+	if err := os.MkdirAll(BackupDir, 0o750); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	name := backupName(now)
+	path := filepath.Join(BackupDir, name)
+	// The snapshot is written under a temporary name so that a failed backup is never listed
+	tmp := path + ".tmp"
+	if err := os.Remove(tmp); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	defer os.Remove(tmp)
+
+	if _, err := DB.Exec("VACUUM INTO ?", tmp); err != nil {
+		return nil, err
+	}
+	version, err := verifyBackup(tmp)
+	if err != nil {
+		return nil, err
+	}
+	sum, size, err := fileSHA256(tmp)
+	if err != nil {
+		return nil, err
+	}
+
+	backup := &models.Backup{
+		Name:          name,
+		Size:          size,
+		SHA256:        sum,
+		SchemaVersion: version,
+		Trigger:       trigger,
+		CreatedAt:     now,
+	}
+	if err := writeBackupInfo(path, backup); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(backupInfoPath(path))
+		return nil, err
+	}
+
+	// A pre-restore backup must not prune the backup that is about to be restored
+	if trigger != models.BackupPreRestore {
+		if err := pruneBackups(); err != nil {
+			utils.Warnf("Failed to delete old backups: %v", err)
+		}
+	}
+	return backup, nil
+}
+
+// ListBackups returns the backups in BackupDir, newest first
+func ListBackups() ([]models.Backup, error) {
+	if DB.dialect != SQLite {
+		return nil, ErrBackupUnsupported
+	}
+	return listBackups()
+}
+
+// GetBackups returns a page of the backups in BackupDir, newest first
+func GetBackups(query ListQuery) (*models.Page, error) {
+	backups, err := ListBackups()
+	if err != nil {
+		return nil, err
+	}
+	return listSlice(query, backups, func(i int) string { return backups[i].Name })
+}
+
+// listBackups reads the backups in BackupDir, newest first
+func listBackups() ([]models.Backup, error) {
+	entries, err := os.ReadDir(BackupDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []models.Backup{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	backups := []models.Backup{}
+	for _, entry := range entries {
+		if entry.IsDir() || !backupFileName.MatchString(entry.Name()) {
+			continue
+		}
+		backup, err := readBackupInfo(filepath.Join(BackupDir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		backups = append(backups, *backup)
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].CreatedAt.Equal(backups[j].CreatedAt) {
+			return backups[i].CreatedAt.After(backups[j].CreatedAt)
+		}
+		return backups[i].Name > backups[j].Name
+	})
+	return backups, nil
+}
+
+// GetBackup returns a backup in BackupDir and the path of its file. Names other than
+// those CreateBackup gives are rejected, so the path never leaves BackupDir.
+func GetBackup(name string) (*models.Backup, string, error) {
+	if DB.dialect != SQLite {
+		return nil, "", ErrBackupUnsupported
+	}
+	if !backupFileName.MatchString(name) {
+		return nil, "", ErrBackupNotFound
+	}
+
+	path := filepath.Join(BackupDir, name)
+	backup, err := readBackupInfo(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, "", ErrBackupNotFound
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return backup, path, nil
+}
+
+// CheckBackup checks a backup file before it is restored: it must pass the integrity
+// check, match the checksum recorded next to it (if any) and have a schema this program
+// can run. Older schemas are fine; they are migrated when the database is next opened.
+func CheckBackup(path string) (*models.Backup, error) {
+	version, err := verifyBackup(path)
+	if err != nil {
+		return nil, err
+	}
+	backup, err := readBackupInfo(path)
+	if err != nil {
+		return nil, err
+	}
+	sum, size, err := fileSHA256(path)
+	if err != nil {
+		return nil, err
+	}
+	if backup.SHA256 != "" && backup.SHA256 != sum {
+		return nil, fmt.Errorf("%w: checksum does not match %s", ErrBackupCorrupt, backupInfoPath(path))
+	}
+	backup.SHA256, backup.Size, backup.SchemaVersion = sum, size, version
+	return backup, nil
+}
+
+// RestoreBackup replaces the SQLite database file at dbPath with a backup that passes
+// CheckBackup. The server must not be running while the file is replaced.
+func RestoreBackup(source, dbPath string) (*models.Backup, error) {
+	backup, err := CheckBackup(source)
+	if err != nil {
+		return nil, err
+	}
+
+	tmp := dbPath + ".restore"
+	defer os.Remove(tmp)
+	if err := copyFile(source, tmp); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, dbPath); err != nil {
+		return nil, err
+	}
+	// A journal left by the replaced database would be applied to the restored one
+	for _, suffix := range []string{"-journal", "-wal", "-shm"} {
+		if err := os.Remove(dbPath + suffix); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return backup, nil
+}
+
+// backupName returns a name for a backup taken at t that is not in use yet
+func backupName(t time.Time) string {
+	base := "backup-" + t.Format("20060102-150405")
+	name := base + ".db"
+	for i := 2; ; i++ {
+		if _, err := os.Stat(filepath.Join(BackupDir, name)); errors.Is(err, fs.ErrNotExist) {
+			return name
+		}
+		name = fmt.Sprintf("%s-%d.db", base, i)
+	}
+}
+
+// pruneBackups deletes the backups beyond the newest BackupKeep
+func pruneBackups() error {
+	backups, err := listBackups()
+	if err != nil {
+		return err
+	}
+	for i := BackupKeep; i < len(backups); i++ {
+		path := filepath.Join(BackupDir, backups[i].Name)
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		if err := os.Remove(backupInfoPath(path)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyBackup runs SQLite's integrity check on a backup file and checks that its
+// schema is one this program can run. It returns the schema version.
+func verifyBackup(path string) (int, error) {
+	if _, err := os.Stat(path); err != nil {
+		return 0, err
+	}
+	escaped := strings.NewReplacer("%", "%25", "?", "%3f", "#", "%23").Replace(path)
+	conn, err := sql.Open(SQLite.driverName(), "file:"+escaped+"?mode=ro")
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	rows, err := conn.Query("PRAGMA integrity_check")
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrBackupCorrupt, err)
+	}
+	defer rows.Close()
+	var problems []string
+	for rows.Next() {
+		var result string
+		if err := rows.Scan(&result); err != nil {
+			return 0, err
+		}
+		if result != "ok" {
+			problems = append(problems, result)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrBackupCorrupt, err)
+	}
+	if len(problems) > 0 {
+		return 0, fmt.Errorf("%w: %s", ErrBackupCorrupt, strings.Join(problems, "; "))
+	}
+
+	applied, err := readAppliedMigrations(conn)
+	if err != nil {
+		return 0, fmt.Errorf("not a database of this program: %w", err)
+	}
+	if err := checkApplied(applied); err != nil {
+		return 0, err
+	}
+	return maxVersion(applied), nil
+}
+
+// backupInfoPath returns the path of the file that records a backup's details
+func backupInfoPath(path string) string {
+	return path + ".json"
+}
+
+// writeBackupInfo records a backup's details next to its file
+func writeBackupInfo(path string, backup *models.Backup) error {
+	data, err := json.MarshalIndent(backup, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(backupInfoPath(path), data, 0o640)
+}
+
+// readBackupInfo returns the details of a backup file. Files copied in without their
+// details are described from the file alone.
+func readBackupInfo(path string) (*models.Backup, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	backup := &models.Backup{}
+	data, err := os.ReadFile(backupInfoPath(path))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		backup.CreatedAt = stat.ModTime().UTC()
+	case err != nil:
+		return nil, err
+	default:
+		if err := json.Unmarshal(data, backup); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", backupInfoPath(path), err)
+		}
+	}
+	backup.Name = filepath.Base(path)
+	backup.Size = stat.Size()
+	return backup, nil
+}
+
+// fileSHA256 returns the hex SHA-256 checksum and size of a file
+func fileSHA256(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+// copyFile copies src to dst and flushes it to disk
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o640)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
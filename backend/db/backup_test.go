@@ -0,0 +1,201 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"to-mrz/models"
+)
+
+// useBackupDir points BackupDir at a temporary directory and keeps the newest keep
+// backups until the test ends
+func useBackupDir(t *testing.T, keep int) {
+	t.Helper()
+	savedDir, savedKeep := BackupDir, BackupKeep
+	t.Cleanup(func() { BackupDir, BackupKeep = savedDir, savedKeep })
+	BackupDir, BackupKeep = t.TempDir(), keep
+}
+
+// createTestBackup takes a backup and returns it
+func createTestBackup(t *testing.T, trigger string) *models.Backup {
+	t.Helper()
+	backup, err := CreateBackup(trigger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return backup
+}
+
+// backupNames returns the names of the backups in BackupDir, newest first
+func backupNames(t *testing.T) []string {
+	t.Helper()
+	backups, err := ListBackups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, b := range backups {
+		names = append(names, b.Name)
+	}
+	return names
+}
+
+// copyBackup copies a backup file, without its details, to a new file and returns its path
+func copyBackup(t *testing.T, backup *models.Backup) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "copy.db")
+	if err := copyFile(filepath.Join(BackupDir, backup.Name), path); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// execOnFile runs a statement on a database file other than DB
+func execOnFile(t *testing.T, path, query string, args ...interface{}) {
+	t.Helper()
+	conn, err := sql.Open(SQLite.driverName(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Exec(query, args...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBackupRotation(t *testing.T) {
+	openTestDB(t)
+	useBackupDir(t, 3)
+
+	var created []string
+	for i := 0; i < 5; i++ {
+		created = append(created, createTestBackup(t, models.BackupScheduled).Name)
+	}
+	slices.Reverse(created)
+	if got := backupNames(t); !slices.Equal(got, created[:3]) {
+		t.Fatalf("backups %v, want the newest 3 of %v", got, created)
+	}
+
+	// the pre-restore backup keeps the backup about to be restored, the oldest one
+	preRestore := createTestBackup(t, models.BackupPreRestore)
+	if got, want := backupNames(t), append([]string{preRestore.Name}, created[:3]...); !slices.Equal(got, want) {
+		t.Errorf("after a pre-restore backup: backups %v, want %v", got, want)
+	}
+	if _, _, err := GetBackup(created[2]); err != nil {
+		t.Errorf("the backup being restored: %v", err)
+	}
+
+	// the next backup prunes again
+	next := createTestBackup(t, models.BackupManual)
+	kept := []string{next.Name, preRestore.Name, created[0]}
+	if got := backupNames(t); !slices.Equal(got, kept) {
+		t.Errorf("after the next backup: backups %v, want %v", got, kept)
+	}
+	// backups taken within a second may reuse the name of one pruned before
+	for _, name := range created[1:] {
+		if slices.Contains(kept, name) {
+			continue
+		}
+		for _, path := range []string{filepath.Join(BackupDir, name), backupInfoPath(filepath.Join(BackupDir, name))} {
+			if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("%s of a pruned backup: %v", path, err)
+			}
+		}
+	}
+}
+
+func TestCheckBackup(t *testing.T) {
+	openTestDB(t)
+	useBackupDir(t, 7)
+	backup := createTestBackup(t, models.BackupManual)
+
+	_, path, err := GetBackup(backup.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checked, err := CheckBackup(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checked.SHA256 != backup.SHA256 || checked.SchemaVersion != LatestVersion() || checked.Trigger != models.BackupManual {
+		t.Errorf("checked backup %+v, want the one taken %+v", checked, backup)
+	}
+
+	for _, name := range []string{"../test.db", "backup-20240101-000000.db", "notes.txt"} {
+		if _, _, err := GetBackup(name); !errors.Is(err, ErrBackupNotFound) {
+			t.Errorf("GetBackup(%q): %v, want ErrBackupNotFound", name, err)
+		}
+	}
+
+	// a backup changed after it was taken no longer matches its recorded checksum
+	execOnFile(t, path, "INSERT INTO departments (name, code) VALUES ('篡改学院', 'X')")
+	if _, err := CheckBackup(path); !errors.Is(err, ErrBackupCorrupt) || !strings.Contains(err.Error(), "checksum") {
+		t.Errorf("changed backup: %v, want a checksum ErrBackupCorrupt", err)
+	}
+
+	garbage := filepath.Join(t.TempDir(), "garbage.db")
+	if err := os.WriteFile(garbage, []byte(strings.Repeat("not a database ", 512)), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CheckBackup(garbage); !errors.Is(err, ErrBackupCorrupt) {
+		t.Errorf("garbage file: %v, want ErrBackupCorrupt", err)
+	}
+
+	// a backup of a newer version of the program cannot be run by this one
+	newer := copyBackup(t, backup)
+	execOnFile(t, newer, "INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (?, 'future', 'x', CURRENT_TIMESTAMP)",
+		LatestVersion()+1)
+	if _, err := CheckBackup(newer); !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("backup of a newer schema: %v, want ErrSchemaTooNew", err)
+	}
+}
+
+func TestRestoreBackup(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "school.db")
+	if err := InitDB(string(SQLite), dbPath); err != nil {
+		t.Fatal(err)
+	}
+	useBackupDir(t, 7)
+	backup := createTestBackup(t, models.BackupManual)
+	createTestUser(t, "after-backup", models.RoleStudent)
+	CloseDB()
+
+	// refused backups leave the database as it is
+	newer := copyBackup(t, backup)
+	execOnFile(t, newer, "INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (?, 'future', 'x', CURRENT_TIMESTAMP)",
+		LatestVersion()+1)
+	if _, err := RestoreBackup(newer, dbPath); !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("restoring a newer schema: %v, want ErrSchemaTooNew", err)
+	}
+	if _, err := RestoreBackup(filepath.Join(BackupDir, "missing.db"), dbPath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("restoring a missing file: %v, want os.ErrNotExist", err)
+	}
+
+	// a journal of the replaced database must not be applied to the restored one
+	if err := os.WriteFile(dbPath+"-journal", []byte("stale"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := RestoreBackup(filepath.Join(BackupDir, backup.Name), dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.SHA256 != backup.SHA256 {
+		t.Errorf("restored checksum %s, want %s", restored.SHA256, backup.SHA256)
+	}
+	if _, err := os.Stat(dbPath + "-journal"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("journal of the replaced database: %v", err)
+	}
+
+	if err := InitDB(string(SQLite), dbPath); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { CloseDB() })
+	if _, err := GetUserByUsername("after-backup"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("user created after the backup: %v, want sql.ErrNoRows", err)
+	}
+}
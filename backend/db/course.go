@@ -6,67 +6,92 @@ import (
 	"to-mrz/models"
 )
 
-// GetAllCourses retrieves all courses with their departments
-func GetAllCourses() ([]*models.Course, error) {
-	rows, err := DB.Query(`
-		SELECT c.id, c.name, c.code, c.credits, c.hours, c.type, c.department_id, c.description,
-		       c.created_at, c.updated_at, d.id, d.name, d.code, d.created_at, d.updated_at
-		FROM courses c
-		LEFT JOIN departments d ON c.department_id = d.id
-		ORDER BY c.id ASC
-	`)
-	if err != nil {
-		return nil, err
+// CourseFilter filters the course list; zero values match every course that is not deleted
+type CourseFilter struct {
+	ListQuery
+	DepartmentID uint
+	Type         string
+	MinCredits   *float64
+	MaxCredits   *float64
+	Deleted      bool // list the deleted courses instead
+}
+
+// courseList sorts and searches the course list
+var courseList = listSpec{
+	sorts: map[string]string{
+		"id":         "c.id",
+		"name":       "c.name",
+		"code":       "c.code",
+		"credits":    "c.credits",
+		"hours":      "c.hours",
+		"type":       "c.type",
+		"created_at": "c.created_at",
+	},
+	order:  []string{"id"},
+	key:    "c.id",
+	search: []string{"c.name", "c.code", "c.description"},
+}
+
+// GetCourses returns a page of the courses matching the filter, with their departments
+func GetCourses(filter CourseFilter) (*models.Page, error) {
+	q := &selectQuery{
+		columns: `c.id, c.name, c.code, c.credits, c.hours, c.type, c.department_id, c.description,
+			c.created_at, c.updated_at, c.deleted_at, d.id, d.name, d.code, d.created_at, d.updated_at`,
+		from: "courses c LEFT JOIN departments d ON c.department_id = d.id",
+	}
+	q.filter(deletedFilter("c.deleted_at", filter.Deleted))
+	if filter.DepartmentID != 0 {
+		q.filter("c.department_id = ?", filter.DepartmentID)
+	}
+	if filter.Type != "" {
+		q.filter("c.type = ?", filter.Type)
+	}
+	if filter.MinCredits != nil {
+		q.filter("c.credits >= ?", *filter.MinCredits)
+	}
+	if filter.MaxCredits != nil {
+		q.filter("c.credits <= ?", *filter.MaxCredits)
 	}
-	defer rows.Close()
 
-	var courses []*models.Course
-	for rows.Next() {
+	courses := []*models.Course{}
+	page, err := courseList.list(filter.ListQuery, q, func(row rowScanner) error {
 		var course models.Course
 		var department models.Department
-		var createdAt, updatedAt, deptCreatedAt, deptUpdatedAt string
-
-		err := rows.Scan(
+		err := row.Scan(
 			&course.ID, &course.Name, &course.Code, &course.Credits, &course.Hours,
-			&course.Type, &course.DepartmentID, &course.Description, &createdAt, &updatedAt,
-			&department.ID, &department.Name, &department.Code, &deptCreatedAt, &deptUpdatedAt,
+			&course.Type, &course.DepartmentID, &course.Description, timestamp{&course.CreatedAt}, timestamp{&course.UpdatedAt}, deletedAt{&course.DeletedAt},
+			&department.ID, &department.Name, &department.Code, timestamp{&department.CreatedAt}, timestamp{&department.UpdatedAt},
 		)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
-		course.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
-		course.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
-		department.CreatedAt, _ = time.Parse(time.RFC3339, deptCreatedAt)
-		department.UpdatedAt, _ = time.Parse(time.RFC3339, deptUpdatedAt)
 		course.Department = department
 
 		courses = append(courses, &course)
-	}
-
-	if err = rows.Err(); err != nil {
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
-
-	return courses, nil
+	page.Items = courses
+	return page, nil
 }
 
-// GetCourseByID retrieves a course by ID
+// GetCourseByID retrieves a course by ID. Deleted courses are not found.
 func GetCourseByID(id uint) (*models.Course, error) {
 	var course models.Course
 	var department models.Department
-	var createdAt, updatedAt, deptCreatedAt, deptUpdatedAt string
-
 	err := DB.QueryRow(`
 		SELECT c.id, c.name, c.code, c.credits, c.hours, c.type, c.department_id, c.description,
 		       c.created_at, c.updated_at, d.id, d.name, d.code, d.created_at, d.updated_at
 		FROM courses c
 		LEFT JOIN departments d ON c.department_id = d.id
-		WHERE c.id = ?
+		WHERE c.id = ? AND c.deleted_at IS NULL
 	`, id).Scan(
 		&course.ID, &course.Name, &course.Code, &course.Credits, &course.Hours,
-		&course.Type, &course.DepartmentID, &course.Description, &createdAt, &updatedAt,
-		&department.ID, &department.Name, &department.Code, &deptCreatedAt, &deptUpdatedAt,
+		&course.Type, &course.DepartmentID, &course.Description, timestamp{&course.CreatedAt}, timestamp{&course.UpdatedAt},
+		&department.ID, &department.Name, &department.Code, timestamp{&department.CreatedAt}, timestamp{&department.UpdatedAt},
 	)
 
 	if err != nil {
@@ -76,10 +101,6 @@ func GetCourseByID(id uint) (*models.Course, error) {
 		return nil, err
 	}
 
-	course.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
-	course.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
-	department.CreatedAt, _ = time.Parse(time.RFC3339, deptCreatedAt)
-	department.UpdatedAt, _ = time.Parse(time.RFC3339, deptUpdatedAt)
 	course.Department = department
 
 	return &course, nil
@@ -87,39 +108,72 @@ func GetCourseByID(id uint) (*models.Course, error) {
 
 // CreateCourse creates a new course
 func CreateCourse(course *models.Course) (uint, error) {
-	now := time.Now().Format(time.RFC3339)
+	now := time.Now()
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
 
-	result, err := DB.Exec(`
+	id, err := insertID(tx, `
 		INSERT INTO courses (name, code, credits, hours, type, department_id, description, created_at, updated_at)
 		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`, course.Name, course.Code, course.Credits, course.Hours, course.Type, course.DepartmentID, course.Description, now, now)
 	if err != nil {
 		return 0, err
 	}
-
-	id, err := result.LastInsertId()
-	if err != nil {
+	if err := syncSearchIndex(tx, "courses", id); err != nil {
 		return 0, err
 	}
 
-	return uint(id), nil
+	return id, tx.Commit()
 }
 
-// UpdateCourse updates an existing course
+// UpdateCourse updates an existing course. It returns sql.ErrNoRows if the course
+// does not exist or has been deleted.
 func UpdateCourse(course *models.Course) error {
-	now := time.Now().Format(time.RFC3339)
+	now := time.Now()
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
-	_, err := DB.Exec(`
+	result, err := tx.Exec(`
 		UPDATE courses
 		SET name = ?, code = ?, credits = ?, hours = ?, type = ?, department_id = ?, description = ?, updated_at = ?
-		WHERE id = ?
+		WHERE id = ? AND deleted_at IS NULL
 	`, course.Name, course.Code, course.Credits, course.Hours, course.Type, course.DepartmentID, course.Description, now, course.ID)
+	if err != nil {
+		return err
+	}
+	if err := requireRowAffected(result); err != nil {
+		return err
+	}
+	if err := syncSearchIndex(tx, "courses", course.ID); err != nil {
+		return err
+	}
 
-	return err
+	return tx.Commit()
 }
 
-// DeleteCourse deletes a course
+// DeleteCourse marks a course deleted. Its offerings, prerequisites and the training
+// plans listing it are kept. It returns sql.ErrNoRows if the course does not exist.
 func DeleteCourse(id uint) error {
-	_, err := DB.Exec("DELETE FROM courses WHERE id = ?", id)
-	return err
+	return softDelete(DB, "courses", id)
+}
+
+// RestoreCourse undoes DeleteCourse. It returns sql.ErrNoRows if there is no such
+// deleted course.
+func RestoreCourse(id uint) error {
+	return restoreDeleted("courses", id)
+}
+
+// PurgeCourse removes a deleted course and its own prerequisite list for good. It
+// returns ErrNotDeleted for courses that are not deleted and a *DependencyError while
+// offerings, training plans or other courses' prerequisites still refer to it.
+func PurgeCourse(id uint) error {
+	return purge("courses", id)
 }
@@ -0,0 +1,98 @@
+package db
+
+import (
+	"to-mrz/models"
+)
+
+// CourseOfferingFilter filters the course offering list; zero values match every
+// offering that is not deleted
+type CourseOfferingFilter struct {
+	ListQuery
+	SemesterID uint
+	CourseID   uint
+	TeacherID  uint
+	Status     string
+	Deleted    bool // list the deleted offerings instead
+}
+
+// courseOfferingList sorts and searches the course offering list
+var courseOfferingList = listSpec{
+	sorts: map[string]string{
+		"id":          "co.id",
+		"course_code": "c.code",
+		"course_name": "c.name",
+		"capacity":    "co.capacity",
+		"created_at":  "co.created_at",
+	},
+	order:  []string{"id"},
+	key:    "co.id",
+	search: []string{"c.name", "c.code", "co.location"},
+}
+
+// GetCourseOfferings returns a page of the course offerings matching the filter, with
+// their courses, semesters and teachers
+func GetCourseOfferings(filter CourseOfferingFilter) (*models.Page, error) {
+	q := &selectQuery{
+		columns: `co.id, co.course_id, co.semester_id, co.teacher_id, co.capacity, COALESCE(co.location, ''),
+			COALESCE(co.schedule, ''), co.status, COALESCE(co.description, ''),
+			co.created_at, co.updated_at, co.deleted_at,
+			c.id, c.name, c.code, c.department_id, sem.id, sem.name,
+			t.id, u.id, u.name, u.role`,
+		from: `course_offerings co JOIN courses c ON co.course_id = c.id JOIN semesters sem ON co.semester_id = sem.id
+			JOIN teachers t ON co.teacher_id = t.id JOIN users u ON t.user_id = u.id`,
+	}
+	q.filter(deletedFilter("co.deleted_at", filter.Deleted))
+	if filter.SemesterID != 0 {
+		q.filter("co.semester_id = ?", filter.SemesterID)
+	}
+	if filter.CourseID != 0 {
+		q.filter("co.course_id = ?", filter.CourseID)
+	}
+	if filter.TeacherID != 0 {
+		q.filter("co.teacher_id = ?", filter.TeacherID)
+	}
+	if filter.Status != "" {
+		q.filter("co.status = ?", filter.Status)
+	}
+
+	offerings := []models.CourseOffering{}
+	page, err := courseOfferingList.list(filter.ListQuery, q, func(row rowScanner) error {
+		var co models.CourseOffering
+		err := row.Scan(
+			&co.ID, &co.CourseID, &co.SemesterID, &co.TeacherID, &co.Capacity, &co.Location,
+			&co.Schedule, &co.Status, &co.Description,
+			timestamp{&co.CreatedAt}, timestamp{&co.UpdatedAt}, deletedAt{&co.DeletedAt},
+			&co.Course.ID, &co.Course.Name, &co.Course.Code, &co.Course.DepartmentID, &co.Semester.ID, &co.Semester.Name,
+			&co.Teacher.ID, &co.Teacher.User.ID, &co.Teacher.User.Name, &co.Teacher.User.Role,
+		)
+		if err != nil {
+			return err
+		}
+		offerings = append(offerings, co)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	page.Items = offerings
+	return page, nil
+}
+
+// DeleteCourseOffering marks a course offering deleted. Its enrollments and grades
+// are kept. It returns sql.ErrNoRows if the offering does not exist.
+func DeleteCourseOffering(id uint) error {
+	return softDelete(DB, "course_offerings", id)
+}
+
+// RestoreCourseOffering undoes DeleteCourseOffering. It returns sql.ErrNoRows if
+// there is no such deleted offering.
+func RestoreCourseOffering(id uint) error {
+	return restoreDeleted("course_offerings", id)
+}
+
+// PurgeCourseOffering removes a deleted course offering for good. It returns
+// ErrNotDeleted for offerings that are not deleted and a *DependencyError while
+// enrollments, grade components, evaluations or textbook applications still refer to it.
+func PurgeCourseOffering(id uint) error {
+	return purge("course_offerings", id)
+}
@@ -2,47 +2,70 @@ package db
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
+	"time"
 
 	"to-mrz/models"
-
-	_ "github.com/mattn/go-sqlite3"
+	"to-mrz/utils"
 )
 
-var DB *sql.DB
+var DB *Database
 
-// InitDB initializes the database connection and creates the necessary tables
-func InitDB() error {
-	// Make sure the data directory exists
-	dbDir := "./data"
-	if _, err := os.Stat(dbDir); os.IsNotExist(err) {
-		err := os.MkdirAll(dbDir, 0755)
-		if err != nil {
-			return fmt.Errorf("failed to create data directory: %w", err)
+// ErrAdminExists is returned when bootstrapping an administrator after one already exists
+var ErrAdminExists = errors.New("an administrator already exists")
+
+// InitDB opens the database and brings its schema up to date
+func InitDB(driver, source string) error {
+	if err := OpenDB(driver, source); err != nil {
+		return err
+	}
+
+	if err := prepareSchema(); err != nil {
+		return fmt.Errorf("failed to prepare schema: %w", err)
+	}
+
+	return nil
+}
+
+// OpenDB opens the database connection without touching the schema. driver is one of
+// the dialects; source is the database file for SQLite and the data source name for
+// the others.
+func OpenDB(driver, source string) error {
+	dialect, err := parseDialect(driver)
+	if err != nil {
+		return err
+	}
+
+	if dialect == SQLite {
+		// Make sure the data directory exists
+		dbDir := filepath.Dir(source)
+		if _, err := os.Stat(dbDir); os.IsNotExist(err) {
+			err := os.MkdirAll(dbDir, 0755)
+			if err != nil {
+				return fmt.Errorf("failed to create data directory: %w", err)
+			}
 		}
 	}
 
-	dbPath := filepath.Join(dbDir, "university.db")
-	var err error
-	DB, err = sql.Open("sqlite3", dbPath)
+	dsn, err := dialect.dataSource(source)
+	if err != nil {
+		return err
+	}
+	conn, err := sql.Open(dialect.driverName(), dsn)
 	if err != nil {
 		return fmt.Errorf("failed to open database: %w", err)
 	}
+	DB = &Database{db: conn, dialect: dialect}
 
 	// Test the connection
 	if err = DB.Ping(); err != nil {
 		return fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	log.Println("Connected to database successfully")
-
-	// Create tables
-	if err = createTables(); err != nil {
-		return fmt.Errorf("failed to create tables: %w", err)
-	}
+	utils.Infof("Connected to %s database successfully", dialect)
 
 	return nil
 }
@@ -55,339 +78,89 @@ func CloseDB() error {
 	return nil
 }
 
-// createTables creates all the necessary tables in the database
-func createTables() error {
-	// Users table
-	_, err := DB.Exec(`
-	CREATE TABLE IF NOT EXISTS users (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		username TEXT UNIQUE NOT NULL,
-		password TEXT NOT NULL,
-		name TEXT NOT NULL,
-		role TEXT NOT NULL,
-		email TEXT,
-		phone TEXT,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-	)`)
-	if err != nil {
-		return err
-	}
-
-	// Departments table
-	_, err = DB.Exec(`
-	CREATE TABLE IF NOT EXISTS departments (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		name TEXT UNIQUE NOT NULL,
-		code TEXT UNIQUE NOT NULL,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-	)`)
-	if err != nil {
-		return err
-	}
-
-	// Majors table
-	_, err = DB.Exec(`
-	CREATE TABLE IF NOT EXISTS majors (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		name TEXT NOT NULL,
-		code TEXT UNIQUE NOT NULL,
-		department_id INTEGER NOT NULL,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (department_id) REFERENCES departments(id)
-	)`)
-	if err != nil {
+// upgradeLegacySchema adds the columns that databases created before versioned
+// migrations may lack. Migrations after the baseline change the schema themselves.
+func upgradeLegacySchema(tx *Tx) error {
+	// Databases created before accounts could be disabled lack the disabled flag
+	if err := ensureColumn(tx, "users", "disabled", "BOOLEAN NOT NULL DEFAULT 0"); err != nil {
 		return err
 	}
 
-	// Classes table
-	_, err = DB.Exec(`
-	CREATE TABLE IF NOT EXISTS classes (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		name TEXT NOT NULL,
-		code TEXT UNIQUE NOT NULL,
-		major_id INTEGER NOT NULL,
-		year INTEGER NOT NULL,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (major_id) REFERENCES majors(id)
-	)`)
-	if err != nil {
+	// Databases created before data scoping lack the department admin's department
+	if err := ensureColumn(tx, "users", "department_id", "INTEGER REFERENCES departments(id)"); err != nil {
 		return err
 	}
 
-	// Teachers table
-	_, err = DB.Exec(`
-	CREATE TABLE IF NOT EXISTS teachers (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		user_id INTEGER UNIQUE NOT NULL,
-		department_id INTEGER NOT NULL,
-		title TEXT,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (user_id) REFERENCES users(id),
-		FOREIGN KEY (department_id) REFERENCES departments(id)
-	)`)
-	if err != nil {
+	// Databases created before the password policy lack the password change tracking
+	if err := ensureColumn(tx, "users", "must_change_password", "BOOLEAN NOT NULL DEFAULT 0"); err != nil {
 		return err
 	}
-
-	// Students table
-	_, err = DB.Exec(`
-	CREATE TABLE IF NOT EXISTS students (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		user_id INTEGER UNIQUE NOT NULL,
-		student_id TEXT UNIQUE NOT NULL,
-		class_id INTEGER NOT NULL,
-		enroll_year INTEGER NOT NULL,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (user_id) REFERENCES users(id),
-		FOREIGN KEY (class_id) REFERENCES classes(id)
-	)`)
-	if err != nil {
+	if err := ensureColumn(tx, "users", "password_changed_at", "TIMESTAMP"); err != nil {
 		return err
 	}
 
-	// Courses table
-	_, err = DB.Exec(`
-	CREATE TABLE IF NOT EXISTS courses (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		name TEXT NOT NULL,
-		code TEXT UNIQUE NOT NULL,
-		credits REAL NOT NULL,
-		hours INTEGER NOT NULL,
-		type TEXT NOT NULL,
-		department_id INTEGER NOT NULL,
-		description TEXT,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (department_id) REFERENCES departments(id)
-	)`)
-	if err != nil {
+	// Databases created before two-factor authentication lack the TOTP columns
+	if err := ensureColumn(tx, "users", "totp_secret", "TEXT"); err != nil {
 		return err
 	}
-
-	// Course Prerequisites table
-	_, err = DB.Exec(`
-	CREATE TABLE IF NOT EXISTS course_prerequisites (
-		course_id INTEGER NOT NULL,
-		prerequisite_id INTEGER NOT NULL,
-		PRIMARY KEY (course_id, prerequisite_id),
-		FOREIGN KEY (course_id) REFERENCES courses(id),
-		FOREIGN KEY (prerequisite_id) REFERENCES courses(id)
-	)`)
-	if err != nil {
+	if err := ensureColumn(tx, "users", "totp_enabled", "BOOLEAN NOT NULL DEFAULT 0"); err != nil {
 		return err
 	}
-
-	// Semesters table
-	_, err = DB.Exec(`
-	CREATE TABLE IF NOT EXISTS semesters (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		name TEXT NOT NULL,
-		start_date TIMESTAMP NOT NULL,
-		end_date TIMESTAMP NOT NULL,
-		current BOOLEAN DEFAULT 0,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-	)`)
-	if err != nil {
+	if err := ensureColumn(tx, "users", "totp_last_step", "INTEGER NOT NULL DEFAULT 0"); err != nil {
 		return err
 	}
 
-	// Course Offerings table
-	_, err = DB.Exec(`
-	CREATE TABLE IF NOT EXISTS course_offerings (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		course_id INTEGER NOT NULL,
-		semester_id INTEGER NOT NULL,
-		teacher_id INTEGER NOT NULL,
-		capacity INTEGER NOT NULL,
-		location TEXT,
-		schedule TEXT,
-		status TEXT NOT NULL,
-		description TEXT,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (course_id) REFERENCES courses(id),
-		FOREIGN KEY (semester_id) REFERENCES semesters(id),
-		FOREIGN KEY (teacher_id) REFERENCES teachers(id)
-	)`)
-	if err != nil {
-		return err
-	}
-
-	// Enrollments table
-	_, err = DB.Exec(`
-	CREATE TABLE IF NOT EXISTS enrollments (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		student_id INTEGER NOT NULL,
-		course_offering_id INTEGER NOT NULL,
-		grade REAL,
-		status TEXT NOT NULL,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (student_id) REFERENCES students(id),
-		FOREIGN KEY (course_offering_id) REFERENCES course_offerings(id)
-	)`)
-	if err != nil {
-		return err
-	}
-
-	// Grade Components table
-	_, err = DB.Exec(`
-	CREATE TABLE IF NOT EXISTS grade_components (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		course_offering_id INTEGER NOT NULL,
-		name TEXT NOT NULL,
-		weight REAL NOT NULL,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (course_offering_id) REFERENCES course_offerings(id)
-	)`)
-	if err != nil {
+	// Accounts created before external sign-in all use local passwords
+	if err := ensureColumn(tx, "users", "auth_source", "TEXT NOT NULL DEFAULT 'local'"); err != nil {
 		return err
 	}
 
-	// Grade Details table
-	_, err = DB.Exec(`
-	CREATE TABLE IF NOT EXISTS grade_details (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		enrollment_id INTEGER NOT NULL,
-		grade_component_id INTEGER NOT NULL,
-		score REAL NOT NULL,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (enrollment_id) REFERENCES enrollments(id),
-		FOREIGN KEY (grade_component_id) REFERENCES grade_components(id),
-		UNIQUE(enrollment_id, grade_component_id)
-	)`)
-	if err != nil {
+	// Databases created before graduation processing lack the student status
+	if err := ensureColumn(tx, "students", "status", "TEXT NOT NULL DEFAULT '在读'"); err != nil {
 		return err
 	}
 
-	// Evaluations table
-	_, err = DB.Exec(`
-	CREATE TABLE IF NOT EXISTS evaluations (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		course_offering_id INTEGER NOT NULL,
-		student_id INTEGER NOT NULL,
-		rating INTEGER NOT NULL,
-		comment TEXT,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (course_offering_id) REFERENCES course_offerings(id),
-		FOREIGN KEY (student_id) REFERENCES students(id)
-	)`)
-	if err != nil {
+	// Databases created before retakes were supported lack retake_of_id
+	if err := ensureColumn(tx, "enrollments", "retake_of_id", "INTEGER REFERENCES enrollments(id)"); err != nil {
 		return err
 	}
 
-	// Textbooks table
-	_, err = DB.Exec(`
-	CREATE TABLE IF NOT EXISTS textbooks (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		title TEXT NOT NULL,
-		author TEXT,
-		publisher TEXT,
-		isbn TEXT UNIQUE,
-		price REAL,
-		description TEXT,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-	)`)
-	if err != nil {
-		return err
-	}
+	return nil
+}
 
-	// Textbook Applications table
-	_, err = DB.Exec(`
-	CREATE TABLE IF NOT EXISTS textbook_applications (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		course_offering_id INTEGER NOT NULL,
-		textbook_id INTEGER NOT NULL,
-		quantity INTEGER NOT NULL,
-		status TEXT NOT NULL,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (course_offering_id) REFERENCES course_offerings(id),
-		FOREIGN KEY (textbook_id) REFERENCES textbooks(id)
-	)`)
+// ensureColumn adds a column to an existing table if it is missing
+func ensureColumn(tx *Tx, table, column, definition string) error {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
 	if err != nil {
 		return err
 	}
+	defer rows.Close()
 
-	// Internships table
-	_, err = DB.Exec(`
-	CREATE TABLE IF NOT EXISTS internships (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		student_id INTEGER NOT NULL,
-		company TEXT NOT NULL,
-		position TEXT NOT NULL,
-		start_date TIMESTAMP NOT NULL,
-		end_date TIMESTAMP NOT NULL,
-		supervisor TEXT,
-		teacher_id INTEGER NOT NULL,
-		status TEXT NOT NULL,
-		report TEXT,
-		grade REAL,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (student_id) REFERENCES students(id),
-		FOREIGN KEY (teacher_id) REFERENCES teachers(id)
-	)`)
-	if err != nil {
-		return err
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
 	}
-
-	// Theses table
-	_, err = DB.Exec(`
-	CREATE TABLE IF NOT EXISTS theses (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		student_id INTEGER NOT NULL,
-		teacher_id INTEGER NOT NULL,
-		title TEXT NOT NULL,
-		abstract TEXT,
-		status TEXT NOT NULL,
-		grade REAL,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (student_id) REFERENCES students(id),
-		FOREIGN KEY (teacher_id) REFERENCES teachers(id)
-	)`)
-	if err != nil {
+	if err := rows.Err(); err != nil {
 		return err
 	}
 
-	return nil
+	_, err = tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
 }
 
 // SeedDB seeds the database with sample data
 func SeedDB() error {
-	// Check if admin exists
 	var count int
-	err := DB.QueryRow("SELECT COUNT(*) FROM users WHERE role = 'admin'").Scan(&count)
-	if err != nil {
-		return err
-	}
-
-	// If admin doesn't exist, create default admin
-	if count == 0 {
-		_, err = DB.Exec(`
-		INSERT INTO users (username, password, name, role, email) 
-		VALUES ('admin', '$2a$10$XQiGvhANVHWBgfA.q3uuSO7XNt0XC/K/F2RIl8Yi8Ow/kH4pQJEFm', 'System Admin', 'admin', 'admin@university.edu')
-		`)
-		if err != nil {
-			return err
-		}
-		log.Println("Default admin created with username 'admin' and password 'admin123'")
-	}
 
 	// Check if departments exist
-	err = DB.QueryRow("SELECT COUNT(*) FROM departments").Scan(&count)
+	err := DB.QueryRow("SELECT COUNT(*) FROM departments").Scan(&count)
 	if err != nil {
 		return err
 	}
@@ -404,7 +177,7 @@ func SeedDB() error {
 		if err != nil {
 			return err
 		}
-		log.Println("Sample departments created")
+		utils.Infof("Sample departments created")
 	}
 
 	// Check if courses exist
@@ -429,36 +202,100 @@ func SeedDB() error {
 		if err != nil {
 			return err
 		}
-		log.Println("Sample courses created")
-	}
-
-	// Add some sample enrollments
-	_, err = DB.Exec(`
-	INSERT INTO enrollments (student_id, course_offering_id, grade, status)
-	VALUES 
-		(1, 1, 85.5, '已完成'),
-		(1, 2, 92.0, '已完成'),
-		(2, 1, 78.5, '已完成'),
-		(3, 1, 92.0, '已完成'),
-		(4, 1, 56.5, '未通过'),
-		(3, 2, 84.5, '已完成')
-	ON CONFLICT(student_id, course_offering_id) DO NOTHING
-	`)
+		utils.Infof("Sample courses created")
+	}
+
+	// Install the default role permissions on first run only, so that permissions an
+	// administrator has revoked from every role stay revoked after a restart
+	seeded, err := seedRolePermissions()
 	if err != nil {
-		log.Printf("Warning: Failed to seed enrollments: %v", err)
+		return err
+	}
+	if seeded {
+		utils.Infof("Default role permissions created")
+	}
+
+	// Add some sample enrollments for the students and course offerings that exist
+	sampleEnrollments := []struct {
+		studentID, courseOfferingID uint
+		grade                       float64
+		status                      string
+	}{
+		{1, 1, 85.5, "已完成"},
+		{1, 2, 92.0, "已完成"},
+		{2, 1, 78.5, "已完成"},
+		{3, 1, 92.0, "已完成"},
+		{4, 1, 56.5, "未通过"},
+		{3, 2, 84.5, "已完成"},
+	}
+	for _, e := range sampleEnrollments {
+		err := DB.QueryRow(`
+			SELECT COUNT(*) FROM students s, course_offerings co WHERE s.id = ? AND co.id = ?
+		`, e.studentID, e.courseOfferingID).Scan(&count)
+		if err == nil && count > 0 {
+			_, err = DB.Exec(`
+				INSERT INTO enrollments (student_id, course_offering_id, grade, status) VALUES (?, ?, ?, ?)
+				ON CONFLICT(student_id, course_offering_id) DO NOTHING
+			`, e.studentID, e.courseOfferingID, e.grade, e.status)
+		}
+		if err != nil {
+			utils.Warnf("Failed to seed enrollments: %v", err)
+			break
+		}
 	}
 
 	return nil
 }
 
-// GetUserByUsername retrieves a user by username
+// GetUserByUsername retrieves a user by username. Deleted users are not found.
 func GetUserByUsername(username string) (*models.User, error) {
-	user := &models.User{}
-	err := DB.QueryRow(
-		"SELECT id, username, password, name, role, email, phone FROM users WHERE username = ?",
-		username).Scan(&user.ID, &user.Username, &user.Password, &user.Name, &user.Role, &user.Email, &user.Phone)
+	return scanUser(DB.QueryRow("SELECT "+userColumns+" FROM users WHERE username = ? AND deleted_at IS NULL", username))
+}
+
+// activeAdminCount counts the administrators who can sign in; deleted and disabled
+// administrators do not count
+const activeAdminCount = "SELECT COUNT(*) FROM users WHERE role = ? AND disabled = FALSE AND deleted_at IS NULL"
+
+// AdminExists reports whether at least one administrator account can sign in
+func AdminExists() (bool, error) {
+	var count int
+	err := DB.QueryRow(activeAdminCount, models.RoleAdmin).Scan(&count)
+	return count > 0, err
+}
+
+// CreateFirstAdmin creates the first administrator account. It refuses to run once
+// an administrator who can sign in exists, so an installation whose administrators
+// were all deleted or disabled can be set up again. It returns ErrUsernameTaken if
+// the username belongs to another account, deleted ones included.
+func CreateFirstAdmin(user *models.User) (uint, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var count int
+	if err := tx.QueryRow(activeAdminCount, models.RoleAdmin).Scan(&count); err != nil {
+		return 0, err
+	}
+	if count > 0 {
+		return 0, ErrAdminExists
+	}
+	if err := tx.QueryRow("SELECT COUNT(*) FROM users WHERE username = ?", user.Username).Scan(&count); err != nil {
+		return 0, err
+	}
+	if count > 0 {
+		return 0, ErrUsernameTaken
+	}
+
+	now := time.Now()
+	id, err := insertID(tx, `
+		INSERT INTO users (username, password, name, role, email, phone, password_changed_at, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, user.Username, user.Password, user.Name, models.RoleAdmin, user.Email, user.Phone, now, now, now)
 	if err != nil {
-		return nil, err
+		return 0, fmt.Errorf("failed to create admin: %w", err)
 	}
-	return user, nil
+
+	return id, tx.Commit()
 }
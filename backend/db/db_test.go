@@ -0,0 +1,133 @@
+package db
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"to-mrz/models"
+)
+
+// openTestDB opens a new SQLite database in a temporary directory with the current
+// schema, and closes it when the test ends
+func openTestDB(t *testing.T) {
+	t.Helper()
+	if err := InitDB(string(SQLite), filepath.Join(t.TempDir(), "test.db")); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { CloseDB() })
+}
+
+// createTestUser adds a local account with the given role and returns its ID
+func createTestUser(t *testing.T, username string, role models.Role) uint {
+	t.Helper()
+	id, err := CreateUser(&models.User{Username: username, Password: "x", Name: username, Role: role})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestFirstAdminIgnoresDeletedAndDisabledAdmins(t *testing.T) {
+	openTestDB(t)
+
+	for _, tc := range []struct {
+		name, update string
+	}{
+		{"disabled", "UPDATE users SET disabled = TRUE WHERE username = ?"},
+		{"deleted", "UPDATE users SET deleted_at = CURRENT_TIMESTAMP WHERE username = ?"},
+	} {
+		username := "admin-" + tc.name
+		if _, err := CreateFirstAdmin(&models.User{Username: username, Password: "x"}); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if _, err := CreateFirstAdmin(&models.User{Username: "another", Password: "x"}); !errors.Is(err, ErrAdminExists) {
+			t.Errorf("%s: second admin: %v, want ErrAdminExists", tc.name, err)
+		}
+
+		if _, err := DB.Exec(tc.update, username); err != nil {
+			t.Fatal(err)
+		}
+		if exists, err := AdminExists(); err != nil || exists {
+			t.Errorf("%s: AdminExists = %v, %v", tc.name, exists, err)
+		}
+		if _, err := CreateFirstAdmin(&models.User{Username: username, Password: "x"}); !errors.Is(err, ErrUsernameTaken) {
+			t.Errorf("%s: reusing the username: %v, want ErrUsernameTaken", tc.name, err)
+		}
+	}
+
+	if _, err := CreateFirstAdmin(&models.User{Username: "admin", Password: "x"}); err != nil {
+		t.Errorf("setting up again: %v", err)
+	}
+}
+
+// rolePermissionCount counts the permissions granted to roles
+func rolePermissionCount(t *testing.T) int {
+	t.Helper()
+	var count int
+	if err := DB.QueryRow("SELECT COUNT(*) FROM role_permissions").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func TestRolePermissionsAreSeededOnce(t *testing.T) {
+	openTestDB(t)
+	defaults := 0
+	for _, permissions := range models.DefaultRolePermissions {
+		defaults += len(permissions)
+	}
+
+	if err := SeedDB(); err != nil {
+		t.Fatal(err)
+	}
+	if count := rolePermissionCount(t); count != defaults {
+		t.Fatalf("the first run installed %d role permissions, want %d", count, defaults)
+	}
+
+	// revoking every permission of every role survives a restart
+	for _, role := range models.AllRoles {
+		if role == models.RoleAdmin {
+			continue
+		}
+		if err := SetRolePermissions(role, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := SeedDB(); err != nil {
+		t.Fatal(err)
+	}
+	if count := rolePermissionCount(t); count != 0 {
+		t.Errorf("restarting reinstalled %d revoked role permissions", count)
+	}
+
+	// databases from before seeds: a customised mapping is kept and recorded as
+	// seeded, an empty one is seeded
+	for _, tc := range []struct {
+		name        string
+		permissions []models.Permission
+		want        int
+	}{
+		{"customised", []models.Permission{models.PermGradeRead}, 1},
+		{"empty", nil, defaults},
+	} {
+		if _, err := DB.Exec("DELETE FROM seeds"); err != nil {
+			t.Fatal(err)
+		}
+		if err := SetRolePermissions(models.RoleTeacher, tc.permissions); err != nil {
+			t.Fatal(err)
+		}
+		if err := SeedDB(); err != nil {
+			t.Fatal(err)
+		}
+		if count := rolePermissionCount(t); count != tc.want {
+			t.Errorf("%s: %d role permissions, want %d", tc.name, count, tc.want)
+		}
+		if err := SeedDB(); err != nil {
+			t.Fatal(err)
+		}
+		if count := rolePermissionCount(t); count != tc.want {
+			t.Errorf("%s: %d role permissions after a second run, want %d", tc.name, count, tc.want)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"to-mrz/models"
+)
+
+// Department errors
+var (
+	ErrDepartmentCodeTaken = errors.New("department code already exists")
+	ErrDepartmentHasMajors = errors.New("department has majors")
+)
+
+// departmentColumns lists the departments columns read by scanDepartment
+const departmentColumns = "id, name, code, created_at, updated_at, deleted_at"
+
+// scanDepartment scans a row selected with departmentColumns
+func scanDepartment(row rowScanner, department *models.Department) error {
+	return row.Scan(&department.ID, &department.Name, &department.Code,
+		timestamp{&department.CreatedAt}, timestamp{&department.UpdatedAt}, deletedAt{&department.DeletedAt})
+}
+
+// DepartmentFilter filters the department list; zero values match every department
+// that is not deleted
+type DepartmentFilter struct {
+	ListQuery
+	Deleted bool // list the deleted departments instead
+}
+
+// departmentList sorts and searches the department list
+var departmentList = listSpec{
+	sorts:  map[string]string{"id": "id", "name": "name", "code": "code", "created_at": "created_at"},
+	order:  []string{"id"},
+	key:    "id",
+	search: []string{"name", "code"},
+}
+
+// GetDepartments returns a page of the departments matching the filter
+func GetDepartments(filter DepartmentFilter) (*models.Page, error) {
+	q := &selectQuery{columns: departmentColumns, from: "departments"}
+	q.filter(deletedFilter("deleted_at", filter.Deleted))
+
+	departments := []models.Department{}
+	page, err := departmentList.list(filter.ListQuery, q, func(row rowScanner) error {
+		var department models.Department
+		if err := scanDepartment(row, &department); err != nil {
+			return err
+		}
+		departments = append(departments, department)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	page.Items = departments
+	return page, nil
+}
+
+// GetDepartmentByID returns a department, or sql.ErrNoRows if it does not exist or
+// has been deleted
+func GetDepartmentByID(id uint) (*models.Department, error) {
+	var department models.Department
+	err := scanDepartment(DB.QueryRow("SELECT "+departmentColumns+" FROM departments WHERE id = ? AND deleted_at IS NULL", id), &department)
+	if err != nil {
+		return nil, err
+	}
+	return &department, nil
+}
+
+// CreateDepartment creates a department. It returns ErrDepartmentCodeTaken if the
+// code is already in use.
+func CreateDepartment(department *models.Department) (uint, error) {
+	if err := checkDepartmentCode(department.Code, 0); err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	id, err := insertID(DB,
+		"INSERT INTO departments (name, code, created_at, updated_at) VALUES (?, ?, ?, ?)",
+		department.Name, department.Code, now, now,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// UpdateDepartment updates a department's name and code. It returns sql.ErrNoRows if
+// the department does not exist and ErrDepartmentCodeTaken if another one has the code.
+func UpdateDepartment(department *models.Department) error {
+	if _, err := GetDepartmentByID(department.ID); err != nil {
+		return err
+	}
+	if err := checkDepartmentCode(department.Code, department.ID); err != nil {
+		return err
+	}
+
+	_, err := DB.Exec(
+		"UPDATE departments SET name = ?, code = ?, updated_at = ? WHERE id = ?",
+		department.Name, department.Code, time.Now(), department.ID,
+	)
+	return err
+}
+
+// DeleteDepartment marks a department deleted. It returns sql.ErrNoRows if the
+// department does not exist and ErrDepartmentHasMajors while majors that are not
+// deleted still belong to it.
+func DeleteDepartment(id uint) error {
+	if _, err := GetDepartmentByID(id); err != nil {
+		return err
+	}
+
+	var count int
+	if err := DB.QueryRow("SELECT COUNT(*) FROM majors WHERE department_id = ? AND deleted_at IS NULL", id).Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrDepartmentHasMajors
+	}
+
+	return softDelete(DB, "departments", id)
+}
+
+// RestoreDepartment undoes DeleteDepartment. It returns sql.ErrNoRows if there is
+// no such deleted department.
+func RestoreDepartment(id uint) error {
+	return restoreDeleted("departments", id)
+}
+
+// PurgeDepartment removes a deleted department for good. It returns ErrNotDeleted
+// for departments that are not deleted and a *DependencyError while majors, courses,
+// teachers or department admins still refer to it.
+func PurgeDepartment(id uint) error {
+	return purge("departments", id)
+}
+
+// checkDepartmentCode returns ErrDepartmentCodeTaken if a department other than
+// exceptID already uses the code
+func checkDepartmentCode(code string, exceptID uint) error {
+	var id uint
+	err := DB.QueryRow("SELECT id FROM departments WHERE code = ? AND id != ?", code, exceptID).Scan(&id)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil
+	case err != nil:
+		return err
+	default:
+		return ErrDepartmentCodeTaken
+	}
+}
@@ -0,0 +1,465 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Dialect is a supported database server. Queries and migrations are written for
+// SQLite and rewritten for the other dialects when they are run.
+type Dialect string
+
+// Supported dialects
+const (
+	SQLite   Dialect = "sqlite"
+	Postgres Dialect = "postgres"
+	MySQL    Dialect = "mysql"
+)
+
+// parseDialect returns the dialect for a configured driver name
+func parseDialect(name string) (Dialect, error) {
+	switch d := Dialect(name); d {
+	case SQLite, Postgres, MySQL:
+		return d, nil
+	}
+	return "", fmt.Errorf("unsupported database driver %q", name)
+}
+
+// driverName returns the database/sql driver registered for the dialect
+func (d Dialect) driverName() string {
+	if d == SQLite {
+		return "sqlite3"
+	}
+	return string(d)
+}
+
+// dataSource adjusts a data source name to the settings the queries rely on. SQLite
+// only enforces foreign keys when asked to on every connection. MySQL connections use
+// ANSI mode so double quotes quote identifiers and || concatenates, and parse DATETIME
+// columns as UTC times.
+func (d Dialect) dataSource(source string) (string, error) {
+	switch d {
+	case SQLite:
+		separator := "?"
+		if strings.Contains(source, "?") {
+			separator = "&"
+		}
+		return source + separator + "_foreign_keys=1", nil
+	case Postgres:
+		return source, nil
+	}
+	cfg, err := mysql.ParseDSN(source)
+	if err != nil {
+		return "", fmt.Errorf("invalid MySQL DSN: %w", err)
+	}
+	cfg.ParseTime = true
+	cfg.Loc = time.UTC
+	if cfg.Params == nil {
+		cfg.Params = map[string]string{}
+	}
+	cfg.Params["sql_mode"] = "'ANSI,TRADITIONAL'"
+	cfg.Params["time_zone"] = "'+00:00'"
+	return cfg.FormatDSN(), nil
+}
+
+// Database is the connection pool. It rewrites every query for its dialect, so the
+// rest of the package only needs to know SQLite's syntax.
+type Database struct {
+	db      *sql.DB
+	dialect Dialect
+	queries sync.Map // SQLite query -> rewritten query
+}
+
+// Dialect returns the database's dialect
+func (d *Database) Dialect() Dialect {
+	return d.dialect
+}
+
+// Exec executes a query without returning any rows
+func (d *Database) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return d.db.Exec(d.rewrite(query), d.dialect.args(args)...)
+}
+
+// Query executes a query that returns rows
+func (d *Database) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return d.db.Query(d.rewrite(query), d.dialect.args(args)...)
+}
+
+// QueryRow executes a query that returns at most one row
+func (d *Database) QueryRow(query string, args ...interface{}) *sql.Row {
+	return d.db.QueryRow(d.rewrite(query), d.dialect.args(args)...)
+}
+
+// Begin starts a transaction
+func (d *Database) Begin() (*Tx, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{tx: tx, db: d}, nil
+}
+
+// Ping verifies the connection to the database
+func (d *Database) Ping() error {
+	return d.db.Ping()
+}
+
+// Close closes the connection pool
+func (d *Database) Close() error {
+	return d.db.Close()
+}
+
+// rewrite returns the query in the database's dialect
+func (d *Database) rewrite(query string) string {
+	if d.dialect == SQLite {
+		return query
+	}
+	if rewritten, ok := d.queries.Load(query); ok {
+		return rewritten.(string)
+	}
+	rewritten := d.dialect.rewrite(query)
+	d.queries.Store(query, rewritten)
+	return rewritten
+}
+
+// Tx is a transaction whose queries are rewritten like the Database's
+type Tx struct {
+	tx *sql.Tx
+	db *Database
+}
+
+// Exec executes a query without returning any rows
+func (t *Tx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return t.tx.Exec(t.db.rewrite(query), t.db.dialect.args(args)...)
+}
+
+// Query executes a query that returns rows
+func (t *Tx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return t.tx.Query(t.db.rewrite(query), t.db.dialect.args(args)...)
+}
+
+// QueryRow executes a query that returns at most one row
+func (t *Tx) QueryRow(query string, args ...interface{}) *sql.Row {
+	return t.tx.QueryRow(t.db.rewrite(query), t.db.dialect.args(args)...)
+}
+
+// Commit commits the transaction
+func (t *Tx) Commit() error {
+	return t.tx.Commit()
+}
+
+// Rollback aborts the transaction
+func (t *Tx) Rollback() error {
+	return t.tx.Rollback()
+}
+
+// execQuerier is implemented by both *Database and *Tx
+type execQuerier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// insertID runs an INSERT into a table with an id column and returns the new row's
+// id. PostgreSQL does not report the last insert id, so it is read back with RETURNING.
+func insertID(q execQuerier, query string, args ...interface{}) (uint, error) {
+	if DB.dialect == Postgres {
+		var id uint
+		err := q.QueryRow(strings.TrimSpace(query)+" RETURNING id", args...).Scan(&id)
+		return id, err
+	}
+
+	result, err := q.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return uint(id), nil
+}
+
+var (
+	onConflictNothing = regexp.MustCompile(`(?is)\s*ON CONFLICT\s*(\([^)]*\))?\s*DO NOTHING`)
+	onConflictUpdate  = regexp.MustCompile(`(?is)ON CONFLICT\s*\([^)]*\)\s*DO UPDATE SET`)
+	excludedColumn    = regexp.MustCompile(`(?i)\bexcluded\.(\w+)`)
+	insertInto        = regexp.MustCompile(`(?i)\bINSERT\s+INTO\b`)
+	likeOperator      = regexp.MustCompile(`(?i)\bLIKE\b`)
+)
+
+// rewrite translates a query written for SQLite into the dialect
+func (d Dialect) rewrite(query string) string {
+	switch d {
+	case Postgres:
+		// SQLite's LIKE ignores case
+		return bindNumbered(likeOperator.ReplaceAllString(query, "ILIKE"))
+	case MySQL:
+		if onConflictNothing.MatchString(query) {
+			query = onConflictNothing.ReplaceAllString(query, "")
+			query = insertInto.ReplaceAllString(query, "INSERT IGNORE INTO")
+		}
+		if onConflictUpdate.MatchString(query) {
+			query = onConflictUpdate.ReplaceAllString(query, "ON DUPLICATE KEY UPDATE")
+			query = excludedColumn.ReplaceAllString(query, "VALUES($1)")
+		}
+	}
+	return query
+}
+
+// bindNumbered replaces ? placeholders outside string literals with $1, $2, ...
+func bindNumbered(query string) string {
+	var b strings.Builder
+	n := 0
+	quoted := false
+	for _, r := range query {
+		switch {
+		case r == '\'':
+			quoted = !quoted
+		case r == '?' && !quoted:
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// args converts query arguments for the dialect. Times are always stored in UTC, and
+// SQLite, which has no timestamp type, stores them as text in timestampLayout.
+func (d Dialect) args(args []interface{}) []interface{} {
+	converted := make([]interface{}, len(args))
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case time.Time:
+			converted[i] = d.timestamp(v)
+		case *time.Time:
+			if v != nil {
+				converted[i] = d.timestamp(*v)
+			}
+		case sql.NullTime:
+			if v.Valid {
+				converted[i] = d.timestamp(v.Time)
+			}
+		default:
+			converted[i] = arg
+		}
+	}
+	return converted
+}
+
+// timestamp converts a time to the value stored for it
+func (d Dialect) timestamp(t time.Time) interface{} {
+	t = t.UTC()
+	if d == SQLite {
+		return t.Format(timestampLayout)
+	}
+	return t
+}
+
+var (
+	autoincrementColumn = regexp.MustCompile(`(?i)INTEGER PRIMARY KEY AUTOINCREMENT`)
+	realColumn          = regexp.MustCompile(`(?i)\bREAL\b`)
+	booleanDefault      = regexp.MustCompile(`(?i)(BOOLEAN[^,\n]*DEFAULT )([01])\b`)
+	timestampColumn     = regexp.MustCompile(`(?i)\bTIMESTAMP\b`)
+	currentTimestamp    = regexp.MustCompile(`(?i)\bCURRENT_TIMESTAMP\b`)
+	createTable         = regexp.MustCompile(`(?is)^CREATE TABLE (?:IF NOT EXISTS )?(\w+) \((.*)\)$`)
+	createIndex         = regexp.MustCompile(`(?is)^CREATE (?:UNIQUE )?INDEX (?:IF NOT EXISTS )?(\w+) ON (\w+) ?\(([^)]*)\)`)
+	dropTable           = regexp.MustCompile(`(?i)^DROP TABLE (IF EXISTS )?(\w+)$`)
+	dropIndex           = regexp.MustCompile(`(?i)^DROP INDEX (IF EXISTS )?(\w+)$`)
+	addColumn           = regexp.MustCompile(`(?is)^(ALTER TABLE \w+ ADD COLUMN )(.*)$`)
+	inlineReference     = regexp.MustCompile(`(?i)\s+REFERENCES (\w+) ?\((\w+)\)`)
+	tableForeignKey     = regexp.MustCompile(`(?i)^FOREIGN KEY \((\w+)\) REFERENCES (\w+) ?\((\w+)\)$`)
+	keyConstraint       = regexp.MustCompile(`(?i)^(?:PRIMARY KEY|UNIQUE) ?\(([^)]*)\)$`)
+	columnDefinition    = regexp.MustCompile(`^"?(\w+)"? (\w+)(.*)$`)
+	keyedColumn         = regexp.MustCompile(`(?i)\b(PRIMARY KEY|UNIQUE|DEFAULT)\b`)
+	lineComment         = regexp.MustCompile(`--[^\n]*`)
+)
+
+// mysqlReserved lists the column names of the schema that MySQL reserves
+var mysqlReserved = map[string]bool{"key": true}
+
+// migrationStatements splits a migration script into statements for the dialect.
+// Scripts are written for SQLite and run unchanged there. For the other dialects
+// column types are mapped, foreign keys are added once all tables of the script
+// exist, and MySQL's TEXT columns that are keyed or have a default become
+// VARCHAR(255). indexes maps the index names of all migrations to their tables.
+func (d Dialect) migrationStatements(script string, indexes map[string]string) []string {
+	if d == SQLite {
+		return []string{script}
+	}
+
+	var statements []string
+	for _, s := range strings.Split(lineComment.ReplaceAllString(script, ""), ";") {
+		if s = strings.TrimSpace(s); s != "" {
+			statements = append(statements, s)
+		}
+	}
+
+	var translated, foreignKeys []string
+	dropsTables := false
+	for _, s := range statements {
+		if m := createTable.FindStringSubmatch(s); m != nil {
+			var fks []string
+			s, fks = d.createTable(s, m[1], m[2])
+			foreignKeys = append(foreignKeys, fks...)
+		} else if m := dropTable.FindStringSubmatch(s); m != nil {
+			dropsTables = true
+			if d == Postgres {
+				s += " CASCADE"
+			}
+		} else if m := dropIndex.FindStringSubmatch(s); m != nil && d == MySQL {
+			s = fmt.Sprintf("DROP INDEX %s ON %s", m[2], indexes[m[2]])
+		} else if m := addColumn.FindStringSubmatch(s); m != nil {
+			s = m[1] + d.columnTypes(m[2], nil)
+		}
+		translated = append(translated, s)
+	}
+	translated = append(translated, foreignKeys...)
+
+	// MySQL refuses to drop tables that others still reference
+	if d == MySQL && dropsTables {
+		translated = append([]string{"SET FOREIGN_KEY_CHECKS = 0"}, translated...)
+		translated = append(translated, "SET FOREIGN_KEY_CHECKS = 1")
+	}
+	return translated
+}
+
+// createTable translates a CREATE TABLE statement, returning it without its foreign
+// keys and the ALTER TABLE statements that add them
+func (d Dialect) createTable(statement, table, body string) (string, []string) {
+	var definitions, foreignKeys []string
+	keyed := map[string]bool{}
+	for _, def := range splitDefinitions(body) {
+		if m := tableForeignKey.FindStringSubmatch(def); m != nil {
+			foreignKeys = append(foreignKeys, fmt.Sprintf("ALTER TABLE %s ADD FOREIGN KEY (%s) REFERENCES %s(%s)", table, m[1], m[2], m[3]))
+			continue
+		}
+		if m := keyConstraint.FindStringSubmatch(def); m != nil {
+			for _, column := range strings.Split(m[1], ",") {
+				keyed[strings.TrimSpace(column)] = true
+			}
+		}
+		if m := inlineReference.FindStringSubmatch(def); m != nil {
+			column := columnDefinition.FindStringSubmatch(def)[1]
+			foreignKeys = append(foreignKeys, fmt.Sprintf("ALTER TABLE %s ADD FOREIGN KEY (%s) REFERENCES %s(%s)", table, column, m[1], m[2]))
+			def = inlineReference.ReplaceAllString(def, "")
+		}
+		definitions = append(definitions, def)
+	}
+
+	for i, def := range definitions {
+		definitions[i] = d.columnTypes(def, keyed)
+	}
+
+	prefix := statement[:strings.Index(statement, "(")]
+	statement = prefix + "(\n\t" + strings.Join(definitions, ",\n\t") + "\n)"
+	if d == MySQL {
+		statement += " DEFAULT CHARSET=utf8mb4"
+	}
+	return statement, foreignKeys
+}
+
+// columnTypes maps the SQLite column types of a column definition. keyed holds the
+// columns of the table's key constraints.
+func (d Dialect) columnTypes(def string, keyed map[string]bool) string {
+	switch d {
+	case Postgres:
+		def = autoincrementColumn.ReplaceAllString(def, "INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY")
+		def = realColumn.ReplaceAllString(def, "DOUBLE PRECISION")
+		def = booleanDefault.ReplaceAllStringFunc(def, func(s string) string {
+			m := booleanDefault.FindStringSubmatch(s)
+			if m[2] == "1" {
+				return m[1] + "TRUE"
+			}
+			return m[1] + "FALSE"
+		})
+	case MySQL:
+		def = autoincrementColumn.ReplaceAllString(def, "INTEGER PRIMARY KEY AUTO_INCREMENT")
+		def = realColumn.ReplaceAllString(def, "DOUBLE")
+		def = timestampColumn.ReplaceAllString(def, "DATETIME(6)")
+		def = currentTimestamp.ReplaceAllString(def, "CURRENT_TIMESTAMP(6)")
+		if m := columnDefinition.FindStringSubmatch(def); m != nil {
+			column, columnType, rest := m[1], m[2], m[3]
+			if strings.EqualFold(columnType, "TEXT") && (keyed[column] || keyedColumn.MatchString(rest)) {
+				columnType = "VARCHAR(255)"
+			}
+			if mysqlReserved[column] {
+				column = `"` + column + `"`
+			}
+			def = column + " " + columnType + rest
+		}
+	}
+	return def
+}
+
+// splitDefinitions splits the body of a CREATE TABLE statement on the commas that
+// separate its column and constraint definitions
+func splitDefinitions(body string) []string {
+	var definitions []string
+	depth, start := 0, 0
+	for i, r := range body {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				definitions = append(definitions, strings.TrimSpace(body[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(definitions, strings.TrimSpace(body[start:]))
+}
+
+// migrationIndexes maps the names of the indexes created by the migrations to their tables
+func migrationIndexes() map[string]string {
+	indexes := map[string]string{}
+	for _, m := range migrations {
+		for _, s := range strings.Split(lineComment.ReplaceAllString(m.Up, ""), ";") {
+			if match := createIndex.FindStringSubmatch(strings.TrimSpace(s)); match != nil {
+				indexes[match[1]] = match[2]
+			}
+		}
+	}
+	return indexes
+}
+
+// reference is a foreign key column
+type reference struct {
+	table, column string
+}
+
+// migrationReferences maps each table to the foreign key columns of the migrations
+// that refer to it
+func migrationReferences() map[string][]reference {
+	references := map[string][]reference{}
+	for _, m := range migrations {
+		for _, s := range strings.Split(lineComment.ReplaceAllString(m.Up, ""), ";") {
+			match := createTable.FindStringSubmatch(strings.TrimSpace(s))
+			if match == nil {
+				continue
+			}
+			for _, def := range splitDefinitions(match[2]) {
+				if fk := tableForeignKey.FindStringSubmatch(def); fk != nil {
+					references[fk[2]] = append(references[fk[2]], reference{match[1], fk[1]})
+				} else if fk := inlineReference.FindStringSubmatch(def); fk != nil {
+					column := columnDefinition.FindStringSubmatch(def)[1]
+					references[fk[1]] = append(references[fk[1]], reference{match[1], column})
+				}
+			}
+		}
+	}
+	return references
+}
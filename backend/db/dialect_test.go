@@ -0,0 +1,210 @@
+package db
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDialectRewrite(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		dialect Dialect
+		query   string
+		want    string
+	}{
+		{
+			"sqlite is unchanged", SQLite,
+			"SELECT id FROM users WHERE name LIKE ? AND id = ?",
+			"SELECT id FROM users WHERE name LIKE ? AND id = ?",
+		},
+		{
+			"postgres numbers placeholders and ignores case in LIKE", Postgres,
+			"SELECT id FROM users WHERE name like ? AND id = ?",
+			"SELECT id FROM users WHERE name ILIKE $1 AND id = $2",
+		},
+		{
+			"postgres keeps ON CONFLICT", Postgres,
+			"INSERT INTO role_permissions (role, permission) VALUES (?, ?) ON CONFLICT DO NOTHING",
+			"INSERT INTO role_permissions (role, permission) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+		},
+		{
+			"mysql keeps placeholders and LIKE", MySQL,
+			"SELECT id FROM users WHERE name LIKE ? AND id = ?",
+			"SELECT id FROM users WHERE name LIKE ? AND id = ?",
+		},
+		{
+			"mysql ignores conflicting inserts", MySQL,
+			"INSERT INTO role_permissions (role, permission) VALUES (?, ?) ON CONFLICT DO NOTHING",
+			"INSERT IGNORE INTO role_permissions (role, permission) VALUES (?, ?)",
+		},
+		{
+			"mysql ignores conflicts on named columns", MySQL,
+			"insert into user_roles (user_id, role)\n\t\tVALUES (?, ?)\n\t\tON CONFLICT (user_id, role) DO NOTHING",
+			"INSERT IGNORE INTO user_roles (user_id, role)\n\t\tVALUES (?, ?)",
+		},
+		{
+			"mysql updates duplicate keys", MySQL,
+			"INSERT INTO login_failures (username, failures, last_failed_at) VALUES (?, 1, ?) " +
+				"ON CONFLICT (username) DO UPDATE SET failures = login_failures.failures + 1, last_failed_at = excluded.last_failed_at",
+			"INSERT INTO login_failures (username, failures, last_failed_at) VALUES (?, 1, ?) " +
+				"ON DUPLICATE KEY UPDATE failures = login_failures.failures + 1, last_failed_at = VALUES(last_failed_at)",
+		},
+	} {
+		if got := tc.dialect.rewrite(tc.query); got != tc.want {
+			t.Errorf("%s:\n got %q\nwant %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestDatabaseRewriteCachesQueries(t *testing.T) {
+	d := &Database{dialect: Postgres}
+	query := "SELECT id FROM users WHERE id = ?"
+	if got := d.rewrite(query); got != "SELECT id FROM users WHERE id = $1" {
+		t.Fatalf("rewrite = %q", got)
+	}
+	if cached, ok := d.queries.Load(query); !ok || cached != "SELECT id FROM users WHERE id = $1" {
+		t.Errorf("cached rewrite = %v, %v", cached, ok)
+	}
+
+	sqlite := &Database{dialect: SQLite}
+	sqlite.rewrite(query)
+	if _, ok := sqlite.queries.Load(query); ok {
+		t.Error("SQLite queries are cached")
+	}
+}
+
+func TestBindNumbered(t *testing.T) {
+	for _, tc := range []struct {
+		query, want string
+	}{
+		{"SELECT 1", "SELECT 1"},
+		{"SELECT ?", "SELECT $1"},
+		{"UPDATE users SET name = ?, email = ? WHERE id = ?", "UPDATE users SET name = $1, email = $2 WHERE id = $3"},
+		{"SELECT '?' || ?", "SELECT '?' || $1"},
+		{"SELECT 'it''s ?', ? FROM t WHERE a = '' AND b = ?", "SELECT 'it''s ?', $1 FROM t WHERE a = '' AND b = $2"},
+		{"SELECT '张三?' AS 名称, ?", "SELECT '张三?' AS 名称, $1"},
+		{"VALUES (?,?,?,?,?,?,?,?,?,?)", "VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10)"},
+	} {
+		if got := bindNumbered(tc.query); got != tc.want {
+			t.Errorf("bindNumbered(%q) = %q, want %q", tc.query, got, tc.want)
+		}
+	}
+}
+
+const testMigration = `-- departments and their majors
+CREATE TABLE departments (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	code TEXT NOT NULL UNIQUE,
+	name TEXT NOT NULL,
+	active BOOLEAN NOT NULL DEFAULT 1,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE majors (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	department_id INTEGER NOT NULL REFERENCES departments(id),
+	credits REAL, -- total credits
+	key TEXT DEFAULT ''
+);
+
+CREATE TABLE major_tags (
+	major_id INTEGER NOT NULL,
+	tag TEXT NOT NULL,
+	PRIMARY KEY (major_id, tag),
+	FOREIGN KEY (major_id) REFERENCES majors(id)
+);
+
+CREATE INDEX idx_majors_department ON majors(department_id);
+`
+
+func TestMigrationStatements(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		dialect Dialect
+		script  string
+		want    []string
+	}{
+		{
+			"sqlite runs the script as written", SQLite, testMigration,
+			[]string{testMigration},
+		},
+		{
+			"postgres", Postgres, testMigration,
+			[]string{
+				"CREATE TABLE departments (\n\tid INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,\n\tcode TEXT NOT NULL UNIQUE,\n\tname TEXT NOT NULL,\n\tactive BOOLEAN NOT NULL DEFAULT TRUE,\n\tcreated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP\n)",
+				"CREATE TABLE majors (\n\tid INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,\n\tdepartment_id INTEGER NOT NULL,\n\tcredits DOUBLE PRECISION,\n\tkey TEXT DEFAULT ''\n)",
+				"CREATE TABLE major_tags (\n\tmajor_id INTEGER NOT NULL,\n\ttag TEXT NOT NULL,\n\tPRIMARY KEY (major_id, tag)\n)",
+				"CREATE INDEX idx_majors_department ON majors(department_id)",
+				"ALTER TABLE majors ADD FOREIGN KEY (department_id) REFERENCES departments(id)",
+				"ALTER TABLE major_tags ADD FOREIGN KEY (major_id) REFERENCES majors(id)",
+			},
+		},
+		{
+			"mysql", MySQL, testMigration,
+			[]string{
+				"CREATE TABLE departments (\n\tid INTEGER PRIMARY KEY AUTO_INCREMENT,\n\tcode VARCHAR(255) NOT NULL UNIQUE,\n\tname TEXT NOT NULL,\n\tactive BOOLEAN NOT NULL DEFAULT 1,\n\tcreated_at DATETIME(6) DEFAULT CURRENT_TIMESTAMP(6)\n) DEFAULT CHARSET=utf8mb4",
+				"CREATE TABLE majors (\n\tid INTEGER PRIMARY KEY AUTO_INCREMENT,\n\tdepartment_id INTEGER NOT NULL,\n\tcredits DOUBLE,\n\t\"key\" VARCHAR(255) DEFAULT ''\n) DEFAULT CHARSET=utf8mb4",
+				"CREATE TABLE major_tags (\n\tmajor_id INTEGER NOT NULL,\n\ttag VARCHAR(255) NOT NULL,\n\tPRIMARY KEY (major_id, tag)\n) DEFAULT CHARSET=utf8mb4",
+				"CREATE INDEX idx_majors_department ON majors(department_id)",
+				"ALTER TABLE majors ADD FOREIGN KEY (department_id) REFERENCES departments(id)",
+				"ALTER TABLE major_tags ADD FOREIGN KEY (major_id) REFERENCES majors(id)",
+			},
+		},
+		{
+			"postgres drops dependent foreign keys with tables", Postgres,
+			"DROP INDEX IF EXISTS idx_majors_department;\nDROP TABLE majors;\nALTER TABLE departments ADD COLUMN closed BOOLEAN NOT NULL DEFAULT 0;",
+			[]string{
+				"DROP INDEX IF EXISTS idx_majors_department",
+				"DROP TABLE majors CASCADE",
+				"ALTER TABLE departments ADD COLUMN closed BOOLEAN NOT NULL DEFAULT FALSE",
+			},
+		},
+		{
+			"mysql drops indexes from their table and tables without checks", MySQL,
+			"DROP INDEX idx_majors_department;\nDROP TABLE majors;\nALTER TABLE departments ADD COLUMN closed_at TIMESTAMP;",
+			[]string{
+				"SET FOREIGN_KEY_CHECKS = 0",
+				"DROP INDEX idx_majors_department ON majors",
+				"DROP TABLE majors",
+				"ALTER TABLE departments ADD COLUMN closed_at DATETIME(6)",
+				"SET FOREIGN_KEY_CHECKS = 1",
+			},
+		},
+		{
+			"comment-only scripts have no statements", Postgres,
+			"-- nothing to undo\n",
+			nil,
+		},
+	} {
+		got := tc.dialect.migrationStatements(tc.script, map[string]string{"idx_majors_department": "majors"})
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s:\n got %q\nwant %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+// TestMigrationsTranslate checks that every migration translates into statements
+// without SQLite-only syntax
+func TestMigrationsTranslate(t *testing.T) {
+	indexes := migrationIndexes()
+	for _, d := range []Dialect{Postgres, MySQL} {
+		for _, m := range migrations {
+			for direction, script := range map[string]string{"up": m.Up, "down": m.Down} {
+				if strings.HasPrefix(script, sqliteOnly) {
+					continue
+				}
+				for _, s := range d.migrationStatements(script, indexes) {
+					for _, sqliteSyntax := range []string{"AUTOINCREMENT", "--", " REAL", "REFERENCES"} {
+						if strings.Contains(s, sqliteSyntax) && !strings.HasPrefix(s, "ALTER TABLE") {
+							t.Errorf("%s %04d_%s.%s: %q in %q", d, m.Version, m.Name, direction, sqliteSyntax, s)
+						}
+					}
+					if d == MySQL && strings.HasPrefix(s, "DROP INDEX") && strings.HasSuffix(s, " ON ") {
+						t.Errorf("%s %04d_%s.%s: index of unknown table in %q", d, m.Version, m.Name, direction, s)
+					}
+				}
+			}
+		}
+	}
+}
@@ -1,125 +1,179 @@
 package db
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
 	"to-mrz/models"
 )
 
-// GetStudentGrades retrieves all grades for a specific student
-func GetStudentGrades(studentID uint) ([]models.Enrollment, error) {
-	enrollments := []models.Enrollment{}
-
-	rows, err := DB.Query(`
-		SELECT 
-			e.id, e.student_id, e.course_offering_id, e.grade, e.status,
+// gradeQuery selects enrollments with their students and course offerings
+func gradeQuery() *selectQuery {
+	return &selectQuery{
+		columns: `e.id, e.student_id, e.course_offering_id, e.grade, e.status,
 			e.created_at, e.updated_at,
-			co.id as co_id, co.semester_id,
-			c.id as c_id, c.name as course_name, c.code as course_code, c.credits,
-			t.id as t_id, u.id as u_id, u.name as teacher_name,
-			s.id as s_id, s.name as semester_name
-		FROM enrollments e
-		JOIN course_offerings co ON e.course_offering_id = co.id
-		JOIN courses c ON co.course_id = c.id
-		JOIN teachers t ON co.teacher_id = t.id
-		JOIN users u ON t.user_id = u.id
-		JOIN semesters s ON co.semester_id = s.id
-		WHERE e.student_id = ?
-	`, studentID)
+			st.id, st.student_id, su.id, su.name, su.role,
+			cl.id, cl.name,
+			co.id, co.semester_id,
+			c.id, c.name, c.code, c.credits,
+			t.id, tu.id, tu.name, tu.role,
+			sem.id, sem.name`,
+		from: `enrollments e
+			JOIN students st ON e.student_id = st.id
+			JOIN users su ON st.user_id = su.id
+			JOIN classes cl ON st.class_id = cl.id
+			JOIN course_offerings co ON e.course_offering_id = co.id
+			JOIN courses c ON co.course_id = c.id
+			JOIN teachers t ON co.teacher_id = t.id
+			JOIN users tu ON t.user_id = tu.id
+			JOIN semesters sem ON co.semester_id = sem.id`,
+	}
+}
 
+// scanGrade reads a row of gradeQuery
+func scanGrade(row rowScanner) (models.Enrollment, error) {
+	var e models.Enrollment
+	var student models.Student
+	var courseOffering models.CourseOffering
+
+	err := row.Scan(
+		&e.ID, &e.StudentID, &e.CourseOfferingID, &e.Grade, &e.Status,
+		timestamp{&e.CreatedAt}, timestamp{&e.UpdatedAt},
+		&student.ID, &student.StudentID, &student.User.ID, &student.User.Name, &student.User.Role,
+		&student.Class.ID, &student.Class.Name,
+		&courseOffering.ID, &courseOffering.SemesterID,
+		&courseOffering.Course.ID, &courseOffering.Course.Name, &courseOffering.Course.Code, &courseOffering.Course.Credits,
+		&courseOffering.Teacher.ID, &courseOffering.Teacher.User.ID, &courseOffering.Teacher.User.Name, &courseOffering.Teacher.User.Role,
+		&courseOffering.Semester.ID, &courseOffering.Semester.Name,
+	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query student grades: %w", err)
+		return e, fmt.Errorf("failed to scan grade: %w", err)
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var e models.Enrollment
-		var courseOffering models.CourseOffering
-		var course models.Course
-		var teacher models.Teacher
-		var teacherUser models.User
-		var semester models.Semester
-
-		err := rows.Scan(
-			&e.ID, &e.StudentID, &e.CourseOfferingID, &e.Grade, &e.Status,
-			&e.CreatedAt, &e.UpdatedAt,
-			&courseOffering.ID, &courseOffering.SemesterID,
-			&course.ID, &course.Name, &course.Code, &course.Credits,
-			&teacher.ID, &teacherUser.ID, &teacherUser.Name,
-			&semester.ID, &semester.Name,
-		)
+	student.UserID = student.User.ID
+	student.ClassID = student.Class.ID
+	courseOffering.CourseID = courseOffering.Course.ID
+	courseOffering.TeacherID = courseOffering.Teacher.ID
+	courseOffering.Teacher.UserID = courseOffering.Teacher.User.ID
+	e.Student = student
+	e.CourseOffering = courseOffering
+	return e, nil
+}
 
+// listGrades returns a page of the enrollments of q
+func listGrades(spec listSpec, query ListQuery, q *selectQuery) (*models.Page, error) {
+	enrollments := []models.Enrollment{}
+	page, err := spec.list(query, q, func(row rowScanner) error {
+		e, err := scanGrade(row)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan student grade: %w", err)
+			return err
 		}
-
-		teacher.User = teacherUser
-		courseOffering.Course = course
-		courseOffering.Teacher = teacher
-		courseOffering.Semester = semester
-		e.CourseOffering = courseOffering
-
 		enrollments = append(enrollments, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	page.Items = enrollments
+	return page, nil
+}
 
-	return enrollments, nil
+// StudentGradeFilter selects the grades of a student
+type StudentGradeFilter struct {
+	ListQuery
+	StudentID uint
 }
 
-// GetCourseGrades retrieves all student grades for a specific course offering
-func GetCourseGrades(courseOfferingID uint) ([]models.Enrollment, error) {
-	enrollments := []models.Enrollment{}
+// studentGradeList sorts and searches a student's grades, latest semester first by default
+var studentGradeList = listSpec{
+	sorts: map[string]string{
+		"semester":    "sem.start_date",
+		"course_code": "c.code",
+		"course_name": "c.name",
+		"grade":       "COALESCE(e.grade, -1)",
+		"created_at":  "e.created_at",
+	},
+	order:  []string{"-semester", "course_code"},
+	key:    "e.id",
+	search: []string{"c.name", "c.code"},
+}
 
-	rows, err := DB.Query(`
-		SELECT 
-			e.id, e.student_id, e.course_offering_id, e.grade, e.status,
-			e.created_at, e.updated_at,
-			s.id as s_id, s.student_id as student_number,
-			u.id as u_id, u.name as student_name,
-			c.id as c_id, c.name as class_name
-		FROM enrollments e
-		JOIN students s ON e.student_id = s.id
-		JOIN users u ON s.user_id = u.id
-		JOIN classes c ON s.class_id = c.id
-		WHERE e.course_offering_id = ?
-	`, courseOfferingID)
+// GetStudentGrades returns a page of the grades of a student
+func GetStudentGrades(filter StudentGradeFilter) (*models.Page, error) {
+	q := gradeQuery()
+	q.filter("e.student_id = ?", filter.StudentID)
+	return listGrades(studentGradeList, filter.ListQuery, q)
+}
+
+// CourseGradeFilter selects the grades of a course offering
+type CourseGradeFilter struct {
+	ListQuery
+	CourseOfferingID uint
+}
+
+// courseGradeList sorts and searches the grades of a course offering, by student
+// number by default
+var courseGradeList = listSpec{
+	sorts: map[string]string{
+		"student_no": "st.student_id",
+		"name":       "su.name",
+		"class":      "cl.name",
+		"grade":      "COALESCE(e.grade, -1)",
+	},
+	order:  []string{"student_no"},
+	key:    "e.id",
+	search: []string{"st.student_id", "su.name"},
+}
+
+// GetCourseGrades returns a page of the grades of a course offering
+func GetCourseGrades(filter CourseGradeFilter) (*models.Page, error) {
+	q := gradeQuery()
+	q.filter("e.course_offering_id = ?", filter.CourseOfferingID)
+	return listGrades(courseGradeList, filter.ListQuery, q)
+}
+
+// GetGrade returns an enrollment with its student and course offering
+func GetGrade(id uint) (*models.Enrollment, error) {
+	q := gradeQuery()
+	q.filter("e.id = ?", id)
+	e, err := scanGrade(DB.QueryRow("SELECT "+q.columns+" FROM "+q.from+q.whereClause(), q.args...))
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
 
+// getCourseRoster returns every enrollment of a course offering, for the grade import
+func getCourseRoster(courseOfferingID uint) ([]models.Enrollment, error) {
+	q := gradeQuery()
+	q.filter("e.course_offering_id = ?", courseOfferingID)
+	rows, err := DB.Query("SELECT "+q.columns+" FROM "+q.from+q.whereClause()+" ORDER BY st.student_id", q.args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query course grades: %w", err)
 	}
 	defer rows.Close()
 
+	enrollments := []models.Enrollment{}
 	for rows.Next() {
-		var e models.Enrollment
-		var student models.Student
-		var studentUser models.User
-		var class models.Class
-
-		err := rows.Scan(
-			&e.ID, &e.StudentID, &e.CourseOfferingID, &e.Grade, &e.Status,
-			&e.CreatedAt, &e.UpdatedAt,
-			&student.ID, &student.StudentID,
-			&studentUser.ID, &studentUser.Name,
-			&class.ID, &class.Name,
-		)
-
+		e, err := scanGrade(rows)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan course grade: %w", err)
+			return nil, err
 		}
-
-		student.User = studentUser
-		student.Class = class
-		e.Student = student
-
 		enrollments = append(enrollments, e)
 	}
-
-	return enrollments, nil
+	return enrollments, rows.Err()
 }
 
-// UpdateGrade updates a student's grade for a specific enrollment
+// ErrGradeExists is returned by CreateGrade when the student already has an
+// enrollment in the course offering
+var ErrGradeExists = errors.New("student already has a grade for the course offering")
+
+// UpdateGrade updates a student's grade for a specific enrollment. It returns
+// sql.ErrNoRows if the enrollment does not exist.
 func UpdateGrade(id uint, grade float64) error {
-	_, err := DB.Exec(`
+	result, err := DB.Exec(`
 		UPDATE enrollments 
 		SET grade = ?, status = ?, updated_at = ?
 		WHERE id = ?
@@ -128,13 +182,29 @@ func UpdateGrade(id uint, grade float64) error {
 	if err != nil {
 		return fmt.Errorf("failed to update grade: %w", err)
 	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
 
 	return nil
 }
 
-// CreateGrade creates a new enrollment record with a grade
+// CreateGrade creates a new enrollment record with a grade. It returns ErrGradeExists
+// if the student is already enrolled in the course offering.
 func CreateGrade(enrollment models.Enrollment) (uint, error) {
-	result, err := DB.Exec(`
+	var count int
+	err := DB.QueryRow("SELECT COUNT(*) FROM enrollments WHERE student_id = ? AND course_offering_id = ?",
+		enrollment.StudentID, enrollment.CourseOfferingID).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	if count > 0 {
+		return 0, ErrGradeExists
+	}
+
+	id, err := insertID(DB, `
 		INSERT INTO enrollments (student_id, course_offering_id, grade, status, created_at, updated_at)
 		VALUES (?, ?, ?, ?, ?, ?)
 	`, enrollment.StudentID, enrollment.CourseOfferingID, enrollment.Grade, getStatusFromGrade(enrollment.Grade), time.Now(), time.Now())
@@ -143,12 +213,7 @@ func CreateGrade(enrollment models.Enrollment) (uint, error) {
 		return 0, fmt.Errorf("failed to create grade: %w", err)
 	}
 
-	id, err := result.LastInsertId()
-	if err != nil {
-		return 0, fmt.Errorf("failed to get created grade ID: %w", err)
-	}
-
-	return uint(id), nil
+	return id, nil
 }
 
 // Helper function to determine status based on grade
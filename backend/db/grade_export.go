@@ -0,0 +1,105 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"to-mrz/models"
+)
+
+// GetExportOfferings retrieves the course offerings covered by a grade export.
+// A zero courseOfferingID, semesterID or departmentID matches all.
+func GetExportOfferings(courseOfferingID, semesterID, departmentID uint) ([]models.CourseOffering, error) {
+	offerings := []models.CourseOffering{}
+
+	rows, err := DB.Query(`
+		SELECT co.id, co.course_id, co.semester_id, c.id, c.name, c.code, c.department_id, sem.id, sem.name
+		FROM course_offerings co
+		JOIN courses c ON co.course_id = c.id
+		JOIN semesters sem ON co.semester_id = sem.id
+		WHERE co.deleted_at IS NULL
+		  AND (? = 0 OR co.id = ?)
+		  AND (? = 0 OR co.semester_id = ?)
+		  AND (? = 0 OR c.department_id = ?)
+		ORDER BY sem.start_date, c.code, co.id
+	`, courseOfferingID, courseOfferingID, semesterID, semesterID, departmentID, departmentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query course offerings: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var co models.CourseOffering
+		err := rows.Scan(
+			&co.ID, &co.CourseID, &co.SemesterID, &co.Course.ID, &co.Course.Name, &co.Course.Code,
+			&co.Course.DepartmentID, &co.Semester.ID, &co.Semester.Name,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan course offering: %w", err)
+		}
+		offerings = append(offerings, co)
+	}
+
+	return offerings, rows.Err()
+}
+
+// EachGradeSheetRow streams the grade sheet of a course offering, calling fn once per
+// enrollment in 学号 order with its component scores
+func EachGradeSheetRow(courseOfferingID uint, fn func(models.GradeSheetRow) error) error {
+	rows, err := DB.Query(`
+		SELECT e.id, s.student_id, u.name, COALESCE(cl.name, ''), e.grade, e.status,
+		       gd.grade_component_id, gd.score
+		FROM enrollments e
+		JOIN students s ON e.student_id = s.id
+		JOIN users u ON s.user_id = u.id
+		LEFT JOIN classes cl ON s.class_id = cl.id
+		LEFT JOIN grade_details gd ON gd.enrollment_id = e.id
+		WHERE e.course_offering_id = ? AND e.status != '已退选'
+		ORDER BY s.student_id, e.id
+	`, courseOfferingID)
+	if err != nil {
+		return fmt.Errorf("failed to query grade sheet: %w", err)
+	}
+	defer rows.Close()
+
+	var current *models.GradeSheetRow
+	for rows.Next() {
+		var row models.GradeSheetRow
+		var componentID sql.NullInt64
+		var total, score sql.NullFloat64
+
+		err := rows.Scan(
+			&row.EnrollmentID, &row.StudentNo, &row.StudentName, &row.ClassName, &total, &row.Status,
+			&componentID, &score,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to scan grade sheet row: %w", err)
+		}
+
+		if current == nil || current.EnrollmentID != row.EnrollmentID {
+			if current != nil {
+				if err := fn(*current); err != nil {
+					return err
+				}
+			}
+			if total.Valid {
+				row.Total = &total.Float64
+			}
+			row.Scores = map[uint]float64{}
+			current = &row
+		}
+
+		if componentID.Valid && score.Valid {
+			current.Scores[uint(componentID.Int64)] = score.Float64
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if current != nil {
+		return fn(*current)
+	}
+	return nil
+}
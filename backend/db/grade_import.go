@@ -0,0 +1,315 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"to-mrz/models"
+)
+
+// Columns in a grade import sheet that are not grade components
+const (
+	ColumnStudentNo   = "学号"
+	ColumnStudentName = "姓名"
+	ColumnClassName   = "班级"
+)
+
+// GetGradeComponents retrieves the grade components of a course offering
+func GetGradeComponents(courseOfferingID uint) ([]models.GradeComponent, error) {
+	components := []models.GradeComponent{}
+
+	rows, err := DB.Query(`
+		SELECT id, course_offering_id, name, weight
+		FROM grade_components
+		WHERE course_offering_id = ?
+		ORDER BY id
+	`, courseOfferingID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query grade components: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var gc models.GradeComponent
+		if err := rows.Scan(&gc.ID, &gc.CourseOfferingID, &gc.Name, &gc.Weight); err != nil {
+			return nil, fmt.Errorf("failed to scan grade component: %w", err)
+		}
+		components = append(components, gc)
+	}
+
+	return components, rows.Err()
+}
+
+// courseOfferingExists reports whether a course offering exists and is not deleted
+func courseOfferingExists(courseOfferingID uint) (bool, error) {
+	var count int
+	err := DB.QueryRow("SELECT COUNT(*) FROM course_offerings WHERE id = ? AND deleted_at IS NULL", courseOfferingID).Scan(&count)
+	return count > 0, err
+}
+
+// GetGradeImportTemplate returns the header and roster rows of a grade import sheet for a course offering
+func GetGradeImportTemplate(courseOfferingID uint) ([][]string, error) {
+	components, err := GetGradeComponents(courseOfferingID)
+	if err != nil {
+		return nil, err
+	}
+
+	roster, err := getCourseRoster(courseOfferingID)
+	if err != nil {
+		return nil, err
+	}
+
+	header := []string{ColumnStudentNo, ColumnStudentName, ColumnClassName}
+	for _, gc := range components {
+		header = append(header, gc.Name)
+	}
+
+	sheet := [][]string{header}
+	for _, e := range roster {
+		if e.Status == "已退选" {
+			continue
+		}
+		sheet = append(sheet, []string{e.Student.StudentID, e.Student.User.Name, e.Student.Class.Name})
+	}
+
+	return sheet, nil
+}
+
+// ValidateGradeImport checks every row of a grade import sheet against the offering's roster
+// and grade components. It returns the parsed rows and all row-level errors found.
+func ValidateGradeImport(courseOfferingID uint, sheet [][]string) ([]models.GradeImportRow, []models.GradeImportError, error) {
+	rows := []models.GradeImportRow{}
+	problems := []models.GradeImportError{}
+
+	exists, err := courseOfferingExists(courseOfferingID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !exists {
+		return nil, nil, sql.ErrNoRows
+	}
+
+	components, err := GetGradeComponents(courseOfferingID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(components) == 0 {
+		problems = append(problems, models.GradeImportError{Message: "该课程未设置成绩组成"})
+		return rows, problems, nil
+	}
+
+	var totalWeight float64
+	for _, gc := range components {
+		if gc.Weight < 0 {
+			problems = append(problems, models.GradeImportError{Column: gc.Name, Message: "成绩组成权重不能为负数"})
+		}
+		totalWeight += gc.Weight
+	}
+	if math.Abs(totalWeight-1) > 1e-6 {
+		problems = append(problems, models.GradeImportError{Message: fmt.Sprintf("成绩组成权重之和为%.2f，应为1", totalWeight)})
+	}
+
+	if len(sheet) == 0 {
+		problems = append(problems, models.GradeImportError{Message: "文件为空"})
+		return rows, problems, nil
+	}
+
+	// Map header columns to student number and grade components
+	studentCol := -1
+	var componentCols []int
+	columnComponent := map[int]models.GradeComponent{}
+	seen := map[string]bool{}
+	byName := map[string]models.GradeComponent{}
+	for _, gc := range components {
+		byName[gc.Name] = gc
+	}
+
+	for i, name := range sheet[0] {
+		switch {
+		case name == ColumnStudentNo:
+			studentCol = i
+		case name == ColumnStudentName || name == ColumnClassName || name == "":
+		case seen[name]:
+			problems = append(problems, models.GradeImportError{Row: 1, Column: name, Message: "列重复"})
+		default:
+			gc, ok := byName[name]
+			if !ok {
+				problems = append(problems, models.GradeImportError{Row: 1, Column: name, Message: "未知的成绩组成"})
+				continue
+			}
+			componentCols = append(componentCols, i)
+			columnComponent[i] = gc
+		}
+		seen[name] = true
+	}
+
+	if studentCol < 0 {
+		problems = append(problems, models.GradeImportError{Row: 1, Column: ColumnStudentNo, Message: "缺少学号列"})
+	}
+	for _, gc := range components {
+		if !seen[gc.Name] {
+			problems = append(problems, models.GradeImportError{Row: 1, Column: gc.Name, Message: "缺少成绩组成列"})
+		}
+	}
+	if len(problems) > 0 {
+		return rows, problems, nil
+	}
+
+	roster, err := getCourseRoster(courseOfferingID)
+	if err != nil {
+		return nil, nil, err
+	}
+	enrolled := map[string]models.Enrollment{}
+	for _, e := range roster {
+		if e.Status != "已退选" {
+			enrolled[e.Student.StudentID] = e
+		}
+	}
+
+	imported := map[string]int{}
+	for i, record := range sheet[1:] {
+		rowNum := i + 2
+		if isBlankRow(record) {
+			continue
+		}
+
+		studentNo := cell(record, studentCol)
+		if studentNo == "" {
+			problems = append(problems, models.GradeImportError{Row: rowNum, Column: ColumnStudentNo, Message: "学号不能为空"})
+			continue
+		}
+
+		if prev, ok := imported[studentNo]; ok {
+			problems = append(problems, models.GradeImportError{Row: rowNum, StudentNo: studentNo, Message: fmt.Sprintf("与第%d行学号重复", prev)})
+			continue
+		}
+		imported[studentNo] = rowNum
+
+		enrollment, ok := enrolled[studentNo]
+		if !ok {
+			known, err := studentNumberExists(studentNo)
+			if err != nil {
+				return nil, nil, err
+			}
+			message := "学生未选修该课程"
+			if !known {
+				message = "学号不存在"
+			}
+			problems = append(problems, models.GradeImportError{Row: rowNum, Column: ColumnStudentNo, StudentNo: studentNo, Message: message})
+			continue
+		}
+
+		row := models.GradeImportRow{
+			Row:          rowNum,
+			EnrollmentID: enrollment.ID,
+			StudentNo:    studentNo,
+			StudentName:  enrollment.Student.User.Name,
+			Scores:       map[string]float64{},
+		}
+
+		valid := true
+		for _, col := range componentCols {
+			gc := columnComponent[col]
+			raw := cell(record, col)
+			score, err := strconv.ParseFloat(raw, 64)
+			switch {
+			case raw == "":
+				problems = append(problems, models.GradeImportError{Row: rowNum, Column: gc.Name, StudentNo: studentNo, Message: "成绩不能为空"})
+				valid = false
+			case err != nil:
+				problems = append(problems, models.GradeImportError{Row: rowNum, Column: gc.Name, StudentNo: studentNo, Message: "成绩不是有效数字"})
+				valid = false
+			case score < 0 || score > 100:
+				problems = append(problems, models.GradeImportError{Row: rowNum, Column: gc.Name, StudentNo: studentNo, Message: "成绩必须在0到100之间"})
+				valid = false
+			default:
+				row.Scores[gc.Name] = score
+				row.Total += score * gc.Weight
+			}
+		}
+		if !valid {
+			continue
+		}
+
+		row.Total = math.Round(row.Total*100) / 100
+		row.Status = getStatusFromGrade(row.Total)
+		rows = append(rows, row)
+	}
+
+	if len(rows) == 0 && len(problems) == 0 {
+		problems = append(problems, models.GradeImportError{Message: "文件中没有成绩数据"})
+	}
+
+	return rows, problems, nil
+}
+
+// ApplyGradeImport writes validated import rows in a single transaction, storing each
+// component score and the weighted total on the enrollment
+func ApplyGradeImport(courseOfferingID uint, rows []models.GradeImportRow) error {
+	components, err := GetGradeComponents(courseOfferingID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin grade import: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	for _, row := range rows {
+		for _, gc := range components {
+			_, err := tx.Exec(`
+				INSERT INTO grade_details (enrollment_id, grade_component_id, score, created_at, updated_at)
+				VALUES (?, ?, ?, ?, ?)
+				ON CONFLICT(enrollment_id, grade_component_id) DO UPDATE SET score = excluded.score, updated_at = excluded.updated_at
+			`, row.EnrollmentID, gc.ID, row.Scores[gc.Name], now, now)
+			if err != nil {
+				return fmt.Errorf("failed to save grade detail for row %d: %w", row.Row, err)
+			}
+		}
+
+		_, err := tx.Exec(`
+			UPDATE enrollments
+			SET grade = ?, status = ?, updated_at = ?
+			WHERE id = ? AND course_offering_id = ?
+		`, row.Total, row.Status, now, row.EnrollmentID, courseOfferingID)
+		if err != nil {
+			return fmt.Errorf("failed to update grade for row %d: %w", row.Row, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit grade import: %w", err)
+	}
+
+	return nil
+}
+
+// studentNumberExists reports whether a student with the given 学号 exists
+func studentNumberExists(studentNo string) (bool, error) {
+	var count int
+	err := DB.QueryRow("SELECT COUNT(*) FROM students WHERE student_id = ?", studentNo).Scan(&count)
+	return count > 0, err
+}
+
+func cell(record []string, i int) string {
+	if i < 0 || i >= len(record) {
+		return ""
+	}
+	return record[i]
+}
+
+func isBlankRow(record []string) bool {
+	for _, v := range record {
+		if v != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,418 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"to-mrz/models"
+)
+
+// ErrReviewConfirmed is returned when changing a graduation review that was already confirmed
+var ErrReviewConfirmed = errors.New("graduation review already confirmed")
+
+// GraduationRequirements selects the checks EvaluateGraduation makes besides the training
+// plan. They read the theses, internships and student_fees tables.
+type GraduationRequirements struct {
+	Thesis     bool // a graded thesis
+	Internship bool // a completed internship
+	FeesPaid   bool // no outstanding fees
+}
+
+// GraduationChecks is the school's configured graduation requirements
+var GraduationChecks GraduationRequirements
+
+// EvaluateGraduation checks a student against every graduation requirement and returns
+// the reasons the student is not eligible; an empty result means eligible
+func EvaluateGraduation(studentID uint) ([]models.GraduationReason, error) {
+	reasons := []models.GraduationReason{}
+
+	audit, err := GetDegreeAudit(studentID)
+	switch {
+	case errors.Is(err, ErrNoTrainingPlan):
+		reasons = append(reasons, models.GraduationReason{Code: models.ReasonNoTrainingPlan, Message: "未找到学生所在专业和年级的培养方案"})
+	case err != nil:
+		return nil, err
+	default:
+		if audit.Status != models.AuditSatisfied {
+			message := fmt.Sprintf("培养方案学分不足：已获学分%.1f，要求%.1f", audit.EarnedCredits, audit.MinCredits)
+			if audit.EarnedCredits >= audit.MinCredits {
+				var unmet []string
+				for _, g := range audit.Groups {
+					if g.Status != models.AuditSatisfied {
+						unmet = append(unmet, g.Name)
+					}
+				}
+				message = "培养方案课程组要求未满足：" + strings.Join(unmet, "、")
+			}
+			reasons = append(reasons, models.GraduationReason{Code: models.ReasonCreditsIncomplete, Message: message})
+		}
+
+		failed, err := getFailedCourseIDs(studentID)
+		if err != nil {
+			return nil, err
+		}
+		var failedRequired []string
+		for _, g := range audit.Groups {
+			for _, course := range g.Courses {
+				if course.Required && course.Status != models.AuditSatisfied && failed[course.CourseID] {
+					failedRequired = append(failedRequired, course.Name)
+				}
+			}
+		}
+		if len(failedRequired) > 0 {
+			reasons = append(reasons, models.GraduationReason{
+				Code:    models.ReasonFailedRequiredCourse,
+				Message: "存在未通过的必修课程：" + strings.Join(failedRequired, "、"),
+			})
+		}
+	}
+
+	var count int
+	if GraduationChecks.Thesis {
+		err = DB.QueryRow("SELECT COUNT(*) FROM theses WHERE student_id = ? AND grade IS NOT NULL", studentID).Scan(&count)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check thesis: %w", err)
+		}
+		if count == 0 {
+			reasons = append(reasons, models.GraduationReason{Code: models.ReasonNoThesisGrade, Message: "毕业论文尚无成绩"})
+		}
+	}
+
+	if GraduationChecks.Internship {
+		err = DB.QueryRow("SELECT COUNT(*) FROM internships WHERE student_id = ? AND status = '已完成'", studentID).Scan(&count)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check internship: %w", err)
+		}
+		if count == 0 {
+			reasons = append(reasons, models.GraduationReason{Code: models.ReasonInternshipIncomplete, Message: "实习未完成"})
+		}
+	}
+
+	if GraduationChecks.FeesPaid {
+		var outstanding float64
+		err = DB.QueryRow("SELECT COALESCE(SUM(amount - paid), 0) FROM student_fees WHERE student_id = ?", studentID).Scan(&outstanding)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check fees: %w", err)
+		}
+		if outstanding > 0 {
+			reasons = append(reasons, models.GraduationReason{Code: models.ReasonFeesOutstanding, Message: fmt.Sprintf("尚有%.2f元费用未缴清", outstanding)})
+		}
+	}
+
+	return reasons, nil
+}
+
+// getFailedCourseIDs returns the courses a student has a failing enrollment for that
+// was not rescued by a passed make-up exam
+func getFailedCourseIDs(studentID uint) (map[uint]bool, error) {
+	rows, err := DB.Query(`
+		SELECT DISTINCT co.course_id
+		FROM enrollments e
+		JOIN course_offerings co ON e.course_offering_id = co.id
+		WHERE e.student_id = ? AND e.status = '未通过'
+		  AND NOT EXISTS (SELECT 1 FROM exam_attempts a WHERE a.enrollment_id = e.id AND a.status = ?)
+	`, studentID, models.MakeupPassed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query failed courses: %w", err)
+	}
+	defer rows.Close()
+
+	failed := map[uint]bool{}
+	for rows.Next() {
+		var courseID uint
+		if err := rows.Scan(&courseID); err != nil {
+			return nil, err
+		}
+		failed[courseID] = true
+	}
+
+	return failed, rows.Err()
+}
+
+// RunGraduationReview evaluates every active student of a cohort (optionally one major)
+// and stores the results as a new draft review
+func RunGraduationReview(graduationYear, cohortYear int, majorID, createdBy uint) (uint, error) {
+	rows, err := DB.Query(`
+		SELECT s.id
+		FROM students s
+		JOIN classes cl ON s.class_id = cl.id
+		WHERE s.enroll_year = ? AND s.status = ? AND (? = 0 OR cl.major_id = ?)
+		ORDER BY s.student_id
+	`, cohortYear, models.StudentActive, majorID, majorID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query final-year students: %w", err)
+	}
+
+	var studentIDs []uint
+	for rows.Next() {
+		var id uint
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		studentIDs = append(studentIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	// Evaluate before opening the transaction so reads don't contend with the writer
+	results := make([][]models.GraduationReason, len(studentIDs))
+	for i, id := range studentIDs {
+		results[i], err = EvaluateGraduation(id)
+		if err != nil {
+			return 0, fmt.Errorf("failed to evaluate student %d: %w", id, err)
+		}
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	reviewID, err := insertID(tx, `
+		INSERT INTO graduation_reviews (graduation_year, cohort_year, major_id, status, created_by, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, graduationYear, cohortYear, majorID, models.ReviewDraft, createdBy, now, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create graduation review: %w", err)
+	}
+
+	for i, studentID := range studentIDs {
+		reasons, err := json.Marshal(results[i])
+		if err != nil {
+			return 0, err
+		}
+		_, err = tx.Exec(`
+			INSERT INTO graduation_results (review_id, student_id, eligible, reasons)
+			VALUES (?, ?, ?, ?)
+		`, reviewID, studentID, len(results[i]) == 0, string(reasons))
+		if err != nil {
+			return 0, fmt.Errorf("failed to save graduation result: %w", err)
+		}
+	}
+
+	return reviewID, tx.Commit()
+}
+
+// GraduationReviewFilter filters the graduation review list; zero values match every review
+type GraduationReviewFilter struct {
+	ListQuery
+	GraduationYear int
+	CohortYear     int
+	MajorID        uint
+	Status         string
+}
+
+// graduationReviewList sorts the graduation review list, newest first by default
+var graduationReviewList = listSpec{
+	sorts: map[string]string{
+		"id":              "r.id",
+		"graduation_year": "r.graduation_year",
+		"cohort_year":     "r.cohort_year",
+		"created_at":      "r.created_at",
+	},
+	order: []string{"-id"},
+	key:   "r.id",
+}
+
+// GetGraduationReviews retrieves a page of the graduation reviews matching the filter,
+// with result counts
+func GetGraduationReviews(filter GraduationReviewFilter) (*models.Page, error) {
+	q := &selectQuery{
+		columns: `r.id, r.graduation_year, r.cohort_year, r.major_id, r.status, r.created_by, r.confirmed_at,
+			r.created_at, r.updated_at,
+			(SELECT COUNT(*) FROM graduation_results gr WHERE gr.review_id = r.id),
+			(SELECT COALESCE(SUM(CASE WHEN COALESCE(gr.override_eligible, gr.eligible) THEN 1 ELSE 0 END), 0)
+			 FROM graduation_results gr WHERE gr.review_id = r.id)`,
+		from: "graduation_reviews r",
+	}
+	if filter.GraduationYear != 0 {
+		q.filter("r.graduation_year = ?", filter.GraduationYear)
+	}
+	if filter.CohortYear != 0 {
+		q.filter("r.cohort_year = ?", filter.CohortYear)
+	}
+	if filter.MajorID != 0 {
+		q.filter("r.major_id = ?", filter.MajorID)
+	}
+	if filter.Status != "" {
+		q.filter("r.status = ?", filter.Status)
+	}
+
+	reviews := []models.GraduationReview{}
+	page, err := graduationReviewList.list(filter.ListQuery, q, func(row rowScanner) error {
+		review, err := scanGraduationReview(row)
+		if err != nil {
+			return err
+		}
+		reviews = append(reviews, *review)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query graduation reviews: %w", err)
+	}
+	page.Items = reviews
+	return page, nil
+}
+
+// GetGraduationReview retrieves a graduation review with every student's result
+func GetGraduationReview(id uint) (*models.GraduationReview, error) {
+	review, err := scanGraduationReview(DB.QueryRow(`
+		SELECT r.id, r.graduation_year, r.cohort_year, r.major_id, r.status, r.created_by, r.confirmed_at,
+		       r.created_at, r.updated_at,
+		       COUNT(gr.id), COALESCE(SUM(CASE WHEN COALESCE(gr.override_eligible, gr.eligible) THEN 1 ELSE 0 END), 0)
+		FROM graduation_reviews r
+		LEFT JOIN graduation_results gr ON gr.review_id = r.id
+		WHERE r.id = ?
+		GROUP BY r.id
+	`, id))
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := DB.Query(`
+		SELECT gr.id, gr.review_id, gr.student_id, s.student_id, u.name, gr.eligible, gr.reasons,
+		       gr.override_eligible, COALESCE(gr.override_reason, ''), gr.overridden_by
+		FROM graduation_results gr
+		JOIN students s ON gr.student_id = s.id
+		JOIN users u ON s.user_id = u.id
+		WHERE gr.review_id = ?
+		ORDER BY s.student_id
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query graduation results: %w", err)
+	}
+	defer rows.Close()
+
+	review.Results = []models.GraduationResult{}
+	for rows.Next() {
+		var r models.GraduationResult
+		var reasons string
+		var override sql.NullBool
+		var overriddenBy sql.NullInt64
+
+		err := rows.Scan(&r.ID, &r.ReviewID, &r.StudentID, &r.StudentNo, &r.StudentName, &r.Eligible, &reasons,
+			&override, &r.OverrideReason, &overriddenBy)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan graduation result: %w", err)
+		}
+
+		if err := json.Unmarshal([]byte(reasons), &r.Reasons); err != nil {
+			return nil, fmt.Errorf("failed to decode graduation reasons: %w", err)
+		}
+		r.FinalEligible = r.Eligible
+		if override.Valid {
+			r.OverrideEligible = &override.Bool
+			r.FinalEligible = override.Bool
+		}
+		if overriddenBy.Valid {
+			by := uint(overriddenBy.Int64)
+			r.OverriddenBy = &by
+		}
+
+		review.Results = append(review.Results, r)
+	}
+
+	return review, rows.Err()
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanGraduationReview(row rowScanner) (*models.GraduationReview, error) {
+	var review models.GraduationReview
+	var confirmedAt sql.NullTime
+
+	err := row.Scan(&review.ID, &review.GraduationYear, &review.CohortYear, &review.MajorID, &review.Status,
+		&review.CreatedBy, nullTimestamp{&confirmedAt}, timestamp{&review.CreatedAt}, timestamp{&review.UpdatedAt}, &review.Total, &review.EligibleCount)
+	if err != nil {
+		return nil, err
+	}
+
+	if confirmedAt.Valid {
+		review.ConfirmedAt = &confirmedAt.Time
+	}
+	return &review, nil
+}
+
+// OverrideGraduationResult records a manual eligibility decision with its justification
+func OverrideGraduationResult(reviewID, studentID uint, eligible bool, justification string, overriddenBy uint) error {
+	var status string
+	err := DB.QueryRow("SELECT status FROM graduation_reviews WHERE id = ?", reviewID).Scan(&status)
+	if err != nil {
+		return err
+	}
+	if status != models.ReviewDraft {
+		return ErrReviewConfirmed
+	}
+
+	result, err := DB.Exec(`
+		UPDATE graduation_results
+		SET override_eligible = ?, override_reason = ?, overridden_by = ?
+		WHERE review_id = ? AND student_id = ?
+	`, eligible, justification, overriddenBy, reviewID, studentID)
+	if err != nil {
+		return fmt.Errorf("failed to override graduation result: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+
+	_, err = DB.Exec("UPDATE graduation_reviews SET updated_at = ? WHERE id = ?", time.Now(), reviewID)
+	return err
+}
+
+// ConfirmGraduationReview marks every finally eligible student of a review as graduated
+// and locks the review. It returns the number of students graduated.
+func ConfirmGraduationReview(id uint) (int64, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var status string
+	err = tx.QueryRow("SELECT status FROM graduation_reviews WHERE id = ?", id).Scan(&status)
+	if err != nil {
+		return 0, err
+	}
+	if status != models.ReviewDraft {
+		return 0, ErrReviewConfirmed
+	}
+
+	now := time.Now()
+	result, err := tx.Exec(`
+		UPDATE students
+		SET status = ?, updated_at = ?
+		WHERE status = ? AND id IN (
+			SELECT student_id FROM graduation_results
+			WHERE review_id = ? AND COALESCE(override_eligible, eligible)
+		)
+	`, models.StudentGraduated, now, models.StudentActive, id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to graduate students: %w", err)
+	}
+
+	graduated, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE graduation_reviews SET status = ?, confirmed_at = ?, updated_at = ? WHERE id = ?
+	`, models.ReviewConfirmed, now, now, id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to confirm graduation review: %w", err)
+	}
+
+	return graduated, tx.Commit()
+}
@@ -0,0 +1,137 @@
+package db
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"to-mrz/models"
+)
+
+// graduationFixtures has a 2020 cohort whose training plan requires two 4-credit
+// courses, and four students of it:
+//   - 2020001 passed both courses and has a thesis grade, an internship and paid fees;
+//   - 2020002 failed 数据结构, has no thesis or internship and owes fees;
+//   - 2020003 passed both courses, but the thesis is ungraded, the internship is still
+//     running and part of a fee is unpaid;
+//   - 2020004 has already graduated and is not reviewed again.
+//
+// A 2021 student with no records shows that other cohorts are left out.
+const graduationFixtures = `
+INSERT INTO users (username, password, name, role) VALUES
+	('2020001', 'x', '张三', 'student'), ('2020002', 'x', '李四', 'student'), ('2020003', 'x', '王五', 'student'),
+	('2020004', 'x', '赵六', 'student'), ('2021001', 'x', '钱七', 'student'), ('t1001', 'x', '王老师', 'teacher'),
+	('admin', 'x', '管理员', 'admin');
+INSERT INTO departments (name, code) VALUES ('计算机学院', 'CS');
+INSERT INTO majors (name, code, department_id) VALUES ('计算机科学与技术', 'CS01', 1);
+INSERT INTO classes (name, code, major_id, year) VALUES ('计科2020-1班', 'CS2020-1', 1, 2020), ('计科2021-1班', 'CS2021-1', 1, 2021);
+INSERT INTO students (user_id, student_id, class_id, enroll_year, status) VALUES
+	(1, '2020001', 1, 2020, '在读'), (2, '2020002', 1, 2020, '在读'), (3, '2020003', 1, 2020, '在读'),
+	(4, '2020004', 1, 2020, '已毕业'), (5, '2021001', 2, 2021, '在读');
+INSERT INTO teachers (user_id, department_id) VALUES (6, 1);
+INSERT INTO courses (name, code, credits, hours, type, department_id) VALUES
+	('程序设计', 'CS101', 4, 64, '必修', 1), ('数据结构', 'CS201', 4, 64, '必修', 1);
+INSERT INTO semesters (name, start_date, end_date) VALUES ('2021-2022学年第一学期', '2021-09-01 00:00:00', '2022-01-15 00:00:00');
+INSERT INTO course_offerings (course_id, semester_id, teacher_id, capacity, status) VALUES (1, 1, 1, 50, 'open'), (2, 1, 1, 50, 'open');
+INSERT INTO enrollments (student_id, course_offering_id, grade, status) VALUES
+	(1, 1, 90, '已完成'), (1, 2, 85, '已完成'),
+	(2, 1, 70, '已完成'), (2, 2, 40, '未通过'),
+	(3, 1, 80, '已完成'), (3, 2, 75, '已完成'),
+	(4, 1, 88, '已完成'), (4, 2, 92, '已完成');
+INSERT INTO training_plans (major_id, cohort_year, name, min_credits) VALUES (1, 2020, '计算机科学与技术2020级培养方案', 8);
+INSERT INTO plan_course_groups (training_plan_id, name, category, min_credits) VALUES (1, '专业必修课', '必修', 8);
+INSERT INTO plan_group_courses (group_id, course_id, required) VALUES (1, 1, TRUE), (1, 2, TRUE);
+INSERT INTO theses (student_id, teacher_id, title, status, grade) VALUES
+	(1, 1, '分布式事务研究', '已答辩', 86), (3, 1, '编译器优化研究', '撰写中', NULL);
+INSERT INTO internships (student_id, company, position, start_date, end_date, teacher_id, status) VALUES
+	(1, '某科技公司', '开发实习生', '2023-07-01 00:00:00', '2023-09-01 00:00:00', 1, '已完成'),
+	(3, '某科技公司', '测试实习生', '2024-03-01 00:00:00', '2024-06-01 00:00:00', 1, '进行中');
+INSERT INTO student_fees (student_id, item, amount, paid) VALUES
+	(1, '学费', 5000, 5000), (2, '学费', 5000, 0), (3, '学费', 5000, 4000), (3, '住宿费', 1200, 1200);
+`
+
+// reasonCodes returns the codes of a student's reasons in a review, by student number
+func reasonCodes(t *testing.T, reviewID uint) map[string][]string {
+	t.Helper()
+	review, err := GetGraduationReview(reviewID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	codes := map[string][]string{}
+	for _, r := range review.Results {
+		if r.Eligible != (len(r.Reasons) == 0) {
+			t.Errorf("%s: eligible = %v with reasons %v", r.StudentNo, r.Eligible, r.Reasons)
+		}
+		codes[r.StudentNo] = []string{}
+		for _, reason := range r.Reasons {
+			codes[r.StudentNo] = append(codes[r.StudentNo], reason.Code)
+		}
+	}
+	return codes
+}
+
+func TestGraduationReview(t *testing.T) {
+	openTestDB(t)
+	if _, err := DB.Exec(graduationFixtures); err != nil {
+		t.Fatal(err)
+	}
+	saved := GraduationChecks
+	t.Cleanup(func() { GraduationChecks = saved })
+
+	for _, tc := range []struct {
+		name   string
+		checks GraduationRequirements
+		want   map[string][]string
+	}{
+		{"training plan only", GraduationRequirements{}, map[string][]string{
+			"2020001": {},
+			"2020002": {models.ReasonCreditsIncomplete, models.ReasonFailedRequiredCourse},
+			"2020003": {},
+		}},
+		{"every requirement", GraduationRequirements{Thesis: true, Internship: true, FeesPaid: true}, map[string][]string{
+			"2020001": {},
+			"2020002": {models.ReasonCreditsIncomplete, models.ReasonFailedRequiredCourse,
+				models.ReasonNoThesisGrade, models.ReasonInternshipIncomplete, models.ReasonFeesOutstanding},
+			"2020003": {models.ReasonNoThesisGrade, models.ReasonInternshipIncomplete, models.ReasonFeesOutstanding},
+		}},
+	} {
+		GraduationChecks = tc.checks
+		reviewID, err := RunGraduationReview(2024, 2020, 1, 7)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+
+		got := reasonCodes(t, reviewID)
+		if len(got) != len(tc.want) {
+			t.Errorf("%s: reviewed %v, want %d students of the 2020 cohort", tc.name, got, len(tc.want))
+		}
+		for studentNo, want := range tc.want {
+			if codes, ok := got[studentNo]; !ok || !slices.Equal(codes, want) {
+				t.Errorf("%s: %s has reasons %v, want %v", tc.name, studentNo, codes, want)
+			}
+		}
+	}
+
+	// the second review counts 2020001 eligible; an override adds 2020003
+	if err := OverrideGraduationResult(2, 3, true, "论文与实习材料已补交", 7); err != nil {
+		t.Fatal(err)
+	}
+	graduated, err := ConfirmGraduationReview(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if graduated != 2 {
+		t.Errorf("%d students graduated, want 2", graduated)
+	}
+	if _, err := ConfirmGraduationReview(2); !errors.Is(err, ErrReviewConfirmed) {
+		t.Errorf("confirming again: %v, want ErrReviewConfirmed", err)
+	}
+
+	review, err := GetGraduationReview(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if review.Total != 3 || review.EligibleCount != 2 {
+		t.Errorf("review counts %d eligible of %d, want 2 of 3", review.EligibleCount, review.Total)
+	}
+}
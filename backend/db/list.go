@@ -0,0 +1,333 @@
+package db
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"to-mrz/models"
+)
+
+// List page sizes
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// ErrInvalidListQuery is matched by the errors of lists given sort fields, searches
+// or cursors they do not accept
+var ErrInvalidListQuery = errors.New("invalid list query")
+
+// ListQueryError is returned for a list query the list does not accept. It matches
+// ErrInvalidListQuery. Reason is a fmt format, formatted with Args, so the API can
+// translate it.
+type ListQueryError struct {
+	Reason string
+	Args   []interface{}
+}
+
+func (e *ListQueryError) Error() string {
+	return fmt.Sprintf("%v: %s", ErrInvalidListQuery, fmt.Sprintf(e.Reason, e.Args...))
+}
+
+func (e *ListQueryError) Unwrap() error {
+	return ErrInvalidListQuery
+}
+
+// ListQuery pages, sorts and searches a list. Page counts from 1 and is ignored when
+// Cursor is set; a PageSize of zero means DefaultPageSize.
+type ListQuery struct {
+	Page     int
+	PageSize int
+	Cursor   string   // the next_cursor of the previous page
+	Sort     []string // field names, descending with a "-" prefix
+	Search   string   // keyword matched against the list's text columns
+}
+
+// pageSize returns the number of rows a page of the query holds
+func (query ListQuery) pageSize() int {
+	if query.PageSize <= 0 {
+		return DefaultPageSize
+	}
+	return min(query.PageSize, MaxPageSize)
+}
+
+// listSpec describes how a list can be sorted and searched
+type listSpec struct {
+	sorts  map[string]string // sort field -> expression, which must not be NULL
+	order  []string          // default sort
+	key    string            // unique column that ends every order
+	search []string          // text columns matched by ListQuery.Search
+}
+
+// orderTerm is one expression of an ORDER BY
+type orderTerm struct {
+	expr string
+	desc bool
+}
+
+// selectQuery is a list's SELECT without ORDER BY and LIMIT. Filters add conditions
+// to where with their arguments.
+type selectQuery struct {
+	columns string
+	from    string
+	where   []string
+	args    []interface{}
+}
+
+// filter adds a condition that every row of the list must meet
+func (q *selectQuery) filter(condition string, args ...interface{}) {
+	q.where = append(q.where, condition)
+	q.args = append(q.args, args...)
+}
+
+// whereClause joins the conditions into a WHERE clause, empty without conditions
+func (q *selectQuery) whereClause() string {
+	if len(q.where) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(q.where, " AND ")
+}
+
+// fields returns the sort fields of the spec, for error messages
+func (spec listSpec) fields() string {
+	fields := make([]string, 0, len(spec.sorts))
+	for field := range spec.sorts {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return strings.Join(fields, ", ")
+}
+
+// orderBy resolves sort fields to the terms of an ORDER BY that always ends with the
+// spec's key, so that rows have a stable order. It also returns the sort in a
+// canonical form, which cursors are tied to.
+func (spec listSpec) orderBy(fields []string) ([]orderTerm, string, error) {
+	if len(fields) == 0 {
+		fields = spec.order
+	}
+
+	var terms []orderTerm
+	var names []string
+	seen := map[string]bool{}
+	for _, field := range fields {
+		name := strings.TrimPrefix(field, "-")
+		expr, ok := spec.sorts[name]
+		if !ok {
+			return nil, "", &ListQueryError{Reason: "Unknown sort field %q, expected one of %s", Args: []interface{}{name, spec.fields()}}
+		}
+		if seen[expr] {
+			continue
+		}
+		seen[expr] = true
+		terms = append(terms, orderTerm{expr: expr, desc: name != field})
+		names = append(names, field)
+	}
+	if !seen[spec.key] {
+		terms = append(terms, orderTerm{expr: spec.key})
+	}
+	return terms, strings.Join(names, ","), nil
+}
+
+// listCursor is the position after the last row of a page. After holds the values
+// of the ORDER BY terms as the database prints them, so that they compare exactly
+// like the stored values.
+type listCursor struct {
+	Sort  string   `json:"sort"`
+	After []string `json:"after"`
+}
+
+func encodeCursor(cursor listCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(value, sort string, terms int) (listCursor, error) {
+	var cursor listCursor
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err == nil {
+		err = json.Unmarshal(data, &cursor)
+	}
+	if err != nil || len(cursor.After) != terms {
+		return cursor, &ListQueryError{Reason: "Malformed cursor"}
+	}
+	if cursor.Sort != sort {
+		return cursor, &ListQueryError{Reason: "The cursor belongs to another sort"}
+	}
+	return cursor, nil
+}
+
+// keysetCondition selects the rows that come after values in the order of terms
+func keysetCondition(terms []orderTerm, values []string) (string, []interface{}) {
+	var alternatives []string
+	var args []interface{}
+	for i, term := range terms {
+		var parts []string
+		for _, previous := range terms[:i] {
+			parts = append(parts, previous.expr+" = ?")
+		}
+		operator := " > ?"
+		if term.desc {
+			operator = " < ?"
+		}
+		parts = append(parts, term.expr+operator)
+		for _, value := range values[:i+1] {
+			args = append(args, value)
+		}
+		alternatives = append(alternatives, "("+strings.Join(parts, " AND ")+")")
+	}
+	return "(" + strings.Join(alternatives, " OR ") + ")", args
+}
+
+// likePattern turns a search keyword into a LIKE pattern matching it anywhere,
+// escaping the wildcards it contains with '!'
+func likePattern(keyword string) string {
+	return "%" + strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(keyword) + "%"
+}
+
+// cursorScanner passes a row to a list's scan function and keeps the ORDER BY values
+// selected after its columns for the next cursor
+type cursorScanner struct {
+	rows   rowScanner
+	values []string
+}
+
+// Scan implements rowScanner
+func (s cursorScanner) Scan(dest ...interface{}) error {
+	for i := range s.values {
+		dest = append(dest, &s.values[i])
+	}
+	return s.rows.Scan(dest...)
+}
+
+// list runs a list query and returns one page of it. scan reads the columns of a row
+// and keeps the item; the caller sets the page's Items to the kept items.
+func (spec listSpec) list(query ListQuery, q *selectQuery, scan func(rowScanner) error) (*models.Page, error) {
+	terms, sortKey, err := spec.orderBy(query.Sort)
+	if err != nil {
+		return nil, err
+	}
+
+	if keyword := strings.TrimSpace(query.Search); keyword != "" {
+		if len(spec.search) == 0 {
+			return nil, &ListQueryError{Reason: "This list cannot be searched"}
+		}
+		conditions := make([]string, len(spec.search))
+		pattern := likePattern(keyword)
+		args := make([]interface{}, len(spec.search))
+		for i, column := range spec.search {
+			conditions[i] = column + " LIKE ? ESCAPE '!'"
+			args[i] = pattern
+		}
+		q.filter("("+strings.Join(conditions, " OR ")+")", args...)
+	}
+
+	page := &models.Page{PageSize: query.pageSize()}
+
+	var cursor listCursor
+	if query.Cursor != "" {
+		if cursor, err = decodeCursor(query.Cursor, sortKey, len(terms)); err != nil {
+			return nil, err
+		}
+	} else {
+		page.Page = max(query.Page, 1)
+	}
+
+	// The total counts every row of the filter, including those before the cursor
+	if err := DB.QueryRow("SELECT COUNT(*) FROM "+q.from+q.whereClause(), q.args...).Scan(&page.Total); err != nil {
+		return nil, err
+	}
+	if query.Cursor != "" {
+		condition, args := keysetCondition(terms, cursor.After)
+		q.filter(condition, args...)
+	}
+
+	// The ORDER BY values are selected as text so that cursors hold them exactly as
+	// stored, whatever the driver would have converted them to
+	textType := "TEXT"
+	if DB.Dialect() == MySQL {
+		textType = "CHAR"
+	}
+	columns := q.columns
+	orders := make([]string, len(terms))
+	for i, term := range terms {
+		columns += ", CAST(" + term.expr + " AS " + textType + ")"
+		orders[i] = term.expr
+		if term.desc {
+			orders[i] += " DESC"
+		}
+	}
+	statement := "SELECT " + columns + " FROM " + q.from + q.whereClause() + " ORDER BY " + strings.Join(orders, ", ") + " LIMIT ?"
+	// One more row than the page holds tells whether there is a next page
+	args := append(q.args, page.PageSize+1)
+	if page.Page > 0 {
+		statement += " OFFSET ?"
+		args = append(args, (page.Page-1)*page.PageSize)
+	}
+
+	rows, err := DB.Query(statement, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var last []string
+	for n := 0; rows.Next(); n++ {
+		if n == page.PageSize {
+			page.NextCursor = encodeCursor(listCursor{Sort: sortKey, After: last})
+			break
+		}
+		values := make([]string, len(terms))
+		if err := scan(cursorScanner{rows, values}); err != nil {
+			return nil, err
+		}
+		last = values
+	}
+	return page, rows.Err()
+}
+
+// listSlice returns one page of a list that is not read from a table, such as the
+// backup files. items is a slice in list order and key(i) the unique key of its i-th
+// item, which cursors continue after. Such lists cannot be sorted or searched.
+func listSlice(query ListQuery, items interface{}, key func(i int) string) (*models.Page, error) {
+	if len(query.Sort) > 0 {
+		return nil, &ListQueryError{Reason: "This list cannot be sorted"}
+	}
+	if strings.TrimSpace(query.Search) != "" {
+		return nil, &ListQueryError{Reason: "This list cannot be searched"}
+	}
+
+	all := reflect.ValueOf(items)
+	page := &models.Page{Total: all.Len(), PageSize: query.pageSize()}
+	start := 0
+	if query.Cursor != "" {
+		cursor, err := decodeCursor(query.Cursor, "", 1)
+		if err != nil {
+			return nil, err
+		}
+		start = -1
+		for i := 0; i < all.Len(); i++ {
+			if key(i) == cursor.After[0] {
+				start = i + 1
+				break
+			}
+		}
+		if start < 0 {
+			return nil, &ListQueryError{Reason: "The cursor's item no longer exists"}
+		}
+	} else {
+		page.Page = max(query.Page, 1)
+		start = min((page.Page-1)*page.PageSize, all.Len())
+	}
+
+	end := min(start+page.PageSize, all.Len())
+	if end < all.Len() {
+		page.NextCursor = encodeCursor(listCursor{After: []string{key(end - 1)}})
+	}
+	page.Items = all.Slice(start, end).Interface()
+	return page, nil
+}
@@ -0,0 +1,376 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"to-mrz/models"
+)
+
+// LoginThrottle holds the brute-force protection thresholds for logins
+type LoginThrottle struct {
+	MaxUserFailures int           // failures per username before lockout
+	MaxIPFailures   int           // failures per client IP before lockout
+	FailureWindow   time.Duration // failures older than this are forgotten
+	LockoutDuration time.Duration
+	BaseDelay       time.Duration // wait after the first failure, doubled after each further one
+	MaxDelay        time.Duration
+}
+
+// LoginLimits are the thresholds applied by BeginLoginAttempt
+var LoginLimits = LoginThrottle{
+	MaxUserFailures: 5,
+	MaxIPFailures:   20,
+	FailureWindow:   15 * time.Minute,
+	LockoutDuration: 15 * time.Minute,
+	BaseDelay:       time.Second,
+	MaxDelay:        30 * time.Second,
+}
+
+// Login throttling errors
+var (
+	ErrLoginLocked  = errors.New("too many failed login attempts")
+	ErrLoginTooSoon = errors.New("login attempted too soon after a failure")
+)
+
+// Throttle key prefixes, see login_throttles
+const (
+	throttleUserPrefix = "user:"
+	throttleIPPrefix   = "ip:"
+)
+
+// loginThrottle is one row of login_throttles
+type loginThrottle struct {
+	failures      int
+	lastFailureAt time.Time
+	lockedUntil   sql.NullTime
+}
+
+// lockedAt reports whether the counter is locked out at the given time
+func (t *loginThrottle) lockedAt(now time.Time) bool {
+	return t.lockedUntil.Valid && t.lockedUntil.Time.After(now)
+}
+
+// delay returns how long to wait after the last failure before trying again
+func (t *loginThrottle) delay(limits LoginThrottle) time.Duration {
+	if t.failures <= 0 || limits.BaseDelay <= 0 {
+		return 0
+	}
+	d := limits.BaseDelay
+	for i := 1; i < t.failures && d < limits.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > limits.MaxDelay {
+		d = limits.MaxDelay
+	}
+	return d
+}
+
+// rowQuerier is implemented by both *Database and *Tx
+type rowQuerier interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// getLoginThrottle loads a throttle counter, returning nil if there is none
+func getLoginThrottle(q rowQuerier, key string) (*loginThrottle, error) {
+	t := &loginThrottle{}
+	err := q.QueryRow(
+		"SELECT failures, last_failure_at, locked_until FROM login_throttles WHERE throttle_key = ?", key,
+	).Scan(&t.failures, timestamp{&t.lastFailureAt}, nullTimestamp{&t.lockedUntil})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// loginCounter is a throttle counter of a login attempt with its threshold
+type loginCounter struct {
+	key         string
+	maxFailures int
+}
+
+// loginCounters lists the counters of a login attempt, always in the same order so
+// that concurrent attempts lock them in the same order
+func loginCounters(username, ip string) []loginCounter {
+	return []loginCounter{
+		{throttleIPPrefix + ip, LoginLimits.MaxIPFailures},
+		{throttleUserPrefix + username, LoginLimits.MaxUserFailures},
+	}
+}
+
+// checkLoginAllowed applies the limits to the IP and username counters of an attempt.
+// Progressive delays apply per username only, so that users sharing an IP address
+// (such as a campus NAT) do not slow each other down.
+func checkLoginAllowed(ipThrottle, userThrottle *loginThrottle, now time.Time) (time.Duration, error) {
+	if ipThrottle.lockedAt(now) {
+		return ipThrottle.lockedUntil.Time.Sub(now), ErrLoginLocked
+	}
+	if userThrottle.lockedAt(now) {
+		return userThrottle.lockedUntil.Time.Sub(now), ErrLoginLocked
+	}
+	if userThrottle.lockedUntil.Valid || now.Sub(userThrottle.lastFailureAt) > LoginLimits.FailureWindow {
+		// Lockout over or failures forgotten
+		return 0, nil
+	}
+	if retry := userThrottle.lastFailureAt.Add(userThrottle.delay(LoginLimits)).Sub(now); retry > 0 {
+		return retry, ErrLoginTooSoon
+	}
+	return 0, nil
+}
+
+// BeginLoginAttempt reports whether a login for username from ip may be attempted now
+// and, if so, counts the attempt as a failure against both the username and the client
+// IP, locking either out once it reaches its threshold. The check and the count happen
+// in one transaction with the counters locked, so concurrent guesses cannot all pass
+// the check before any of them is counted.
+//
+// When the attempt may not go ahead, it returns ErrLoginLocked or ErrLoginTooSoon
+// together with how long the client should wait. Otherwise the caller finishes the
+// attempt with RecordLoginFailure or, when the credentials were right, with
+// ReleaseLoginAttempt.
+func BeginLoginAttempt(username, ip string) (time.Duration, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	counters := loginCounters(username, ip)
+	throttles := make([]*loginThrottle, len(counters))
+	for i, counter := range counters {
+		// Writing the row first locks it until the transaction ends; a new counter
+		// starts without failures, and is rolled back if the attempt is refused
+		_, err := tx.Exec(`
+			INSERT INTO login_throttles (throttle_key, failures, last_failure_at)
+			VALUES (?, 0, ?)
+			ON CONFLICT(throttle_key) DO UPDATE SET failures = login_throttles.failures
+		`, counter.key, now)
+		if err != nil {
+			return 0, err
+		}
+		if throttles[i], err = getLoginThrottle(tx, counter.key); err != nil {
+			return 0, err
+		}
+	}
+
+	if wait, err := checkLoginAllowed(throttles[0], throttles[1], now); err != nil {
+		return wait, err
+	}
+
+	for i, counter := range counters {
+		t := throttles[i]
+		failures, lastFailureAt := t.failures+1, t.lastFailureAt
+		if t.failures == 0 || t.lockedUntil.Valid || now.Sub(t.lastFailureAt) > LoginLimits.FailureWindow {
+			// Lockout over or failures forgotten: this attempt starts a new count
+			failures, lastFailureAt = 1, now
+		}
+
+		var lockedUntil interface{}
+		if failures >= counter.maxFailures {
+			lockedUntil = now.Add(LoginLimits.LockoutDuration)
+		}
+
+		_, err := tx.Exec(`
+			UPDATE login_throttles SET failures = ?, last_failure_at = ?, locked_until = ?
+			WHERE throttle_key = ?
+		`, failures, lastFailureAt, lockedUntil, counter.key)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return 0, tx.Commit()
+}
+
+// RecordLoginFailure records the time of a failed attempt begun with BeginLoginAttempt,
+// which has already been counted, so that the next attempt for the username waits
+// for its progressive delay
+func RecordLoginFailure(username, ip string) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	for _, counter := range loginCounters(username, ip) {
+		if _, err := tx.Exec("UPDATE login_throttles SET last_failure_at = ? WHERE throttle_key = ?", now, counter.key); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ReleaseLoginAttempt takes back the failure counted by BeginLoginAttempt for an
+// attempt whose credentials were right, or that could not be checked, lifting a
+// lockout the attempt itself brought on
+func ReleaseLoginAttempt(username, ip string) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, counter := range loginCounters(username, ip) {
+		// locked_until comes first: MySQL assigns left to right with the new values
+		_, err := tx.Exec(`
+			UPDATE login_throttles SET
+				locked_until = CASE WHEN failures - 1 < ? THEN NULL ELSE locked_until END,
+				failures = failures - 1
+			WHERE throttle_key = ? AND failures > 0
+		`, counter.maxFailures, counter.key)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// RecordLoginSuccess clears the failure counter of a username. The client IP's counter
+// is kept so that an attacker cannot reset it by signing in to their own account.
+func RecordLoginSuccess(username string) error {
+	_, err := DB.Exec("DELETE FROM login_throttles WHERE throttle_key = ?", throttleUserPrefix+username)
+	return err
+}
+
+// UnlockUser clears the lockout and failure counter of a user's username
+func UnlockUser(userID uint) error {
+	var username string
+	if err := DB.QueryRow("SELECT username FROM users WHERE id = ?", userID).Scan(&username); err != nil {
+		return err
+	}
+	return RecordLoginSuccess(username)
+}
+
+// UnlockIP clears the lockout and failure counter of a client IP
+func UnlockIP(ip string) error {
+	result, err := DB.Exec("DELETE FROM login_throttles WHERE throttle_key = ?", throttleIPPrefix+ip)
+	if err != nil {
+		return err
+	}
+	return requireRowAffected(result)
+}
+
+// LockoutFilter filters the active lockouts; Kind is "user", "ip" or empty for both
+type LockoutFilter struct {
+	ListQuery
+	Kind string
+}
+
+// lockoutList sorts and searches the active lockouts
+var lockoutList = listSpec{
+	sorts:  map[string]string{"locked_until": "locked_until", "failures": "failures"},
+	order:  []string{"-locked_until"},
+	key:    "throttle_key",
+	search: []string{"throttle_key"},
+}
+
+// GetActiveLockouts returns a page of the usernames and client IPs that are currently
+// locked out
+func GetActiveLockouts(filter LockoutFilter) (*models.Page, error) {
+	q := &selectQuery{columns: "throttle_key, failures, locked_until", from: "login_throttles"}
+	q.filter("locked_until > ?", time.Now())
+	switch filter.Kind {
+	case "user":
+		q.filter("throttle_key LIKE ?", throttleUserPrefix+"%")
+	case "ip":
+		q.filter("throttle_key LIKE ?", throttleIPPrefix+"%")
+	}
+
+	lockouts := []models.LoginLockout{}
+	page, err := lockoutList.list(filter.ListQuery, q, func(row rowScanner) error {
+		var key string
+		var lockout models.LoginLockout
+		if err := row.Scan(&key, &lockout.Failures, timestamp{&lockout.LockedUntil}); err != nil {
+			return err
+		}
+
+		if value, ok := strings.CutPrefix(key, throttleIPPrefix); ok {
+			lockout.Kind, lockout.Value = "ip", value
+		} else {
+			lockout.Kind, lockout.Value = "user", strings.TrimPrefix(key, throttleUserPrefix)
+		}
+		lockouts = append(lockouts, lockout)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	page.Items = lockouts
+	return page, nil
+}
+
+// RecordLoginEvent adds a sign-in attempt to the login history
+func RecordLoginEvent(event *models.LoginEvent) error {
+	_, err := DB.Exec(`
+		INSERT INTO login_events (user_id, username, ip_address, user_agent, success, reason, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, event.UserID, event.Username, event.IPAddress, event.UserAgent, event.Success, event.Reason, time.Now())
+	return err
+}
+
+// LoginEventFilter narrows the login history returned by GetLoginEvents
+type LoginEventFilter struct {
+	ListQuery
+	UserID    *uint
+	Username  string
+	IPAddress string
+	Success   *bool
+}
+
+// loginEventList sorts and searches the login history, newest first by default
+var loginEventList = listSpec{
+	sorts:  map[string]string{"id": "id", "created_at": "created_at", "username": "username"},
+	order:  []string{"-id"},
+	key:    "id",
+	search: []string{"username", "ip_address", "user_agent"},
+}
+
+// GetLoginEvents returns a page of the sign-in attempts matching the filter
+func GetLoginEvents(filter LoginEventFilter) (*models.Page, error) {
+	q := &selectQuery{
+		columns: "id, user_id, username, COALESCE(ip_address, ''), COALESCE(user_agent, ''), success, COALESCE(reason, ''), created_at",
+		from:    "login_events",
+	}
+	if filter.UserID != nil {
+		q.filter("user_id = ?", *filter.UserID)
+	}
+	if filter.Username != "" {
+		q.filter("username = ?", filter.Username)
+	}
+	if filter.IPAddress != "" {
+		q.filter("ip_address = ?", filter.IPAddress)
+	}
+	if filter.Success != nil {
+		q.filter("success = ?", *filter.Success)
+	}
+
+	events := []models.LoginEvent{}
+	page, err := loginEventList.list(filter.ListQuery, q, func(row rowScanner) error {
+		var event models.LoginEvent
+		var userID sql.NullInt64
+		if err := row.Scan(&event.ID, &userID, &event.Username, &event.IPAddress, &event.UserAgent,
+			&event.Success, &event.Reason, timestamp{&event.CreatedAt}); err != nil {
+			return err
+		}
+		if userID.Valid {
+			id := uint(userID.Int64)
+			event.UserID = &id
+		}
+		events = append(events, event)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	page.Items = events
+	return page, nil
+}
@@ -0,0 +1,81 @@
+package db
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+// withoutLoginDelays turns off the progressive delays between attempts for one test
+func withoutLoginDelays(t *testing.T) {
+	saved := LoginLimits
+	t.Cleanup(func() { LoginLimits = saved })
+	LoginLimits.BaseDelay = 0
+}
+
+func TestConcurrentLoginAttemptsAreCounted(t *testing.T) {
+	openTestDB(t)
+	withoutLoginDelays(t)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed, locked := 0, 0
+	for i := 0; i < 4*LoginLimits.MaxUserFailures; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := BeginLoginAttempt("zhangsan", "192.0.2.1")
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				allowed++
+			case errors.Is(err, ErrLoginLocked):
+				locked++
+			default:
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed != LoginLimits.MaxUserFailures {
+		t.Errorf("%d parallel attempts allowed, want %d", allowed, LoginLimits.MaxUserFailures)
+	}
+	if allowed+locked != 4*LoginLimits.MaxUserFailures {
+		t.Errorf("%d allowed and %d locked out of %d", allowed, locked, 4*LoginLimits.MaxUserFailures)
+	}
+}
+
+func TestReleasedLoginAttemptsAreNotCounted(t *testing.T) {
+	openTestDB(t)
+	withoutLoginDelays(t)
+
+	// right credentials give their attempt back, even the one that reached the limit
+	for i := 0; i < 2*LoginLimits.MaxUserFailures; i++ {
+		if _, err := BeginLoginAttempt("zhangsan", "192.0.2.1"); err != nil {
+			t.Fatalf("attempt %d: %v", i+1, err)
+		}
+		if i < LoginLimits.MaxUserFailures-1 {
+			if err := RecordLoginFailure("zhangsan", "192.0.2.1"); err != nil {
+				t.Fatal(err)
+			}
+		} else if err := ReleaseLoginAttempt("zhangsan", "192.0.2.1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// one more failure locks the username
+	if _, err := BeginLoginAttempt("zhangsan", "192.0.2.1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := BeginLoginAttempt("zhangsan", "192.0.2.2"); !errors.Is(err, ErrLoginLocked) {
+		t.Errorf("after %d failures: %v, want ErrLoginLocked", LoginLimits.MaxUserFailures, err)
+	}
+
+	// refused attempts are not counted against the IP
+	var failures int
+	if err := DB.QueryRow("SELECT failures FROM login_throttles WHERE throttle_key = ?", throttleIPPrefix+"192.0.2.2").Scan(&failures); err == nil {
+		t.Errorf("refused attempt counted %d failures against its IP", failures)
+	}
+}
@@ -0,0 +1,88 @@
+package db
+
+import (
+	"errors"
+
+	"to-mrz/models"
+)
+
+// ErrDepartmentDeleted is returned when restoring a row whose department is deleted
+var ErrDepartmentDeleted = errors.New("department is deleted")
+
+// MajorFilter filters the major list; zero values match every major that is not deleted
+type MajorFilter struct {
+	ListQuery
+	DepartmentID uint
+	Deleted      bool // list the deleted majors instead
+}
+
+// majorList sorts and searches the major list
+var majorList = listSpec{
+	sorts:  map[string]string{"id": "m.id", "name": "m.name", "code": "m.code", "created_at": "m.created_at"},
+	order:  []string{"id"},
+	key:    "m.id",
+	search: []string{"m.name", "m.code"},
+}
+
+// GetMajors returns a page of the majors matching the filter, with their departments
+func GetMajors(filter MajorFilter) (*models.Page, error) {
+	q := &selectQuery{
+		columns: `m.id, m.name, m.code, m.department_id, m.created_at, m.updated_at, m.deleted_at,
+			d.id, d.name, d.code, d.created_at, d.updated_at, d.deleted_at`,
+		from: "majors m JOIN departments d ON m.department_id = d.id",
+	}
+	q.filter(deletedFilter("m.deleted_at", filter.Deleted))
+	if filter.DepartmentID != 0 {
+		q.filter("m.department_id = ?", filter.DepartmentID)
+	}
+
+	majors := []models.Major{}
+	page, err := majorList.list(filter.ListQuery, q, func(row rowScanner) error {
+		var major models.Major
+		err := row.Scan(
+			&major.ID, &major.Name, &major.Code, &major.DepartmentID,
+			timestamp{&major.CreatedAt}, timestamp{&major.UpdatedAt}, deletedAt{&major.DeletedAt},
+			&major.Department.ID, &major.Department.Name, &major.Department.Code,
+			timestamp{&major.Department.CreatedAt}, timestamp{&major.Department.UpdatedAt}, deletedAt{&major.Department.DeletedAt},
+		)
+		if err != nil {
+			return err
+		}
+		majors = append(majors, major)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	page.Items = majors
+	return page, nil
+}
+
+// DeleteMajor marks a major deleted. Its classes and training plans are kept. It
+// returns sql.ErrNoRows if the major does not exist.
+func DeleteMajor(id uint) error {
+	return softDelete(DB, "majors", id)
+}
+
+// RestoreMajor undoes DeleteMajor. It returns sql.ErrNoRows if there is no such
+// deleted major and ErrDepartmentDeleted while its department is deleted.
+func RestoreMajor(id uint) error {
+	var departmentDeleted bool
+	err := DB.QueryRow(`
+		SELECT d.deleted_at IS NOT NULL FROM majors m JOIN departments d ON m.department_id = d.id WHERE m.id = ?
+	`, id).Scan(&departmentDeleted)
+	if err != nil {
+		return err
+	}
+	if departmentDeleted {
+		return ErrDepartmentDeleted
+	}
+	return restoreDeleted("majors", id)
+}
+
+// PurgeMajor removes a deleted major for good. It returns ErrNotDeleted for majors
+// that are not deleted and a *DependencyError while classes or training plans still
+// refer to it.
+func PurgeMajor(id uint) error {
+	return purge("majors", id)
+}
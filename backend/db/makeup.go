@@ -0,0 +1,369 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"to-mrz/models"
+)
+
+// GradePolicy is the school's policy for counting repeated attempts on transcripts and GPA
+var GradePolicy = models.GradePolicyBest
+
+var (
+	// ErrEnrollmentNotFailed is returned when a retake is requested for an enrollment that was passed
+	ErrEnrollmentNotFailed = errors.New("enrollment has not failed")
+	// ErrRetakeCourseMismatch is returned when the retake offering is for a different course
+	ErrRetakeCourseMismatch = errors.New("retake offering is for a different course")
+	// ErrRetakeNotLater is returned when the retake offering is not in a later semester
+	ErrRetakeNotLater = errors.New("retake offering must be in a later semester")
+	// ErrRetakeExists is returned when the failed enrollment already has a retake, or the
+	// student is already enrolled in the retake offering
+	ErrRetakeExists = errors.New("retake already registered")
+	// ErrMakeupAlreadyScored is returned when a make-up exam already has a score
+	ErrMakeupAlreadyScored = errors.New("make-up exam already scored")
+)
+
+// CreateRetakeEnrollment enrolls a student in a later offering of a course they failed,
+// linking the new enrollment to the original one. It returns ErrRetakeExists if the
+// failed enrollment already has a retake or the student is already in the offering.
+func CreateRetakeEnrollment(originalID, courseOfferingID uint) (uint, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// 先写锁住原选课记录，同一记录的并发重修请求依次执行，后面的请求能看到前面登记的重修
+	result, err := tx.Exec("UPDATE enrollments SET updated_at = updated_at WHERE id = ?", originalID)
+	if err != nil {
+		return 0, err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return 0, err
+	} else if n == 0 {
+		return 0, sql.ErrNoRows
+	}
+
+	var studentID, originalCourseID uint
+	var status string
+	var originalStart time.Time
+	err = tx.QueryRow(`
+		SELECT e.student_id, e.status, co.course_id, s.start_date
+		FROM enrollments e
+		JOIN course_offerings co ON e.course_offering_id = co.id
+		JOIN semesters s ON co.semester_id = s.id
+		WHERE e.id = ?
+	`, originalID).Scan(&studentID, &status, &originalCourseID, timestamp{&originalStart})
+	if err != nil {
+		return 0, err
+	}
+
+	if status != "未通过" {
+		return 0, ErrEnrollmentNotFailed
+	}
+
+	var courseID uint
+	var start time.Time
+	err = tx.QueryRow(`
+		SELECT co.course_id, s.start_date
+		FROM course_offerings co
+		JOIN semesters s ON co.semester_id = s.id
+		WHERE co.id = ? AND co.deleted_at IS NULL
+	`, courseOfferingID).Scan(&courseID, timestamp{&start})
+	if err != nil {
+		return 0, err
+	}
+
+	if courseID != originalCourseID {
+		return 0, ErrRetakeCourseMismatch
+	}
+	if !start.After(originalStart) {
+		return 0, ErrRetakeNotLater
+	}
+
+	var count int
+	err = tx.QueryRow(`
+		SELECT COUNT(*) FROM enrollments
+		WHERE retake_of_id = ? OR (student_id = ? AND course_offering_id = ?)
+	`, originalID, studentID, courseOfferingID).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	if count > 0 {
+		return 0, ErrRetakeExists
+	}
+
+	id, err := insertID(tx, `
+		INSERT INTO enrollments (student_id, course_offering_id, grade, status, retake_of_id, created_at, updated_at)
+		VALUES (?, ?, 0, '已选', ?, ?, ?)
+	`, studentID, courseOfferingID, originalID, time.Now(), time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to create retake enrollment: %w", err)
+	}
+
+	return id, tx.Commit()
+}
+
+// GenerateMakeupRoster creates pending make-up exams for every failing enrollment that
+// does not have one yet. A zero semesterID or courseOfferingID matches all.
+func GenerateMakeupRoster(semesterID, courseOfferingID uint) (int64, error) {
+	result, err := DB.Exec(`
+		INSERT INTO exam_attempts (enrollment_id, type, status, created_at, updated_at)
+		SELECT e.id, ?, ?, ?, ?
+		FROM enrollments e
+		JOIN course_offerings co ON e.course_offering_id = co.id
+		WHERE e.status = '未通过' AND co.deleted_at IS NULL
+		  AND (? = 0 OR co.semester_id = ?)
+		  AND (? = 0 OR co.id = ?)
+		  AND NOT EXISTS (
+			SELECT 1 FROM exam_attempts a WHERE a.enrollment_id = e.id AND a.type = ?
+		  )
+	`, models.AttemptMakeup, models.MakeupPending, time.Now(), time.Now(),
+		semesterID, semesterID, courseOfferingID, courseOfferingID, models.AttemptMakeup)
+	if err != nil {
+		return 0, fmt.Errorf("failed to generate make-up roster: %w", err)
+	}
+
+	return result.RowsAffected()
+}
+
+// GetMakeupRoster retrieves make-up exams with student and course information.
+// A zero semesterID or courseOfferingID matches all.
+func GetMakeupRoster(semesterID, courseOfferingID uint) ([]models.ExamAttempt, error) {
+	attempts := []models.ExamAttempt{}
+
+	rows, err := DB.Query(`
+		SELECT
+			a.id, a.enrollment_id, a.type, a.raw_score, a.score, a.status,
+			e.id, e.student_id, e.course_offering_id, e.grade, e.status,
+			s.id, s.student_id, u.id, u.name,
+			co.id, c.id, c.name, c.code, c.credits
+		FROM exam_attempts a
+		JOIN enrollments e ON a.enrollment_id = e.id
+		JOIN students s ON e.student_id = s.id
+		JOIN users u ON s.user_id = u.id
+		JOIN course_offerings co ON e.course_offering_id = co.id
+		JOIN courses c ON co.course_id = c.id
+		WHERE a.type = ? AND co.deleted_at IS NULL
+		  AND (? = 0 OR co.semester_id = ?)
+		  AND (? = 0 OR co.id = ?)
+		ORDER BY c.code, s.student_id
+	`, models.AttemptMakeup, semesterID, semesterID, courseOfferingID, courseOfferingID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query make-up roster: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a models.ExamAttempt
+		var rawScore, score sql.NullFloat64
+
+		err := rows.Scan(
+			&a.ID, &a.EnrollmentID, &a.Type, &rawScore, &score, &a.Status,
+			&a.Enrollment.ID, &a.Enrollment.StudentID, &a.Enrollment.CourseOfferingID, &a.Enrollment.Grade, &a.Enrollment.Status,
+			&a.Enrollment.Student.ID, &a.Enrollment.Student.StudentID, &a.Enrollment.Student.User.ID, &a.Enrollment.Student.User.Name,
+			&a.Enrollment.CourseOffering.ID, &a.Enrollment.CourseOffering.Course.ID, &a.Enrollment.CourseOffering.Course.Name,
+			&a.Enrollment.CourseOffering.Course.Code, &a.Enrollment.CourseOffering.Course.Credits,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan make-up exam: %w", err)
+		}
+
+		if rawScore.Valid {
+			a.RawScore = &rawScore.Float64
+		}
+		if score.Valid {
+			a.Score = &score.Float64
+		}
+
+		attempts = append(attempts, a)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return attempts, nil
+}
+
+// RecordMakeupScore records the result of a make-up exam. A passed make-up is recorded as 60.
+func RecordMakeupScore(id uint, rawScore float64) error {
+	var status string
+	err := DB.QueryRow("SELECT status FROM exam_attempts WHERE id = ? AND type = ?", id, models.AttemptMakeup).Scan(&status)
+	if err != nil {
+		return err
+	}
+
+	if status != models.MakeupPending {
+		return ErrMakeupAlreadyScored
+	}
+
+	score, status := makeupResult(rawScore)
+	_, err = DB.Exec(`
+		UPDATE exam_attempts
+		SET raw_score = ?, score = ?, status = ?, updated_at = ?
+		WHERE id = ?
+	`, rawScore, score, status, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to record make-up score: %w", err)
+	}
+
+	return nil
+}
+
+// makeupResult caps a passing make-up score and determines its status
+func makeupResult(rawScore float64) (float64, string) {
+	if rawScore >= models.MakeupPassScore {
+		return models.MakeupPassScore, models.MakeupPassed
+	}
+	return rawScore, models.MakeupFailed
+}
+
+// GetTranscript builds a student's transcript, applying the grade policy to repeated attempts
+func GetTranscript(studentID uint, policy models.GradePolicy) (*models.Transcript, error) {
+	rows, err := DB.Query(`
+		SELECT
+			e.id, e.retake_of_id, c.id, c.code, c.name, c.credits, sem.name,
+			e.grade, a.score
+		FROM enrollments e
+		JOIN course_offerings co ON e.course_offering_id = co.id
+		JOIN courses c ON co.course_id = c.id
+		JOIN semesters sem ON co.semester_id = sem.id
+		LEFT JOIN exam_attempts a ON a.enrollment_id = e.id AND a.type = ? AND a.score IS NOT NULL
+		WHERE e.student_id = ? AND e.status IN ('已完成', '未通过')
+		ORDER BY sem.start_date, e.id
+	`, models.AttemptMakeup, studentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transcript: %w", err)
+	}
+	defer rows.Close()
+
+	// Attempts grouped by course, in chronological order
+	var courseOrder []uint
+	attempts := map[uint][]models.TranscriptEntry{}
+
+	for rows.Next() {
+		var entry models.TranscriptEntry
+		var retakeOfID sql.NullInt64
+		var makeupScore sql.NullFloat64
+
+		err := rows.Scan(
+			&entry.EnrollmentID, &retakeOfID, &entry.CourseID, &entry.CourseCode, &entry.CourseName,
+			&entry.Credits, &entry.SemesterName, &entry.Score, &makeupScore,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan transcript entry: %w", err)
+		}
+
+		entry.AttemptType = models.AttemptRegular
+		if retakeOfID.Valid {
+			entry.AttemptType = models.AttemptRetake
+		}
+
+		if _, ok := attempts[entry.CourseID]; !ok {
+			courseOrder = append(courseOrder, entry.CourseID)
+		}
+		attempts[entry.CourseID] = append(attempts[entry.CourseID], entry)
+
+		if makeupScore.Valid {
+			makeup := entry
+			makeup.AttemptType = models.AttemptMakeup
+			makeup.Score = makeupScore.Float64
+			attempts[entry.CourseID] = append(attempts[entry.CourseID], makeup)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	transcript := &models.Transcript{
+		StudentID: studentID,
+		Policy:    policy,
+		Entries:   []models.TranscriptEntry{},
+	}
+
+	var totalPoints, totalCredits float64
+	for _, courseID := range courseOrder {
+		entries := applyGradePolicy(attempts[courseID], policy)
+		passed := false
+		for _, entry := range entries {
+			if entry.Counted {
+				totalPoints += entry.GradePoint * entry.Credits
+				totalCredits += entry.Credits
+			}
+			if entry.Passed {
+				passed = true
+			}
+		}
+		if passed {
+			transcript.EarnedCredits += entries[0].Credits
+		}
+		transcript.Entries = append(transcript.Entries, entries...)
+	}
+
+	if totalCredits > 0 {
+		transcript.GPA = totalPoints / totalCredits
+	}
+
+	return transcript, nil
+}
+
+// applyGradePolicy marks which of a course's chronologically ordered attempts count
+func applyGradePolicy(entries []models.TranscriptEntry, policy models.GradePolicy) []models.TranscriptEntry {
+	for i := range entries {
+		entries[i].Passed = entries[i].Score >= 60
+		entries[i].GradePoint = gradePoint(entries[i].Score)
+	}
+
+	switch policy {
+	case models.GradePolicyAll:
+		for i := range entries {
+			entries[i].Counted = true
+		}
+	case models.GradePolicyLatest:
+		entries[len(entries)-1].Counted = true
+	default:
+		best := 0
+		for i := range entries {
+			// Later attempts win ties
+			if entries[i].Score >= entries[best].Score {
+				best = i
+			}
+		}
+		entries[best].Counted = true
+	}
+
+	return entries
+}
+
+// gradePoint converts a percentage score to the 4.0 grade point scale
+func gradePoint(score float64) float64 {
+	bands := []struct {
+		min   float64
+		point float64
+	}{
+		{90, 4.0}, {85, 3.7}, {82, 3.3}, {78, 3.0}, {75, 2.7},
+		{72, 2.3}, {68, 2.0}, {64, 1.5}, {60, 1.0},
+	}
+	for _, band := range bands {
+		if score >= band.min {
+			return band.point
+		}
+	}
+	return 0
+}
+
+// ValidGradePolicy reports whether policy is a known grade policy
+func ValidGradePolicy(policy models.GradePolicy) bool {
+	policies := []models.GradePolicy{models.GradePolicyBest, models.GradePolicyLatest, models.GradePolicyAll}
+	for _, p := range policies {
+		if p == policy {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package db
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+// retakeFixtures has two students who failed offering 1 of a course, and two later
+// offerings of the same course. The second student is already enrolled in offering 2.
+const retakeFixtures = `
+INSERT INTO users (username, password, name, role) VALUES
+	('2023001', 'x', '张三', 'student'), ('2023002', 'x', '李四', 'student'), ('t1001', 'x', '王老师', 'teacher');
+INSERT INTO departments (name, code) VALUES ('计算机学院', 'CS');
+INSERT INTO majors (name, code, department_id) VALUES ('计算机科学与技术', 'CS01', 1);
+INSERT INTO classes (name, code, major_id, year) VALUES ('计科2023-1班', 'CS2023-1', 1, 2023);
+INSERT INTO students (user_id, student_id, class_id, enroll_year) VALUES (1, '2023001', 1, 2023), (2, '2023002', 1, 2023);
+INSERT INTO teachers (user_id, department_id) VALUES (3, 1);
+INSERT INTO courses (name, code, credits, hours, type, department_id) VALUES ('高等数学', 'MATH101', 4, 64, '必修', 1);
+INSERT INTO semesters (name, start_date, end_date) VALUES
+	('2023-2024学年第一学期', '2023-09-01 00:00:00', '2024-01-15 00:00:00'),
+	('2023-2024学年第二学期', '2024-02-20 00:00:00', '2024-07-01 00:00:00');
+INSERT INTO course_offerings (course_id, semester_id, teacher_id, capacity, status) VALUES
+	(1, 1, 1, 50, 'open'), (1, 2, 1, 50, 'open'), (1, 2, 1, 50, 'open');
+INSERT INTO enrollments (student_id, course_offering_id, grade, status) VALUES
+	(1, 1, 45, '未通过'), (2, 1, 50, '未通过'), (2, 2, 0, '已选');
+`
+
+func TestRetakeIsRegisteredOnce(t *testing.T) {
+	openTestDB(t)
+	if _, err := DB.Exec(retakeFixtures); err != nil {
+		t.Fatal(err)
+	}
+
+	// parallel requests for the same failed enrollment, into either later offering
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	created, exists := 0, 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(offeringID uint) {
+			defer wg.Done()
+			_, err := CreateRetakeEnrollment(1, offeringID)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				created++
+			case errors.Is(err, ErrRetakeExists):
+				exists++
+			default:
+				t.Error(err)
+			}
+		}(uint(2 + i%2))
+	}
+	wg.Wait()
+
+	if created != 1 || exists != 9 {
+		t.Errorf("%d retakes created and %d refused, want 1 and 9", created, exists)
+	}
+
+	// the student is already enrolled in the offering
+	if _, err := CreateRetakeEnrollment(2, 2); !errors.Is(err, ErrRetakeExists) {
+		t.Errorf("retake into an enrolled offering: %v, want ErrRetakeExists", err)
+	}
+	if _, err := CreateRetakeEnrollment(2, 3); err != nil {
+		t.Errorf("retake into another offering: %v", err)
+	}
+}
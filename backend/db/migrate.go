@@ -0,0 +1,396 @@
+package db
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"to-mrz/utils"
+)
+
+// Migration is a numbered schema change with the SQL that applies and reverts it
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string // SHA-256 of Up and Down, recorded when the migration is applied
+}
+
+// MigrationStatus describes a migration known to the program or recorded in the database
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time // nil while pending
+	Modified  bool       // applied with a different checksum than the program's
+	Unknown   bool       // applied by a newer version of the program
+}
+
+// Migration errors
+var (
+	ErrSchemaTooNew      = errors.New("database schema is newer than this program")
+	ErrMigrationModified = errors.New("an applied migration has been modified")
+	ErrPendingMigrations = errors.New("database schema is out of date")
+)
+
+// AutoMigrate applies pending migrations when the database is opened by InitDB
+var AutoMigrate = true
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationFileName matches migrations/0001_initial_schema.up.sql and the like
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// migrations lists every migration in version order
+var migrations = mustLoadMigrations()
+
+// mustLoadMigrations reads the embedded migration files. Every version from 1 up must
+// have both an up and a down file; anything else is a packaging mistake.
+func mustLoadMigrations() []Migration {
+	files, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		panic(err)
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, file := range files {
+		match := migrationFileName.FindStringSubmatch(file.Name())
+		if match == nil {
+			panic(fmt.Sprintf("unexpected migration file %s", file.Name()))
+		}
+		version, _ := strconv.Atoi(match[1])
+		content, err := migrationFiles.ReadFile(path.Join("migrations", file.Name()))
+		if err != nil {
+			panic(err)
+		}
+
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			panic(fmt.Sprintf("migration %d is named both %s and %s", version, m.Name, match[2]))
+		}
+		if match[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	list := make([]Migration, 0, len(byVersion))
+	for version := 1; version <= len(byVersion); version++ {
+		m := byVersion[version]
+		if m == nil {
+			panic(fmt.Sprintf("migration %d is missing", version))
+		}
+		if m.Up == "" || m.Down == "" {
+			panic(fmt.Sprintf("migration %d needs both an up and a down file", version))
+		}
+		sum := sha256.Sum256([]byte(m.Up + "\x00" + m.Down))
+		m.Checksum = hex.EncodeToString(sum[:])
+		list = append(list, *m)
+	}
+	return list
+}
+
+// LatestVersion returns the schema version this program expects
+func LatestVersion() int {
+	return len(migrations)
+}
+
+// appliedMigration is a row of schema_migrations
+type appliedMigration struct {
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+// ensureMigrationsTable creates the table that records applied migrations
+func ensureMigrationsTable() error {
+	for _, statement := range DB.dialect.migrationStatements(`
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		checksum TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL
+	)`, nil) {
+		if _, err := DB.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appliedMigrations returns the applied migrations by version
+func appliedMigrations() (map[int]appliedMigration, error) {
+	if err := ensureMigrationsTable(); err != nil {
+		return nil, err
+	}
+	return readAppliedMigrations(DB)
+}
+
+// queryer is implemented by *Database, *Tx and *sql.DB
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// readAppliedMigrations reads schema_migrations through q
+func readAppliedMigrations(q queryer) (map[int]appliedMigration, error) {
+	rows, err := q.Query("SELECT version, name, checksum, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]appliedMigration{}
+	for rows.Next() {
+		var version int
+		var m appliedMigration
+		if err := rows.Scan(&version, &m.Name, &m.Checksum, timestamp{&m.AppliedAt}); err != nil {
+			return nil, err
+		}
+		applied[version] = m
+	}
+	return applied, rows.Err()
+}
+
+// checkApplied refuses databases migrated by a newer program or with migrations that
+// were changed after they were applied
+func checkApplied(applied map[int]appliedMigration) error {
+	if version := maxVersion(applied); version > LatestVersion() {
+		return fmt.Errorf("%w: it is at version %d, this program only knows up to %d",
+			ErrSchemaTooNew, version, LatestVersion())
+	}
+	for version, m := range applied {
+		if m.Checksum != migrations[version-1].Checksum {
+			return fmt.Errorf("%w: %d_%s", ErrMigrationModified, version, m.Name)
+		}
+	}
+	return nil
+}
+
+// maxVersion returns the highest applied version, 0 if none
+func maxVersion(applied map[int]appliedMigration) int {
+	max := 0
+	for version := range applied {
+		if version > max {
+			max = version
+		}
+	}
+	return max
+}
+
+// SchemaVersion returns the database's schema version and the number of pending migrations
+func SchemaVersion() (int, int, error) {
+	applied, err := appliedMigrations()
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := checkApplied(applied); err != nil {
+		return 0, 0, err
+	}
+	return maxVersion(applied), LatestVersion() - len(applied), nil
+}
+
+// Migrate applies the pending migrations up to and including target, or all of them
+// when target is 0. It returns the number of migrations applied.
+func Migrate(target int) (int, error) {
+	if target == 0 {
+		target = LatestVersion()
+	}
+	if target < 0 || target > LatestVersion() {
+		return 0, fmt.Errorf("no migration %d (latest is %d)", target, LatestVersion())
+	}
+
+	applied, err := appliedMigrations()
+	if err != nil {
+		return 0, err
+	}
+	if err := checkApplied(applied); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, m := range migrations[:target] {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		if err := applyMigration(m); err != nil {
+			return count, fmt.Errorf("migration %d_%s failed: %w", m.Version, m.Name, err)
+		}
+		utils.Infof("Applied migration %d_%s", m.Version, m.Name)
+		count++
+	}
+	return count, nil
+}
+
+// applyMigration runs a migration and records it in one transaction. MySQL commits
+// schema changes immediately, so a failed migration may leave part of it applied there.
+func applyMigration(m Migration) error {
+	// Databases from before versioned migrations already have tables but no record
+	// of the baseline; they need the columns that used to be added at startup. Those
+	// databases were all SQLite.
+	legacy := false
+	if m.Version == 1 && DB.dialect == SQLite {
+		var err error
+		if legacy, err = tableExists("users"); err != nil {
+			return err
+		}
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := execMigration(tx, m.Up); err != nil {
+		return err
+	}
+	if legacy {
+		if err := upgradeLegacySchema(tx); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)
+	`, m.Version, m.Name, m.Checksum, time.Now()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// sqliteOnly starts migration scripts that only tidy up data SQLite stores loosely.
+// Other databases record such migrations without running them.
+const sqliteOnly = "-- sqlite only\n"
+
+// execMigration runs the statements of a migration script in the database's dialect
+func execMigration(tx *Tx, script string) error {
+	if DB.dialect != SQLite && strings.HasPrefix(script, sqliteOnly) {
+		return nil
+	}
+	// Scripts may drop or refill tables in any order; the foreign keys only have to
+	// hold once the whole migration is done
+	if DB.dialect == SQLite {
+		if _, err := tx.Exec("PRAGMA defer_foreign_keys = ON"); err != nil {
+			return err
+		}
+	}
+	for _, statement := range DB.dialect.migrationStatements(script, migrationIndexes()) {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Rollback reverts the applied migrations above target, newest first. It returns the
+// number of migrations reverted.
+func Rollback(target int) (int, error) {
+	if target < 0 || target > LatestVersion() {
+		return 0, fmt.Errorf("no migration %d (latest is %d)", target, LatestVersion())
+	}
+
+	applied, err := appliedMigrations()
+	if err != nil {
+		return 0, err
+	}
+	if err := checkApplied(applied); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for version := maxVersion(applied); version > target; version-- {
+		if _, ok := applied[version]; !ok {
+			continue
+		}
+		m := migrations[version-1]
+		if err := revertMigration(m); err != nil {
+			return count, fmt.Errorf("rolling back migration %d_%s failed: %w", m.Version, m.Name, err)
+		}
+		utils.Infof("Rolled back migration %d_%s", m.Version, m.Name)
+		count++
+	}
+	return count, nil
+}
+
+// revertMigration reverts a migration and removes its record in one transaction
+func revertMigration(m Migration) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := execMigration(tx, m.Down); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM schema_migrations WHERE version = ?", m.Version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// MigrationStatuses lists the program's migrations and any unknown applied ones by version
+func MigrationStatuses() ([]MigrationStatus, error) {
+	applied, err := appliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []MigrationStatus
+	for _, m := range migrations {
+		status := MigrationStatus{Version: m.Version, Name: m.Name}
+		if a, ok := applied[m.Version]; ok {
+			appliedAt := a.AppliedAt
+			status.AppliedAt = &appliedAt
+			status.Modified = a.Checksum != m.Checksum
+		}
+		statuses = append(statuses, status)
+	}
+	for version, a := range applied {
+		if version > LatestVersion() {
+			appliedAt := a.AppliedAt
+			statuses = append(statuses, MigrationStatus{Version: version, Name: a.Name, AppliedAt: &appliedAt, Unknown: true})
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// prepareSchema brings the schema up to date at startup, or refuses to start when
+// it cannot be used by this program
+func prepareSchema() error {
+	version, pending, err := SchemaVersion()
+	if err != nil {
+		return err
+	}
+	if pending == 0 {
+		return nil
+	}
+	if !AutoMigrate {
+		return fmt.Errorf("%w: it is at version %d, this program needs %d; run `migrate` first",
+			ErrPendingMigrations, version, LatestVersion())
+	}
+	_, err = Migrate(0)
+	return err
+}
+
+// tableExists reports whether a table exists in a SQLite database
+func tableExists(name string) (bool, error) {
+	var count int
+	err := DB.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", name).Scan(&count)
+	return count > 0, err
+}
@@ -0,0 +1,33 @@
+DROP TABLE IF EXISTS user_roles;
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS login_events;
+DROP TABLE IF EXISTS login_throttles;
+DROP TABLE IF EXISTS login_challenges;
+DROP TABLE IF EXISTS recovery_codes;
+DROP TABLE IF EXISTS password_history;
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS graduation_results;
+DROP TABLE IF EXISTS graduation_reviews;
+DROP TABLE IF EXISTS student_fees;
+DROP TABLE IF EXISTS theses;
+DROP TABLE IF EXISTS internships;
+DROP TABLE IF EXISTS textbook_applications;
+DROP TABLE IF EXISTS textbooks;
+DROP TABLE IF EXISTS evaluations;
+DROP TABLE IF EXISTS plan_group_courses;
+DROP TABLE IF EXISTS plan_course_groups;
+DROP TABLE IF EXISTS training_plans;
+DROP TABLE IF EXISTS grade_details;
+DROP TABLE IF EXISTS grade_components;
+DROP TABLE IF EXISTS exam_attempts;
+DROP TABLE IF EXISTS enrollments;
+DROP TABLE IF EXISTS course_offerings;
+DROP TABLE IF EXISTS semesters;
+DROP TABLE IF EXISTS course_prerequisites;
+DROP TABLE IF EXISTS courses;
+DROP TABLE IF EXISTS students;
+DROP TABLE IF EXISTS teachers;
+DROP TABLE IF EXISTS classes;
+DROP TABLE IF EXISTS majors;
+DROP TABLE IF EXISTS departments;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,416 @@
+-- Baseline schema. Databases created before versioned migrations already have
+-- some or all of these tables, so they are created only if missing; the columns
+-- added to existing tables since then are filled in by upgradeLegacySchema.
+
+-- Users table
+CREATE TABLE IF NOT EXISTS users (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	username TEXT UNIQUE NOT NULL,
+	password TEXT NOT NULL,
+	name TEXT NOT NULL,
+	role TEXT NOT NULL,
+	email TEXT,
+	phone TEXT,
+	disabled BOOLEAN NOT NULL DEFAULT 0,
+	department_id INTEGER REFERENCES departments(id),
+	must_change_password BOOLEAN NOT NULL DEFAULT 0,
+	password_changed_at TIMESTAMP,
+	totp_secret TEXT,
+	totp_enabled BOOLEAN NOT NULL DEFAULT 0,
+	totp_last_step INTEGER NOT NULL DEFAULT 0,
+	auth_source TEXT NOT NULL DEFAULT 'local',
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Departments table
+CREATE TABLE IF NOT EXISTS departments (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT UNIQUE NOT NULL,
+	code TEXT UNIQUE NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Majors table
+CREATE TABLE IF NOT EXISTS majors (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	code TEXT UNIQUE NOT NULL,
+	department_id INTEGER NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (department_id) REFERENCES departments(id)
+);
+
+-- Classes table
+CREATE TABLE IF NOT EXISTS classes (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	code TEXT UNIQUE NOT NULL,
+	major_id INTEGER NOT NULL,
+	year INTEGER NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (major_id) REFERENCES majors(id)
+);
+
+-- Teachers table
+CREATE TABLE IF NOT EXISTS teachers (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER UNIQUE NOT NULL,
+	department_id INTEGER NOT NULL,
+	title TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users(id),
+	FOREIGN KEY (department_id) REFERENCES departments(id)
+);
+
+-- Students table
+CREATE TABLE IF NOT EXISTS students (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER UNIQUE NOT NULL,
+	student_id TEXT UNIQUE NOT NULL,
+	class_id INTEGER NOT NULL,
+	enroll_year INTEGER NOT NULL,
+	status TEXT NOT NULL DEFAULT '在读',
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users(id),
+	FOREIGN KEY (class_id) REFERENCES classes(id)
+);
+
+-- Courses table
+CREATE TABLE IF NOT EXISTS courses (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	code TEXT UNIQUE NOT NULL,
+	credits REAL NOT NULL,
+	hours INTEGER NOT NULL,
+	type TEXT NOT NULL,
+	department_id INTEGER NOT NULL,
+	description TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (department_id) REFERENCES departments(id)
+);
+
+-- Course Prerequisites table
+CREATE TABLE IF NOT EXISTS course_prerequisites (
+	course_id INTEGER NOT NULL,
+	prerequisite_id INTEGER NOT NULL,
+	PRIMARY KEY (course_id, prerequisite_id),
+	FOREIGN KEY (course_id) REFERENCES courses(id),
+	FOREIGN KEY (prerequisite_id) REFERENCES courses(id)
+);
+
+-- Semesters table
+CREATE TABLE IF NOT EXISTS semesters (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	start_date TIMESTAMP NOT NULL,
+	end_date TIMESTAMP NOT NULL,
+	current BOOLEAN DEFAULT 0,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Course Offerings table
+CREATE TABLE IF NOT EXISTS course_offerings (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	course_id INTEGER NOT NULL,
+	semester_id INTEGER NOT NULL,
+	teacher_id INTEGER NOT NULL,
+	capacity INTEGER NOT NULL,
+	location TEXT,
+	schedule TEXT,
+	status TEXT NOT NULL,
+	description TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (course_id) REFERENCES courses(id),
+	FOREIGN KEY (semester_id) REFERENCES semesters(id),
+	FOREIGN KEY (teacher_id) REFERENCES teachers(id)
+);
+
+-- Enrollments table
+CREATE TABLE IF NOT EXISTS enrollments (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	student_id INTEGER NOT NULL,
+	course_offering_id INTEGER NOT NULL,
+	grade REAL,
+	status TEXT NOT NULL,
+	retake_of_id INTEGER,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (student_id) REFERENCES students(id),
+	FOREIGN KEY (course_offering_id) REFERENCES course_offerings(id),
+	FOREIGN KEY (retake_of_id) REFERENCES enrollments(id)
+);
+
+-- Exam Attempts table (补考)
+CREATE TABLE IF NOT EXISTS exam_attempts (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	enrollment_id INTEGER NOT NULL,
+	type TEXT NOT NULL,
+	raw_score REAL,
+	score REAL,
+	status TEXT NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (enrollment_id) REFERENCES enrollments(id),
+	UNIQUE(enrollment_id, type)
+);
+
+-- Grade Components table
+CREATE TABLE IF NOT EXISTS grade_components (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	course_offering_id INTEGER NOT NULL,
+	name TEXT NOT NULL,
+	weight REAL NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (course_offering_id) REFERENCES course_offerings(id)
+);
+
+-- Grade Details table
+CREATE TABLE IF NOT EXISTS grade_details (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	enrollment_id INTEGER NOT NULL,
+	grade_component_id INTEGER NOT NULL,
+	score REAL NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (enrollment_id) REFERENCES enrollments(id),
+	FOREIGN KEY (grade_component_id) REFERENCES grade_components(id),
+	UNIQUE(enrollment_id, grade_component_id)
+);
+
+-- Training Plans table (培养方案)
+CREATE TABLE IF NOT EXISTS training_plans (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	major_id INTEGER NOT NULL,
+	cohort_year INTEGER NOT NULL,
+	name TEXT NOT NULL,
+	min_credits REAL NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (major_id) REFERENCES majors(id),
+	UNIQUE(major_id, cohort_year)
+);
+
+-- Plan Course Groups table
+CREATE TABLE IF NOT EXISTS plan_course_groups (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	training_plan_id INTEGER NOT NULL,
+	name TEXT NOT NULL,
+	category TEXT NOT NULL,
+	min_credits REAL NOT NULL,
+	FOREIGN KEY (training_plan_id) REFERENCES training_plans(id)
+);
+
+-- Plan Group Courses table
+CREATE TABLE IF NOT EXISTS plan_group_courses (
+	group_id INTEGER NOT NULL,
+	course_id INTEGER NOT NULL,
+	required BOOLEAN DEFAULT 0,
+	PRIMARY KEY (group_id, course_id),
+	FOREIGN KEY (group_id) REFERENCES plan_course_groups(id),
+	FOREIGN KEY (course_id) REFERENCES courses(id)
+);
+
+-- Evaluations table
+CREATE TABLE IF NOT EXISTS evaluations (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	course_offering_id INTEGER NOT NULL,
+	student_id INTEGER NOT NULL,
+	rating INTEGER NOT NULL,
+	comment TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (course_offering_id) REFERENCES course_offerings(id),
+	FOREIGN KEY (student_id) REFERENCES students(id)
+);
+
+-- Textbooks table
+CREATE TABLE IF NOT EXISTS textbooks (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	title TEXT NOT NULL,
+	author TEXT,
+	publisher TEXT,
+	isbn TEXT UNIQUE,
+	price REAL,
+	description TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Textbook Applications table
+CREATE TABLE IF NOT EXISTS textbook_applications (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	course_offering_id INTEGER NOT NULL,
+	textbook_id INTEGER NOT NULL,
+	quantity INTEGER NOT NULL,
+	status TEXT NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (course_offering_id) REFERENCES course_offerings(id),
+	FOREIGN KEY (textbook_id) REFERENCES textbooks(id)
+);
+
+-- Internships table
+CREATE TABLE IF NOT EXISTS internships (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	student_id INTEGER NOT NULL,
+	company TEXT NOT NULL,
+	position TEXT NOT NULL,
+	start_date TIMESTAMP NOT NULL,
+	end_date TIMESTAMP NOT NULL,
+	supervisor TEXT,
+	teacher_id INTEGER NOT NULL,
+	status TEXT NOT NULL,
+	report TEXT,
+	grade REAL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (student_id) REFERENCES students(id),
+	FOREIGN KEY (teacher_id) REFERENCES teachers(id)
+);
+
+-- Theses table
+CREATE TABLE IF NOT EXISTS theses (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	student_id INTEGER NOT NULL,
+	teacher_id INTEGER NOT NULL,
+	title TEXT NOT NULL,
+	abstract TEXT,
+	status TEXT NOT NULL,
+	grade REAL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (student_id) REFERENCES students(id),
+	FOREIGN KEY (teacher_id) REFERENCES teachers(id)
+);
+
+-- Student Fees table
+CREATE TABLE IF NOT EXISTS student_fees (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	student_id INTEGER NOT NULL,
+	item TEXT NOT NULL,
+	amount REAL NOT NULL,
+	paid REAL NOT NULL DEFAULT 0,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (student_id) REFERENCES students(id)
+);
+
+-- Graduation Reviews table
+CREATE TABLE IF NOT EXISTS graduation_reviews (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	graduation_year INTEGER NOT NULL,
+	cohort_year INTEGER NOT NULL,
+	major_id INTEGER NOT NULL DEFAULT 0,
+	status TEXT NOT NULL,
+	created_by INTEGER NOT NULL,
+	confirmed_at TIMESTAMP,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (created_by) REFERENCES users(id)
+);
+
+-- Graduation Review Results table
+CREATE TABLE IF NOT EXISTS graduation_results (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	review_id INTEGER NOT NULL,
+	student_id INTEGER NOT NULL,
+	eligible BOOLEAN NOT NULL,
+	reasons TEXT NOT NULL,
+	override_eligible BOOLEAN,
+	override_reason TEXT,
+	overridden_by INTEGER,
+	FOREIGN KEY (review_id) REFERENCES graduation_reviews(id),
+	FOREIGN KEY (student_id) REFERENCES students(id),
+	FOREIGN KEY (overridden_by) REFERENCES users(id),
+	UNIQUE(review_id, student_id)
+);
+
+-- Sessions table (one row per login, holds the current refresh token)
+CREATE TABLE IF NOT EXISTS sessions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	role TEXT NOT NULL,
+	refresh_token_hash TEXT UNIQUE NOT NULL,
+	previous_token_hash TEXT,
+	user_agent TEXT,
+	ip_address TEXT,
+	expires_at TIMESTAMP NOT NULL,
+	last_used_at TIMESTAMP,
+	revoked_at TIMESTAMP,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+-- Password History table (previous password hashes, for the no-reuse rule)
+CREATE TABLE IF NOT EXISTS password_history (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	password_hash TEXT NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+-- Recovery Codes table (one-time two-factor backup codes, stored hashed)
+CREATE TABLE IF NOT EXISTS recovery_codes (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	code_hash TEXT NOT NULL,
+	used_at TIMESTAMP,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+-- Login Challenges table (password checked, waiting for the two-factor code)
+CREATE TABLE IF NOT EXISTS login_challenges (
+	token_hash TEXT PRIMARY KEY,
+	user_id INTEGER NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+-- Login Throttles table (failed login counters keyed by "user:<name>" or "ip:<addr>")
+CREATE TABLE IF NOT EXISTS login_throttles (
+	key TEXT PRIMARY KEY,
+	failures INTEGER NOT NULL DEFAULT 0,
+	last_failure_at TIMESTAMP NOT NULL,
+	locked_until TIMESTAMP
+);
+
+-- Login Events table (sign-in history)
+CREATE TABLE IF NOT EXISTS login_events (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER,
+	username TEXT NOT NULL,
+	ip_address TEXT,
+	user_agent TEXT,
+	success BOOLEAN NOT NULL,
+	reason TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+-- Role Permissions table (admin-editable role-to-permission mapping)
+CREATE TABLE IF NOT EXISTS role_permissions (
+	role TEXT NOT NULL,
+	permission TEXT NOT NULL,
+	PRIMARY KEY (role, permission)
+);
+
+-- User Roles table (roles held in addition to users.role)
+CREATE TABLE IF NOT EXISTS user_roles (
+	user_id INTEGER NOT NULL,
+	role TEXT NOT NULL,
+	PRIMARY KEY (user_id, role),
+	FOREIGN KEY (user_id) REFERENCES users(id)
+);
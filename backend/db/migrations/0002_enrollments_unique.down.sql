@@ -0,0 +1 @@
+DROP INDEX enrollments_student_offering;
@@ -0,0 +1,5 @@
+-- A student is enrolled in a course offering at most once; retakes are separate
+-- enrollments in a later offering. SeedDB's ON CONFLICT clause relies on this.
+-- Fails if an existing database already holds duplicate enrollments, which have
+-- to be merged by hand first.
+CREATE UNIQUE INDEX enrollments_student_offering ON enrollments (student_id, course_offering_id);
@@ -0,0 +1 @@
+ALTER TABLE login_throttles RENAME COLUMN throttle_key TO "key";
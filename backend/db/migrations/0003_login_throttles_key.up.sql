@@ -0,0 +1,3 @@
+-- "key" is a reserved word in MySQL, so the throttle counter's key gets a name that
+-- every supported database accepts without quoting.
+ALTER TABLE login_throttles RENAME COLUMN "key" TO throttle_key;
@@ -0,0 +1,2 @@
+-- sqlite only
+-- Normalized timestamps read the same as the values they replaced, so they are kept.
@@ -0,0 +1,123 @@
+-- sqlite only
+-- Timestamps used to be stored in whatever form they were written in: CURRENT_TIMESTAMP
+-- defaults in UTC, RFC 3339 strings and Go times carrying the server's UTC offset.
+-- Rewrite them all as UTC in the layout the program now writes,
+-- "YYYY-MM-DD HH:MM:SS.SSS". Values SQLite cannot parse are left as they are, and
+-- reading them fails with an invalid timestamp error.
+
+UPDATE schema_migrations SET
+	applied_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', applied_at), applied_at);
+
+UPDATE users SET
+	password_changed_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', password_changed_at), password_changed_at),
+	created_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', created_at), created_at),
+	updated_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', updated_at), updated_at);
+
+UPDATE departments SET
+	created_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', created_at), created_at),
+	updated_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', updated_at), updated_at);
+
+UPDATE majors SET
+	created_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', created_at), created_at),
+	updated_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', updated_at), updated_at);
+
+UPDATE classes SET
+	created_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', created_at), created_at),
+	updated_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', updated_at), updated_at);
+
+UPDATE teachers SET
+	created_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', created_at), created_at),
+	updated_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', updated_at), updated_at);
+
+UPDATE students SET
+	created_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', created_at), created_at),
+	updated_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', updated_at), updated_at);
+
+UPDATE courses SET
+	created_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', created_at), created_at),
+	updated_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', updated_at), updated_at);
+
+UPDATE semesters SET
+	start_date = COALESCE(strftime('%Y-%m-%d %H:%M:%f', start_date), start_date),
+	end_date = COALESCE(strftime('%Y-%m-%d %H:%M:%f', end_date), end_date),
+	created_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', created_at), created_at),
+	updated_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', updated_at), updated_at);
+
+UPDATE course_offerings SET
+	created_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', created_at), created_at),
+	updated_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', updated_at), updated_at);
+
+UPDATE enrollments SET
+	created_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', created_at), created_at),
+	updated_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', updated_at), updated_at);
+
+UPDATE exam_attempts SET
+	created_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', created_at), created_at),
+	updated_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', updated_at), updated_at);
+
+UPDATE grade_components SET
+	created_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', created_at), created_at),
+	updated_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', updated_at), updated_at);
+
+UPDATE grade_details SET
+	created_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', created_at), created_at),
+	updated_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', updated_at), updated_at);
+
+UPDATE training_plans SET
+	created_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', created_at), created_at),
+	updated_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', updated_at), updated_at);
+
+UPDATE evaluations SET
+	created_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', created_at), created_at),
+	updated_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', updated_at), updated_at);
+
+UPDATE textbooks SET
+	created_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', created_at), created_at),
+	updated_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', updated_at), updated_at);
+
+UPDATE textbook_applications SET
+	created_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', created_at), created_at),
+	updated_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', updated_at), updated_at);
+
+UPDATE internships SET
+	start_date = COALESCE(strftime('%Y-%m-%d %H:%M:%f', start_date), start_date),
+	end_date = COALESCE(strftime('%Y-%m-%d %H:%M:%f', end_date), end_date),
+	created_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', created_at), created_at),
+	updated_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', updated_at), updated_at);
+
+UPDATE theses SET
+	created_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', created_at), created_at),
+	updated_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', updated_at), updated_at);
+
+UPDATE student_fees SET
+	created_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', created_at), created_at),
+	updated_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', updated_at), updated_at);
+
+UPDATE graduation_reviews SET
+	confirmed_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', confirmed_at), confirmed_at),
+	created_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', created_at), created_at),
+	updated_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', updated_at), updated_at);
+
+UPDATE sessions SET
+	expires_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', expires_at), expires_at),
+	last_used_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', last_used_at), last_used_at),
+	revoked_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', revoked_at), revoked_at),
+	created_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', created_at), created_at);
+
+UPDATE password_history SET
+	created_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', created_at), created_at);
+
+UPDATE recovery_codes SET
+	used_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', used_at), used_at),
+	created_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', created_at), created_at);
+
+UPDATE login_challenges SET
+	expires_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', expires_at), expires_at),
+	created_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', created_at), created_at);
+
+UPDATE login_throttles SET
+	last_failure_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', last_failure_at), last_failure_at),
+	locked_until = COALESCE(strftime('%Y-%m-%d %H:%M:%f', locked_until), locked_until);
+
+UPDATE login_events SET
+	created_at = COALESCE(strftime('%Y-%m-%d %H:%M:%f', created_at), created_at);
@@ -0,0 +1,6 @@
+-- Rows that were deleted become visible again.
+ALTER TABLE course_offerings DROP COLUMN deleted_at;
+ALTER TABLE users DROP COLUMN deleted_at;
+ALTER TABLE courses DROP COLUMN deleted_at;
+ALTER TABLE majors DROP COLUMN deleted_at;
+ALTER TABLE departments DROP COLUMN deleted_at;
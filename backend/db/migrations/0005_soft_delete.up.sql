@@ -0,0 +1,8 @@
+-- Departments, majors, courses, users and course offerings are deleted by setting
+-- deleted_at, so that the rows referring to them keep their meaning. Only rows
+-- without any references left can be purged for good.
+ALTER TABLE departments ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE majors ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE courses ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE course_offerings ADD COLUMN deleted_at TIMESTAMP;
@@ -0,0 +1 @@
+-- The dropped grants are not restored; give role.manage to other roles again by hand.
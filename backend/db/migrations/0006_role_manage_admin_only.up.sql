@@ -0,0 +1,3 @@
+-- role.manage lets its holder give roles and permissions, so it is reserved for
+-- administrators like data.purge; grants made to other roles are dropped.
+DELETE FROM role_permissions WHERE permission = 'role.manage';
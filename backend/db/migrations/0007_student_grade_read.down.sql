@@ -0,0 +1 @@
+DELETE FROM role_permissions WHERE role = 'student' AND permission = 'grade.read';
@@ -0,0 +1,6 @@
+-- Reading grades and transcripts now needs grade.read, which students hold by default.
+-- Installations whose permissions are already seeded give it to students here; new
+-- installations get it with the other defaults.
+INSERT INTO role_permissions (role, permission)
+SELECT DISTINCT 'student', 'grade.read' FROM role_permissions
+WHERE NOT EXISTS (SELECT 1 FROM role_permissions WHERE role = 'student' AND permission = 'grade.read');
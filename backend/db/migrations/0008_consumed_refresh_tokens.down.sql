@@ -0,0 +1,12 @@
+-- Sessions keep only their most recently consumed token.
+ALTER TABLE sessions ADD COLUMN previous_token_hash TEXT;
+
+UPDATE sessions SET previous_token_hash = (
+	SELECT c.token_hash FROM consumed_refresh_tokens c
+	WHERE c.session_id = sessions.id
+	ORDER BY c.consumed_at DESC
+	LIMIT 1
+);
+
+DROP INDEX idx_consumed_refresh_tokens_session;
+DROP TABLE consumed_refresh_tokens;
@@ -0,0 +1,18 @@
+-- Every refresh token a session has rotated away from, so that replaying any of them,
+-- not only the last one, is recognised as reuse and revokes the session.
+CREATE TABLE consumed_refresh_tokens (
+	token_hash TEXT PRIMARY KEY,
+	session_id INTEGER NOT NULL,
+	user_id INTEGER NOT NULL,
+	consumed_at TIMESTAMP NOT NULL,
+	FOREIGN KEY (session_id) REFERENCES sessions(id),
+	FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE INDEX idx_consumed_refresh_tokens_session ON consumed_refresh_tokens(session_id);
+
+INSERT INTO consumed_refresh_tokens (token_hash, session_id, user_id, consumed_at)
+SELECT previous_token_hash, id, user_id, COALESCE(last_used_at, created_at) FROM sessions
+WHERE previous_token_hash IS NOT NULL;
+
+ALTER TABLE sessions DROP COLUMN previous_token_hash;
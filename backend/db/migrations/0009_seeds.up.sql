@@ -0,0 +1,6 @@
+-- Default data installed once on first run, such as the default role permissions,
+-- so that data an administrator removed on purpose is not installed again.
+CREATE TABLE seeds (
+	name TEXT PRIMARY KEY,
+	seeded_at TIMESTAMP NOT NULL
+);
@@ -0,0 +1,253 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"to-mrz/models"
+)
+
+// seedRolePermissionsName is the seeds record of the default role permissions
+const seedRolePermissionsName = "role_permissions"
+
+// Permission errors
+var (
+	ErrAdminPermissions    = errors.New("the admin role always has every permission")
+	ErrAdminOnlyPermission = errors.New("permission is reserved for administrators")
+)
+
+// ValidRole reports whether role is a known role
+func ValidRole(role models.Role) bool {
+	for _, r := range models.AllRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidPermission reports whether permission is a known permission
+func ValidPermission(permission models.Permission) bool {
+	for _, p := range models.AllPermissions {
+		if p.Name == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// seedRolePermissions installs the default role-to-permission mapping once and
+// records that in seeds. It reports whether it installed the mapping.
+//
+// Databases created before seeds existed have no record: a non-empty mapping is
+// recorded as seeded, an empty one is seeded.
+func seedRolePermissions() (bool, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var count int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM seeds WHERE name = ?", seedRolePermissionsName).Scan(&count); err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return false, nil
+	}
+
+	if err := tx.QueryRow("SELECT COUNT(*) FROM role_permissions").Scan(&count); err != nil {
+		return false, err
+	}
+	seeded := count == 0
+	if seeded {
+		for role, permissions := range models.DefaultRolePermissions {
+			for _, permission := range permissions {
+				if _, err := tx.Exec("INSERT INTO role_permissions (role, permission) VALUES (?, ?) ON CONFLICT DO NOTHING", role, permission); err != nil {
+					return false, err
+				}
+			}
+		}
+	}
+
+	if _, err := tx.Exec("INSERT INTO seeds (name, seeded_at) VALUES (?, ?) ON CONFLICT DO NOTHING", seedRolePermissionsName, time.Now()); err != nil {
+		return false, err
+	}
+	return seeded, tx.Commit()
+}
+
+// GetRolePermissions returns every role with its permissions
+func GetRolePermissions() ([]models.RolePermissions, error) {
+	rows, err := DB.Query("SELECT role, permission FROM role_permissions ORDER BY role, permission")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byRole := map[models.Role][]models.Permission{}
+	for rows.Next() {
+		var role models.Role
+		var permission models.Permission
+		if err := rows.Scan(&role, &permission); err != nil {
+			return nil, err
+		}
+		byRole[role] = append(byRole[role], permission)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result := make([]models.RolePermissions, 0, len(models.AllRoles))
+	for _, role := range models.AllRoles {
+		permissions := byRole[role]
+		if role == models.RoleAdmin {
+			permissions = allPermissionNames()
+		}
+		if permissions == nil {
+			permissions = []models.Permission{}
+		}
+		result = append(result, models.RolePermissions{Role: role, Permissions: permissions})
+	}
+	return result, nil
+}
+
+// GetRoles returns a page of the roles with the permissions they grant, in the order
+// of models.AllRoles
+func GetRoles(query ListQuery) (*models.Page, error) {
+	roles, err := GetRolePermissions()
+	if err != nil {
+		return nil, err
+	}
+	return listSlice(query, roles, func(i int) string { return string(roles[i].Role) })
+}
+
+// SetRolePermissions replaces the permissions granted to a role
+func SetRolePermissions(role models.Role, permissions []models.Permission) error {
+	if role == models.RoleAdmin {
+		return ErrAdminPermissions
+	}
+	for _, permission := range permissions {
+		if models.AdminOnlyPermissions[permission] {
+			return ErrAdminOnlyPermission
+		}
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM role_permissions WHERE role = ?", role); err != nil {
+		return err
+	}
+	for _, permission := range permissions {
+		if _, err := tx.Exec("INSERT INTO role_permissions (role, permission) VALUES (?, ?) ON CONFLICT DO NOTHING", role, permission); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetUserRoles returns a user's primary role followed by any additional roles
+func GetUserRoles(userID uint) ([]models.Role, error) {
+	var primary models.Role
+	if err := DB.QueryRow("SELECT role FROM users WHERE id = ?", userID).Scan(&primary); err != nil {
+		return nil, err
+	}
+
+	rows, err := DB.Query("SELECT role FROM user_roles WHERE user_id = ? ORDER BY role", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []models.Role{primary}
+	for rows.Next() {
+		var role models.Role
+		if err := rows.Scan(&role); err != nil {
+			return nil, err
+		}
+		if role != primary {
+			roles = append(roles, role)
+		}
+	}
+	return roles, rows.Err()
+}
+
+// SetUserRoles replaces a user's additional roles. The primary role in users.role
+// is kept and ignored if present in roles.
+func SetUserRoles(userID uint, roles []models.Role) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var primary models.Role
+	if err := tx.QueryRow("SELECT role FROM users WHERE id = ?", userID).Scan(&primary); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM user_roles WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+	for _, role := range roles {
+		if role == primary {
+			continue
+		}
+		if _, err := tx.Exec("INSERT INTO user_roles (user_id, role) VALUES (?, ?) ON CONFLICT DO NOTHING", userID, role); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetUserPermissions returns the union of the permissions of all of a user's roles
+func GetUserPermissions(userID uint) (map[models.Permission]bool, error) {
+	roles, err := GetUserRoles(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	permissions := map[models.Permission]bool{}
+	for _, role := range roles {
+		if role == models.RoleAdmin {
+			for _, p := range allPermissionNames() {
+				permissions[p] = true
+			}
+			return permissions, nil
+		}
+	}
+
+	rows, err := DB.Query(`
+		SELECT DISTINCT permission FROM role_permissions
+		WHERE role IN (SELECT role FROM users WHERE id = ? UNION SELECT role FROM user_roles WHERE user_id = ?)
+	`, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var permission models.Permission
+		if err := rows.Scan(&permission); err != nil {
+			return nil, err
+		}
+		if !models.AdminOnlyPermissions[permission] {
+			permissions[permission] = true
+		}
+	}
+	return permissions, rows.Err()
+}
+
+// allPermissionNames returns the names of every known permission
+func allPermissionNames() []models.Permission {
+	names := make([]models.Permission, len(models.AllPermissions))
+	for i, p := range models.AllPermissions {
+		names[i] = p.Name
+	}
+	return names
+}
@@ -0,0 +1,36 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// referenceQueries select a row that requests may refer to by ID, if it exists and
+// has not been deleted
+var referenceQueries = map[string]string{
+	"departments":      "SELECT id FROM departments WHERE id = ? AND deleted_at IS NULL",
+	"majors":           "SELECT id FROM majors WHERE id = ? AND deleted_at IS NULL",
+	"classes":          "SELECT id FROM classes WHERE id = ?",
+	"courses":          "SELECT id FROM courses WHERE id = ? AND deleted_at IS NULL",
+	"course_offerings": "SELECT id FROM course_offerings WHERE id = ? AND deleted_at IS NULL",
+	"semesters":        "SELECT id FROM semesters WHERE id = ?",
+	"students": `SELECT s.id FROM students s JOIN users u ON s.user_id = u.id
+		WHERE s.id = ? AND u.deleted_at IS NULL`,
+	"teachers": `SELECT t.id FROM teachers t JOIN users u ON t.user_id = u.id
+		WHERE t.id = ? AND u.deleted_at IS NULL`,
+}
+
+// RowExists reports whether a row of a table that requests may refer to exists and
+// has not been deleted
+func RowExists(table string, id uint) (bool, error) {
+	query, ok := referenceQueries[table]
+	if !ok {
+		return false, fmt.Errorf("rows of %s cannot be referred to", table)
+	}
+	err := DB.QueryRow(query, id).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,222 @@
+package db
+
+import (
+	"time"
+
+	"to-mrz/models"
+)
+
+// Repositories groups the data access used by the HTTP handlers, one interface per
+// aggregate. NewSQLRepositories returns the implementation backed by DB; tests can
+// fill the fields with in-memory fakes instead.
+//
+// Methods return sql.ErrNoRows for missing rows and the sentinel errors of this
+// package (ErrUsernameTaken, ErrLastAdmin, ...) like the functions they stand for.
+//
+// Deleting a department, major, course, course offering or user only marks it
+// deleted; Restore brings it back and Purge removes a deleted row for good.
+//
+// List methods return one page of the rows matching their filter and wrap
+// ErrInvalidListQuery for sort fields, searches or cursors they do not accept.
+type Repositories struct {
+	Departments     DepartmentRepository
+	Majors          MajorRepository
+	Courses         CourseRepository
+	CourseOfferings CourseOfferingRepository
+	Enrollments     EnrollmentRepository
+	Users           UserRepository
+	Roles           RoleRepository
+	Sessions        SessionRepository
+	Scopes          ScopeRepository
+	Logins          LoginRepository
+	TwoFactor       TwoFactorRepository
+	TrainingPlans   TrainingPlanRepository
+	Graduation      GraduationRepository
+	Backups         BackupRepository
+	Search          SearchRepository
+	References      ReferenceRepository
+}
+
+// DepartmentRepository stores departments
+type DepartmentRepository interface {
+	List(filter DepartmentFilter) (*models.Page, error)
+	Get(id uint) (*models.Department, error)
+	Create(department *models.Department) (uint, error)
+	Update(department *models.Department) error
+	Delete(id uint) error
+	Restore(id uint) error
+	Purge(id uint) error
+}
+
+// MajorRepository stores majors
+type MajorRepository interface {
+	List(filter MajorFilter) (*models.Page, error)
+	Delete(id uint) error
+	Restore(id uint) error
+	Purge(id uint) error
+}
+
+// CourseRepository stores the course catalogue
+type CourseRepository interface {
+	List(filter CourseFilter) (*models.Page, error)
+	Get(id uint) (*models.Course, error)
+	Create(course *models.Course) (uint, error)
+	Update(course *models.Course) error
+	Delete(id uint) error
+	Restore(id uint) error
+	Purge(id uint) error
+}
+
+// CourseOfferingRepository stores the offerings of courses in semesters
+type CourseOfferingRepository interface {
+	List(filter CourseOfferingFilter) (*models.Page, error)
+	Delete(id uint) error
+	Restore(id uint) error
+	Purge(id uint) error
+}
+
+// EnrollmentRepository stores enrollments with their grades, retakes and make-up exams
+type EnrollmentRepository interface {
+	StudentGrades(filter StudentGradeFilter) (*models.Page, error)
+	CourseGrades(filter CourseGradeFilter) (*models.Page, error)
+	Grade(id uint) (*models.Enrollment, error)
+	CreateGrade(enrollment models.Enrollment) (uint, error)
+	UpdateGrade(id uint, grade float64) error
+	CreateRetake(originalID, courseOfferingID uint) (uint, error)
+	Transcript(studentID uint, policy models.GradePolicy) (*models.Transcript, error)
+
+	MakeupRoster(semesterID, courseOfferingID uint) ([]models.ExamAttempt, error)
+	GenerateMakeupRoster(semesterID, courseOfferingID uint) (int64, error)
+	RecordMakeupScore(id uint, rawScore float64) error
+
+	GradeComponents(courseOfferingID uint) ([]models.GradeComponent, error)
+	ExportOfferings(courseOfferingID, semesterID, departmentID uint) ([]models.CourseOffering, error)
+	EachGradeSheetRow(courseOfferingID uint, fn func(models.GradeSheetRow) error) error
+	GradeImportTemplate(courseOfferingID uint) ([][]string, error)
+	ValidateGradeImport(courseOfferingID uint, sheet [][]string) ([]models.GradeImportRow, []models.GradeImportError, error)
+	ApplyGradeImport(courseOfferingID uint, rows []models.GradeImportRow) error
+}
+
+// UserRepository stores user accounts
+type UserRepository interface {
+	Get(id uint) (*models.User, error)
+	GetByUsername(username string) (*models.User, error)
+	List(filter UserFilter) (*models.Page, error)
+	Create(user *models.User) (uint, error)
+	CreateFirstAdmin(user *models.User) (uint, error)
+	UpdateProfile(id uint, name, email, phone string) error
+	// SyncExternal updates an account from its external backend, making it one of its accounts
+	SyncExternal(id uint, source, name, email, phone string) error
+	UpdateRole(id uint, role models.Role, departmentID *uint) error
+	SetDisabled(id uint, disabled bool) error
+	Delete(id uint) error
+	Restore(id uint) error
+	Purge(id uint) error
+	UpdatePassword(id uint, hash string, keepSessionID uint, mustChange bool) error
+	RecentPasswordHashes(id uint, n int) ([]string, error)
+
+	ValidateImport(role models.Role, sheet [][]string) ([]models.UserImportRow, []models.UserImportError, error)
+	ApplyImport(role models.Role, rows []models.UserImportRow) error
+}
+
+// RoleRepository stores role permissions and the additional roles of users
+type RoleRepository interface {
+	List(query ListQuery) (*models.Page, error)
+	SetPermissions(role models.Role, permissions []models.Permission) error
+	UserRoles(userID uint) ([]models.Role, error)
+	SetUserRoles(userID uint, roles []models.Role) error
+	UserPermissions(userID uint) (map[models.Permission]bool, error)
+}
+
+// SessionRepository stores login sessions and their refresh tokens
+type SessionRepository interface {
+	Create(session *models.Session, tokenHash string) (uint, error)
+	Rotate(tokenHash, newTokenHash string, expiresAt time.Time) (*models.Session, error)
+	List(filter SessionFilter) (*models.Page, error)
+	Revoke(sessionID, userID uint) error
+	RevokeAllForUser(userID uint) (int64, error)
+	// Check returns nil while a session is active and its user enabled with the given role
+	Check(sessionID, userID uint, role string) error
+}
+
+// ScopeRepository works out the data scope of users and the owners of the rows it limits
+type ScopeRepository interface {
+	DataScope(userID uint) (*models.DataScope, error)
+	DepartmentOwner(id uint) (*models.RowOwner, error)
+	MajorOwner(id uint) (*models.RowOwner, error)
+	CourseOwner(id uint) (*models.RowOwner, error)
+	CourseOfferingOwner(id uint) (*models.RowOwner, error)
+	EnrollmentOwner(id uint) (*models.RowOwner, error)
+	ExamAttemptOwner(id uint) (*models.RowOwner, error)
+	StudentOwner(id uint) (*models.RowOwner, error)
+	TrainingPlanOwner(id uint) (*models.RowOwner, error)
+}
+
+// LoginRepository stores failed login counters and the sign-in history
+type LoginRepository interface {
+	BeginAttempt(username, ip string) (time.Duration, error)
+	RecordFailure(username, ip string) error
+	ReleaseAttempt(username, ip string) error
+	RecordSuccess(username string) error
+	UnlockUser(userID uint) error
+	UnlockIP(ip string) error
+	ActiveLockouts(filter LockoutFilter) (*models.Page, error)
+	RecordEvent(event *models.LoginEvent) error
+	Events(filter LoginEventFilter) (*models.Page, error)
+}
+
+// TwoFactorRepository stores TOTP secrets, recovery codes and pending login challenges
+type TwoFactorRepository interface {
+	Required(userID uint) (bool, error)
+	Status(userID uint) (*models.TwoFactorStatus, error)
+	Secret(userID uint) (secret string, lastStep int64, enabled bool, err error)
+	StartSetup(userID uint, secret string) error
+	Enable(userID uint, step int64, recoveryCodeHashes []string) error
+	RecordStep(userID uint, step int64) error
+	Disable(userID uint) error
+	ReplaceRecoveryCodes(userID uint, codeHashes []string) error
+	UseRecoveryCode(userID uint, codeHash string) error
+
+	CreateChallenge(userID uint, tokenHash string, expiresAt time.Time) error
+	GetChallenge(tokenHash string) (uint, error)
+	DeleteChallenge(tokenHash string) error
+}
+
+// TrainingPlanRepository stores training plans and audits students against them
+type TrainingPlanRepository interface {
+	List(filter TrainingPlanFilter) (*models.Page, error)
+	Get(id uint) (*models.TrainingPlan, error)
+	Create(plan *models.TrainingPlan) (uint, error)
+	Update(plan *models.TrainingPlan) error
+	Delete(id uint) error
+	DegreeAudit(studentID uint) (*models.DegreeAudit, error)
+}
+
+// GraduationRepository stores graduation reviews and their results
+type GraduationRepository interface {
+	Run(graduationYear, cohortYear int, majorID, createdBy uint) (uint, error)
+	List(filter GraduationReviewFilter) (*models.Page, error)
+	Get(id uint) (*models.GraduationReview, error)
+	Override(reviewID, studentID uint, eligible bool, justification string, overriddenBy uint) error
+	Confirm(id uint) (int64, error)
+}
+
+// BackupRepository stores database backups. Its methods return ErrBackupUnsupported
+// when the database is not SQLite.
+type BackupRepository interface {
+	List(query ListQuery) (*models.Page, error)
+	Create(trigger string) (*models.Backup, error)
+	Get(name string) (backup *models.Backup, path string, err error)
+}
+
+// SearchRepository searches courses, teachers and students. Writes to them through
+// this package keep the search index up to date.
+type SearchRepository interface {
+	Search(query SearchQuery) (*models.SearchResult, error)
+}
+
+// ReferenceRepository checks the IDs that requests refer to
+type ReferenceRepository interface {
+	// Exists reports whether the row of a table exists and has not been deleted
+	Exists(table string, id uint) (bool, error)
+}
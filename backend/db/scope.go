@@ -0,0 +1,147 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+
+	"to-mrz/models"
+)
+
+// GetDataScope works out which rows a user may touch from all of their roles.
+// Administrators and academic affairs staff are unrestricted; department, teacher and
+// student roles reach their own rows, and any other role reaches none.
+func GetDataScope(userID uint) (*models.DataScope, error) {
+	roles, err := GetUserRoles(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	scope := &models.DataScope{}
+	for _, role := range roles {
+		switch role {
+		case models.RoleAdmin, models.RoleAcademic:
+			scope.Unrestricted = true
+		case models.RoleDepartment:
+			var departmentID sql.NullInt64
+			if err := DB.QueryRow("SELECT department_id FROM users WHERE id = ?", userID).Scan(&departmentID); err != nil {
+				return nil, err
+			}
+			scope.DepartmentID = uint(departmentID.Int64)
+		case models.RoleTeacher:
+			if err := scanOptionalID("SELECT id FROM teachers WHERE user_id = ?", userID, &scope.TeacherID); err != nil {
+				return nil, err
+			}
+		case models.RoleStudent:
+			if err := scanOptionalID("SELECT id FROM students WHERE user_id = ?", userID, &scope.StudentID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return scope, nil
+}
+
+// scanOptionalID scans a single ID, leaving dest as 0 when no row matches
+func scanOptionalID(query string, arg interface{}, dest *uint) error {
+	err := DB.QueryRow(query, arg).Scan(dest)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	return err
+}
+
+// GetDepartmentOwner returns the owner of a department
+func GetDepartmentOwner(id uint) (*models.RowOwner, error) {
+	return &models.RowOwner{DepartmentID: id}, nil
+}
+
+// GetCourseOwner returns the department a course belongs to
+func GetCourseOwner(id uint) (*models.RowOwner, error) {
+	owner := &models.RowOwner{}
+	err := DB.QueryRow("SELECT department_id FROM courses WHERE id = ?", id).Scan(&owner.DepartmentID)
+	if err != nil {
+		return nil, err
+	}
+	return owner, nil
+}
+
+// GetCourseOfferingOwner returns the department and teacher of a course offering
+func GetCourseOfferingOwner(id uint) (*models.RowOwner, error) {
+	owner := &models.RowOwner{}
+	err := DB.QueryRow(`
+		SELECT c.department_id, co.teacher_id
+		FROM course_offerings co
+		JOIN courses c ON co.course_id = c.id
+		WHERE co.id = ?
+	`, id).Scan(&owner.DepartmentID, &owner.TeacherID)
+	if err != nil {
+		return nil, err
+	}
+	return owner, nil
+}
+
+// GetEnrollmentOwner returns the department, teacher and student of an enrollment
+func GetEnrollmentOwner(id uint) (*models.RowOwner, error) {
+	owner := &models.RowOwner{}
+	err := DB.QueryRow(`
+		SELECT c.department_id, co.teacher_id, e.student_id
+		FROM enrollments e
+		JOIN course_offerings co ON e.course_offering_id = co.id
+		JOIN courses c ON co.course_id = c.id
+		WHERE e.id = ?
+	`, id).Scan(&owner.DepartmentID, &owner.TeacherID, &owner.StudentID)
+	if err != nil {
+		return nil, err
+	}
+	return owner, nil
+}
+
+// GetExamAttemptOwner returns the owner of the enrollment an exam attempt belongs to
+func GetExamAttemptOwner(id uint) (*models.RowOwner, error) {
+	var enrollmentID uint
+	if err := DB.QueryRow("SELECT enrollment_id FROM exam_attempts WHERE id = ?", id).Scan(&enrollmentID); err != nil {
+		return nil, err
+	}
+	return GetEnrollmentOwner(enrollmentID)
+}
+
+// GetStudentOwner returns a student and the department of their major
+func GetStudentOwner(id uint) (*models.RowOwner, error) {
+	owner := &models.RowOwner{StudentID: id}
+	err := DB.QueryRow(`
+		SELECT m.department_id
+		FROM students s
+		JOIN classes cl ON s.class_id = cl.id
+		JOIN majors m ON cl.major_id = m.id
+		WHERE s.id = ?
+	`, id).Scan(&owner.DepartmentID)
+	if err != nil {
+		return nil, err
+	}
+	return owner, nil
+}
+
+// GetMajorOwner returns the department a major belongs to
+func GetMajorOwner(id uint) (*models.RowOwner, error) {
+	owner := &models.RowOwner{}
+	err := DB.QueryRow("SELECT department_id FROM majors WHERE id = ?", id).Scan(&owner.DepartmentID)
+	if err != nil {
+		return nil, err
+	}
+	return owner, nil
+}
+
+// GetTrainingPlanOwner returns the department of a training plan's major
+func GetTrainingPlanOwner(id uint) (*models.RowOwner, error) {
+	owner := &models.RowOwner{}
+	err := DB.QueryRow(`
+		SELECT m.department_id
+		FROM training_plans tp
+		JOIN majors m ON tp.major_id = m.id
+		WHERE tp.id = ?
+	`, id).Scan(&owner.DepartmentID)
+	if err != nil {
+		return nil, err
+	}
+	return owner, nil
+}
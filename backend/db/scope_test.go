@@ -0,0 +1,36 @@
+package db
+
+import (
+	"testing"
+
+	"to-mrz/models"
+)
+
+func TestDataScopeRestrictsUnlistedRoles(t *testing.T) {
+	openTestDB(t)
+
+	for _, tc := range []struct {
+		role         models.Role
+		unrestricted bool
+	}{
+		{models.RoleAdmin, true},
+		{models.RoleAcademic, true},
+		{models.RoleTeacher, false},
+		{models.RoleStudent, false},
+		{models.RoleSupervisor, false},
+		{models.RoleFinance, false},
+		{models.Role("auditor"), false},
+	} {
+		userID := createTestUser(t, "user-"+string(tc.role), tc.role)
+		scope, err := GetDataScope(userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if scope.Unrestricted != tc.unrestricted {
+			t.Errorf("%s: unrestricted = %v, want %v", tc.role, scope.Unrestricted, tc.unrestricted)
+		}
+		if !tc.unrestricted && scope.Allows(&models.RowOwner{DepartmentID: 1, TeacherID: 1, StudentID: 1}) {
+			t.Errorf("%s without a department, teacher or student record reaches a row", tc.role)
+		}
+	}
+}
@@ -0,0 +1,380 @@
+package db
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"to-mrz/models"
+	"to-mrz/utils"
+)
+
+// Search limits
+const (
+	DefaultSearchLimit = 5
+	MaxSearchLimit     = 20
+)
+
+// Search result types
+const (
+	SearchCourse  = "course"
+	SearchTeacher = "teacher"
+	SearchStudent = "student"
+)
+
+// SearchTypes lists the result types in the order their groups are returned
+var SearchTypes = []string{SearchCourse, SearchTeacher, SearchStudent}
+
+// searchIndexReady is set by InitSearchIndex once the search_index FTS5 table exists.
+// Without it searches fall back to LIKE on the source tables.
+var searchIndexReady bool
+
+// searchKind describes the rows of one result type. source selects the rows that can
+// be found with the columns id, title, code, body and detail, sync_id (the row of
+// syncTable they come from) and the columns used for scoping.
+type searchKind struct {
+	source    string
+	syncTable string // table whose writes change the indexed text
+	refs      string // selects the ids of the rows that come from a syncTable row
+}
+
+var searchKinds = map[string]searchKind{
+	SearchCourse: {
+		source: `SELECT c.id, c.id AS sync_id, c.name AS title, c.code, COALESCE(c.description, '') AS body,
+				COALESCE(d.name, '') AS detail
+			FROM courses c LEFT JOIN departments d ON c.department_id = d.id
+			WHERE c.deleted_at IS NULL`,
+		syncTable: "courses",
+		refs:      "SELECT id FROM courses WHERE id = ?",
+	},
+	SearchTeacher: {
+		// 教师按姓名查找，职称作为正文
+		source: `SELECT t.id, t.user_id AS sync_id, u.name AS title, '' AS code, COALESCE(t.title, '') AS body,
+				COALESCE(d.name, '') AS detail
+			FROM teachers t
+			JOIN users u ON t.user_id = u.id
+			LEFT JOIN departments d ON t.department_id = d.id
+			WHERE u.deleted_at IS NULL`,
+		syncTable: "users",
+		refs:      "SELECT id FROM teachers WHERE user_id = ?",
+	},
+	SearchStudent: {
+		// 学生按学号与姓名查找
+		source: `SELECT s.id, s.user_id AS sync_id, u.name AS title, s.student_id AS code, '' AS body,
+				COALESCE(cl.name, '') AS detail, m.department_id
+			FROM students s
+			JOIN users u ON s.user_id = u.id
+			LEFT JOIN classes cl ON s.class_id = cl.id
+			LEFT JOIN majors m ON cl.major_id = m.id
+			WHERE u.deleted_at IS NULL`,
+		syncTable: "users",
+		refs:      "SELECT id FROM students WHERE user_id = ?",
+	},
+}
+
+// InitSearchIndex creates the full-text search index and fills it from the courses,
+// teachers and students. It needs SQLite built with FTS5 (the sqlite_fts5 build tag);
+// otherwise it logs that searches use LIKE and leaves the index off.
+func InitSearchIndex() error {
+	searchIndexReady = false
+	if DB.Dialect() != SQLite {
+		utils.Warnf("Full-text search needs SQLite; searching the %s database with LIKE", DB.Dialect())
+		return nil
+	}
+
+	// An index left by a build with FTS5 can be neither used nor dropped without it
+	var fts5 bool
+	if err := DB.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&fts5); err != nil {
+		return err
+	}
+	if !fts5 {
+		utils.Warnf("SQLite was built without FTS5 (build with -tags sqlite_fts5); searching with LIKE")
+		return nil
+	}
+
+	// The trigram tokenizer matches any substring of three or more characters, which
+	// suits Chinese names without word boundaries
+	_, err := DB.Exec(`CREATE VIRTUAL TABLE IF NOT EXISTS search_index
+		USING fts5(kind UNINDEXED, ref_id UNINDEXED, title, code, body, tokenize = 'trigram')`)
+	if err != nil {
+		return fmt.Errorf("failed to create search index: %w", err)
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM search_index"); err != nil {
+		return fmt.Errorf("failed to clear search index: %w", err)
+	}
+	for _, kind := range SearchTypes {
+		_, err := tx.Exec("INSERT INTO search_index (kind, ref_id, title, code, body) SELECT ?, id, title, code, body FROM ("+searchKinds[kind].source+") hit", kind)
+		if err != nil {
+			return fmt.Errorf("failed to index %ss: %w", kind, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	searchIndexReady = true
+	return nil
+}
+
+// syncSearchIndex updates the search index after a write to a row of table, in the
+// same transaction as the write. Rows that can no longer be found, such as deleted
+// ones, leave the index.
+func syncSearchIndex(q execQuerier, table string, id uint) error {
+	if !searchIndexReady {
+		return nil
+	}
+	for _, kind := range SearchTypes {
+		spec := searchKinds[kind]
+		if spec.syncTable != table {
+			continue
+		}
+		_, err := q.Exec("DELETE FROM search_index WHERE kind = ? AND ref_id IN ("+spec.refs+")", kind, id)
+		if err != nil {
+			return fmt.Errorf("failed to update search index: %w", err)
+		}
+
+		_, err = q.Exec(`
+			INSERT INTO search_index (kind, ref_id, title, code, body)
+			SELECT ?, id, title, code, body FROM (`+spec.source+`) hit WHERE sync_id = ?
+		`, kind, id)
+		if err != nil {
+			return fmt.Errorf("failed to update search index: %w", err)
+		}
+	}
+	return nil
+}
+
+// SearchQuery is a search across courses, teachers and students
+type SearchQuery struct {
+	Keyword string
+	Types   []string // result types to search, all of SearchTypes when empty
+	Limit   int      // hits per type, DefaultSearchLimit when zero
+	Scope   *models.DataScope
+}
+
+// Search finds the courses, teachers and students matching every word of the
+// keyword, one group per type. Courses and teachers are visible to everyone; the
+// scope limits students to those of its department, the teacher's offerings and the
+// student themself.
+func Search(query SearchQuery) (*models.SearchResult, error) {
+	terms := strings.Fields(query.Keyword)
+	limit := query.Limit
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+	limit = min(limit, MaxSearchLimit)
+
+	types := query.Types
+	if len(types) == 0 {
+		types = SearchTypes
+	}
+
+	result := &models.SearchResult{Query: strings.Join(terms, " "), Groups: []models.SearchGroup{}}
+	for _, kind := range SearchTypes {
+		if !containsString(types, kind) {
+			continue
+		}
+		group, err := searchGroup(kind, terms, limit, query.Scope)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search %ss: %w", kind, err)
+		}
+		result.Groups = append(result.Groups, *group)
+	}
+	return result, nil
+}
+
+// searchGroup finds the hits of one type
+func searchGroup(kind string, terms []string, limit int, scope *models.DataScope) (*models.SearchGroup, error) {
+	group := &models.SearchGroup{Type: kind, Items: []models.SearchHit{}}
+
+	q := &selectQuery{
+		columns: "hit.id, hit.title, hit.code, hit.body, hit.detail",
+		from:    "(" + searchKinds[kind].source + ") hit",
+	}
+	if kind == SearchStudent {
+		condition, args := studentSearchScope(scope)
+		if condition == "" {
+			return group, nil
+		}
+		q.filter(condition, args...)
+	}
+
+	order := "hit.title, hit.id"
+	if searchIndexReady {
+		// Terms of three or more characters use the trigram index; shorter ones can
+		// only be matched with LIKE on the indexed text
+		q.from += " JOIN search_index ON search_index.ref_id = hit.id"
+		q.filter("search_index.kind = ?", kind)
+		var phrases []string
+		for _, term := range terms {
+			if utf8.RuneCountInString(term) >= 3 {
+				phrases = append(phrases, `"`+strings.ReplaceAll(term, `"`, `""`)+`"`)
+			} else {
+				likeAnyColumn(q, []string{"search_index.title", "search_index.code", "search_index.body"}, term)
+			}
+		}
+		if len(phrases) > 0 {
+			q.filter("search_index MATCH ?", strings.Join(phrases, " "))
+			order = "search_index.rank, hit.id"
+		}
+	} else {
+		for _, term := range terms {
+			likeAnyColumn(q, []string{"hit.title", "hit.code", "hit.body"}, term)
+		}
+	}
+
+	if err := DB.QueryRow("SELECT COUNT(*) FROM "+q.from+q.whereClause(), q.args...).Scan(&group.Total); err != nil {
+		return nil, err
+	}
+	if group.Total == 0 {
+		return group, nil
+	}
+
+	rows, err := DB.Query("SELECT "+q.columns+" FROM "+q.from+q.whereClause()+" ORDER BY "+order+" LIMIT ?", append(q.args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var hit models.SearchHit
+		var body string
+		if err := rows.Scan(&hit.ID, &hit.Title, &hit.Code, &body, &hit.Detail); err != nil {
+			return nil, err
+		}
+		hit.Highlight = map[string]string{}
+		for field, text := range map[string]string{"title": hit.Title, "code": hit.Code, "body": body} {
+			if marked, ok := highlight(text, terms, field == "body"); ok {
+				hit.Highlight[field] = marked
+			}
+		}
+		group.Items = append(group.Items, hit)
+	}
+	return group, rows.Err()
+}
+
+// studentSearchScope returns the condition on hit that selects the students a scope
+// may find, empty when it may not find any
+func studentSearchScope(scope *models.DataScope) (string, []interface{}) {
+	if scope == nil {
+		return "", nil
+	}
+	if scope.Unrestricted {
+		return "1 = 1", nil
+	}
+
+	var conditions []string
+	var args []interface{}
+	if scope.DepartmentID != 0 {
+		conditions = append(conditions, "hit.department_id = ?")
+		args = append(args, scope.DepartmentID)
+	}
+	if scope.TeacherID != 0 {
+		conditions = append(conditions, `hit.id IN (
+			SELECT e.student_id FROM enrollments e
+			JOIN course_offerings co ON e.course_offering_id = co.id
+			WHERE co.teacher_id = ? AND co.deleted_at IS NULL
+		)`)
+		args = append(args, scope.TeacherID)
+	}
+	if scope.StudentID != 0 {
+		conditions = append(conditions, "hit.id = ?")
+		args = append(args, scope.StudentID)
+	}
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return "(" + strings.Join(conditions, " OR ") + ")", args
+}
+
+// likeAnyColumn filters q to the rows where one of the columns contains term
+func likeAnyColumn(q *selectQuery, columns []string, term string) {
+	conditions := make([]string, len(columns))
+	args := make([]interface{}, len(columns))
+	for i, column := range columns {
+		conditions[i] = column + " LIKE ? ESCAPE '!'"
+		args[i] = likePattern(term)
+	}
+	q.filter("("+strings.Join(conditions, " OR ")+")", args...)
+}
+
+// snippetRadius is the number of characters kept on each side of the first match
+// when a long text is cut down to a snippet
+const snippetRadius = 30
+
+// highlight HTML-escapes text and wraps the parts matching any of the terms,
+// ignoring case, in <mark>. With snippet set, long texts are cut down to the part
+// around the first match. It reports false when nothing matches.
+func highlight(text string, terms []string, snippet bool) (string, bool) {
+	runes := []rune(text)
+	folded := make([]rune, len(runes))
+	for i, r := range runes {
+		folded[i] = unicode.ToLower(r)
+	}
+
+	marked := make([]bool, len(runes))
+	first := -1
+	for _, term := range terms {
+		needle := []rune(strings.ToLower(term))
+		if len(needle) == 0 {
+			continue
+		}
+		for i := 0; i+len(needle) <= len(folded); i++ {
+			if string(folded[i:i+len(needle)]) != string(needle) {
+				continue
+			}
+			for j := i; j < i+len(needle); j++ {
+				marked[j] = true
+			}
+			if first < 0 || i < first {
+				first = i
+			}
+		}
+	}
+	if first < 0 {
+		return "", false
+	}
+
+	start, end := 0, len(runes)
+	if snippet && len(runes) > 2*snippetRadius {
+		start = max(first-snippetRadius, 0)
+		end = min(start+2*snippetRadius, len(runes))
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	for i := start; i < end; i++ {
+		if marked[i] && (i == start || !marked[i-1]) {
+			b.WriteString("<mark>")
+		}
+		b.WriteString(html.EscapeString(string(runes[i])))
+		if marked[i] && (i == end-1 || !marked[i+1]) {
+			b.WriteString("</mark>")
+		}
+	}
+	if end < len(runes) {
+		b.WriteString("…")
+	}
+	return b.String(), true
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
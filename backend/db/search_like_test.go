@@ -0,0 +1,6 @@
+//go:build !sqlite_fts5
+
+package db
+
+// searchIndexBuilt is whether InitSearchIndex can build the FTS5 index in this build
+const searchIndexBuilt = false
@@ -0,0 +1,221 @@
+package db
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"to-mrz/models"
+)
+
+// searchFixtures has a department with a course, a teacher and two students in
+// different classes. Users 1-3 are the teacher and the students.
+const searchFixtures = `
+INSERT INTO users (username, password, name, role) VALUES
+	('t1001', 'x', '王建国', 'teacher'), ('2023001', 'x', '张晓明', 'student'), ('2023002', 'x', '张晓红', 'student');
+INSERT INTO departments (name, code) VALUES ('计算机学院', 'CS');
+INSERT INTO majors (name, code, department_id) VALUES ('计算机科学与技术', 'CS01', 1);
+INSERT INTO classes (name, code, major_id, year) VALUES ('计科2023-1班', 'CS2023-1', 1, 2023), ('计科2023-2班', 'CS2023-2', 1, 2023);
+INSERT INTO teachers (user_id, department_id, title) VALUES (1, 1, '教授');
+INSERT INTO students (user_id, student_id, class_id, enroll_year) VALUES (2, '2023001', 1, 2023), (3, '2023002', 2, 2023);
+INSERT INTO courses (name, code, credits, hours, type, department_id, description) VALUES
+	('数据结构', 'CS201', 4, 64, '必修', 1, '线性表、树与图');
+`
+
+// openSearchTestDB opens a test database with searchFixtures and builds the search
+// index, which uses FTS5 in sqlite_fts5 builds and LIKE otherwise
+func openSearchTestDB(t *testing.T) {
+	t.Helper()
+	openTestDB(t)
+	if _, err := DB.Exec(searchFixtures); err != nil {
+		t.Fatal(err)
+	}
+	if err := InitSearchIndex(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { searchIndexReady = false })
+	if searchIndexReady != searchIndexBuilt {
+		t.Fatalf("search index ready = %v, want %v in this build", searchIndexReady, searchIndexBuilt)
+	}
+}
+
+// searchTitles returns the titles of the hits of one type
+func searchTitles(t *testing.T, kind, keyword string, scope *models.DataScope) []string {
+	t.Helper()
+	result, err := Search(SearchQuery{Keyword: keyword, Types: []string{kind}, Scope: scope})
+	if err != nil {
+		t.Fatal(err)
+	}
+	titles := []string{}
+	for _, hit := range result.Groups[0].Items {
+		titles = append(titles, hit.Title)
+	}
+	return titles
+}
+
+// indexedTitles returns the titles the FTS5 index holds for a row, nil without the index
+func indexedTitles(t *testing.T, kind string, id uint) []string {
+	t.Helper()
+	if !searchIndexReady {
+		return nil
+	}
+	rows, err := DB.Query("SELECT title FROM search_index WHERE kind = ? AND ref_id = ?", kind, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	titles := []string{}
+	for rows.Next() {
+		var title string
+		if err := rows.Scan(&title); err != nil {
+			t.Fatal(err)
+		}
+		titles = append(titles, title)
+	}
+	return titles
+}
+
+func TestSearchIndexFollowsWrites(t *testing.T) {
+	openSearchTestDB(t)
+	all := &models.DataScope{Unrestricted: true}
+
+	id, err := CreateCourse(&models.Course{Name: "操作系统原理", Code: "CS301", Credits: 3, Hours: 48, Type: "必修", DepartmentID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	course := &models.Course{ID: id, Name: "计算机网络", Code: "CS301", Credits: 3, Hours: 48, Type: "必修", DepartmentID: 1}
+
+	// keywords of three or more characters use the FTS5 index, shorter ones LIKE
+	for _, step := range []struct {
+		name    string
+		write   func() error
+		found   map[string][]string
+		indexed []string
+	}{
+		{"created", func() error { return nil }, map[string][]string{"操作系统": {"操作系统原理"}, "系统": {"操作系统原理"}}, []string{"操作系统原理"}},
+		{"updated", func() error { return UpdateCourse(course) }, map[string][]string{"操作系统": {}, "计算机网络": {"计算机网络"}, "网络": {"计算机网络"}}, []string{"计算机网络"}},
+		{"deleted", func() error { return DeleteCourse(id) }, map[string][]string{"计算机网络": {}, "网络": {}}, []string{}},
+		{"restored", func() error { return RestoreCourse(id) }, map[string][]string{"计算机网络": {"计算机网络"}, "网络": {"计算机网络"}}, []string{"计算机网络"}},
+	} {
+		if err := step.write(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if got := indexedTitles(t, SearchCourse, id); searchIndexReady && !slices.Equal(got, step.indexed) {
+			t.Errorf("course %s: index holds %v, want %v", step.name, got, step.indexed)
+		}
+		for keyword, want := range step.found {
+			if got := searchTitles(t, SearchCourse, keyword, all); !slices.Equal(got, want) {
+				t.Errorf("course %s: %q finds %v, want %v", step.name, keyword, got, want)
+			}
+		}
+	}
+
+	for _, step := range []struct {
+		name    string
+		write   func() error
+		found   map[string][]string
+		indexed []string
+	}{
+		{"renamed", func() error { return UpdateUserProfile(1, "王立新", "", "") }, map[string][]string{"王建国": {}, "王立新": {"王立新"}, "立新": {"王立新"}}, []string{"王立新"}},
+		{"deleted", func() error { return DeleteUser(1) }, map[string][]string{"王立新": {}, "立新": {}}, []string{}},
+		{"restored", func() error { return RestoreUser(1) }, map[string][]string{"王立新": {"王立新"}, "立新": {"王立新"}}, []string{"王立新"}},
+	} {
+		if err := step.write(); err != nil {
+			t.Fatalf("teacher %s: %v", step.name, err)
+		}
+		if got := indexedTitles(t, SearchTeacher, 1); searchIndexReady && !slices.Equal(got, step.indexed) {
+			t.Errorf("teacher %s: index holds %v, want %v", step.name, got, step.indexed)
+		}
+		for keyword, want := range step.found {
+			if got := searchTitles(t, SearchTeacher, keyword, all); !slices.Equal(got, want) {
+				t.Errorf("teacher %s: %q finds %v, want %v", step.name, keyword, got, want)
+			}
+		}
+	}
+
+	if err := UpdateUserProfile(2, "张晓东", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := searchTitles(t, SearchStudent, "张晓东", all); !slices.Equal(got, []string{"张晓东"}) {
+		t.Errorf("renamed student finds %v", got)
+	}
+}
+
+func TestSearchLimitsStudentsToScope(t *testing.T) {
+	openSearchTestDB(t)
+
+	for _, tc := range []struct {
+		name  string
+		scope *models.DataScope
+		want  []string
+	}{
+		{"student 1", &models.DataScope{StudentID: 1}, []string{"张晓明"}},
+		{"student 2", &models.DataScope{StudentID: 2}, []string{"张晓红"}},
+		{"department", &models.DataScope{DepartmentID: 1}, []string{"张晓明", "张晓红"}},
+		{"other department", &models.DataScope{DepartmentID: 2}, []string{}},
+		{"teacher without offerings", &models.DataScope{TeacherID: 1}, []string{}},
+		{"no scope", nil, []string{}},
+	} {
+		for _, keyword := range []string{"张晓", "2023"} {
+			got := searchTitles(t, SearchStudent, keyword, tc.scope)
+			slices.Sort(got)
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("%s: %q finds %v, want %v", tc.name, keyword, got, tc.want)
+			}
+		}
+	}
+
+	// courses and teachers are not scoped
+	if got := searchTitles(t, SearchTeacher, "王建国", &models.DataScope{StudentID: 1}); !slices.Equal(got, []string{"王建国"}) {
+		t.Errorf("student finds teachers %v", got)
+	}
+}
+
+func TestSearchHighlightsAreEscaped(t *testing.T) {
+	openSearchTestDB(t)
+
+	_, err := CreateCourse(&models.Course{Name: `<b>算法</b>设计 & 分析`, Code: "CS<1>", Credits: 3, Hours: 48, Type: "必修",
+		DepartmentID: 1, Description: `<script>alert("算法")</script>`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, keyword := range []string{"算法", "<b>算法", "cs<1"} {
+		result, err := Search(SearchQuery{Keyword: keyword, Types: []string{SearchCourse}, Scope: &models.DataScope{Unrestricted: true}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if items := result.Groups[0].Items; len(items) != 1 {
+			t.Errorf("%q finds %d courses, want 1", keyword, len(items))
+			continue
+		}
+		hit := result.Groups[0].Items[0]
+		if hit.Title != `<b>算法</b>设计 & 分析` {
+			t.Errorf("%q: title %q is not the stored name", keyword, hit.Title)
+		}
+		for field, marked := range hit.Highlight {
+			for _, raw := range []string{"<b>", "<script>", "& ", `"`, "<1>"} {
+				if strings.Contains(marked, raw) {
+					t.Errorf("%q: %s highlight %q contains unescaped %q", keyword, field, marked, raw)
+				}
+			}
+		}
+	}
+
+	want := map[string]string{
+		"title": "&lt;b&gt;<mark>算法</mark>&lt;/b&gt;设计 &amp; 分析",
+		"body":  "&lt;script&gt;alert(&#34;<mark>算法</mark>&#34;)&lt;/script&gt;",
+	}
+	result, err := Search(SearchQuery{Keyword: "算法", Types: []string{SearchCourse}, Scope: &models.DataScope{Unrestricted: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for field, marked := range want {
+		if got := result.Groups[0].Items[0].Highlight[field]; got != marked {
+			t.Errorf("%s highlight %q, want %q", field, got, marked)
+		}
+	}
+	if got := result.Groups[0].Items[0].Highlight["code"]; got != "" {
+		t.Errorf("code highlight %q for a keyword it does not contain", got)
+	}
+}
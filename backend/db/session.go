@@ -0,0 +1,242 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"to-mrz/models"
+	"to-mrz/utils"
+)
+
+// Session errors
+var (
+	ErrSessionRevoked     = errors.New("session has been revoked")
+	ErrSessionExpired     = errors.New("session has expired")
+	ErrRefreshTokenReused = errors.New("refresh token has already been used")
+	ErrUserDisabled       = errors.New("user account is disabled")
+	ErrRoleChanged        = errors.New("user role has changed")
+
+	// ErrPasswordChangeRequired is returned by CheckSession for a valid session whose
+	// user must change their password before doing anything else
+	ErrPasswordChangeRequired = errors.New("password change required")
+
+	// ErrTwoFactorSetupRequired is returned by CheckSession for a valid session whose
+	// user's role requires two-factor authentication they have not set up yet
+	ErrTwoFactorSetupRequired = errors.New("two-factor authentication must be set up")
+)
+
+// CreateSession stores a new login session with the hash of its refresh token
+func CreateSession(session *models.Session, tokenHash string) (uint, error) {
+	now := time.Now()
+	id, err := insertID(DB, `
+		INSERT INTO sessions (user_id, role, refresh_token_hash, user_agent, ip_address, expires_at, last_used_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, session.UserID, session.Role, tokenHash, session.UserAgent, session.IPAddress, session.ExpiresAt, now, now)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// RotateSession exchanges a refresh token for a new one and extends the session.
+// Every rotated token is kept in consumed_refresh_tokens; presenting any of them
+// again revokes the whole session, since it means the token was copied.
+func RotateSession(tokenHash, newTokenHash string, expiresAt time.Time) (*models.Session, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	session := &models.Session{}
+	var revokedAt sql.NullTime
+	var disabled bool
+	var currentRole models.Role
+	err = tx.QueryRow(`
+		SELECT s.id, s.user_id, s.role, s.expires_at, s.revoked_at, u.disabled OR u.deleted_at IS NOT NULL, u.role
+		FROM sessions s
+		JOIN users u ON s.user_id = u.id
+		WHERE s.refresh_token_hash = ?
+	`, tokenHash).Scan(&session.ID, &session.UserID, &session.Role, timestamp{&session.ExpiresAt}, nullTimestamp{&revokedAt}, &disabled, &currentRole)
+	if errors.Is(err, sql.ErrNoRows) {
+		var sessionID uint
+		err := tx.QueryRow("SELECT session_id FROM consumed_refresh_tokens WHERE token_hash = ?", tokenHash).Scan(&sessionID)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec("UPDATE sessions SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL", time.Now(), sessionID); err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case revokedAt.Valid:
+		return nil, ErrSessionRevoked
+	case time.Now().After(session.ExpiresAt):
+		return nil, ErrSessionExpired
+	case disabled:
+		return nil, ErrUserDisabled
+	case currentRole != session.Role:
+		return nil, ErrRoleChanged
+	}
+
+	now := time.Now()
+	_, err = tx.Exec(`
+		UPDATE sessions SET refresh_token_hash = ?, expires_at = ?, last_used_at = ? WHERE id = ?
+	`, newTokenHash, expiresAt, now, session.ID)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO consumed_refresh_tokens (token_hash, session_id, user_id, consumed_at) VALUES (?, ?, ?, ?)
+	`, tokenHash, session.ID, session.UserID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	session.ExpiresAt = expiresAt
+	session.LastUsedAt = &now
+	return session, nil
+}
+
+// CheckSession verifies that an access token's session is still active and that
+// its user is enabled and still has the role the token was issued for
+func CheckSession(sessionID, userID uint, role string) error {
+	var revokedAt, passwordChangedAt sql.NullTime
+	var expiresAt, createdAt time.Time
+	var disabled, mustChangePassword, totpEnabled bool
+	var currentRole, authSource string
+	err := DB.QueryRow(`
+		SELECT s.revoked_at, s.expires_at, u.disabled OR u.deleted_at IS NOT NULL, u.role, u.must_change_password, u.password_changed_at, u.created_at, u.totp_enabled, u.auth_source
+		FROM sessions s
+		JOIN users u ON s.user_id = u.id
+		WHERE s.id = ? AND s.user_id = ?
+	`, sessionID, userID).Scan(nullTimestamp{&revokedAt}, timestamp{&expiresAt}, &disabled, &currentRole, &mustChangePassword, nullTimestamp{&passwordChangedAt}, timestamp{&createdAt}, &totpEnabled, &authSource)
+	if err != nil {
+		return err
+	}
+
+	if !passwordChangedAt.Valid {
+		passwordChangedAt.Time = createdAt
+	}
+
+	switch {
+	case revokedAt.Valid:
+		return ErrSessionRevoked
+	case time.Now().After(expiresAt):
+		return ErrSessionExpired
+	case disabled:
+		return ErrUserDisabled
+	case currentRole != role:
+		return ErrRoleChanged
+	case authSource == models.AuthSourceLocal &&
+		(mustChangePassword || utils.CurrentPasswordPolicy.Expired(passwordChangedAt.Time)):
+		// Passwords of external accounts are managed, and expire, elsewhere
+		return ErrPasswordChangeRequired
+	}
+
+	if !totpEnabled {
+		required, err := TwoFactorRequired(userID)
+		if err != nil {
+			return err
+		}
+		if required {
+			return ErrTwoFactorSetupRequired
+		}
+	}
+	return nil
+}
+
+// SessionFilter selects the active sessions of a user. CurrentID is the session
+// making the request, which is marked current.
+type SessionFilter struct {
+	ListQuery
+	UserID    uint
+	CurrentID uint
+}
+
+// sessionList sorts and searches a user's sessions, newest first by default
+var sessionList = listSpec{
+	sorts: map[string]string{
+		"created_at":   "created_at",
+		"last_used_at": "COALESCE(last_used_at, created_at)",
+		"expires_at":   "expires_at",
+	},
+	order:  []string{"-created_at"},
+	key:    "id",
+	search: []string{"user_agent", "ip_address"},
+}
+
+// GetUserSessions returns a page of the active sessions of a user
+func GetUserSessions(filter SessionFilter) (*models.Page, error) {
+	q := &selectQuery{
+		columns: "id, user_id, role, user_agent, ip_address, expires_at, last_used_at, created_at",
+		from:    "sessions",
+	}
+	q.filter("user_id = ? AND revoked_at IS NULL AND expires_at > ?", filter.UserID, time.Now())
+
+	sessions := []models.Session{}
+	page, err := sessionList.list(filter.ListQuery, q, func(row rowScanner) error {
+		var s models.Session
+		var userAgent, ipAddress sql.NullString
+		var lastUsedAt sql.NullTime
+		if err := row.Scan(&s.ID, &s.UserID, &s.Role, &userAgent, &ipAddress, timestamp{&s.ExpiresAt}, nullTimestamp{&lastUsedAt}, timestamp{&s.CreatedAt}); err != nil {
+			return err
+		}
+		s.Current = s.ID == filter.CurrentID
+		s.UserAgent = userAgent.String
+		s.IPAddress = ipAddress.String
+		if lastUsedAt.Valid {
+			s.LastUsedAt = &lastUsedAt.Time
+		}
+		sessions = append(sessions, s)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	page.Items = sessions
+	return page, nil
+}
+
+// RevokeSession revokes one session of a user
+func RevokeSession(sessionID, userID uint) error {
+	result, err := DB.Exec(`
+		UPDATE sessions SET revoked_at = ? WHERE id = ? AND user_id = ? AND revoked_at IS NULL
+	`, time.Now(), sessionID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// RevokeUserSessions revokes every active session of a user and returns how many were revoked
+func RevokeUserSessions(userID uint) (int64, error) {
+	result, err := DB.Exec(`
+		UPDATE sessions SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL
+	`, time.Now(), userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
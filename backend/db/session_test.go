@@ -0,0 +1,52 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"to-mrz/models"
+)
+
+func TestReplayingAnyRotatedRefreshTokenRevokesTheSession(t *testing.T) {
+	openTestDB(t)
+	userID := createTestUser(t, "zhangsan", models.RoleStudent)
+	expiresAt := time.Now().Add(time.Hour)
+
+	// each session rotates its token from t0 to t3, then one of the older tokens comes back
+	for _, replayed := range []string{"t0", "t1", "t2"} {
+		family := "session-" + replayed + "-"
+		sessionID, err := CreateSession(&models.Session{UserID: userID, Role: models.RoleStudent, ExpiresAt: expiresAt}, family+"t0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, step := range [][2]string{{"t0", "t1"}, {"t1", "t2"}, {"t2", "t3"}} {
+			if _, err := RotateSession(family+step[0], family+step[1], expiresAt); err != nil {
+				t.Fatalf("rotating %s: %v", step[0], err)
+			}
+		}
+
+		if _, err := RotateSession(family+replayed, family+"stolen", expiresAt); !errors.Is(err, ErrRefreshTokenReused) {
+			t.Errorf("replaying %s: %v, want ErrRefreshTokenReused", replayed, err)
+		}
+		if err := CheckSession(sessionID, userID, string(models.RoleStudent)); !errors.Is(err, ErrSessionRevoked) {
+			t.Errorf("session after replaying %s: %v, want ErrSessionRevoked", replayed, err)
+		}
+		if _, err := RotateSession(family+"t3", family+"t4", expiresAt); !errors.Is(err, ErrSessionRevoked) {
+			t.Errorf("current token after replaying %s: %v, want ErrSessionRevoked", replayed, err)
+		}
+	}
+
+	if _, err := RotateSession("unknown", "new", expiresAt); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("unknown token: %v, want sql.ErrNoRows", err)
+	}
+
+	// the consumed tokens go with the user's sessions
+	if err := DeleteUser(userID); err != nil {
+		t.Fatal(err)
+	}
+	if err := PurgeUser(userID); err != nil {
+		t.Errorf("purging a user with rotated sessions: %v", err)
+	}
+}
@@ -0,0 +1,197 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"to-mrz/models"
+)
+
+// Soft delete errors
+var (
+	ErrNotDeleted   = errors.New("only deleted rows can be purged")
+	ErrPurgeBlocked = errors.New("other rows still refer to this one")
+)
+
+// DependencyError is returned when a row cannot be purged because other rows still
+// refer to it. It matches ErrPurgeBlocked.
+type DependencyError struct {
+	Dependencies []models.Dependency
+}
+
+func (e *DependencyError) Error() string {
+	blockers := make([]string, len(e.Dependencies))
+	for i, d := range e.Dependencies {
+		blockers[i] = fmt.Sprintf("%d in %s.%s", d.Count, d.Table, d.Column)
+	}
+	return fmt.Sprintf("%v: %s", ErrPurgeBlocked, strings.Join(blockers, ", "))
+}
+
+func (e *DependencyError) Unwrap() error {
+	return ErrPurgeBlocked
+}
+
+// purgeRule says what happens to the rows that refer to a row when it is purged.
+// Every other reference declared by the migrations blocks the purge.
+type purgeRule struct {
+	owned    []reference // rows that only exist for the purged row, deleted with it
+	detached []reference // nullable references to the purged row, cleared
+}
+
+// purgeRules lists the tables whose rows are soft-deleted
+var purgeRules = map[string]purgeRule{
+	"departments": {},
+	"majors":      {},
+	"courses": {
+		owned: []reference{{"course_prerequisites", "course_id"}},
+	},
+	"course_offerings": {},
+	"users": {
+		owned: []reference{
+			{"consumed_refresh_tokens", "user_id"},
+			{"sessions", "user_id"},
+			{"login_challenges", "user_id"},
+			{"password_history", "user_id"},
+			{"recovery_codes", "user_id"},
+			{"user_roles", "user_id"},
+		},
+		detached: []reference{{"login_events", "user_id"}},
+	},
+}
+
+// softDelete marks a row of a purgeRules table deleted. It returns sql.ErrNoRows if
+// the row does not exist or is already deleted.
+func softDelete(q execQuerier, table string, id uint) error {
+	now := time.Now()
+	result, err := q.Exec("UPDATE "+table+" SET deleted_at = ?, updated_at = ? WHERE id = ? AND deleted_at IS NULL", now, now, id)
+	if err != nil {
+		return err
+	}
+	if err := requireRowAffected(result); err != nil {
+		return err
+	}
+	return syncSearchIndex(q, table, id)
+}
+
+// restoreDeleted clears the deleted mark of a row of a purgeRules table. It returns
+// sql.ErrNoRows if the row does not exist or is not deleted.
+func restoreDeleted(table string, id uint) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("UPDATE "+table+" SET deleted_at = NULL, updated_at = ? WHERE id = ? AND deleted_at IS NOT NULL", time.Now(), id)
+	if err != nil {
+		return err
+	}
+	if err := requireRowAffected(result); err != nil {
+		return err
+	}
+	if err := syncSearchIndex(tx, table, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// purge removes a deleted row of a purgeRules table for good, along with the rows it
+// owns. It returns sql.ErrNoRows if the row does not exist, ErrNotDeleted if it has
+// not been deleted first and a *DependencyError while other rows refer to it.
+func purge(table string, id uint) error {
+	rule := purgeRules[table]
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var deleted bool
+	if err := tx.QueryRow("SELECT deleted_at IS NOT NULL FROM "+table+" WHERE id = ?", id).Scan(&deleted); err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrNotDeleted
+	}
+
+	dependencies, err := purgeDependencies(tx, table, rule, id)
+	if err != nil {
+		return err
+	}
+	if len(dependencies) > 0 {
+		return &DependencyError{Dependencies: dependencies}
+	}
+
+	for _, ref := range rule.owned {
+		if _, err := tx.Exec("DELETE FROM "+ref.table+" WHERE "+ref.column+" = ?", id); err != nil {
+			return err
+		}
+	}
+	for _, ref := range rule.detached {
+		if _, err := tx.Exec("UPDATE "+ref.table+" SET "+ref.column+" = NULL WHERE "+ref.column+" = ?", id); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec("DELETE FROM "+table+" WHERE id = ?", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// purgeDependencies counts the rows that refer to a row and keep it from being purged
+func purgeDependencies(tx *Tx, table string, rule purgeRule, id uint) ([]models.Dependency, error) {
+	handled := map[reference]bool{}
+	for _, ref := range rule.owned {
+		handled[ref] = true
+	}
+	for _, ref := range rule.detached {
+		handled[ref] = true
+	}
+
+	dependencies := []models.Dependency{}
+	for _, ref := range migrationReferences()[table] {
+		if handled[ref] {
+			continue
+		}
+		var count int
+		err := tx.QueryRow("SELECT COUNT(*) FROM "+ref.table+" WHERE "+ref.column+" = ?", id).Scan(&count)
+		if err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			dependencies = append(dependencies, models.Dependency{Table: ref.table, Column: ref.column, Count: count})
+		}
+	}
+	return dependencies, nil
+}
+
+// deletedFilter returns the condition that selects deleted rows when deleted is true
+// and the others otherwise. column is the deleted_at column, with its table alias.
+func deletedFilter(column string, deleted bool) string {
+	if deleted {
+		return column + " IS NOT NULL"
+	}
+	return column + " IS NULL"
+}
+
+// deletedAt scans a nullable timestamp into a *time.Time, nil for NULL
+type deletedAt struct {
+	t **time.Time
+}
+
+// Scan implements sql.Scanner
+func (d deletedAt) Scan(value interface{}) error {
+	var t sql.NullTime
+	if err := (nullTimestamp{&t}).Scan(value); err != nil {
+		return err
+	}
+	*d.t = nil
+	if t.Valid {
+		*d.t = &t.Time
+	}
+	return nil
+}
@@ -0,0 +1,372 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"to-mrz/models"
+)
+
+// NewSQLRepositories returns the repositories backed by the database opened by InitDB
+func NewSQLRepositories() *Repositories {
+	return &Repositories{
+		Departments:     sqlDepartments{},
+		Majors:          sqlMajors{},
+		Courses:         sqlCourses{},
+		CourseOfferings: sqlCourseOfferings{},
+		Enrollments:     sqlEnrollments{},
+		Users:           sqlUsers{},
+		Roles:           sqlRoles{},
+		Sessions:        sqlSessions{},
+		Scopes:          sqlScopes{},
+		Logins:          sqlLogins{},
+		TwoFactor:       sqlTwoFactor{},
+		TrainingPlans:   sqlTrainingPlans{},
+		Graduation:      sqlGraduation{},
+		Backups:         sqlBackups{},
+		Search:          sqlSearch{},
+		References:      sqlReferences{},
+	}
+}
+
+type sqlDepartments struct{}
+
+func (sqlDepartments) List(filter DepartmentFilter) (*models.Page, error) {
+	return GetDepartments(filter)
+}
+func (sqlDepartments) Get(id uint) (*models.Department, error)   { return GetDepartmentByID(id) }
+func (sqlDepartments) Create(d *models.Department) (uint, error) { return CreateDepartment(d) }
+func (sqlDepartments) Update(d *models.Department) error         { return UpdateDepartment(d) }
+func (sqlDepartments) Delete(id uint) error                      { return DeleteDepartment(id) }
+func (sqlDepartments) Restore(id uint) error                     { return RestoreDepartment(id) }
+func (sqlDepartments) Purge(id uint) error                       { return PurgeDepartment(id) }
+
+type sqlMajors struct{}
+
+func (sqlMajors) List(filter MajorFilter) (*models.Page, error) { return GetMajors(filter) }
+
+func (sqlMajors) Delete(id uint) error  { return DeleteMajor(id) }
+func (sqlMajors) Restore(id uint) error { return RestoreMajor(id) }
+func (sqlMajors) Purge(id uint) error   { return PurgeMajor(id) }
+
+type sqlCourses struct{}
+
+func (sqlCourses) List(filter CourseFilter) (*models.Page, error) { return GetCourses(filter) }
+
+// Get returns sql.ErrNoRows for a missing course, like the other repositories
+func (sqlCourses) Get(id uint) (*models.Course, error) {
+	course, err := GetCourseByID(id)
+	if err == nil && course == nil {
+		return nil, sql.ErrNoRows
+	}
+	return course, err
+}
+
+func (sqlCourses) Create(course *models.Course) (uint, error) { return CreateCourse(course) }
+func (sqlCourses) Update(course *models.Course) error         { return UpdateCourse(course) }
+func (sqlCourses) Delete(id uint) error                       { return DeleteCourse(id) }
+func (sqlCourses) Restore(id uint) error                      { return RestoreCourse(id) }
+func (sqlCourses) Purge(id uint) error                        { return PurgeCourse(id) }
+
+type sqlCourseOfferings struct{}
+
+func (sqlCourseOfferings) List(filter CourseOfferingFilter) (*models.Page, error) {
+	return GetCourseOfferings(filter)
+}
+
+func (sqlCourseOfferings) Delete(id uint) error  { return DeleteCourseOffering(id) }
+func (sqlCourseOfferings) Restore(id uint) error { return RestoreCourseOffering(id) }
+func (sqlCourseOfferings) Purge(id uint) error   { return PurgeCourseOffering(id) }
+
+type sqlEnrollments struct{}
+
+func (sqlEnrollments) StudentGrades(filter StudentGradeFilter) (*models.Page, error) {
+	return GetStudentGrades(filter)
+}
+
+func (sqlEnrollments) CourseGrades(filter CourseGradeFilter) (*models.Page, error) {
+	return GetCourseGrades(filter)
+}
+
+func (sqlEnrollments) Grade(id uint) (*models.Enrollment, error) { return GetGrade(id) }
+
+func (sqlEnrollments) CreateGrade(enrollment models.Enrollment) (uint, error) {
+	return CreateGrade(enrollment)
+}
+
+func (sqlEnrollments) UpdateGrade(id uint, grade float64) error { return UpdateGrade(id, grade) }
+
+func (sqlEnrollments) CreateRetake(originalID, courseOfferingID uint) (uint, error) {
+	return CreateRetakeEnrollment(originalID, courseOfferingID)
+}
+
+func (sqlEnrollments) Transcript(studentID uint, policy models.GradePolicy) (*models.Transcript, error) {
+	return GetTranscript(studentID, policy)
+}
+
+func (sqlEnrollments) MakeupRoster(semesterID, courseOfferingID uint) ([]models.ExamAttempt, error) {
+	return GetMakeupRoster(semesterID, courseOfferingID)
+}
+
+func (sqlEnrollments) GenerateMakeupRoster(semesterID, courseOfferingID uint) (int64, error) {
+	return GenerateMakeupRoster(semesterID, courseOfferingID)
+}
+
+func (sqlEnrollments) RecordMakeupScore(id uint, rawScore float64) error {
+	return RecordMakeupScore(id, rawScore)
+}
+
+func (sqlEnrollments) GradeComponents(courseOfferingID uint) ([]models.GradeComponent, error) {
+	return GetGradeComponents(courseOfferingID)
+}
+
+func (sqlEnrollments) ExportOfferings(courseOfferingID, semesterID, departmentID uint) ([]models.CourseOffering, error) {
+	return GetExportOfferings(courseOfferingID, semesterID, departmentID)
+}
+
+func (sqlEnrollments) EachGradeSheetRow(courseOfferingID uint, fn func(models.GradeSheetRow) error) error {
+	return EachGradeSheetRow(courseOfferingID, fn)
+}
+
+func (sqlEnrollments) GradeImportTemplate(courseOfferingID uint) ([][]string, error) {
+	return GetGradeImportTemplate(courseOfferingID)
+}
+
+func (sqlEnrollments) ValidateGradeImport(courseOfferingID uint, sheet [][]string) ([]models.GradeImportRow, []models.GradeImportError, error) {
+	return ValidateGradeImport(courseOfferingID, sheet)
+}
+
+func (sqlEnrollments) ApplyGradeImport(courseOfferingID uint, rows []models.GradeImportRow) error {
+	return ApplyGradeImport(courseOfferingID, rows)
+}
+
+type sqlUsers struct{}
+
+func (sqlUsers) Get(id uint) (*models.User, error) { return GetUserByID(id) }
+
+func (sqlUsers) GetByUsername(username string) (*models.User, error) {
+	return GetUserByUsername(username)
+}
+
+func (sqlUsers) List(filter UserFilter) (*models.Page, error) { return GetUsers(filter) }
+func (sqlUsers) Create(user *models.User) (uint, error)       { return CreateUser(user) }
+func (sqlUsers) CreateFirstAdmin(user *models.User) (uint, error) {
+	return CreateFirstAdmin(user)
+}
+
+func (sqlUsers) UpdateProfile(id uint, name, email, phone string) error {
+	return UpdateUserProfile(id, name, email, phone)
+}
+
+func (sqlUsers) SyncExternal(id uint, source, name, email, phone string) error {
+	return SyncExternalUser(id, source, name, email, phone)
+}
+
+func (sqlUsers) UpdateRole(id uint, role models.Role, departmentID *uint) error {
+	return UpdateUserRole(id, role, departmentID)
+}
+
+func (sqlUsers) SetDisabled(id uint, disabled bool) error { return SetUserDisabled(id, disabled) }
+func (sqlUsers) Delete(id uint) error                     { return DeleteUser(id) }
+func (sqlUsers) Restore(id uint) error                    { return RestoreUser(id) }
+func (sqlUsers) Purge(id uint) error                      { return PurgeUser(id) }
+
+func (sqlUsers) UpdatePassword(id uint, hash string, keepSessionID uint, mustChange bool) error {
+	return UpdateUserPassword(id, hash, keepSessionID, mustChange)
+}
+
+func (sqlUsers) RecentPasswordHashes(id uint, n int) ([]string, error) {
+	return GetRecentPasswordHashes(id, n)
+}
+
+func (sqlUsers) ValidateImport(role models.Role, sheet [][]string) ([]models.UserImportRow, []models.UserImportError, error) {
+	return ValidateUserImport(role, sheet)
+}
+
+func (sqlUsers) ApplyImport(role models.Role, rows []models.UserImportRow) error {
+	return ApplyUserImport(role, rows)
+}
+
+type sqlRoles struct{}
+
+func (sqlRoles) List(query ListQuery) (*models.Page, error) { return GetRoles(query) }
+
+func (sqlRoles) SetPermissions(role models.Role, permissions []models.Permission) error {
+	return SetRolePermissions(role, permissions)
+}
+
+func (sqlRoles) UserRoles(userID uint) ([]models.Role, error) { return GetUserRoles(userID) }
+
+func (sqlRoles) SetUserRoles(userID uint, roles []models.Role) error {
+	return SetUserRoles(userID, roles)
+}
+
+func (sqlRoles) UserPermissions(userID uint) (map[models.Permission]bool, error) {
+	return GetUserPermissions(userID)
+}
+
+type sqlSessions struct{}
+
+func (sqlSessions) Create(session *models.Session, tokenHash string) (uint, error) {
+	return CreateSession(session, tokenHash)
+}
+
+func (sqlSessions) Rotate(tokenHash, newTokenHash string, expiresAt time.Time) (*models.Session, error) {
+	return RotateSession(tokenHash, newTokenHash, expiresAt)
+}
+
+func (sqlSessions) List(filter SessionFilter) (*models.Page, error) {
+	return GetUserSessions(filter)
+}
+
+func (sqlSessions) Revoke(sessionID, userID uint) error { return RevokeSession(sessionID, userID) }
+
+func (sqlSessions) RevokeAllForUser(userID uint) (int64, error) {
+	return RevokeUserSessions(userID)
+}
+
+func (sqlSessions) Check(sessionID, userID uint, role string) error {
+	return CheckSession(sessionID, userID, role)
+}
+
+type sqlScopes struct{}
+
+func (sqlScopes) DataScope(userID uint) (*models.DataScope, error) { return GetDataScope(userID) }
+
+func (sqlScopes) DepartmentOwner(id uint) (*models.RowOwner, error) { return GetDepartmentOwner(id) }
+
+func (sqlScopes) MajorOwner(id uint) (*models.RowOwner, error) { return GetMajorOwner(id) }
+
+func (sqlScopes) CourseOwner(id uint) (*models.RowOwner, error) { return GetCourseOwner(id) }
+
+func (sqlScopes) CourseOfferingOwner(id uint) (*models.RowOwner, error) {
+	return GetCourseOfferingOwner(id)
+}
+
+func (sqlScopes) EnrollmentOwner(id uint) (*models.RowOwner, error) { return GetEnrollmentOwner(id) }
+
+func (sqlScopes) ExamAttemptOwner(id uint) (*models.RowOwner, error) { return GetExamAttemptOwner(id) }
+
+func (sqlScopes) StudentOwner(id uint) (*models.RowOwner, error) { return GetStudentOwner(id) }
+
+func (sqlScopes) TrainingPlanOwner(id uint) (*models.RowOwner, error) {
+	return GetTrainingPlanOwner(id)
+}
+
+type sqlLogins struct{}
+
+func (sqlLogins) BeginAttempt(username, ip string) (time.Duration, error) {
+	return BeginLoginAttempt(username, ip)
+}
+
+func (sqlLogins) RecordFailure(username, ip string) error  { return RecordLoginFailure(username, ip) }
+func (sqlLogins) ReleaseAttempt(username, ip string) error { return ReleaseLoginAttempt(username, ip) }
+func (sqlLogins) RecordSuccess(username string) error      { return RecordLoginSuccess(username) }
+func (sqlLogins) UnlockUser(userID uint) error             { return UnlockUser(userID) }
+func (sqlLogins) UnlockIP(ip string) error                 { return UnlockIP(ip) }
+
+func (sqlLogins) ActiveLockouts(filter LockoutFilter) (*models.Page, error) {
+	return GetActiveLockouts(filter)
+}
+
+func (sqlLogins) RecordEvent(event *models.LoginEvent) error { return RecordLoginEvent(event) }
+
+func (sqlLogins) Events(filter LoginEventFilter) (*models.Page, error) {
+	return GetLoginEvents(filter)
+}
+
+type sqlTwoFactor struct{}
+
+func (sqlTwoFactor) Required(userID uint) (bool, error) { return TwoFactorRequired(userID) }
+
+func (sqlTwoFactor) Status(userID uint) (*models.TwoFactorStatus, error) {
+	return GetTwoFactorStatus(userID)
+}
+
+func (sqlTwoFactor) Secret(userID uint) (string, int64, bool, error) {
+	return GetTOTPSecret(userID)
+}
+
+func (sqlTwoFactor) StartSetup(userID uint, secret string) error {
+	return StartTwoFactorSetup(userID, secret)
+}
+
+func (sqlTwoFactor) Enable(userID uint, step int64, recoveryCodeHashes []string) error {
+	return EnableTwoFactor(userID, step, recoveryCodeHashes)
+}
+
+func (sqlTwoFactor) RecordStep(userID uint, step int64) error { return RecordTOTPStep(userID, step) }
+func (sqlTwoFactor) Disable(userID uint) error                { return DisableTwoFactor(userID) }
+
+func (sqlTwoFactor) ReplaceRecoveryCodes(userID uint, codeHashes []string) error {
+	return ReplaceRecoveryCodes(userID, codeHashes)
+}
+
+func (sqlTwoFactor) UseRecoveryCode(userID uint, codeHash string) error {
+	return UseRecoveryCode(userID, codeHash)
+}
+
+func (sqlTwoFactor) CreateChallenge(userID uint, tokenHash string, expiresAt time.Time) error {
+	return CreateLoginChallenge(userID, tokenHash, expiresAt)
+}
+
+func (sqlTwoFactor) GetChallenge(tokenHash string) (uint, error) {
+	return GetLoginChallenge(tokenHash)
+}
+
+func (sqlTwoFactor) DeleteChallenge(tokenHash string) error {
+	return DeleteLoginChallenge(tokenHash)
+}
+
+type sqlTrainingPlans struct{}
+
+func (sqlTrainingPlans) List(filter TrainingPlanFilter) (*models.Page, error) {
+	return GetTrainingPlans(filter)
+}
+
+func (sqlTrainingPlans) Get(id uint) (*models.TrainingPlan, error) { return GetTrainingPlanByID(id) }
+
+func (sqlTrainingPlans) Create(plan *models.TrainingPlan) (uint, error) {
+	return CreateTrainingPlan(plan)
+}
+
+func (sqlTrainingPlans) Update(plan *models.TrainingPlan) error { return UpdateTrainingPlan(plan) }
+func (sqlTrainingPlans) Delete(id uint) error                   { return DeleteTrainingPlan(id) }
+
+func (sqlTrainingPlans) DegreeAudit(studentID uint) (*models.DegreeAudit, error) {
+	return GetDegreeAudit(studentID)
+}
+
+type sqlGraduation struct{}
+
+func (sqlGraduation) Run(graduationYear, cohortYear int, majorID, createdBy uint) (uint, error) {
+	return RunGraduationReview(graduationYear, cohortYear, majorID, createdBy)
+}
+
+func (sqlGraduation) List(filter GraduationReviewFilter) (*models.Page, error) {
+	return GetGraduationReviews(filter)
+}
+
+func (sqlGraduation) Get(id uint) (*models.GraduationReview, error) {
+	return GetGraduationReview(id)
+}
+
+func (sqlGraduation) Override(reviewID, studentID uint, eligible bool, justification string, overriddenBy uint) error {
+	return OverrideGraduationResult(reviewID, studentID, eligible, justification, overriddenBy)
+}
+
+func (sqlGraduation) Confirm(id uint) (int64, error) { return ConfirmGraduationReview(id) }
+
+type sqlBackups struct{}
+
+func (sqlBackups) List(query ListQuery) (*models.Page, error)      { return GetBackups(query) }
+func (sqlBackups) Create(trigger string) (*models.Backup, error)   { return CreateBackup(trigger) }
+func (sqlBackups) Get(name string) (*models.Backup, string, error) { return GetBackup(name) }
+
+type sqlSearch struct{}
+
+func (sqlSearch) Search(query SearchQuery) (*models.SearchResult, error) { return Search(query) }
+
+type sqlReferences struct{}
+
+func (sqlReferences) Exists(table string, id uint) (bool, error) { return RowExists(table, id) }
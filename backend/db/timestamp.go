@@ -0,0 +1,79 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// timestampLayout is how timestamps are stored in SQLite: UTC with millisecond
+// precision. CURRENT_TIMESTAMP column defaults write the same layout without the
+// milliseconds, so stored values sort in time order either way.
+const timestampLayout = "2006-01-02 15:04:05.000"
+
+// ErrInvalidTimestamp is returned when a stored timestamp cannot be read
+var ErrInvalidTimestamp = errors.New("invalid timestamp")
+
+// timestampLayouts are the layouts parseTimestamp accepts, in the order tried
+var timestampLayouts = []string{
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02T15:04:05.999999999",
+	time.RFC3339Nano,
+	"2006-01-02",
+}
+
+// parseTimestamp parses a timestamp stored as text. Values without a zone are UTC.
+func parseTimestamp(s string) (time.Time, error) {
+	for _, layout := range timestampLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.UTC); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%w %q", ErrInvalidTimestamp, s)
+}
+
+// timestamp scans a non-NULL timestamp column into a time.Time, in UTC
+type timestamp struct {
+	t *time.Time
+}
+
+// Scan implements sql.Scanner
+func (ts timestamp) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case time.Time:
+		// The SQLite driver returns the zero time for text it cannot parse
+		if v.IsZero() {
+			return ErrInvalidTimestamp
+		}
+		*ts.t = v.UTC()
+		return nil
+	case string:
+		t, err := parseTimestamp(v)
+		*ts.t = t
+		return err
+	case []byte:
+		t, err := parseTimestamp(string(v))
+		*ts.t = t
+		return err
+	case nil:
+		return fmt.Errorf("%w: NULL", ErrInvalidTimestamp)
+	}
+	return fmt.Errorf("%w: unexpected %T", ErrInvalidTimestamp, value)
+}
+
+// nullTimestamp scans a nullable timestamp column into a sql.NullTime, in UTC
+type nullTimestamp struct {
+	t *sql.NullTime
+}
+
+// Scan implements sql.Scanner
+func (ts nullTimestamp) Scan(value interface{}) error {
+	if value == nil {
+		*ts.t = sql.NullTime{}
+		return nil
+	}
+	ts.t.Valid = true
+	return timestamp{&ts.t.Time}.Scan(value)
+}
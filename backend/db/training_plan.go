@@ -0,0 +1,420 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"to-mrz/models"
+)
+
+// ErrNoTrainingPlan is returned when a student's major and cohort have no training plan
+var ErrNoTrainingPlan = errors.New("no training plan for student's major and cohort")
+
+// TrainingPlanFilter filters the training plan list; zero values match every plan
+type TrainingPlanFilter struct {
+	ListQuery
+	MajorID    uint
+	CohortYear int
+}
+
+// trainingPlanList sorts and searches the training plan list, newest cohort first by default
+var trainingPlanList = listSpec{
+	sorts: map[string]string{
+		"id":          "tp.id",
+		"cohort_year": "tp.cohort_year",
+		"name":        "tp.name",
+		"major_code":  "m.code",
+		"min_credits": "tp.min_credits",
+	},
+	order:  []string{"-cohort_year", "major_code"},
+	key:    "tp.id",
+	search: []string{"tp.name", "m.name", "m.code"},
+}
+
+// GetTrainingPlans retrieves a page of the training plans matching the filter,
+// without their course groups
+func GetTrainingPlans(filter TrainingPlanFilter) (*models.Page, error) {
+	q := &selectQuery{
+		columns: "tp.id, tp.major_id, tp.cohort_year, tp.name, tp.min_credits, m.id, m.name, m.code",
+		from:    "training_plans tp JOIN majors m ON tp.major_id = m.id",
+	}
+	if filter.MajorID != 0 {
+		q.filter("tp.major_id = ?", filter.MajorID)
+	}
+	if filter.CohortYear != 0 {
+		q.filter("tp.cohort_year = ?", filter.CohortYear)
+	}
+
+	plans := []models.TrainingPlan{}
+	page, err := trainingPlanList.list(filter.ListQuery, q, func(row rowScanner) error {
+		var tp models.TrainingPlan
+		err := row.Scan(&tp.ID, &tp.MajorID, &tp.CohortYear, &tp.Name, &tp.MinCredits, &tp.Major.ID, &tp.Major.Name, &tp.Major.Code)
+		if err != nil {
+			return fmt.Errorf("failed to scan training plan: %w", err)
+		}
+		plans = append(plans, tp)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query training plans: %w", err)
+	}
+	page.Items = plans
+	return page, nil
+}
+
+// GetTrainingPlanByID retrieves a training plan with its course groups and courses
+func GetTrainingPlanByID(id uint) (*models.TrainingPlan, error) {
+	var tp models.TrainingPlan
+	err := DB.QueryRow(`
+		SELECT tp.id, tp.major_id, tp.cohort_year, tp.name, tp.min_credits, m.id, m.name, m.code
+		FROM training_plans tp
+		JOIN majors m ON tp.major_id = m.id
+		WHERE tp.id = ?
+	`, id).Scan(&tp.ID, &tp.MajorID, &tp.CohortYear, &tp.Name, &tp.MinCredits, &tp.Major.ID, &tp.Major.Name, &tp.Major.Code)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := loadPlanGroups(&tp); err != nil {
+		return nil, err
+	}
+
+	return &tp, nil
+}
+
+// loadPlanGroups fills in the course groups and courses of a training plan
+func loadPlanGroups(tp *models.TrainingPlan) error {
+	tp.Groups = []models.PlanCourseGroup{}
+
+	rows, err := DB.Query(`
+		SELECT g.id, g.training_plan_id, g.name, g.category, g.min_credits,
+		       c.id, c.name, c.code, c.credits, c.type, gc.required
+		FROM plan_course_groups g
+		LEFT JOIN plan_group_courses gc ON gc.group_id = g.id
+		LEFT JOIN courses c ON gc.course_id = c.id
+		WHERE g.training_plan_id = ?
+		ORDER BY g.id, c.code
+	`, tp.ID)
+	if err != nil {
+		return fmt.Errorf("failed to query plan course groups: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var g models.PlanCourseGroup
+		var courseID sql.NullInt64
+		var name, code, courseType sql.NullString
+		var credits sql.NullFloat64
+		var required sql.NullBool
+
+		err := rows.Scan(&g.ID, &g.TrainingPlanID, &g.Name, &g.Category, &g.MinCredits,
+			&courseID, &name, &code, &credits, &courseType, &required)
+		if err != nil {
+			return fmt.Errorf("failed to scan plan course group: %w", err)
+		}
+
+		if n := len(tp.Groups); n == 0 || tp.Groups[n-1].ID != g.ID {
+			g.Courses = []models.PlanCourse{}
+			tp.Groups = append(tp.Groups, g)
+		}
+
+		if courseID.Valid {
+			group := &tp.Groups[len(tp.Groups)-1]
+			group.Courses = append(group.Courses, models.PlanCourse{
+				CourseID: uint(courseID.Int64),
+				Course: models.Course{
+					ID:      uint(courseID.Int64),
+					Name:    name.String,
+					Code:    code.String,
+					Credits: credits.Float64,
+					Type:    courseType.String,
+				},
+				Required: required.Bool,
+			})
+		}
+	}
+
+	return rows.Err()
+}
+
+// CreateTrainingPlan creates a training plan with its course groups
+func CreateTrainingPlan(tp *models.TrainingPlan) (uint, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	id, err := insertID(tx, `
+		INSERT INTO training_plans (major_id, cohort_year, name, min_credits, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, tp.MajorID, tp.CohortYear, tp.Name, tp.MinCredits, now, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create training plan: %w", err)
+	}
+
+	if err := insertPlanGroups(tx, id, tp.Groups); err != nil {
+		return 0, err
+	}
+
+	return id, tx.Commit()
+}
+
+// UpdateTrainingPlan updates a training plan and replaces its course groups
+func UpdateTrainingPlan(tp *models.TrainingPlan) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE training_plans
+		SET major_id = ?, cohort_year = ?, name = ?, min_credits = ?, updated_at = ?
+		WHERE id = ?
+	`, tp.MajorID, tp.CohortYear, tp.Name, tp.MinCredits, time.Now(), tp.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update training plan: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+
+	if err := deletePlanGroups(tx, tp.ID); err != nil {
+		return err
+	}
+	if err := insertPlanGroups(tx, tp.ID, tp.Groups); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteTrainingPlan deletes a training plan and its course groups
+func DeleteTrainingPlan(id uint) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := deletePlanGroups(tx, id); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec("DELETE FROM training_plans WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete training plan: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return tx.Commit()
+}
+
+func insertPlanGroups(tx *Tx, planID uint, groups []models.PlanCourseGroup) error {
+	for _, g := range groups {
+		groupID, err := insertID(tx, `
+			INSERT INTO plan_course_groups (training_plan_id, name, category, min_credits)
+			VALUES (?, ?, ?, ?)
+		`, planID, g.Name, g.Category, g.MinCredits)
+		if err != nil {
+			return fmt.Errorf("failed to create plan course group: %w", err)
+		}
+
+		for _, pc := range g.Courses {
+			_, err := tx.Exec(`
+				INSERT INTO plan_group_courses (group_id, course_id, required)
+				VALUES (?, ?, ?)
+			`, groupID, pc.CourseID, pc.Required)
+			if err != nil {
+				return fmt.Errorf("failed to add course %d to plan course group: %w", pc.CourseID, err)
+			}
+		}
+	}
+	return nil
+}
+
+func deletePlanGroups(tx *Tx, planID uint) error {
+	_, err := tx.Exec(`
+		DELETE FROM plan_group_courses
+		WHERE group_id IN (SELECT id FROM plan_course_groups WHERE training_plan_id = ?)
+	`, planID)
+	if err != nil {
+		return fmt.Errorf("failed to delete plan group courses: %w", err)
+	}
+
+	_, err = tx.Exec("DELETE FROM plan_course_groups WHERE training_plan_id = ?", planID)
+	if err != nil {
+		return fmt.Errorf("failed to delete plan course groups: %w", err)
+	}
+	return nil
+}
+
+// GetStudentTrainingPlan finds the training plan for a student's major and cohort year
+func GetStudentTrainingPlan(studentID uint) (*models.TrainingPlan, error) {
+	var planID uint
+	err := DB.QueryRow(`
+		SELECT tp.id
+		FROM students s
+		JOIN classes cl ON s.class_id = cl.id
+		JOIN training_plans tp ON tp.major_id = cl.major_id AND tp.cohort_year = s.enroll_year
+		WHERE s.id = ?
+	`, studentID).Scan(&planID)
+	if err == sql.ErrNoRows {
+		return nil, ErrNoTrainingPlan
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return GetTrainingPlanByID(planID)
+}
+
+// getStudentCourseStatuses maps each course a student has taken to an audit status.
+// A course is satisfied if any attempt passed (including a passed make-up exam) and
+// in progress if an enrollment is still awaiting a grade.
+func getStudentCourseStatuses(studentID uint) (map[uint]string, error) {
+	rows, err := DB.Query(`
+		SELECT co.course_id, e.status,
+		       EXISTS (SELECT 1 FROM exam_attempts a WHERE a.enrollment_id = e.id AND a.status = ?)
+		FROM enrollments e
+		JOIN course_offerings co ON e.course_offering_id = co.id
+		WHERE e.student_id = ?
+	`, models.MakeupPassed, studentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query student courses: %w", err)
+	}
+	defer rows.Close()
+
+	statuses := map[uint]string{}
+	for rows.Next() {
+		var courseID uint
+		var status string
+		var makeupPassed bool
+		if err := rows.Scan(&courseID, &status, &makeupPassed); err != nil {
+			return nil, fmt.Errorf("failed to scan student course: %w", err)
+		}
+
+		switch {
+		case status == "已完成" || makeupPassed:
+			statuses[courseID] = models.AuditSatisfied
+		case status == "已选" && statuses[courseID] != models.AuditSatisfied:
+			statuses[courseID] = models.AuditInProgress
+		}
+	}
+
+	return statuses, rows.Err()
+}
+
+// GetDegreeAudit compares a student's enrollments against their training plan
+func GetDegreeAudit(studentID uint) (*models.DegreeAudit, error) {
+	plan, err := GetStudentTrainingPlan(studentID)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses, err := getStudentCourseStatuses(studentID)
+	if err != nil {
+		return nil, err
+	}
+
+	return auditPlan(studentID, plan, statuses), nil
+}
+
+// auditPlan evaluates each course group of a plan against a student's course statuses
+func auditPlan(studentID uint, plan *models.TrainingPlan, statuses map[uint]string) *models.DegreeAudit {
+	audit := &models.DegreeAudit{
+		StudentID:      studentID,
+		TrainingPlanID: plan.ID,
+		PlanName:       plan.Name,
+		MinCredits:     plan.MinCredits,
+		Groups:         []models.DegreeAuditGroup{},
+	}
+
+	// Credits toward the plan total count each course once even if it appears in several groups
+	counted := map[uint]bool{}
+	groupsSatisfied, groupsPossible := true, true
+
+	for _, g := range plan.Groups {
+		group := models.DegreeAuditGroup{
+			GroupID:    g.ID,
+			Name:       g.Name,
+			Category:   g.Category,
+			MinCredits: g.MinCredits,
+			Courses:    []models.DegreeAuditCourse{},
+		}
+
+		requiredSatisfied, requiredPossible := true, true
+		for _, pc := range g.Courses {
+			status := statuses[pc.CourseID]
+			if status == "" {
+				status = models.AuditMissing
+			}
+
+			switch status {
+			case models.AuditSatisfied:
+				group.EarnedCredits += pc.Course.Credits
+			case models.AuditInProgress:
+				group.InProgressCredits += pc.Course.Credits
+			}
+
+			if !counted[pc.CourseID] {
+				counted[pc.CourseID] = true
+				switch status {
+				case models.AuditSatisfied:
+					audit.EarnedCredits += pc.Course.Credits
+				case models.AuditInProgress:
+					audit.InProgressCredits += pc.Course.Credits
+				}
+			}
+
+			if pc.Required && status != models.AuditSatisfied {
+				requiredSatisfied = false
+				if status == models.AuditMissing {
+					requiredPossible = false
+				}
+			}
+
+			group.Courses = append(group.Courses, models.DegreeAuditCourse{
+				CourseID: pc.CourseID,
+				Code:     pc.Course.Code,
+				Name:     pc.Course.Name,
+				Credits:  pc.Course.Credits,
+				Required: pc.Required,
+				Status:   status,
+			})
+		}
+
+		group.Status = auditStatus(
+			requiredSatisfied && group.EarnedCredits >= g.MinCredits,
+			requiredPossible && group.EarnedCredits+group.InProgressCredits >= g.MinCredits,
+		)
+		groupsSatisfied = groupsSatisfied && group.Status == models.AuditSatisfied
+		groupsPossible = groupsPossible && group.Status != models.AuditMissing
+
+		audit.Groups = append(audit.Groups, group)
+	}
+
+	audit.Status = auditStatus(
+		groupsSatisfied && audit.EarnedCredits >= plan.MinCredits,
+		groupsPossible && audit.EarnedCredits+audit.InProgressCredits >= plan.MinCredits,
+	)
+
+	return audit
+}
+
+func auditStatus(satisfied, inProgress bool) string {
+	switch {
+	case satisfied:
+		return models.AuditSatisfied
+	case inProgress:
+		return models.AuditInProgress
+	default:
+		return models.AuditMissing
+	}
+}
@@ -0,0 +1,237 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"to-mrz/models"
+)
+
+// TwoFactorRoles are the roles whose users must use two-factor authentication
+var TwoFactorRoles = map[models.Role]bool{}
+
+// Two-factor errors
+var (
+	ErrTwoFactorEnabled    = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotStarted = errors.New("two-factor setup has not been started")
+	ErrTOTPCodeReused      = errors.New("two-factor code has already been used")
+)
+
+// TwoFactorRequired reports whether any of the user's roles requires two-factor authentication
+func TwoFactorRequired(userID uint) (bool, error) {
+	if len(TwoFactorRoles) == 0 {
+		return false, nil
+	}
+
+	roles, err := GetUserRoles(userID)
+	if err != nil {
+		return false, err
+	}
+	for _, role := range roles {
+		if TwoFactorRoles[role] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// GetTwoFactorStatus returns whether a user has two-factor authentication enabled or required
+func GetTwoFactorStatus(userID uint) (*models.TwoFactorStatus, error) {
+	status := &models.TwoFactorStatus{}
+	if err := DB.QueryRow("SELECT totp_enabled FROM users WHERE id = ?", userID).Scan(&status.Enabled); err != nil {
+		return nil, err
+	}
+
+	err := DB.QueryRow(
+		"SELECT COUNT(*) FROM recovery_codes WHERE user_id = ? AND used_at IS NULL", userID,
+	).Scan(&status.RecoveryCodesRemaining)
+	if err != nil {
+		return nil, err
+	}
+
+	status.Required, err = TwoFactorRequired(userID)
+	if err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// GetTOTPSecret returns a user's TOTP secret (empty before setup), the last time
+// step a code was accepted for, and whether two-factor authentication is enabled
+func GetTOTPSecret(userID uint) (secret string, lastStep int64, enabled bool, err error) {
+	var stored sql.NullString
+	err = DB.QueryRow(
+		"SELECT totp_secret, totp_last_step, totp_enabled FROM users WHERE id = ?", userID,
+	).Scan(&stored, &lastStep, &enabled)
+	return stored.String, lastStep, enabled, err
+}
+
+// StartTwoFactorSetup stores a new, not yet enabled TOTP secret for a user
+func StartTwoFactorSetup(userID uint, secret string) error {
+	result, err := DB.Exec(`
+		UPDATE users SET totp_secret = ?, totp_last_step = 0, updated_at = ?
+		WHERE id = ? AND totp_enabled = FALSE
+	`, secret, time.Now(), userID)
+	if err != nil {
+		return err
+	}
+	if err := requireRowAffected(result); err != nil {
+		if _, _, enabled, lookupErr := GetTOTPSecret(userID); lookupErr == nil && enabled {
+			return ErrTwoFactorEnabled
+		}
+		return err
+	}
+	return nil
+}
+
+// EnableTwoFactor turns on two-factor authentication once the user has proved their
+// authenticator works with a code from the given step, and stores their recovery codes
+func EnableTwoFactor(userID uint, step int64, recoveryCodeHashes []string) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE users SET totp_enabled = TRUE, totp_last_step = ?, updated_at = ?
+		WHERE id = ? AND totp_enabled = FALSE AND totp_secret IS NOT NULL
+	`, step, time.Now(), userID)
+	if err != nil {
+		return err
+	}
+	if err := requireRowAffected(result); err != nil {
+		return ErrTwoFactorNotStarted
+	}
+
+	if err := replaceRecoveryCodes(tx, userID, recoveryCodeHashes); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RecordTOTPStep marks the time step of an accepted code as used. It returns
+// ErrTOTPCodeReused if that step, or a later one, was already used.
+func RecordTOTPStep(userID uint, step int64) error {
+	result, err := DB.Exec(
+		"UPDATE users SET totp_last_step = ? WHERE id = ? AND totp_last_step < ?", step, userID, step,
+	)
+	if err != nil {
+		return err
+	}
+	if err := requireRowAffected(result); err != nil {
+		return ErrTOTPCodeReused
+	}
+	return nil
+}
+
+// ReplaceRecoveryCodes discards a user's recovery codes and stores new ones
+func ReplaceRecoveryCodes(userID uint, codeHashes []string) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(tx, userID, codeHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// replaceRecoveryCodes discards a user's recovery codes and stores new ones within a transaction
+func replaceRecoveryCodes(tx *Tx, userID uint, codeHashes []string) error {
+	if _, err := tx.Exec("DELETE FROM recovery_codes WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, hash := range codeHashes {
+		_, err := tx.Exec(
+			"INSERT INTO recovery_codes (user_id, code_hash, created_at) VALUES (?, ?, ?)", userID, hash, now,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UseRecoveryCode consumes one of a user's unused recovery codes, returning
+// sql.ErrNoRows if there is no such code
+func UseRecoveryCode(userID uint, codeHash string) error {
+	result, err := DB.Exec(`
+		UPDATE recovery_codes SET used_at = ?
+		WHERE user_id = ? AND code_hash = ? AND used_at IS NULL
+	`, time.Now(), userID, codeHash)
+	if err != nil {
+		return err
+	}
+	return requireRowAffected(result)
+}
+
+// DisableTwoFactor turns off two-factor authentication for a user and discards their
+// secret and recovery codes. Users whose role requires it must set it up again.
+func DisableTwoFactor(userID uint) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE users SET totp_secret = NULL, totp_enabled = FALSE, totp_last_step = 0, updated_at = ?
+		WHERE id = ?
+	`, time.Now(), userID)
+	if err != nil {
+		return err
+	}
+	if err := requireRowAffected(result); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM recovery_codes WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CreateLoginChallenge records that a user passed the password check and now has to
+// give a two-factor code, identified by the hash of a one-time challenge token
+func CreateLoginChallenge(userID uint, tokenHash string, expiresAt time.Time) error {
+	// Drop stale challenges while we are here
+	if _, err := DB.Exec("DELETE FROM login_challenges WHERE expires_at < ?", time.Now()); err != nil {
+		return err
+	}
+
+	_, err := DB.Exec(`
+		INSERT INTO login_challenges (token_hash, user_id, expires_at, created_at) VALUES (?, ?, ?, ?)
+	`, tokenHash, userID, expiresAt, time.Now())
+	return err
+}
+
+// GetLoginChallenge returns the user of an unexpired login challenge. Unknown and
+// expired challenges both return sql.ErrNoRows.
+func GetLoginChallenge(tokenHash string) (uint, error) {
+	var userID uint
+	var expiresAt time.Time
+	err := DB.QueryRow(
+		"SELECT user_id, expires_at FROM login_challenges WHERE token_hash = ?", tokenHash,
+	).Scan(&userID, timestamp{&expiresAt})
+	if err != nil {
+		return 0, err
+	}
+	if time.Now().After(expiresAt) {
+		return 0, sql.ErrNoRows
+	}
+	return userID, nil
+}
+
+// DeleteLoginChallenge removes a login challenge once it has been answered
+func DeleteLoginChallenge(tokenHash string) error {
+	_, err := DB.Exec("DELETE FROM login_challenges WHERE token_hash = ?", tokenHash)
+	return err
+}
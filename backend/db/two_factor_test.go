@@ -0,0 +1,111 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"to-mrz/models"
+	"to-mrz/utils"
+)
+
+func TestTOTPStepCannotBeReused(t *testing.T) {
+	openTestDB(t)
+	userID := createTestUser(t, "teacher1", models.RoleTeacher)
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := StartTwoFactorSetup(userID, secret); err != nil {
+		t.Fatal(err)
+	}
+
+	// enabling consumes the step of the code that proved the authenticator works
+	now := time.Unix(1234567890, 0)
+	code, _ := utils.TOTPCode(secret, now)
+	step, ok := utils.ValidateTOTP(secret, code, now, 0)
+	if !ok {
+		t.Fatal("setup code rejected")
+	}
+	if err := EnableTwoFactor(userID, step, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	_, lastStep, enabled, err := GetTOTPSecret(userID)
+	if err != nil || !enabled || lastStep != step {
+		t.Fatalf("after enabling: last step %d, enabled %v, %v; want %d, true", lastStep, enabled, err, step)
+	}
+	if _, ok := utils.ValidateTOTP(secret, code, now, lastStep); ok {
+		t.Error("setup code accepted again at login")
+	}
+
+	// a login with the next code records its step, once
+	next, _ := utils.TOTPCode(secret, now.Add(utils.TOTPPeriod))
+	nextStep, ok := utils.ValidateTOTP(secret, next, now.Add(utils.TOTPPeriod), lastStep)
+	if !ok {
+		t.Fatal("next code rejected")
+	}
+	if err := RecordTOTPStep(userID, nextStep); err != nil {
+		t.Fatal(err)
+	}
+	if err := RecordTOTPStep(userID, nextStep); !errors.Is(err, ErrTOTPCodeReused) {
+		t.Errorf("recording the same step twice: %v, want ErrTOTPCodeReused", err)
+	}
+	if err := RecordTOTPStep(userID, step); !errors.Is(err, ErrTOTPCodeReused) {
+		t.Errorf("recording an earlier step: %v, want ErrTOTPCodeReused", err)
+	}
+}
+
+func TestRecoveryCodesAreSingleUse(t *testing.T) {
+	openTestDB(t)
+	userID := createTestUser(t, "teacher1", models.RoleTeacher)
+	otherID := createTestUser(t, "teacher2", models.RoleTeacher)
+
+	secret, _ := utils.GenerateTOTPSecret()
+	if err := StartTwoFactorSetup(userID, secret); err != nil {
+		t.Fatal(err)
+	}
+	first, second := utils.HashToken("aaaaabbbbb"), utils.HashToken("cccccddddd")
+	if err := EnableTwoFactor(userID, 1, []string{first, second}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := UseRecoveryCode(otherID, first); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("another user's code: %v, want sql.ErrNoRows", err)
+	}
+	if err := UseRecoveryCode(userID, first); err != nil {
+		t.Fatalf("first use: %v", err)
+	}
+	if err := UseRecoveryCode(userID, first); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("second use: %v, want sql.ErrNoRows", err)
+	}
+	if err := UseRecoveryCode(userID, second); err != nil {
+		t.Errorf("other code: %v", err)
+	}
+
+	// new codes replace the old ones, used or not
+	third := utils.HashToken("eeeeefffff")
+	if err := ReplaceRecoveryCodes(userID, []string{third}); err != nil {
+		t.Fatal(err)
+	}
+	if err := UseRecoveryCode(userID, second); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("replaced code: %v, want sql.ErrNoRows", err)
+	}
+	if err := UseRecoveryCode(userID, third); err != nil {
+		t.Errorf("new code: %v", err)
+	}
+
+	// disabling discards the codes
+	if err := DisableTwoFactor(userID); err != nil {
+		t.Fatal(err)
+	}
+	status, err := GetTwoFactorStatus(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Enabled || status.RecoveryCodesRemaining != 0 {
+		t.Errorf("after disabling: %+v", status)
+	}
+}
@@ -0,0 +1,419 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"to-mrz/models"
+	"to-mrz/utils"
+)
+
+// User errors
+var (
+	ErrUsernameTaken = errors.New("username already exists")
+	ErrLastAdmin     = errors.New("cannot remove the last active administrator")
+	ErrMissingDeptID = errors.New("department admins must have a department")
+)
+
+// userColumns lists the users columns read by scanUser
+const userColumns = "id, username, password, name, role, COALESCE(email, ''), COALESCE(phone, ''), disabled, department_id, must_change_password, password_changed_at, totp_enabled, auth_source, created_at, updated_at, deleted_at"
+
+// scanUser scans a row selected with userColumns
+func scanUser(row rowScanner) (*models.User, error) {
+	user := &models.User{}
+	var departmentID sql.NullInt64
+	var passwordChangedAt sql.NullTime
+	err := row.Scan(&user.ID, &user.Username, &user.Password, &user.Name, &user.Role, &user.Email, &user.Phone,
+		&user.Disabled, &departmentID, &user.MustChangePassword, nullTimestamp{&passwordChangedAt}, &user.TwoFactorEnabled,
+		&user.AuthSource, timestamp{&user.CreatedAt}, timestamp{&user.UpdatedAt}, deletedAt{&user.DeletedAt})
+	if err != nil {
+		return nil, err
+	}
+	if departmentID.Valid {
+		id := uint(departmentID.Int64)
+		user.DepartmentID = &id
+	}
+	// Accounts created before password changes were tracked count from their creation
+	user.PasswordChangedAt = user.CreatedAt
+	if passwordChangedAt.Valid {
+		user.PasswordChangedAt = passwordChangedAt.Time
+	}
+	return user, nil
+}
+
+// GetUserByID retrieves a user by ID. Deleted users are not found.
+func GetUserByID(id uint) (*models.User, error) {
+	return scanUser(DB.QueryRow("SELECT "+userColumns+" FROM users WHERE id = ? AND deleted_at IS NULL", id))
+}
+
+// UserFilter filters the user list; zero values match every user that is not deleted
+type UserFilter struct {
+	ListQuery
+	Role         models.Role
+	DepartmentID uint
+	Disabled     *bool
+	Deleted      bool // list the deleted users instead
+}
+
+// userList sorts and searches the user list
+var userList = listSpec{
+	sorts: map[string]string{
+		"id":         "id",
+		"username":   "username",
+		"name":       "name",
+		"role":       "role",
+		"created_at": "created_at",
+	},
+	order:  []string{"id"},
+	key:    "id",
+	search: []string{"username", "name", "email", "phone"},
+}
+
+// GetUsers returns a page of the users matching the filter
+func GetUsers(filter UserFilter) (*models.Page, error) {
+	q := &selectQuery{columns: userColumns, from: "users"}
+	q.filter(deletedFilter("deleted_at", filter.Deleted))
+	if filter.Role != "" {
+		q.filter("(role = ? OR id IN (SELECT user_id FROM user_roles WHERE role = ?))", filter.Role, filter.Role)
+	}
+	if filter.DepartmentID != 0 {
+		q.filter("department_id = ?", filter.DepartmentID)
+	}
+	if filter.Disabled != nil {
+		q.filter("disabled = ?", *filter.Disabled)
+	}
+
+	users := []*models.User{}
+	page, err := userList.list(filter.ListQuery, q, func(row rowScanner) error {
+		user, err := scanUser(row)
+		if err != nil {
+			return err
+		}
+		users = append(users, user)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	page.Items = users
+	return page, nil
+}
+
+// CreateUser creates a user whose password has already been hashed
+func CreateUser(user *models.User) (uint, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	id, err := createUser(tx, user)
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// createUser inserts a user within a transaction
+func createUser(tx *Tx, user *models.User) (uint, error) {
+	if user.Role == models.RoleDepartment && user.DepartmentID == nil {
+		return 0, ErrMissingDeptID
+	}
+
+	var count int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM users WHERE username = ?", user.Username).Scan(&count); err != nil {
+		return 0, err
+	}
+	if count > 0 {
+		return 0, ErrUsernameTaken
+	}
+
+	authSource := user.AuthSource
+	if authSource == "" {
+		authSource = models.AuthSourceLocal
+	}
+
+	now := time.Now()
+	id, err := insertID(tx, `
+		INSERT INTO users (username, password, name, role, email, phone, department_id, must_change_password, password_changed_at, auth_source, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, user.Username, user.Password, user.Name, user.Role, user.Email, user.Phone, user.DepartmentID,
+		user.MustChangePassword, now, authSource, now, now)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// SyncExternalUser refreshes the profile of a user who signed in through an external
+// backend and records that backend as the account's source. Empty values keep the
+// stored ones, since not every backend releases every attribute.
+func SyncExternalUser(id uint, source, name, email, phone string) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE users SET
+			auth_source = ?,
+			name = COALESCE(NULLIF(?, ''), name),
+			email = COALESCE(NULLIF(?, ''), email),
+			phone = COALESCE(NULLIF(?, ''), phone),
+			must_change_password = FALSE,
+			updated_at = ?
+		WHERE id = ?
+	`, source, name, email, phone, time.Now(), id)
+	if err != nil {
+		return err
+	}
+	return commitUserUpdate(tx, result, id)
+}
+
+// UpdateUserProfile updates a user's name, email and phone
+func UpdateUserProfile(id uint, name, email, phone string) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE users SET name = ?, email = ?, phone = ?, updated_at = ? WHERE id = ?
+	`, name, email, phone, time.Now(), id)
+	if err != nil {
+		return err
+	}
+	return commitUserUpdate(tx, result, id)
+}
+
+// commitUserUpdate commits an update of a user's name once it changed a row,
+// bringing the search index up to date
+func commitUserUpdate(tx *Tx, result sql.Result, id uint) error {
+	if err := requireRowAffected(result); err != nil {
+		return err
+	}
+	if err := syncSearchIndex(tx, "users", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateUserRole changes a user's primary role and, for department admins, their department.
+// Existing sessions are rejected by the auth middleware once the role differs.
+func UpdateUserRole(id uint, role models.Role, departmentID *uint) error {
+	if role == models.RoleDepartment && departmentID == nil {
+		return ErrMissingDeptID
+	}
+	if role != models.RoleDepartment {
+		departmentID = nil
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if role != models.RoleAdmin {
+		if err := checkNotLastAdmin(tx, id); err != nil {
+			return err
+		}
+	}
+
+	result, err := tx.Exec(`
+		UPDATE users SET role = ?, department_id = ?, updated_at = ? WHERE id = ?
+	`, role, departmentID, time.Now(), id)
+	if err != nil {
+		return err
+	}
+	if err := requireRowAffected(result); err != nil {
+		return err
+	}
+
+	// The new primary role should not also be listed as an additional role
+	if _, err := tx.Exec("DELETE FROM user_roles WHERE user_id = ? AND role = ?", id, role); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SetUserDisabled disables or re-enables an account. Disabling also revokes its sessions.
+func SetUserDisabled(id uint, disabled bool) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if disabled {
+		if err := checkNotLastAdmin(tx, id); err != nil {
+			return err
+		}
+	}
+
+	result, err := tx.Exec("UPDATE users SET disabled = ?, updated_at = ? WHERE id = ?", disabled, time.Now(), id)
+	if err != nil {
+		return err
+	}
+	if err := requireRowAffected(result); err != nil {
+		return err
+	}
+
+	if disabled {
+		if _, err := tx.Exec("UPDATE sessions SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL", time.Now(), id); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// DeleteUser marks a user deleted and revokes their sessions. Deleted users cannot
+// sign in and their username stays taken until they are purged.
+func DeleteUser(id uint) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkNotLastAdmin(tx, id); err != nil {
+		return err
+	}
+	if err := softDelete(tx, "users", id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE sessions SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL", time.Now(), id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RestoreUser undoes DeleteUser. It returns sql.ErrNoRows if there is no such
+// deleted user.
+func RestoreUser(id uint) error {
+	return restoreDeleted("users", id)
+}
+
+// PurgeUser removes a deleted user for good, with their sessions, password history,
+// recovery codes and additional roles; their sign-in history is kept without them.
+// It returns ErrNotDeleted for users that are not deleted and a *DependencyError
+// while other rows still refer to the user.
+func PurgeUser(id uint) error {
+	return purge("users", id)
+}
+
+// UpdateUserPassword stores a new password hash and revokes the user's sessions,
+// except keepSessionID (0 revokes all). The previous hash is kept in the password
+// history. mustChange forces another change at the next login, for passwords set
+// by an administrator.
+func UpdateUserPassword(id uint, hash string, keepSessionID uint, mustChange bool) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var previous string
+	if err := tx.QueryRow("SELECT password FROM users WHERE id = ?", id).Scan(&previous); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	_, err = tx.Exec(`
+		UPDATE users SET password = ?, must_change_password = ?, password_changed_at = ?, updated_at = ? WHERE id = ?
+	`, hash, mustChange, now, now, id)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("INSERT INTO password_history (user_id, password_hash, created_at) VALUES (?, ?, ?)", id, previous, now); err != nil {
+		return err
+	}
+	// Only the hashes the no-reuse rule can still look at are worth keeping
+	_, err = tx.Exec(`
+		DELETE FROM password_history WHERE user_id = ? AND id NOT IN (
+			SELECT id FROM password_history WHERE user_id = ? ORDER BY id DESC LIMIT ?
+		)
+	`, id, id, max(utils.CurrentPasswordPolicy.History-1, 0))
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE sessions SET revoked_at = ? WHERE user_id = ? AND id != ? AND revoked_at IS NULL
+	`, now, id, keepSessionID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetRecentPasswordHashes returns the hash of a user's current password followed by
+// up to n-1 previous ones, newest first
+func GetRecentPasswordHashes(id uint, n int) ([]string, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+
+	var current string
+	if err := DB.QueryRow("SELECT password FROM users WHERE id = ?", id).Scan(&current); err != nil {
+		return nil, err
+	}
+	hashes := []string{current}
+
+	rows, err := DB.Query(`
+		SELECT password_hash FROM password_history WHERE user_id = ? ORDER BY id DESC LIMIT ?
+	`, id, n-1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+
+	return hashes, rows.Err()
+}
+
+// checkNotLastAdmin fails if the user is the only enabled administrator
+func checkNotLastAdmin(tx *Tx, id uint) error {
+	var isAdmin bool
+	err := tx.QueryRow("SELECT role = ? AND disabled = FALSE AND deleted_at IS NULL FROM users WHERE id = ?", models.RoleAdmin, id).Scan(&isAdmin)
+	if err != nil || !isAdmin {
+		return err
+	}
+
+	var others int
+	err = tx.QueryRow("SELECT COUNT(*) FROM users WHERE role = ? AND disabled = FALSE AND deleted_at IS NULL AND id != ?", models.RoleAdmin, id).Scan(&others)
+	if err != nil {
+		return err
+	}
+	if others == 0 {
+		return ErrLastAdmin
+	}
+	return nil
+}
+
+// requireRowAffected returns sql.ErrNoRows when an update matched nothing
+func requireRowAffected(result sql.Result) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,345 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"to-mrz/models"
+	"to-mrz/utils"
+)
+
+// Columns in a user import sheet besides ColumnStudentNo and ColumnClassName
+const (
+	ColumnUsername   = "用户名"
+	ColumnRealName   = "姓名"
+	ColumnEmail      = "邮箱"
+	ColumnPhone      = "电话"
+	ColumnPassword   = "初始密码"
+	ColumnEnrollYear = "入学年份"
+	ColumnDepartment = "院系"
+	ColumnTitle      = "职称"
+)
+
+// userImportColumns lists the columns of a user import sheet for each role; the
+// required ones come first
+var userImportColumns = map[models.Role]struct {
+	required []string
+	optional []string
+}{
+	models.RoleStudent: {
+		required: []string{ColumnStudentNo, ColumnRealName, ColumnClassName},
+		optional: []string{ColumnUsername, ColumnEnrollYear, ColumnEmail, ColumnPhone, ColumnPassword},
+	},
+	models.RoleTeacher: {
+		required: []string{ColumnUsername, ColumnRealName, ColumnDepartment},
+		optional: []string{ColumnTitle, ColumnEmail, ColumnPhone, ColumnPassword},
+	},
+}
+
+// CanImportUsers reports whether accounts of the role can be bulk imported
+func CanImportUsers(role models.Role) bool {
+	_, ok := userImportColumns[role]
+	return ok
+}
+
+// GetUserImportTemplate returns the header row of a user import sheet for a role
+func GetUserImportTemplate(role models.Role) [][]string {
+	columns := userImportColumns[role]
+	header := append(append([]string{}, columns.required...), columns.optional...)
+	return [][]string{header}
+}
+
+// importClass is a class a student row can refer to
+type importClass struct {
+	id   uint
+	year int
+}
+
+// ValidateUserImport checks every row of a student or teacher import sheet. It returns
+// the parsed rows and all row-level errors found.
+func ValidateUserImport(role models.Role, sheet [][]string) ([]models.UserImportRow, []models.UserImportError, error) {
+	rows := []models.UserImportRow{}
+	problems := []models.UserImportError{}
+
+	if len(sheet) == 0 {
+		problems = append(problems, models.UserImportError{Message: "文件为空"})
+		return rows, problems, nil
+	}
+
+	// Map header columns
+	columns := userImportColumns[role]
+	known := map[string]bool{}
+	for _, name := range append(append([]string{}, columns.required...), columns.optional...) {
+		known[name] = true
+	}
+	col := map[string]int{}
+	for i, name := range sheet[0] {
+		switch {
+		case name == "":
+		case !known[name]:
+			problems = append(problems, models.UserImportError{Row: 1, Column: name, Message: "未知的列"})
+		case col[name] != 0:
+			problems = append(problems, models.UserImportError{Row: 1, Column: name, Message: "列重复"})
+		default:
+			// Stored off by one so that a missing column reads as 0
+			col[name] = i + 1
+		}
+	}
+	for _, name := range columns.required {
+		if col[name] == 0 {
+			problems = append(problems, models.UserImportError{Row: 1, Column: name, Message: "缺少必填列"})
+		}
+	}
+	if len(problems) > 0 {
+		return rows, problems, nil
+	}
+	value := func(record []string, name string) string {
+		return cell(record, col[name]-1)
+	}
+
+	classes, err := loadImportClasses()
+	if err != nil {
+		return nil, nil, err
+	}
+	departments, err := loadImportDepartments()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	usernames := map[string]int{}
+	studentNos := map[string]int{}
+	for i, record := range sheet[1:] {
+		rowNum := i + 2
+		if isBlankRow(record) {
+			continue
+		}
+
+		row := models.UserImportRow{
+			Row:      rowNum,
+			Username: value(record, ColumnUsername),
+			Name:     value(record, ColumnRealName),
+			Email:    value(record, ColumnEmail),
+			Phone:    value(record, ColumnPhone),
+			Password: value(record, ColumnPassword),
+		}
+		var rowProblems []models.UserImportError
+		problem := func(column, message string) {
+			rowProblems = append(rowProblems, models.UserImportError{Row: rowNum, Column: column, Username: row.Username, Message: message})
+		}
+
+		switch role {
+		case models.RoleStudent:
+			row.StudentNo = value(record, ColumnStudentNo)
+			if row.Username == "" {
+				// Students sign in with their 学号 unless a username is given
+				row.Username = row.StudentNo
+			}
+			if row.StudentNo == "" {
+				problem(ColumnStudentNo, "学号不能为空")
+			} else if prev, ok := studentNos[row.StudentNo]; ok {
+				problem(ColumnStudentNo, fmt.Sprintf("与第%d行学号重复", prev))
+			} else {
+				studentNos[row.StudentNo] = rowNum
+				exists, err := studentNumberExists(row.StudentNo)
+				if err != nil {
+					return nil, nil, err
+				}
+				if exists {
+					problem(ColumnStudentNo, "学号已存在")
+				}
+			}
+
+			className := value(record, ColumnClassName)
+			class, found, ambiguous := classes.find(className)
+			switch {
+			case className == "":
+				problem(ColumnClassName, "班级不能为空")
+			case ambiguous:
+				problem(ColumnClassName, "班级名称不唯一，请填写班级代码")
+			case !found:
+				problem(ColumnClassName, "班级不存在")
+			default:
+				row.ClassID = class.id
+				row.EnrollYear = class.year
+			}
+
+			if raw := value(record, ColumnEnrollYear); raw != "" {
+				year, err := strconv.Atoi(raw)
+				if err != nil || year < 1900 || year > time.Now().Year()+1 {
+					problem(ColumnEnrollYear, "入学年份无效")
+				} else {
+					row.EnrollYear = year
+				}
+			}
+
+		case models.RoleTeacher:
+			row.Title = value(record, ColumnTitle)
+			department := value(record, ColumnDepartment)
+			id, ok := departments[department]
+			switch {
+			case department == "":
+				problem(ColumnDepartment, "院系不能为空")
+			case !ok:
+				problem(ColumnDepartment, "院系不存在")
+			default:
+				row.DepartmentID = id
+			}
+		}
+
+		if row.Name == "" {
+			problem(ColumnRealName, "姓名不能为空")
+		}
+
+		if row.Username == "" {
+			problem(ColumnUsername, "用户名不能为空")
+		} else if prev, ok := usernames[row.Username]; ok {
+			problem(ColumnUsername, fmt.Sprintf("与第%d行用户名重复", prev))
+		} else {
+			usernames[row.Username] = rowNum
+			if _, err := GetUserByUsername(row.Username); err == nil {
+				problem(ColumnUsername, "用户名已存在")
+			} else if !errors.Is(err, sql.ErrNoRows) {
+				return nil, nil, err
+			}
+		}
+
+		if row.Password != "" {
+			if err := utils.CurrentPasswordPolicy.Validate(row.Password); err != nil {
+				problem(ColumnPassword, "初始密码不符合密码策略："+err.Error())
+			}
+		}
+
+		if len(rowProblems) > 0 {
+			problems = append(problems, rowProblems...)
+			continue
+		}
+		rows = append(rows, row)
+	}
+
+	if len(rows) == 0 && len(problems) == 0 {
+		problems = append(problems, models.UserImportError{Message: "文件中没有账号数据"})
+	}
+
+	return rows, problems, nil
+}
+
+// importClasses looks up classes by code or, failing that, by name
+type importClasses struct {
+	byCode map[string]importClass
+	byName map[string][]importClass
+}
+
+// find returns the class with the given code or unique name
+func (c importClasses) find(codeOrName string) (class importClass, found, ambiguous bool) {
+	if class, ok := c.byCode[codeOrName]; ok {
+		return class, true, false
+	}
+	matches := c.byName[codeOrName]
+	if len(matches) == 1 {
+		return matches[0], true, false
+	}
+	return importClass{}, false, len(matches) > 1
+}
+
+// loadImportClasses loads every class for looking up the classes of imported students
+func loadImportClasses() (importClasses, error) {
+	classes := importClasses{byCode: map[string]importClass{}, byName: map[string][]importClass{}}
+
+	rows, err := DB.Query("SELECT id, code, name, year FROM classes")
+	if err != nil {
+		return classes, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var code, name string
+		var class importClass
+		if err := rows.Scan(&class.id, &code, &name, &class.year); err != nil {
+			return classes, err
+		}
+		classes.byCode[code] = class
+		classes.byName[name] = append(classes.byName[name], class)
+	}
+
+	return classes, rows.Err()
+}
+
+// loadImportDepartments maps department codes and names to department IDs
+func loadImportDepartments() (map[string]uint, error) {
+	rows, err := DB.Query("SELECT id, code, name FROM departments WHERE deleted_at IS NULL")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	departments := map[string]uint{}
+	for rows.Next() {
+		var id uint
+		var code, name string
+		if err := rows.Scan(&id, &code, &name); err != nil {
+			return nil, err
+		}
+		departments[code] = id
+		departments[name] = id
+	}
+
+	return departments, rows.Err()
+}
+
+// ApplyUserImport creates the accounts of validated import rows in a single transaction,
+// together with their student or teacher records. Every account must change its initial
+// password at first login. PasswordHash must be set on each row.
+func ApplyUserImport(role models.Role, rows []models.UserImportRow) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin user import: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	for i := range rows {
+		row := &rows[i]
+		user := &models.User{
+			Username:           row.Username,
+			Password:           row.PasswordHash,
+			Name:               row.Name,
+			Role:               role,
+			Email:              row.Email,
+			Phone:              row.Phone,
+			MustChangePassword: true,
+		}
+		id, err := createUser(tx, user)
+		if err != nil {
+			return fmt.Errorf("failed to create user for row %d: %w", row.Row, err)
+		}
+		row.UserID = id
+
+		switch role {
+		case models.RoleStudent:
+			_, err = tx.Exec(`
+				INSERT INTO students (user_id, student_id, class_id, enroll_year, created_at, updated_at)
+				VALUES (?, ?, ?, ?, ?, ?)
+			`, id, row.StudentNo, row.ClassID, row.EnrollYear, now, now)
+		case models.RoleTeacher:
+			_, err = tx.Exec(`
+				INSERT INTO teachers (user_id, department_id, title, created_at, updated_at)
+				VALUES (?, ?, ?, ?, ?)
+			`, id, row.DepartmentID, row.Title, now, now)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create %s record for row %d: %w", role, row.Row, err)
+		}
+		if err := syncSearchIndex(tx, "users", id); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit user import: %w", err)
+	}
+
+	return nil
+}
@@ -5,14 +5,38 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"to-mrz/api"
+	"to-mrz/auth"
+	"to-mrz/config"
+	"to-mrz/controllers"
 	"to-mrz/db"
+	"to-mrz/middleware"
+	"to-mrz/models"
+	"to-mrz/utils"
+
+	"github.com/gin-gonic/gin"
 )
 
 func main() {
+	// Load configuration from file and environment
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	applyConfig(cfg)
+
+	// Run an administrative command if one was given
+	if len(os.Args) > 1 {
+		if err := runCommand(cfg, os.Args[1:]); err != nil {
+			log.Fatalf("%s: %v", os.Args[1], err)
+		}
+		return
+	}
+
 	// Initialize database
-	if err := db.InitDB(); err != nil {
+	if err := db.InitDB(cfg.Database.Driver, cfg.Database.Source()); err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 	defer db.CloseDB()
@@ -22,17 +46,36 @@ func main() {
 		log.Fatalf("Failed to seed database: %v", err)
 	}
 
+	// Build the full-text search index
+	if err := db.InitSearchIndex(); err != nil {
+		log.Fatalf("Failed to initialize search index: %v", err)
+	}
+
+	// Allow creating the first administrator on a fresh installation
+	if err := enableFirstRunSetup(); err != nil {
+		log.Fatalf("Failed to check for administrator: %v", err)
+	}
+
+	// Take backups on a schedule
+	if cfg.Backup.Interval > 0 {
+		if cfg.Database.Driver == config.DriverSQLite {
+			go scheduleBackups(time.Duration(cfg.Backup.Interval))
+		} else {
+			utils.Warnf("Scheduled backups are only supported for SQLite; back up the %s database with its own tools", cfg.Database.Driver)
+		}
+	}
+
 	// Setup router
-	router := api.SetupRouter()
+	router := api.SetupRouter(cfg.Server, db.NewSQLRepositories())
 
 	// Start the server in a goroutine
 	go func() {
-		if err := router.Run(":8080"); err != nil {
+		if err := router.Run(cfg.Server.ListenAddr); err != nil {
 			log.Fatalf("Failed to start server: %v", err)
 		}
 	}()
 
-	log.Println("Server started on port 8080")
+	utils.Infof("Server started on %s (%s mode)", cfg.Server.ListenAddr, cfg.Env)
 
 	// Wait for interrupt signal to gracefully shutdown the server
 	quit := make(chan os.Signal, 1)
@@ -41,11 +84,176 @@ func main() {
 	// Kill -9 (SIGKILL) can't be caught, so don't need to add it
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
-	log.Println("Shutting down server...")
+	utils.Infof("Shutting down server...")
 
 	// Close database connection
 	if err := db.CloseDB(); err != nil {
 		log.Fatalf("Error closing database: %v", err)
 	}
-	log.Println("Server exited properly")
+	utils.Infof("Server exited properly")
+}
+
+// applyConfig pushes the loaded settings into the packages that use them
+func applyConfig(cfg *config.Config) {
+	middleware.Configure(cfg.JWT.Secret, time.Duration(cfg.JWT.Expiry), time.Duration(cfg.JWT.RefreshExpiry))
+	db.AutoMigrate = cfg.Database.AutoMigrate
+	db.BackupDir = cfg.Backup.Dir
+	db.BackupKeep = cfg.Backup.Keep
+	db.GradePolicy = models.GradePolicy(cfg.Grades.Policy)
+	db.GraduationChecks = db.GraduationRequirements{
+		Thesis:     cfg.Graduation.RequireThesis,
+		Internship: cfg.Graduation.RequireInternship,
+		FeesPaid:   cfg.Graduation.RequireFeesPaid,
+	}
+	db.LoginLimits = db.LoginThrottle{
+		MaxUserFailures: cfg.Login.MaxUserFailures,
+		MaxIPFailures:   cfg.Login.MaxIPFailures,
+		FailureWindow:   time.Duration(cfg.Login.FailureWindow),
+		LockoutDuration: time.Duration(cfg.Login.LockoutDuration),
+		BaseDelay:       time.Duration(cfg.Login.BaseDelay),
+		MaxDelay:        time.Duration(cfg.Login.MaxDelay),
+	}
+	utils.CurrentPasswordPolicy = utils.PasswordPolicy{
+		MinLength:  cfg.Password.MinLength,
+		MinClasses: cfg.Password.MinCharacterClasses,
+		History:    cfg.Password.History,
+		MaxAge:     time.Duration(cfg.Password.MaxAge),
+	}
+	controllers.TwoFactorIssuer = cfg.TwoFactor.Issuer
+	db.TwoFactorRoles = map[models.Role]bool{}
+	for _, role := range cfg.TwoFactor.RequiredRoles {
+		db.TwoFactorRoles[models.Role(role)] = true
+	}
+	configureAuth(cfg.Auth)
+
+	// Messages below log.level are dropped; debug also turns on gin debug output
+	utils.CurrentLogLevel, _ = utils.ParseLogLevel(cfg.Log.Level)
+	if cfg.Log.Level == "debug" {
+		gin.SetMode(gin.DebugMode)
+	} else {
+		gin.SetMode(gin.ReleaseMode)
+	}
+
+	if cfg.Env == config.EnvDevelopment && cfg.JWT.Secret == config.DefaultJWTSecret {
+		utils.Warnf("Using the default JWT secret; set APP_JWT_SECRET before deploying")
+	}
+}
+
+// scheduleBackups takes a backup every interval, counted from the newest existing one
+func scheduleBackups(interval time.Duration) {
+	var wait time.Duration
+	backups, err := db.ListBackups()
+	if err != nil {
+		utils.Errorf("Failed to list backups: %v", err)
+	} else if len(backups) > 0 {
+		wait = time.Until(backups[0].CreatedAt.Add(interval))
+	}
+
+	for {
+		time.Sleep(wait)
+		wait = interval
+
+		backup, err := db.CreateBackup(models.BackupScheduled)
+		if err != nil {
+			utils.Errorf("Scheduled backup failed: %v", err)
+			continue
+		}
+		utils.Infof("Scheduled backup %s written", backup.Name)
+	}
+}
+
+// configureAuth sets up the password and single sign-on backends
+func configureAuth(cfg config.AuthConfig) {
+	auth.Password = nil
+	for _, provider := range cfg.Providers {
+		switch provider {
+		case config.ProviderLocal:
+			auth.Password = append(auth.Password, auth.Local{})
+		case config.ProviderLDAP:
+			auth.Password = append(auth.Password, &auth.LDAP{
+				URL:          cfg.LDAP.URL,
+				StartTLS:     cfg.LDAP.StartTLS,
+				SkipVerify:   cfg.LDAP.InsecureSkipVerify,
+				BindDN:       cfg.LDAP.BindDN,
+				BindPassword: cfg.LDAP.BindPassword,
+				BaseDN:       cfg.LDAP.BaseDN,
+				UserFilter:   cfg.LDAP.UserFilter,
+				Attributes: auth.LDAPAttributes{
+					Username: cfg.LDAP.UsernameAttribute,
+					Name:     cfg.LDAP.NameAttribute,
+					Email:    cfg.LDAP.EmailAttribute,
+					Phone:    cfg.LDAP.PhoneAttribute,
+					Groups:   cfg.LDAP.GroupAttribute,
+				},
+				Timeout: time.Duration(cfg.LDAP.Timeout),
+				Policy:  provisioningPolicy(cfg.LDAP.ProvisioningConfig),
+			})
+		}
+	}
+
+	auth.SSO = map[string]auth.SSOAuthenticator{}
+	if cfg.CAS.URL != "" {
+		auth.SSO[auth.ProviderCAS] = &auth.CAS{
+			URL:          cfg.CAS.URL,
+			ServiceURL:   cfg.CAS.ServiceURL,
+			ValidatePath: cfg.CAS.ValidatePath,
+			DisplayName:  cfg.CAS.Label,
+			Attributes: auth.SSOAttributes{
+				Name:   cfg.CAS.NameAttribute,
+				Email:  cfg.CAS.EmailAttribute,
+				Phone:  cfg.CAS.PhoneAttribute,
+				Groups: cfg.CAS.GroupAttribute,
+			},
+			Policy: provisioningPolicy(cfg.CAS.ProvisioningConfig),
+		}
+	}
+	if cfg.OIDC.Issuer != "" {
+		auth.SSO[auth.ProviderOIDC] = &auth.OIDC{
+			Issuer:        cfg.OIDC.Issuer,
+			ClientID:      cfg.OIDC.ClientID,
+			ClientSecret:  cfg.OIDC.ClientSecret,
+			RedirectURL:   cfg.OIDC.RedirectURL,
+			Scopes:        cfg.OIDC.Scopes,
+			UsernameClaim: cfg.OIDC.UsernameClaim,
+			DisplayName:   cfg.OIDC.Label,
+			Claims: auth.SSOAttributes{
+				Name:   cfg.OIDC.NameClaim,
+				Email:  cfg.OIDC.EmailClaim,
+				Phone:  cfg.OIDC.PhoneClaim,
+				Groups: cfg.OIDC.GroupsClaim,
+			},
+			Policy: provisioningPolicy(cfg.OIDC.ProvisioningConfig),
+		}
+	}
+}
+
+// provisioningPolicy converts the role mapping of an external backend
+func provisioningPolicy(cfg config.ProvisioningConfig) auth.ProvisioningPolicy {
+	policy := auth.ProvisioningPolicy{
+		RoleMapping:       map[string]models.Role{},
+		DefaultRole:       models.Role(cfg.DefaultRole),
+		LinkExistingUsers: cfg.LinkExistingUsers,
+	}
+	for group, role := range cfg.RoleMapping {
+		policy.RoleMapping[group] = models.Role(role)
+	}
+	return policy
+}
+
+// enableFirstRunSetup logs a one-time setup token when no administrator exists yet
+func enableFirstRunSetup() error {
+	exists, err := db.AdminExists()
+	if err != nil || exists {
+		return err
+	}
+
+	token, err := utils.RandomToken(16)
+	if err != nil {
+		return err
+	}
+	controllers.EnableSetup(token)
+
+	// written at every log level, since the server is unusable without it
+	log.Printf("No administrator account exists. Run `bootstrap-admin` or POST /api/setup with setup_token %s", token)
+	return nil
 }
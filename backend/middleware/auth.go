@@ -1,32 +1,59 @@
 package middleware
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
+	"to-mrz/apierr"
+	"to-mrz/db"
 	"to-mrz/models"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var jwtSecret = []byte("your-secret-key") // In production, use environment variables
+var (
+	jwtSecret     = []byte("your-secret-key")
+	jwtExpiry     = 15 * time.Minute
+	refreshExpiry = 7 * 24 * time.Hour
+)
+
+// Configure sets the token signing secret and the access/refresh token lifetimes
+func Configure(secret string, expiry, refresh time.Duration) {
+	jwtSecret = []byte(secret)
+	jwtExpiry = expiry
+	refreshExpiry = refresh
+}
 
 // Claims represents the JWT claims
 type Claims struct {
-	UserID uint   `json:"user_id"`
-	Role   string `json:"role"`
+	UserID    uint   `json:"user_id"`
+	Role      string `json:"role"`
+	SessionID uint   `json:"sid"`
 	jwt.RegisteredClaims
 }
 
-// GenerateToken generates a JWT token for a user
-func GenerateToken(user *models.User) (string, error) {
-	expirationTime := time.Now().Add(24 * time.Hour)
+// TokenExpiry returns the lifetime of access tokens
+func TokenExpiry() time.Duration {
+	return jwtExpiry
+}
+
+// RefreshTokenExpiry returns the lifetime of refresh tokens
+func RefreshTokenExpiry() time.Duration {
+	return refreshExpiry
+}
+
+// GenerateToken generates a short-lived access token for a user's login session
+func GenerateToken(user *models.User, sessionID uint) (string, error) {
+	expirationTime := time.Now().Add(jwtExpiry)
 	claims := &Claims{
-		UserID: user.ID,
-		Role:   string(user.Role),
+		UserID:    user.ID,
+		Role:      string(user.Role),
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -63,62 +90,147 @@ func ValidateToken(tokenString string) (*Claims, error) {
 	return nil, fmt.Errorf("invalid token")
 }
 
-// AuthMiddleware is a middleware for authenticating users
-func AuthMiddleware() gin.HandlerFunc {
+// setupRoutes are the only routes open to users who must first change their password
+// or set up two-factor authentication
+var setupRoutes = map[error]map[string]bool{
+	db.ErrPasswordChangeRequired: {
+		"PUT /api/user/password": true,
+		"GET /api/user":          true,
+		"POST /api/logout":       true,
+	},
+	db.ErrTwoFactorSetupRequired: {
+		"GET /api/user/2fa":         true,
+		"POST /api/user/2fa/setup":  true,
+		"POST /api/user/2fa/enable": true,
+		"GET /api/user":             true,
+		"POST /api/logout":          true,
+	},
+}
+
+// AuthMiddleware is a middleware for authenticating users. The sessions are checked
+// through repos, which also serve the permission and scope checks after it.
+func AuthMiddleware(repos *db.Repositories) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
-			c.Abort()
+			apierr.Abort(c, apierr.Unauthorized("Authorization header is required"))
 			return
 		}
 
 		parts := strings.SplitN(authHeader, " ", 2)
 		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header format must be Bearer {token}"})
-			c.Abort()
+			apierr.Abort(c, apierr.Unauthorized("Authorization header format must be Bearer {token}"))
 			return
 		}
 
 		claims, err := ValidateToken(parts[1])
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
-			c.Abort()
+			apierr.Abort(c, apierr.New(http.StatusUnauthorized, apierr.CodeSessionExpired, "Invalid or expired token"))
 			return
 		}
 
-		// Add user ID and role to the context
+		// Reject tokens whose session was revoked or whose user was disabled or changed role,
+		// and hold users who must change their password or set up two-factor authentication
+		// to the routes that let them do so
+		err = repos.Sessions.Check(claims.SessionID, claims.UserID, claims.Role)
+		for gate, routes := range setupRoutes {
+			if errors.Is(err, gate) && routes[c.Request.Method+" "+c.FullPath()] {
+				err = nil
+				break
+			}
+		}
+		if err != nil {
+			switch {
+			case errors.Is(err, db.ErrPasswordChangeRequired):
+				apierr.Abort(c, apierr.New(http.StatusForbidden, apierr.CodePasswordChangeRequired, "Password change required").
+					With("must_change_password", true))
+			case errors.Is(err, db.ErrTwoFactorSetupRequired):
+				apierr.Abort(c, apierr.New(http.StatusForbidden, apierr.CodeTwoFactorSetupRequired, "Two-factor authentication must be set up").
+					With("two_factor_setup_required", true))
+			case errors.Is(err, db.ErrUserDisabled):
+				apierr.Abort(c, apierr.New(http.StatusUnauthorized, apierr.CodeAccountDisabled, "User account is disabled"))
+			case errors.Is(err, db.ErrRoleChanged):
+				apierr.Abort(c, apierr.New(http.StatusUnauthorized, apierr.CodeSessionExpired, "User role has changed, please log in again"))
+			case errors.Is(err, sql.ErrNoRows), errors.Is(err, db.ErrSessionRevoked), errors.Is(err, db.ErrSessionExpired):
+				apierr.Abort(c, apierr.New(http.StatusUnauthorized, apierr.CodeSessionExpired, "Session has ended, please log in again"))
+			default:
+				apierr.Abort(c, apierr.Internal(err, "Failed to verify session"))
+			}
+			return
+		}
+
+		// Add user ID, role and session to the context
 		c.Set("user_id", claims.UserID)
 		c.Set("role", claims.Role)
+		c.Set("session_id", claims.SessionID)
+		c.Set("repositories", repos)
 		c.Next()
 	}
 }
 
-// RoleMiddleware checks if the user has the required role
-func RoleMiddleware(roles ...string) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		role, exists := c.Get("role")
-		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-			c.Abort()
-			return
+// repositories returns the repositories of the AuthMiddleware that signed the user in
+func repositories(c *gin.Context) *db.Repositories {
+	return c.MustGet("repositories").(*db.Repositories)
+}
+
+// loadPermissions returns the current user's permissions, loading them once per request
+func loadPermissions(c *gin.Context) (map[models.Permission]bool, error) {
+	if cached, ok := c.Get("permissions"); ok {
+		return cached.(map[models.Permission]bool), nil
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		return nil, errNotAuthenticated
+	}
+
+	permissions, err := repositories(c).Roles.UserPermissions(userID.(uint))
+	if err != nil {
+		return nil, err
+	}
+	c.Set("permissions", permissions)
+	return permissions, nil
+}
+
+var errNotAuthenticated = errors.New("user not authenticated")
+
+// HasPermission reports whether the current user holds a permission through any of their roles
+func HasPermission(c *gin.Context, permission models.Permission) bool {
+	permissions, err := loadPermissions(c)
+	return err == nil && permissions[permission]
+}
+
+// IsAdmin reports whether the current user has the admin role, as primary or additional
+// role. Only administrators hold the admin-only permissions.
+func IsAdmin(c *gin.Context) bool {
+	for permission := range models.AdminOnlyPermissions {
+		if !HasPermission(c, permission) {
+			return false
 		}
+	}
+	return true
+}
 
-		userRole := role.(string)
-		allowed := false
-		for _, r := range roles {
-			if userRole == r {
-				allowed = true
-				break
+// PermissionMiddleware checks if the user holds any of the given permissions
+func PermissionMiddleware(permissions ...models.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		granted, err := loadPermissions(c)
+		if err != nil {
+			if errors.Is(err, errNotAuthenticated) {
+				apierr.Abort(c, apierr.Unauthorized("User not authenticated"))
+			} else {
+				apierr.Abort(c, apierr.Internal(err, "Failed to load permissions"))
 			}
+			return
 		}
 
-		if !allowed {
-			c.JSON(http.StatusForbidden, gin.H{"error": "User does not have the required role"})
-			c.Abort()
-			return
+		for _, p := range permissions {
+			if granted[p] {
+				c.Next()
+				return
+			}
 		}
 
-		c.Next()
+		apierr.Abort(c, apierr.New(http.StatusForbidden, apierr.CodePermissionDenied, "User does not have the required permission"))
 	}
 }
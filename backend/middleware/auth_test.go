@@ -0,0 +1,176 @@
+package middleware
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"to-mrz/apierr"
+	"to-mrz/db"
+	"to-mrz/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fakeSessions answers session checks from a map of session ID to result;
+// unknown sessions do not exist
+type fakeSessions struct {
+	db.SessionRepository
+	results map[uint]error
+}
+
+func (f fakeSessions) Check(sessionID, userID uint, role string) error {
+	err, ok := f.results[sessionID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	return err
+}
+
+// fakeRoles holds the permissions of each user
+type fakeRoles struct {
+	db.RoleRepository
+	permissions map[uint][]models.Permission
+}
+
+func (f fakeRoles) UserPermissions(userID uint) (map[models.Permission]bool, error) {
+	granted := map[models.Permission]bool{}
+	for _, p := range f.permissions[userID] {
+		granted[p] = true
+	}
+	return granted, nil
+}
+
+// fakeScopes holds the data scope of each user and the owners of course offerings
+type fakeScopes struct {
+	db.ScopeRepository
+	scopes    map[uint]*models.DataScope
+	offerings map[uint]*models.RowOwner
+}
+
+func (f fakeScopes) DataScope(userID uint) (*models.DataScope, error) {
+	return f.scopes[userID], nil
+}
+
+func (f fakeScopes) CourseOfferingOwner(id uint) (*models.RowOwner, error) {
+	owner, ok := f.offerings[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return owner, nil
+}
+
+const (
+	adminID   = 1
+	teacherID = 2
+	studentID = 3
+)
+
+// newTestRouter serves GET /api/offerings/:id to users who hold grade.read and may
+// see the offering, checking them against in-memory repositories
+func newTestRouter() *gin.Engine {
+	repos := &db.Repositories{
+		Sessions: fakeSessions{results: map[uint]error{
+			10: nil,
+			11: db.ErrSessionRevoked,
+			12: db.ErrPasswordChangeRequired,
+			20: nil,
+			30: nil,
+		}},
+		Roles: fakeRoles{permissions: map[uint][]models.Permission{
+			adminID:   {models.PermGradeRead, models.PermRoleManage, models.PermDataPurge},
+			teacherID: {models.PermGradeRead},
+		}},
+		Scopes: fakeScopes{
+			scopes: map[uint]*models.DataScope{
+				adminID:   {Unrestricted: true},
+				teacherID: {TeacherID: 7},
+				studentID: {StudentID: 9},
+			},
+			offerings: map[uint]*models.RowOwner{
+				100: {DepartmentID: 1, TeacherID: 7},
+				101: {DepartmentID: 1, TeacherID: 8},
+			},
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Errors())
+	r.GET("/api/offerings/:id",
+		AuthMiddleware(repos),
+		PermissionMiddleware(models.PermGradeRead),
+		ScopeMiddleware(ParamOwner("id", db.ScopeRepository.CourseOfferingOwner)),
+		func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"admin": IsAdmin(c)})
+		})
+	return r
+}
+
+func TestAuthPermissionAndScope(t *testing.T) {
+	r := newTestRouter()
+
+	for _, tc := range []struct {
+		name      string
+		userID    uint
+		sessionID uint
+		path      string
+		status    int
+		code      string
+	}{
+		{"admin", adminID, 10, "/api/offerings/101", http.StatusOK, ""},
+		{"revoked session", adminID, 11, "/api/offerings/101", http.StatusUnauthorized, apierr.CodeSessionExpired},
+		{"unknown session", adminID, 99, "/api/offerings/101", http.StatusUnauthorized, apierr.CodeSessionExpired},
+		{"password change pending", adminID, 12, "/api/offerings/101", http.StatusForbidden, apierr.CodePasswordChangeRequired},
+		{"teacher of the offering", teacherID, 20, "/api/offerings/100", http.StatusOK, ""},
+		{"offering of another teacher", teacherID, 20, "/api/offerings/101", http.StatusForbidden, apierr.CodeOutOfScope},
+		{"missing offering", teacherID, 20, "/api/offerings/404", http.StatusNotFound, apierr.CodeNotFound},
+		{"invalid offering ID", teacherID, 20, "/api/offerings/abc", http.StatusBadRequest, ""},
+		{"no permission", studentID, 30, "/api/offerings/100", http.StatusForbidden, apierr.CodePermissionDenied},
+	} {
+		token, err := GenerateToken(&models.User{ID: tc.userID}, tc.sessionID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != tc.status {
+			t.Errorf("%s: status %d, want %d: %s", tc.name, w.Code, tc.status, w.Body)
+			continue
+		}
+		var body struct {
+			Code  string `json:"code"`
+			Admin bool   `json:"admin"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if tc.code != "" && body.Code != tc.code {
+			t.Errorf("%s: code %q, want %q", tc.name, body.Code, tc.code)
+		}
+		if tc.status == http.StatusOK && body.Admin != (tc.userID == adminID) {
+			t.Errorf("%s: IsAdmin = %v", tc.name, body.Admin)
+		}
+	}
+}
+
+func TestAuthMiddlewareRequiresBearerToken(t *testing.T) {
+	r := newTestRouter()
+	for _, header := range []string{"", "Basic YWRtaW46YWRtaW4=", "Bearer not-a-token"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/offerings/100", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("Authorization %q: status %d, want 401", header, w.Code)
+		}
+	}
+}
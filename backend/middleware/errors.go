@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+
+	"to-mrz/apierr"
+	"to-mrz/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Errors writes the response for the error a handler recorded with apierr.Abort, in
+// the language the client asks for with Accept-Language. Errors other than
+// *apierr.Error, and panics, become 500 errors; their details are logged rather
+// than sent.
+func Errors() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		err := c.Errors.Last().Err
+		var response *apierr.Error
+		if !errors.As(err, &response) {
+			response = apierr.Internal(err, "Internal server error")
+		}
+		if response.Status >= 500 && response.Err != nil {
+			utils.Errorf("%s %s: %v", c.Request.Method, c.Request.URL.Path, response)
+		}
+		c.JSON(response.Status, response.Response(apierr.Language(c.GetHeader("Accept-Language"))))
+	}
+}
+
+// Recovery turns a panic in a handler into a 500 error. It must come after Errors.
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecovery(func(c *gin.Context, recovered interface{}) {
+		apierr.Abort(c, apierr.Internal(fmt.Errorf("panic: %v", recovered), "Internal server error"))
+	})
+}
+
+// NotFound answers requests for routes that do not exist
+func NotFound(c *gin.Context) {
+	apierr.Abort(c, apierr.NotFound("Route not found"))
+}
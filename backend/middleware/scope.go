@@ -0,0 +1,178 @@
+package middleware
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"to-mrz/apierr"
+	"to-mrz/db"
+	"to-mrz/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OwnerLookup returns the owner of the row with the given ID, such as
+// db.ScopeRepository.CourseOfferingOwner
+type OwnerLookup func(scopes db.ScopeRepository, id uint) (*models.RowOwner, error)
+
+// ScopeTarget locates a row a request touches and returns its owner.
+// It returns a nil owner when the request does not name such a row.
+type ScopeTarget func(c *gin.Context, scope *models.DataScope) (*models.RowOwner, error)
+
+// errInvalidScopeID is returned when a row ID in the request is not a number
+var errInvalidScopeID = errors.New("invalid ID")
+
+// CurrentScope returns the current user's data scope, loading it once per request
+func CurrentScope(c *gin.Context) (*models.DataScope, error) {
+	if cached, ok := c.Get("scope"); ok {
+		return cached.(*models.DataScope), nil
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		return nil, errNotAuthenticated
+	}
+
+	scope, err := repositories(c).Scopes.DataScope(userID.(uint))
+	if err != nil {
+		return nil, err
+	}
+	c.Set("scope", scope)
+	return scope, nil
+}
+
+// ScopeMiddleware enforces row-level data scoping. Every target named by the request
+// must be within the user's scope; scoped users must name at least one target.
+func ScopeMiddleware(targets ...ScopeTarget) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scope, err := CurrentScope(c)
+		if err != nil {
+			if errors.Is(err, errNotAuthenticated) {
+				apierr.Abort(c, apierr.Unauthorized("User not authenticated"))
+			} else {
+				apierr.Abort(c, apierr.Internal(err, "Failed to load data scope"))
+			}
+			return
+		}
+
+		if scope.Unrestricted {
+			c.Next()
+			return
+		}
+
+		named := false
+		for _, target := range targets {
+			owner, err := target(c, scope)
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				apierr.Abort(c, apierr.NotFound("The requested data does not exist"))
+				return
+			case errors.Is(err, errInvalidScopeID):
+				apierr.Abort(c, apierr.BadRequest("Invalid ID"))
+				return
+			case err != nil:
+				apierr.Abort(c, apierr.Internal(err, "Failed to check data scope"))
+				return
+			case owner == nil:
+				continue
+			}
+
+			named = true
+			if !scope.Allows(owner) {
+				apierr.Abort(c, apierr.New(http.StatusForbidden, apierr.CodeOutOfScope, "Data is outside your scope"))
+				return
+			}
+		}
+
+		if !named {
+			apierr.Abort(c, apierr.New(http.StatusForbidden, apierr.CodeOutOfScope, "Data is outside your scope"))
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// lookupID resolves a textual ID with lookup, treating an empty value as absent
+func lookupID(c *gin.Context, value string, lookup OwnerLookup) (*models.RowOwner, error) {
+	if value == "" {
+		return nil, nil
+	}
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return nil, errInvalidScopeID
+	}
+	return lookup(repositories(c).Scopes, uint(id))
+}
+
+// ParamOwner targets the row identified by a path parameter
+func ParamOwner(name string, lookup OwnerLookup) ScopeTarget {
+	return func(c *gin.Context, _ *models.DataScope) (*models.RowOwner, error) {
+		return lookupID(c, c.Param(name), lookup)
+	}
+}
+
+// QueryOwner targets the row identified by a query parameter
+func QueryOwner(name string, lookup OwnerLookup) ScopeTarget {
+	return func(c *gin.Context, _ *models.DataScope) (*models.RowOwner, error) {
+		return lookupID(c, c.Query(name), lookup)
+	}
+}
+
+// BodyOwner targets the row identified by a numeric field of the JSON request body.
+// The body is restored so the handler can bind it again.
+func BodyOwner(field string, lookup OwnerLookup) ScopeTarget {
+	return func(c *gin.Context, _ *models.DataScope) (*models.RowOwner, error) {
+		if c.Request.Body == nil {
+			return nil, nil
+		}
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			return nil, err
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(body, &fields); err != nil {
+			// Malformed bodies are rejected by the handler's binding
+			return nil, nil
+		}
+		raw, ok := fields[field]
+		if !ok {
+			return nil, nil
+		}
+
+		var id uint
+		if err := json.Unmarshal(raw, &id); err != nil {
+			return nil, errInvalidScopeID
+		}
+		if id == 0 {
+			return nil, nil
+		}
+		return lookup(repositories(c).Scopes, id)
+	}
+}
+
+// StudentQueryOwner targets the student named by a query parameter, defaulting to the
+// current user's own student record when the parameter is absent
+func StudentQueryOwner(name string) ScopeTarget {
+	return func(c *gin.Context, scope *models.DataScope) (*models.RowOwner, error) {
+		if c.Query(name) == "" && scope.StudentID != 0 {
+			return &models.RowOwner{StudentID: scope.StudentID}, nil
+		}
+		return lookupID(c, c.Query(name), db.ScopeRepository.StudentOwner)
+	}
+}
+
+// NewTopLevelRow targets a row that belongs to no department, such as a new
+// department; only unrestricted users may touch it
+func NewTopLevelRow() ScopeTarget {
+	return func(c *gin.Context, _ *models.DataScope) (*models.RowOwner, error) {
+		return &models.RowOwner{}, nil
+	}
+}
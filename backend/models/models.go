@@ -15,26 +15,233 @@ const (
 	RoleFinance    Role = "finance"    // 财务人员
 )
 
+// AllRoles lists every role in display order
+var AllRoles = []Role{RoleAdmin, RoleAcademic, RoleDepartment, RoleTeacher, RoleStudent, RoleSupervisor, RoleFinance}
+
+// Permission 权限名称，格式为 资源.操作
+type Permission string
+
+const (
+	PermDepartmentWrite    Permission = "department.write"     // 创建/修改院系
+	PermDepartmentDelete   Permission = "department.delete"    // 删除院系
+	PermCourseWrite        Permission = "course.write"         // 创建/修改课程
+	PermCourseDelete       Permission = "course.delete"        // 删除课程
+	PermGradeRead          Permission = "grade.read"           // 查看课程成绩
+	PermGradeWrite         Permission = "grade.write"          // 录入/修改成绩
+	PermGradeImport        Permission = "grade.import"         // 批量导入成绩
+	PermGradeExport        Permission = "grade.export"         // 导出成绩
+	PermGradeRetake        Permission = "grade.retake"         // 安排重修
+	PermMakeupRead         Permission = "makeup.read"          // 查看补考名单
+	PermMakeupGenerate     Permission = "makeup.generate"      // 生成补考名单
+	PermMakeupWrite        Permission = "makeup.write"         // 录入补考成绩
+	PermTrainingPlanWrite  Permission = "training_plan.write"  // 创建/修改培养方案
+	PermTrainingPlanDelete Permission = "training_plan.delete" // 删除培养方案
+	PermGraduationRead     Permission = "graduation.read"      // 查看毕业审核
+	PermGraduationReview   Permission = "graduation.review"    // 执行/确认毕业审核
+	PermUserManage         Permission = "user.manage"          // 管理用户账号
+	PermSessionManage      Permission = "session.manage"       // 强制用户下线
+	PermRoleManage         Permission = "role.manage"          // 管理角色权限与用户角色
+	PermBackupManage       Permission = "backup.manage"        // 备份数据库与下载备份
+	PermDataPurge          Permission = "data.purge"           // 彻底删除已删除的数据（仅管理员）
+)
+
+// PermissionInfo 权限说明
+type PermissionInfo struct {
+	Name        Permission `json:"name"`
+	Description string     `json:"description"`
+}
+
+// AllPermissions lists every permission known to the system
+var AllPermissions = []PermissionInfo{
+	{PermDepartmentWrite, "创建/修改院系"},
+	{PermDepartmentDelete, "删除院系"},
+	{PermCourseWrite, "创建/修改课程"},
+	{PermCourseDelete, "删除课程"},
+	{PermGradeRead, "查看成绩"},
+	{PermGradeWrite, "录入/修改成绩"},
+	{PermGradeImport, "批量导入成绩"},
+	{PermGradeExport, "导出成绩"},
+	{PermGradeRetake, "安排重修"},
+	{PermMakeupRead, "查看补考名单"},
+	{PermMakeupGenerate, "生成补考名单"},
+	{PermMakeupWrite, "录入补考成绩"},
+	{PermTrainingPlanWrite, "创建/修改培养方案"},
+	{PermTrainingPlanDelete, "删除培养方案"},
+	{PermGraduationRead, "查看毕业审核"},
+	{PermGraduationReview, "执行/确认毕业审核"},
+	{PermUserManage, "管理用户账号"},
+	{PermSessionManage, "强制用户下线"},
+	{PermRoleManage, "管理角色权限与用户角色（仅管理员）"},
+	{PermBackupManage, "备份数据库与下载备份"},
+	{PermDataPurge, "彻底删除已删除的数据（仅管理员）"},
+}
+
+// AdminOnlyPermissions cannot be granted to other roles. Managing roles is among
+// them, since it would let a role grant itself every other permission.
+var AdminOnlyPermissions = map[Permission]bool{
+	PermRoleManage: true,
+	PermDataPurge:  true,
+}
+
+// DefaultRolePermissions is the initial role-to-permission mapping. The admin role
+// always has every permission and is not listed.
+var DefaultRolePermissions = map[Role][]Permission{
+	RoleAcademic: {
+		PermDepartmentWrite, PermDepartmentDelete, PermCourseWrite, PermCourseDelete,
+		PermGradeRead, PermGradeWrite, PermGradeImport, PermGradeExport, PermGradeRetake,
+		PermMakeupRead, PermMakeupGenerate, PermMakeupWrite,
+		PermTrainingPlanWrite, PermTrainingPlanDelete, PermGraduationRead, PermGraduationReview,
+	},
+	RoleDepartment: {
+		PermDepartmentWrite, PermCourseWrite,
+		PermGradeRead, PermGradeWrite, PermGradeImport, PermGradeExport, PermGradeRetake,
+		PermMakeupRead, PermMakeupWrite, PermTrainingPlanWrite,
+	},
+	RoleTeacher: {
+		PermGradeRead, PermGradeWrite, PermGradeImport, PermGradeExport,
+		PermMakeupRead, PermMakeupWrite,
+	},
+	RoleStudent:    {PermGradeRead},
+	RoleSupervisor: {PermGradeRead},
+	RoleFinance:    {PermGraduationRead},
+}
+
+// RolePermissions 角色及其权限
+type RolePermissions struct {
+	Role        Role         `json:"role"`
+	Permissions []Permission `json:"permissions"`
+}
+
+// AuthSourceLocal 本地密码账号；其他来源的账号由外部认证系统自动创建，密码由外部系统管理
+const AuthSourceLocal = "local"
+
 // User 用户信息
 type User struct {
-	ID        uint      `json:"id" gorm:"primaryKey"`
-	Username  string    `json:"username" gorm:"unique"`
-	Password  string    `json:"-"` // 密码不返回
-	Name      string    `json:"name"`
-	Role      Role      `json:"role"`
-	Email     string    `json:"email"`
-	Phone     string    `json:"phone"`
+	ID                     uint       `json:"id" gorm:"primaryKey"`
+	Username               string     `json:"username" gorm:"unique"`
+	Password               string     `json:"-"` // 密码不返回
+	Name                   string     `json:"name"`
+	Role                   Role       `json:"role"`
+	Email                  string     `json:"email"`
+	Phone                  string     `json:"phone"`
+	Disabled               bool       `json:"disabled"`
+	DepartmentID           *uint      `json:"department_id,omitempty"` // 院系管理员所管理的院系
+	MustChangePassword     bool       `json:"must_change_password"`    // 下次登录须修改密码
+	PasswordChangedAt      time.Time  `json:"password_changed_at"`
+	TwoFactorEnabled       bool       `json:"two_factor_enabled"`                  // 已启用两步验证
+	TwoFactorSetupRequired bool       `json:"two_factor_setup_required,omitempty"` // 角色要求两步验证但尚未启用（不入库）
+	AuthSource             string     `json:"auth_source"`                         // 认证来源：local 或 ldap、cas、oidc
+	CreatedAt              time.Time  `json:"created_at"`
+	UpdatedAt              time.Time  `json:"updated_at"`
+	DeletedAt              *time.Time `json:"deleted_at,omitempty"`
+}
+
+// DataScope 当前用户可访问的数据范围（行级权限）
+type DataScope struct {
+	Unrestricted bool `json:"unrestricted"`            // 管理员、教务处不受限制
+	DepartmentID uint `json:"department_id,omitempty"` // 院系管理员：本院系
+	TeacherID    uint `json:"teacher_id,omitempty"`    // 教师：本人授课的开课
+	StudentID    uint `json:"student_id,omitempty"`    // 学生：本人
+}
+
+// RowOwner 数据行的归属（院系、授课教师、学生），为0表示不适用
+type RowOwner struct {
+	DepartmentID uint
+	TeacherID    uint
+	StudentID    uint
+}
+
+// Allows reports whether the scope may touch a row with the given owner
+func (s *DataScope) Allows(owner *RowOwner) bool {
+	switch {
+	case s.Unrestricted:
+		return true
+	case s.DepartmentID != 0 && owner.DepartmentID == s.DepartmentID:
+		return true
+	case s.TeacherID != 0 && owner.TeacherID == s.TeacherID:
+		return true
+	case s.StudentID != 0 && owner.StudentID == s.StudentID:
+		return true
+	}
+	return false
+}
+
+// Session 登录会话，保存当前有效的刷新令牌
+type Session struct {
+	ID         uint       `json:"id"`
+	UserID     uint       `json:"user_id"`
+	Role       Role       `json:"role"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Current    bool       `json:"current"`
+}
+
+// Login event results other than success
+const (
+	LoginReasonInvalidCredentials = "invalid_credentials"
+	LoginReasonLocked             = "locked"    // too many failures, temporarily locked out
+	LoginReasonThrottled          = "throttled" // retried before the progressive delay passed
+	LoginReasonDisabled           = "disabled"
+	LoginReasonInvalidTwoFactor   = "invalid_two_factor"
+	LoginReasonNotProvisioned     = "not_provisioned" // external user without a mapped role, or clashing with another account
+)
+
+// LoginEvent records one sign-in attempt
+type LoginEvent struct {
+	ID        uint      `json:"id"`
+	UserID    *uint     `json:"user_id"` // nil when the username does not exist
+	Username  string    `json:"username"`
+	IPAddress string    `json:"ip_address"`
+	UserAgent string    `json:"user_agent"`
+	Success   bool      `json:"success"`
+	Reason    string    `json:"reason,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// LoginLockout is a username or client IP that is temporarily locked out
+type LoginLockout struct {
+	Kind        string    `json:"kind"` // "user" or "ip"
+	Value       string    `json:"value"`
+	Failures    int       `json:"failures"`
+	LockedUntil time.Time `json:"locked_until"`
+}
+
+// Backup triggers
+const (
+	BackupManual     = "manual"      // requested by an administrator
+	BackupScheduled  = "scheduled"   // taken by the backup schedule
+	BackupPreRestore = "pre-restore" // the database as it was before a restore
+)
+
+// Backup is a database backup file
+type Backup struct {
+	Name          string    `json:"name"`
+	Size          int64     `json:"size"`
+	SHA256        string    `json:"sha256"`         // checksum of the file, for verifying copies
+	SchemaVersion int       `json:"schema_version"` // migration version of the backed-up database
+	Trigger       string    `json:"trigger"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// TwoFactorStatus 当前用户的两步验证状态
+type TwoFactorStatus struct {
+	Enabled                bool `json:"enabled"`
+	Required               bool `json:"required"` // 用户的角色要求启用两步验证
+	RecoveryCodesRemaining int  `json:"recovery_codes_remaining"`
 }
 
 // Department 院系信息
 type Department struct {
-	ID        uint      `json:"id" gorm:"primaryKey"`
-	Name      string    `json:"name" gorm:"unique"`
-	Code      string    `json:"code" gorm:"unique"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	Name      string     `json:"name" gorm:"unique"`
+	Code      string     `json:"code" gorm:"unique"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
 
 // Major 专业信息
@@ -46,6 +253,7 @@ type Major struct {
 	Department   Department `json:"department" gorm:"foreignKey:DepartmentID"`
 	CreatedAt    time.Time  `json:"created_at"`
 	UpdatedAt    time.Time  `json:"updated_at"`
+	DeletedAt    *time.Time `json:"deleted_at,omitempty"`
 }
 
 // Class 班级信息
@@ -81,10 +289,17 @@ type Student struct {
 	ClassID    uint      `json:"class_id"`
 	Class      Class     `json:"class" gorm:"foreignKey:ClassID"`
 	EnrollYear int       `json:"enroll_year"` // 入学年份
+	Status     string    `json:"status"`      // 学籍状态：在读/已毕业
 	CreatedAt  time.Time `json:"created_at"`
 	UpdatedAt  time.Time `json:"updated_at"`
 }
 
+// 学籍状态
+const (
+	StudentActive    = "在读"
+	StudentGraduated = "已毕业"
+)
+
 // Course 课程信息
 type Course struct {
 	ID            uint       `json:"id" gorm:"primaryKey"`
@@ -99,6 +314,7 @@ type Course struct {
 	Prerequisites []Course   `json:"prerequisites" gorm:"many2many:course_prerequisites"`
 	CreatedAt     time.Time  `json:"created_at"`
 	UpdatedAt     time.Time  `json:"updated_at"`
+	DeletedAt     *time.Time `json:"deleted_at,omitempty"`
 }
 
 // Semester 学期信息
@@ -114,20 +330,21 @@ type Semester struct {
 
 // CourseOffering 开课信息
 type CourseOffering struct {
-	ID          uint      `json:"id" gorm:"primaryKey"`
-	CourseID    uint      `json:"course_id"`
-	Course      Course    `json:"course" gorm:"foreignKey:CourseID"`
-	SemesterID  uint      `json:"semester_id"`
-	Semester    Semester  `json:"semester" gorm:"foreignKey:SemesterID"`
-	TeacherID   uint      `json:"teacher_id"`
-	Teacher     Teacher   `json:"teacher" gorm:"foreignKey:TeacherID"`
-	Capacity    int       `json:"capacity"`    // 容量
-	Location    string    `json:"location"`    // 教室
-	Schedule    string    `json:"schedule"`    // 上课时间
-	Status      string    `json:"status"`      // 状态
-	Description string    `json:"description"` // 课程描述
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	CourseID    uint       `json:"course_id"`
+	Course      Course     `json:"course" gorm:"foreignKey:CourseID"`
+	SemesterID  uint       `json:"semester_id"`
+	Semester    Semester   `json:"semester" gorm:"foreignKey:SemesterID"`
+	TeacherID   uint       `json:"teacher_id"`
+	Teacher     Teacher    `json:"teacher" gorm:"foreignKey:TeacherID"`
+	Capacity    int        `json:"capacity"`    // 容量
+	Location    string     `json:"location"`    // 教室
+	Schedule    string     `json:"schedule"`    // 上课时间
+	Status      string     `json:"status"`      // 状态
+	Description string     `json:"description"` // 课程描述
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
 }
 
 // Enrollment 选课记录
@@ -137,12 +354,76 @@ type Enrollment struct {
 	Student          Student        `json:"student" gorm:"foreignKey:StudentID"`
 	CourseOfferingID uint           `json:"course_offering_id"`
 	CourseOffering   CourseOffering `json:"course_offering" gorm:"foreignKey:CourseOfferingID"`
-	Grade            float64        `json:"grade"`  // 成绩
-	Status           string         `json:"status"` // 状态：已选/已退选
+	Grade            float64        `json:"grade"`        // 成绩
+	Status           string         `json:"status"`       // 状态：已选/已退选
+	RetakeOfID       *uint          `json:"retake_of_id"` // 重修时对应的原选课记录
 	CreatedAt        time.Time      `json:"created_at"`
 	UpdatedAt        time.Time      `json:"updated_at"`
 }
 
+// 考试类型
+const (
+	AttemptRegular = "正考"
+	AttemptMakeup  = "补考"
+	AttemptRetake  = "重修"
+)
+
+// 补考记录状态
+const (
+	MakeupPending = "待考"
+	MakeupPassed  = "已通过"
+	MakeupFailed  = "未通过"
+)
+
+// MakeupPassScore 补考通过后记载的成绩上限
+const MakeupPassScore = 60.0
+
+// ExamAttempt 同一选课记录上的额外考试记录（补考）
+type ExamAttempt struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	EnrollmentID uint       `json:"enrollment_id"`
+	Enrollment   Enrollment `json:"enrollment" gorm:"foreignKey:EnrollmentID"`
+	Type         string     `json:"type"`      // 补考
+	RawScore     *float64   `json:"raw_score"` // 卷面成绩
+	Score        *float64   `json:"score"`     // 记载成绩，补考通过记60分
+	Status       string     `json:"status"`    // 状态：待考/已通过/未通过
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// GradePolicy 多次考试（补考/重修）时成绩的认定策略
+type GradePolicy string
+
+const (
+	GradePolicyBest   GradePolicy = "best"   // 取最高成绩
+	GradePolicyLatest GradePolicy = "latest" // 取最近一次成绩
+	GradePolicyAll    GradePolicy = "all"    // 所有成绩均计入
+)
+
+// TranscriptEntry 成绩单中的一次考试记录
+type TranscriptEntry struct {
+	EnrollmentID uint    `json:"enrollment_id"`
+	CourseID     uint    `json:"course_id"`
+	CourseCode   string  `json:"course_code"`
+	CourseName   string  `json:"course_name"`
+	Credits      float64 `json:"credits"`
+	SemesterName string  `json:"semester_name"`
+	AttemptType  string  `json:"attempt_type"` // 正考/补考/重修
+	Score        float64 `json:"score"`
+	GradePoint   float64 `json:"grade_point"`
+	Passed       bool    `json:"passed"`
+	Counted      bool    `json:"counted"` // 是否按认定策略计入成绩单与绩点
+}
+
+// Transcript 学生成绩单
+type Transcript struct {
+	StudentID     uint              `json:"student_id"`
+	Policy        GradePolicy       `json:"policy"`
+	Entries       []TranscriptEntry `json:"entries"`
+	EarnedCredits float64           `json:"earned_credits"`
+	GPA           float64           `json:"gpa"`
+}
+
 // 成绩组成
 type GradeComponent struct {
 	ID               uint           `json:"id" gorm:"primaryKey"`
@@ -166,6 +447,140 @@ type GradeDetail struct {
 	UpdatedAt        time.Time      `json:"updated_at"`
 }
 
+// GradeImportRow 成绩导入中通过校验的一行
+type GradeImportRow struct {
+	Row          int                `json:"row"` // 文件中的行号，从1开始
+	EnrollmentID uint               `json:"enrollment_id"`
+	StudentNo    string             `json:"student_no"` // 学号
+	StudentName  string             `json:"student_name"`
+	Scores       map[string]float64 `json:"scores"` // 成绩组成名称 -> 分数
+	Total        float64            `json:"total"`  // 按权重计算的总评成绩
+	Status       string             `json:"status"`
+}
+
+// GradeImportError 成绩导入中的行级错误
+type GradeImportError struct {
+	Row       int    `json:"row"` // 0 表示与具体行无关的错误
+	Column    string `json:"column,omitempty"`
+	StudentNo string `json:"student_no,omitempty"`
+	Message   string `json:"message"`
+}
+
+// UserImportRow 学生、教师账号批量导入中通过校验的一行
+type UserImportRow struct {
+	Row          int    `json:"row"` // 文件中的行号，从1开始
+	UserID       uint   `json:"user_id,omitempty"`
+	Username     string `json:"username"`
+	Name         string `json:"name"`
+	Email        string `json:"email,omitempty"`
+	Phone        string `json:"phone,omitempty"`
+	StudentNo    string `json:"student_no,omitempty"` // 学号（学生）
+	ClassID      uint   `json:"class_id,omitempty"`
+	EnrollYear   int    `json:"enroll_year,omitempty"`
+	DepartmentID uint   `json:"department_id,omitempty"` // 所属院系（教师）
+	Title        string `json:"title,omitempty"`
+	Password     string `json:"password,omitempty"` // 仅返回系统生成的初始密码
+	PasswordHash string `json:"-"`
+}
+
+// UserImportError 账号批量导入中的行级错误
+type UserImportError struct {
+	Row      int    `json:"row"` // 0 表示与具体行无关的错误
+	Column   string `json:"column,omitempty"`
+	Username string `json:"username,omitempty"`
+	Message  string `json:"message"`
+}
+
+// GradeSheetRow 成绩表导出中的一行
+type GradeSheetRow struct {
+	EnrollmentID uint
+	StudentNo    string // 学号
+	StudentName  string
+	ClassName    string
+	Scores       map[uint]float64 // 成绩组成ID -> 分数，未录入的组成不在其中
+	Total        *float64         // 总评，尚未评定时为 nil
+	Status       string
+}
+
+// 培养方案课程组类别
+const (
+	GroupCoreRequired     = "核心必修"
+	GroupMajorElective    = "专业选修"
+	GroupGeneralEducation = "通识教育"
+	GroupPractice         = "实践环节"
+)
+
+// TrainingPlan 培养方案（按专业和年级）
+type TrainingPlan struct {
+	ID         uint              `json:"id" gorm:"primaryKey"`
+	MajorID    uint              `json:"major_id"`
+	Major      Major             `json:"major" gorm:"foreignKey:MajorID"`
+	CohortYear int               `json:"cohort_year"` // 适用的入学年份
+	Name       string            `json:"name"`
+	MinCredits float64           `json:"min_credits"` // 毕业最低总学分
+	Groups     []PlanCourseGroup `json:"groups"`
+	CreatedAt  time.Time         `json:"created_at"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+}
+
+// PlanCourseGroup 培养方案中的课程组
+type PlanCourseGroup struct {
+	ID             uint         `json:"id" gorm:"primaryKey"`
+	TrainingPlanID uint         `json:"training_plan_id"`
+	Name           string       `json:"name"`
+	Category       string       `json:"category"`    // 核心必修/专业选修/通识教育/实践环节
+	MinCredits     float64      `json:"min_credits"` // 该组最低学分
+	Courses        []PlanCourse `json:"courses"`
+}
+
+// PlanCourse 课程组中的课程
+type PlanCourse struct {
+	CourseID uint   `json:"course_id"`
+	Course   Course `json:"course" gorm:"foreignKey:CourseID"`
+	Required bool   `json:"required"` // 是否为该组必修课程
+}
+
+// 学位审核状态
+const (
+	AuditSatisfied  = "satisfied"
+	AuditInProgress = "in_progress"
+	AuditMissing    = "missing"
+)
+
+// DegreeAuditCourse 学位审核中的课程要求
+type DegreeAuditCourse struct {
+	CourseID uint    `json:"course_id"`
+	Code     string  `json:"code"`
+	Name     string  `json:"name"`
+	Credits  float64 `json:"credits"`
+	Required bool    `json:"required"`
+	Status   string  `json:"status"`
+}
+
+// DegreeAuditGroup 学位审核中的课程组结果
+type DegreeAuditGroup struct {
+	GroupID           uint                `json:"group_id"`
+	Name              string              `json:"name"`
+	Category          string              `json:"category"`
+	MinCredits        float64             `json:"min_credits"`
+	EarnedCredits     float64             `json:"earned_credits"`
+	InProgressCredits float64             `json:"in_progress_credits"`
+	Status            string              `json:"status"`
+	Courses           []DegreeAuditCourse `json:"courses"`
+}
+
+// DegreeAudit 学生对照培养方案的学位审核结果
+type DegreeAudit struct {
+	StudentID         uint               `json:"student_id"`
+	TrainingPlanID    uint               `json:"training_plan_id"`
+	PlanName          string             `json:"plan_name"`
+	MinCredits        float64            `json:"min_credits"`
+	EarnedCredits     float64            `json:"earned_credits"`
+	InProgressCredits float64            `json:"in_progress_credits"`
+	Status            string             `json:"status"`
+	Groups            []DegreeAuditGroup `json:"groups"`
+}
+
 // 教学评估
 type Evaluation struct {
 	ID               uint           `json:"id" gorm:"primaryKey"`
@@ -238,3 +653,107 @@ type Thesis struct {
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
+
+// StudentFee 学生缴费记录
+type StudentFee struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	StudentID uint      `json:"student_id"`
+	Item      string    `json:"item"`   // 收费项目，例如学费、住宿费
+	Amount    float64   `json:"amount"` // 应缴金额
+	Paid      float64   `json:"paid"`   // 已缴金额
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// 毕业审核批次状态
+const (
+	ReviewDraft     = "draft"
+	ReviewConfirmed = "confirmed"
+)
+
+// 毕业审核不通过原因
+const (
+	ReasonNoTrainingPlan       = "no_training_plan"
+	ReasonCreditsIncomplete    = "credits_incomplete"
+	ReasonFailedRequiredCourse = "failed_required_course"
+	ReasonNoThesisGrade        = "no_thesis_grade"
+	ReasonInternshipIncomplete = "internship_incomplete"
+	ReasonFeesOutstanding      = "fees_outstanding"
+)
+
+// GraduationReason 毕业审核不通过的原因
+type GraduationReason struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// GraduationReview 毕业审核批次
+type GraduationReview struct {
+	ID             uint               `json:"id" gorm:"primaryKey"`
+	GraduationYear int                `json:"graduation_year"`
+	CohortYear     int                `json:"cohort_year"` // 审核的入学年份
+	MajorID        uint               `json:"major_id"`    // 0 表示全部专业
+	Status         string             `json:"status"`      // draft/confirmed
+	CreatedBy      uint               `json:"created_by"`
+	Total          int                `json:"total"`
+	EligibleCount  int                `json:"eligible_count"`
+	Results        []GraduationResult `json:"results,omitempty"`
+	ConfirmedAt    *time.Time         `json:"confirmed_at"`
+	CreatedAt      time.Time          `json:"created_at"`
+	UpdatedAt      time.Time          `json:"updated_at"`
+}
+
+// GraduationResult 毕业审核中单个学生的结果
+type GraduationResult struct {
+	ID               uint               `json:"id" gorm:"primaryKey"`
+	ReviewID         uint               `json:"review_id"`
+	StudentID        uint               `json:"student_id"`
+	StudentNo        string             `json:"student_no"`
+	StudentName      string             `json:"student_name"`
+	Eligible         bool               `json:"eligible"` // 系统审核结果
+	Reasons          []GraduationReason `json:"reasons"`
+	OverrideEligible *bool              `json:"override_eligible"` // 人工调整结果
+	OverrideReason   string             `json:"override_reason"`
+	OverriddenBy     *uint              `json:"overridden_by"`
+	FinalEligible    bool               `json:"final_eligible"`
+}
+
+// Dependency counts the rows of a table that still refer to a row, which keep it
+// from being purged
+type Dependency struct {
+	Table  string `json:"table"`
+	Column string `json:"column"`
+	Count  int    `json:"count"`
+}
+
+// Page 分页列表的一页。游标分页时 Page 为 0；NextCursor 为空表示没有下一页。
+type Page struct {
+	Items      interface{} `json:"items"`
+	Total      int         `json:"total"` // 符合筛选条件的总条数
+	Page       int         `json:"page,omitempty"`
+	PageSize   int         `json:"page_size"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
+
+// SearchHit 搜索结果中的一条（课程、教师或学生）。Highlight 为匹配到关键字的字段
+// （title、code、body），已做 HTML 转义，匹配部分以 <mark> 标出，较长的正文只保留匹配附近的片段。
+type SearchHit struct {
+	ID        uint              `json:"id"`
+	Title     string            `json:"title"`            // 课程名称或教师、学生姓名
+	Code      string            `json:"code,omitempty"`   // 课程编号或学号
+	Detail    string            `json:"detail,omitempty"` // 所属院系或班级
+	Highlight map[string]string `json:"highlight"`
+}
+
+// SearchGroup 一类搜索结果，Total 为该类匹配的总条数
+type SearchGroup struct {
+	Type  string      `json:"type"`
+	Total int         `json:"total"`
+	Items []SearchHit `json:"items"`
+}
+
+// SearchResult 全局搜索结果，按类型分组
+type SearchResult struct {
+	Query  string        `json:"query"`
+	Groups []SearchGroup `json:"groups"`
+}
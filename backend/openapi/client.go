@@ -0,0 +1,277 @@
+package openapi
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// WriteClient writes a JavaScript module with a function per operation, which calls
+// the route with axios. Paths are relative to axios.defaults.baseURL, which points at
+// the base path of the API. JSDoc types generated from the schemas describe the
+// request and response bodies.
+func (d *Document) WriteClient(w io.Writer, generator string) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "// Code generated by %s. DO NOT EDIT.\n", generator)
+	fmt.Fprintf(out, "// %s %s 的接口客户端，路径相对于 axios.defaults.baseURL（%s）\n\n", d.Info.Title, d.Info.Version, d.basePath)
+	fmt.Fprintln(out, "import axios from 'axios'")
+
+	names := make([]string, 0, len(d.Components.Schemas))
+	for name := range d.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		d.writeTypedef(out, name, d.Components.Schemas[name])
+	}
+
+	// operations grouped by tag, in the order of the tags
+	written := map[*operationRef]bool{}
+	for _, tag := range d.Tags {
+		first := true
+		for _, ref := range d.operations {
+			if len(ref.op.Tags) == 0 || ref.op.Tags[0] != tag.Name {
+				continue
+			}
+			if first {
+				fmt.Fprintf(out, "\n// %s\n", tag.Name)
+				first = false
+			}
+			d.writeFunction(out, ref)
+			written[ref] = true
+		}
+	}
+	for _, ref := range d.operations {
+		if !written[ref] {
+			d.writeFunction(out, ref)
+		}
+	}
+	return out.Flush()
+}
+
+// writeTypedef writes the JSDoc type of a component schema
+func (d *Document) writeTypedef(out *bufio.Writer, name string, s *Schema) {
+	fmt.Fprintln(out, "\n/**")
+	if s.Type != "object" || s.Properties == nil {
+		fmt.Fprintf(out, " * @typedef {%s} %s\n */\n", d.jsType(s), name)
+		return
+	}
+	fmt.Fprintf(out, " * @typedef {Object} %s\n", name)
+	properties := make([]string, 0, len(s.Properties))
+	for property := range s.Properties {
+		properties = append(properties, property)
+	}
+	sort.Strings(properties)
+	for _, property := range properties {
+		field := property
+		if !contains(s.Required, property) {
+			field = "[" + property + "]"
+		}
+		fmt.Fprintf(out, " * @property {%s} %s\n", d.jsType(s.Properties[property]), field)
+	}
+	fmt.Fprintln(out, " */")
+}
+
+// writeFunction writes the function that calls an operation
+func (d *Document) writeFunction(out *bufio.Writer, ref *operationRef) {
+	op := ref.op
+	var args, query []string
+	url := ref.path
+
+	fmt.Fprintln(out, "\n/**")
+	if op.Summary != "" {
+		fmt.Fprintf(out, " * %s\n", op.Summary)
+	}
+	for _, p := range op.Parameters {
+		if p.In != "path" {
+			continue
+		}
+		arg := camelCase(p.Name)
+		args = append(args, arg)
+		value := arg
+		if p.Schema.Type == "string" {
+			value = "encodeURIComponent(" + arg + ")"
+		}
+		url = strings.Replace(url, "{"+p.Name+"}", "${"+value+"}", 1)
+		fmt.Fprintf(out, " * @param {%s} %s\n", d.jsType(p.Schema), arg)
+	}
+
+	body := ""
+	if op.RequestBody != nil {
+		args = append(args, "data")
+		body = "data"
+		name := "data"
+		if !op.RequestBody.Required {
+			name = "[data]"
+		}
+		if media, ok := op.RequestBody.Content["application/json"]; ok {
+			fmt.Fprintf(out, " * @param {%s} %s\n", d.jsType(media.Schema), name)
+		} else {
+			fmt.Fprintf(out, " * @param {FormData} %s - 含 %s 文件字段的表单\n", name, ref.route.Upload)
+		}
+	}
+
+	required := false
+	for _, p := range op.Parameters {
+		if p.In == "query" {
+			query = append(query, p.Name)
+			required = required || p.Required
+		}
+	}
+	if len(query) > 0 {
+		if required {
+			args = append(args, "params")
+			fmt.Fprintln(out, " * @param {Object} params - 查询参数")
+		} else {
+			args = append(args, "params = {}")
+			fmt.Fprintln(out, " * @param {Object} [params] - 查询参数")
+		}
+		for _, p := range op.Parameters {
+			if p.In != "query" {
+				continue
+			}
+			field := "params." + p.Name
+			if !p.Required {
+				field = "[" + field + "]"
+			}
+			description := ""
+			if p.Description != "" {
+				description = " - " + p.Description
+			}
+			fmt.Fprintf(out, " * @param {%s} %s%s\n", d.jsType(p.Schema), field, description)
+		}
+	}
+	args = append(args, "config = {}")
+	fmt.Fprintln(out, " * @param {Object} [config] - axios 请求配置")
+
+	result, options := "*", []string{"...config"}
+	if len(query) > 0 {
+		options = append(options, "params")
+	}
+	for status, response := range op.Responses {
+		if status == "default" {
+			continue
+		}
+		if media, ok := response.Content["application/json"]; ok {
+			result = d.jsType(media.Schema)
+		} else if len(ref.route.File) > 0 {
+			result = "Blob"
+			options = append(options, "responseType: 'blob'")
+		} else if ref.route.HTML {
+			result = "string"
+		}
+	}
+	fmt.Fprintf(out, " * @returns {Promise<import('axios').AxiosResponse<%s>>}\n */\n", result)
+
+	config := "config"
+	if len(options) > 1 {
+		config = "{ " + strings.Join(options, ", ") + " }"
+	}
+	call := "`" + url + "`"
+	if !strings.Contains(url, "${") {
+		call = "'" + url + "'"
+	}
+	method := strings.ToLower(ref.method)
+	switch method {
+	case "post", "put", "patch":
+		if body == "" {
+			body = "null"
+		}
+		call += ", " + body
+	}
+	fmt.Fprintf(out, "export function %s(%s) {\n  return axios.%s(%s, %s)\n}\n", op.OperationID, strings.Join(args, ", "), method, call, config)
+}
+
+// jsType returns the JSDoc type of a schema
+func (d *Document) jsType(s *Schema) string {
+	t := d.baseType(s)
+	if s.Nullable && t != "*" {
+		return "?" + t
+	}
+	return t
+}
+
+func (d *Document) baseType(s *Schema) string {
+	switch {
+	case s.Ref != "":
+		return strings.TrimPrefix(s.Ref, componentPrefix)
+	case len(s.AllOf) == 1:
+		return d.jsType(s.AllOf[0])
+	case len(s.OneOf) > 0:
+		options := make([]string, len(s.OneOf))
+		for i, option := range s.OneOf {
+			options[i] = d.jsType(option)
+		}
+		return "(" + strings.Join(options, "|") + ")"
+	case len(s.Enum) > 0:
+		values := make([]string, len(s.Enum))
+		for i, value := range s.Enum {
+			if str, ok := value.(string); ok {
+				values[i] = "'" + str + "'"
+			} else {
+				values[i] = fmt.Sprint(value)
+			}
+		}
+		return "(" + strings.Join(values, "|") + ")"
+	}
+
+	switch s.Type {
+	case "string":
+		if s.Format == "binary" {
+			return "Blob"
+		}
+		return "string"
+	case "integer", "number":
+		return "number"
+	case "boolean":
+		return "boolean"
+	case "array":
+		if s.Items == nil {
+			return "Array"
+		}
+		return "Array<" + d.jsType(s.Items) + ">"
+	case "object":
+		if additional, ok := s.AdditionalProperties.(*Schema); ok {
+			return "Object<string, " + d.jsType(additional) + ">"
+		}
+		if len(s.Properties) == 0 {
+			return "Object"
+		}
+		properties := make([]string, 0, len(s.Properties))
+		for property := range s.Properties {
+			properties = append(properties, property)
+		}
+		sort.Strings(properties)
+		for i, property := range properties {
+			optional := ""
+			if !contains(s.Required, property) {
+				optional = "?"
+			}
+			properties[i] = property + optional + ": " + d.jsType(s.Properties[property])
+		}
+		return "{ " + strings.Join(properties, ", ") + " }"
+	}
+	return "*"
+}
+
+// camelCase converts a snake_case parameter name to a JavaScript name
+func camelCase(name string) string {
+	parts := strings.Split(name, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,302 @@
+// Package openapi describes the HTTP API as an OpenAPI 3.0 document. Routes are
+// documented with Route values and their schemas are generated from the Go types of
+// the request and response bodies, so the document follows the code. The document
+// can check responses against their schemas (ValidateResponse) and write a
+// JavaScript client for the frontend (WriteClient).
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Version is the OpenAPI version of the documents
+const Version = "3.0.3"
+
+// Document is an OpenAPI document
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Servers    []Server            `json:"servers,omitempty"`
+	Tags       []Tag               `json:"tags,omitempty"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+
+	basePath   string
+	errorBody  interface{}
+	operations []*operationRef // in the order they were added
+	types      map[string]reflect.Type
+}
+
+// Info describes the API
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// Server is a base URL of the API
+type Server struct {
+	URL string `json:"url"`
+}
+
+// Tag groups operations in the documentation
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem holds the operations of a path by lowercase method
+type PathItem map[string]*Operation
+
+// Operation is a documented route
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+// Parameter is a path or query parameter
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody describes the body of a request
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response describes the response with one status
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType is the schema of a body with one content type
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Components holds the schemas referred to by the operations
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme describes how requests are authenticated
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+// bearerAuth is the name of the security scheme of the access tokens
+const bearerAuth = "bearerAuth"
+
+// Route documents a route. Body and Response are values of the types of the JSON
+// bodies; Instance and OneOf describe bodies a single Go type cannot.
+type Route struct {
+	OperationID  string // defaults to the name of the handler
+	Summary      string
+	Description  string
+	Tag          string
+	Public       bool // reachable without an access token
+	Query        []Param
+	Body         interface{} // JSON request body
+	BodyOptional bool        // the body may be left out
+	Upload       string      // name of the file field of a multipart request body
+	Status       int         // status of success, 200 if 0
+	Response     interface{} // JSON response body
+	File         []string    // content types of a file download, instead of Response
+	HTML         bool        // the response is an HTML page
+}
+
+// Param is a query parameter. Type is a JSON schema type, string if empty.
+type Param struct {
+	Name        string
+	Type        string
+	Description string
+	Required    bool
+	Enum        []string
+	Example     string // value used when requests are made to check the API
+}
+
+// operationRef locates an operation of the document
+type operationRef struct {
+	method string
+	path   string
+	op     *Operation
+	route  Route
+}
+
+// New returns an empty document for the API served under basePath. Error responses
+// of every operation have the schema of errorBody.
+func New(info Info, basePath string, errorBody interface{}) *Document {
+	d := &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Servers: []Server{{URL: basePath}},
+		Paths:   map[string]PathItem{},
+		Components: Components{
+			Schemas: map[string]*Schema{},
+			SecuritySchemes: map[string]SecurityScheme{
+				bearerAuth: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+		basePath:  basePath,
+		errorBody: errorBody,
+		types:     map[string]reflect.Type{},
+	}
+	return d
+}
+
+// AddTag adds a tag in the order the documentation shows them
+func (d *Document) AddTag(name, description string) {
+	d.Tags = append(d.Tags, Tag{Name: name, Description: description})
+}
+
+// Add documents the route for method and path, a gin path with :name parameters.
+// Paths in the document are relative to the base path.
+func (d *Document) Add(method, path, operationID string, route Route) {
+	if route.OperationID != "" {
+		operationID = route.OperationID
+	}
+	op := &Operation{
+		OperationID: operationID,
+		Summary:     route.Summary,
+		Description: route.Description,
+		Responses:   map[string]*Response{},
+	}
+	if route.Tag != "" {
+		op.Tags = []string{route.Tag}
+	}
+	if !route.Public {
+		op.Security = []map[string][]string{{bearerAuth: {}}}
+	}
+
+	specPath, params := PathParams(strings.TrimPrefix(path, d.basePath))
+	for _, name := range params {
+		op.Parameters = append(op.Parameters, Parameter{Name: name, In: "path", Required: true, Schema: pathParamSchema(name)})
+	}
+	for _, p := range route.Query {
+		schema := &Schema{Type: p.Type}
+		if schema.Type == "" {
+			schema.Type = "string"
+		}
+		for _, value := range p.Enum {
+			schema.Enum = append(schema.Enum, value)
+		}
+		op.Parameters = append(op.Parameters, Parameter{Name: p.Name, In: "query", Description: p.Description, Required: p.Required, Schema: schema})
+	}
+
+	switch {
+	case route.Body != nil:
+		op.RequestBody = &RequestBody{Required: !route.BodyOptional, Content: map[string]MediaType{
+			"application/json": {Schema: d.schemaOf(route.Body, inRequest)},
+		}}
+	case route.Upload != "":
+		op.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{
+			"multipart/form-data": {Schema: &Schema{
+				Type:       "object",
+				Properties: map[string]*Schema{route.Upload: {Type: "string", Format: "binary"}},
+				Required:   []string{route.Upload},
+			}},
+		}}
+	}
+
+	status := route.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := &Response{Description: http.StatusText(status)}
+	switch {
+	case route.HTML:
+		success.Content = map[string]MediaType{"text/html": {Schema: &Schema{Type: "string"}}}
+	case len(route.File) > 0:
+		success.Content = map[string]MediaType{}
+		for _, contentType := range route.File {
+			success.Content[contentType] = MediaType{Schema: &Schema{Type: "string", Format: "binary"}}
+		}
+	case route.Response != nil:
+		success.Content = map[string]MediaType{"application/json": {Schema: d.schemaOf(route.Response, inResponse)}}
+	}
+	op.Responses[strconv.Itoa(status)] = success
+	op.Responses["default"] = &Response{
+		Description: "Error",
+		Content:     map[string]MediaType{"application/json": {Schema: d.schemaOf(d.errorBody, inResponse)}},
+	}
+
+	item := d.Paths[specPath]
+	if item == nil {
+		item = PathItem{}
+		d.Paths[specPath] = item
+	}
+	item[strings.ToLower(method)] = op
+	d.operations = append(d.operations, &operationRef{method: method, path: specPath, op: op, route: route})
+}
+
+// Operation returns the operation for method and path, a gin path, or nil
+func (d *Document) Operation(method, path string) *Operation {
+	specPath, _ := PathParams(strings.TrimPrefix(path, d.basePath))
+	return d.Paths[specPath][strings.ToLower(method)]
+}
+
+// PathParams converts a gin path to an OpenAPI path and lists its parameters
+func PathParams(path string) (string, []string) {
+	var params []string
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			name := segment[1:]
+			params = append(params, name)
+			segments[i] = "{" + name + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// pathParamSchema is the schema of a path parameter: IDs are integers
+func pathParamSchema(name string) *Schema {
+	if name == "id" || strings.HasSuffix(name, "_id") {
+		return &Schema{Type: "integer", Minimum: floatPtr(1)}
+	}
+	return &Schema{Type: "string"}
+}
+
+// OperationID derives an operation ID from the name of a gin handler, such as
+// "to-mrz/controllers.(*Server).GetCourse-fm" for getCourse
+func OperationID(handler string) string {
+	name := handler[strings.LastIndex(handler, ".")+1:]
+	name = strings.TrimSuffix(name, "-fm")
+	if name == "" {
+		return ""
+	}
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+// Check reports problems with the document itself, such as operation IDs used twice
+func (d *Document) Check() []string {
+	var problems []string
+	seen := map[string]string{}
+	for _, ref := range d.operations {
+		key := ref.method + " " + ref.path
+		if other, ok := seen[ref.op.OperationID]; ok {
+			problems = append(problems, fmt.Sprintf("%s: operation ID %q is also used by %s", key, ref.op.OperationID, other))
+		}
+		seen[ref.op.OperationID] = key
+	}
+	return problems
+}
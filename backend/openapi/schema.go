@@ -0,0 +1,381 @@
+package openapi
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema is a JSON schema, in the OpenAPI 3.0 dialect
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"` // false or a *Schema
+	Items                *Schema            `json:"items,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	ExclusiveMinimum     bool               `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     bool               `json:"exclusiveMaximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+}
+
+// schemaMode tells how a Go type is described. Response schemas list every field the
+// server writes; request schemas take the rules from the binding tags.
+type schemaMode int
+
+const (
+	inResponse schemaMode = iota
+	inRequest
+)
+
+// componentPrefix is the start of references to component schemas
+const componentPrefix = "#/components/schemas/"
+
+// Instance describes a struct whose interface{} fields hold values of known types,
+// such as models.Page with the items of one list. It is a component named Name.
+type Instance struct {
+	Name   string
+	Type   interface{}            // value of the struct type
+	Fields map[string]interface{} // values of the types of fields, by JSON name
+}
+
+// oneOf describes a body that has one of several types
+type oneOf []interface{}
+
+// OneOf describes a body that has the type of one of the values
+func OneOf(values ...interface{}) interface{} {
+	return oneOf(values)
+}
+
+// Enum documents the values of a named type, such as a string type with constants.
+// The type becomes a component listing the values.
+func (d *Document) Enum(value interface{}, values ...interface{}) {
+	t := reflect.TypeOf(value)
+	d.register(t.Name(), t)
+	d.Components.Schemas[t.Name()] = &Schema{Type: jsonType(t), Enum: values}
+}
+
+// schemaOf returns the schema of a request or response body
+func (d *Document) schemaOf(value interface{}, mode schemaMode) *Schema {
+	switch v := value.(type) {
+	case Instance:
+		return d.instanceSchema(v, mode)
+	case oneOf:
+		s := &Schema{}
+		for _, option := range v {
+			s.OneOf = append(s.OneOf, d.schemaOf(option, mode))
+		}
+		return s
+	}
+	return d.typeSchema(reflect.TypeOf(value), mode)
+}
+
+// instanceSchema adds the component of a struct with the field types of the instance
+func (d *Document) instanceSchema(instance Instance, mode schemaMode) *Schema {
+	t := reflect.TypeOf(instance.Type)
+	if _, ok := d.Components.Schemas[instance.Name]; !ok {
+		d.register(instance.Name, t)
+		d.Components.Schemas[instance.Name] = d.structSchema(t, mode, instance.Fields)
+	}
+	return &Schema{Ref: componentPrefix + instance.Name}
+}
+
+// register reserves a component name for a type. Two types with the same name would
+// describe one of them wrongly, so that is a programming error.
+func (d *Document) register(name string, t reflect.Type) {
+	if other, ok := d.types[name]; ok && other != t {
+		panic(fmt.Sprintf("openapi: schema %s describes both %s and %s", name, other, t))
+	}
+	d.types[name] = t
+}
+
+// componentName returns the name of the component of a named struct type, capitalized
+// for unexported types. Request schemas of the models are separate components, since
+// only their rules differ.
+func componentName(t reflect.Type, mode schemaMode) string {
+	name := strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+	if mode == inRequest && !strings.HasSuffix(name, "Request") {
+		name += "Input"
+	}
+	return name
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// typeSchema returns the schema of a Go type
+func (d *Document) typeSchema(t reflect.Type, mode schemaMode) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+	if t.Kind() == reflect.Ptr {
+		return nullable(d.typeSchema(t.Elem(), mode))
+	}
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+	if _, ok := d.Components.Schemas[t.Name()]; ok && d.types[t.Name()] == t && t.Kind() != reflect.Struct {
+		// a documented enum
+		return &Schema{Ref: componentPrefix + t.Name()}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if t.Name() == "" {
+			return d.structSchema(t, mode, nil)
+		}
+		name := componentName(t, mode)
+		if _, ok := d.Components.Schemas[name]; !ok {
+			d.register(name, t)
+			d.Components.Schemas[name] = &Schema{} // placeholder for recursive types
+			d.Components.Schemas[name] = d.structSchema(t, mode, nil)
+		}
+		return &Schema{Ref: componentPrefix + name}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		s := &Schema{Type: "array", Items: d.typeSchema(t.Elem(), mode)}
+		if mode == inResponse && t.Kind() == reflect.Slice {
+			s.Nullable = true
+		}
+		return s
+	case reflect.Map:
+		s := &Schema{Type: "object", AdditionalProperties: d.typeSchema(t.Elem(), mode)}
+		if mode == inResponse {
+			s.Nullable = true
+		}
+		return s
+	case reflect.Interface:
+		return &Schema{}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Minimum: floatPtr(0)}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		s := &Schema{Type: "integer"}
+		if t.Kind() == reflect.Int64 {
+			s.Format = "int64"
+		}
+		return s
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	}
+	panic(fmt.Sprintf("openapi: cannot describe %s", t))
+}
+
+// jsonType is the JSON schema type of a basic Go type
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Bool:
+		return "boolean"
+	}
+	return "string"
+}
+
+// nullable marks a schema as allowing null. A reference cannot have siblings in
+// OpenAPI 3.0, so it is wrapped in allOf.
+func nullable(s *Schema) *Schema {
+	if s.Ref != "" {
+		return &Schema{AllOf: []*Schema{s}, Nullable: true}
+	}
+	if s.Type == "" && len(s.OneOf) == 0 {
+		return s // any value, null included
+	}
+	s.Nullable = true
+	return s
+}
+
+// structSchema returns the object schema of a struct type. fields overrides the types
+// of fields by JSON name.
+func (d *Document) structSchema(t reflect.Type, mode schemaMode, fields map[string]interface{}) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	if mode == inResponse {
+		s.AdditionalProperties = false
+	}
+	d.addFields(s, t, mode, fields)
+	sort.Strings(s.Required)
+	return s
+}
+
+// addFields adds the JSON fields of a struct type to an object schema, including
+// those of embedded structs
+func (d *Document) addFields(s *Schema, t reflect.Type, mode schemaMode, fields map[string]interface{}) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options := tag, ""
+		if comma := strings.Index(tag, ","); comma >= 0 {
+			name, options = tag[:comma], tag[comma+1:]
+		}
+
+		if f.Anonymous && name == "" {
+			embedded := f.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				d.addFields(s, embedded, mode, fields)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		var property *Schema
+		if override, ok := fields[name]; ok {
+			property = d.schemaOf(override, mode)
+		} else {
+			property = d.typeSchema(f.Type, mode)
+		}
+
+		required := false
+		switch mode {
+		case inResponse:
+			required = !hasOption(options, "omitempty")
+		case inRequest:
+			required = applyRules(property, f.Type, f.Tag.Get("binding"))
+			if required && property.Nullable {
+				property.Nullable = false
+			}
+		}
+		if required {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = property
+	}
+}
+
+// hasOption reports whether a comma-separated tag has an option
+func hasOption(options, option string) bool {
+	for _, o := range strings.Split(options, ",") {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+// applyRules adds the validator rules of a binding tag to the schema of a field and
+// reports whether the field is required. Rules after dive apply to the elements of a
+// slice and are not described.
+func applyRules(s *Schema, t reflect.Type, binding string) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	target := s
+	if len(s.AllOf) == 1 {
+		target = s.AllOf[0]
+	}
+
+	required := false
+	for _, rule := range strings.Split(binding, ",") {
+		name, param := rule, ""
+		if eq := strings.Index(rule, "="); eq >= 0 {
+			name, param = rule[:eq], rule[eq+1:]
+		}
+		switch name {
+		case "dive":
+			return required
+		case "required":
+			required = true
+		case "email":
+			target.Format = "email"
+		case "url":
+			target.Format = "uri"
+		case "oneof":
+			target.Enum = nil
+			for _, value := range strings.Fields(param) {
+				target.Enum = append(target.Enum, enumValue(t, value))
+			}
+		case "min", "max", "len":
+			n, err := strconv.Atoi(param)
+			if err != nil {
+				continue
+			}
+			switch t.Kind() {
+			case reflect.String:
+				if name != "max" {
+					target.MinLength = &n
+				}
+				if name != "min" {
+					target.MaxLength = &n
+				}
+			case reflect.Slice, reflect.Array, reflect.Map:
+				if name != "max" {
+					target.MinItems = &n
+				}
+				if name != "min" {
+					target.MaxItems = &n
+				}
+			default:
+				if name != "max" {
+					target.Minimum = floatPtr(float64(n))
+				}
+				if name != "min" {
+					target.Maximum = floatPtr(float64(n))
+				}
+			}
+		case "gt", "gte", "lt", "lte":
+			n, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				continue
+			}
+			switch name {
+			case "gt", "gte":
+				target.Minimum = &n
+				target.ExclusiveMinimum = name == "gt"
+			default:
+				target.Maximum = &n
+				target.ExclusiveMaximum = name == "lt"
+			}
+		}
+	}
+	return required
+}
+
+// enumValue converts a oneof value to the JSON type of the field
+func enumValue(t reflect.Type, value string) interface{} {
+	switch jsonType(t) {
+	case "integer":
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return n
+		}
+	case "number":
+		if n, err := strconv.ParseFloat(value, 64); err == nil {
+			return n
+		}
+	}
+	return value
+}
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
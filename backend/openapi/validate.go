@@ -0,0 +1,200 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ValidateResponse checks a response of the route for method and path, a gin path,
+// against the document and returns its problems. Statuses without their own response
+// must be errors, described by the default response.
+func (d *Document) ValidateResponse(method, path string, status int, contentType string, body []byte) []string {
+	op := d.Operation(method, path)
+	if op == nil {
+		return []string{"the route is not documented"}
+	}
+
+	response, ok := op.Responses[strconv.Itoa(status)]
+	if !ok {
+		if status < 400 {
+			return []string{fmt.Sprintf("status %d is not documented", status)}
+		}
+		response = op.Responses["default"]
+	}
+
+	if len(response.Content) == 0 {
+		if len(bytes.TrimSpace(body)) > 0 {
+			return []string{fmt.Sprintf("status %d should have no body", status)}
+		}
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return []string{fmt.Sprintf("invalid content type %q", contentType)}
+	}
+	media, ok := response.Content[mediaType]
+	if !ok {
+		return []string{fmt.Sprintf("content type %s is not documented for status %d", mediaType, status)}
+	}
+	if mediaType != "application/json" {
+		return nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return []string{fmt.Sprintf("invalid JSON: %v", err)}
+	}
+	return d.validate(media.Schema, value, "$")
+}
+
+// Resolve follows a reference to a component schema
+func (d *Document) Resolve(s *Schema) *Schema {
+	for s.Ref != "" {
+		target, ok := d.Components.Schemas[strings.TrimPrefix(s.Ref, componentPrefix)]
+		if !ok {
+			return &Schema{}
+		}
+		s = target
+	}
+	return s
+}
+
+// validate checks a decoded JSON value against a schema. path locates the value in
+// the messages, like $.items[0].name.
+func (d *Document) validate(s *Schema, value interface{}, path string) []string {
+	s = d.Resolve(s)
+	if value == nil {
+		if s.Nullable || (s.Type == "" && len(s.AllOf) == 0 && len(s.OneOf) == 0) {
+			return nil
+		}
+		return []string{path + ": is null"}
+	}
+
+	var problems []string
+	for _, part := range s.AllOf {
+		problems = append(problems, d.validate(part, value, path)...)
+	}
+	if len(s.OneOf) > 0 {
+		matches := 0
+		for _, option := range s.OneOf {
+			if len(d.validate(option, value, path)) == 0 {
+				matches++
+			}
+		}
+		if matches != 1 {
+			problems = append(problems, fmt.Sprintf("%s: matches %d of the %d schemas of oneOf", path, matches, len(s.OneOf)))
+		}
+	}
+
+	switch s.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return append(problems, typeProblem(path, s.Type, value))
+		}
+		for _, name := range s.Required {
+			if _, ok := object[name]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: missing required property %s", path, name))
+			}
+		}
+		names := make([]string, 0, len(object))
+		for name := range object {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if property, ok := s.Properties[name]; ok {
+				problems = append(problems, d.validate(property, object[name], path+"."+name)...)
+				continue
+			}
+			switch additional := s.AdditionalProperties.(type) {
+			case bool:
+				if !additional {
+					problems = append(problems, fmt.Sprintf("%s: undocumented property %s", path, name))
+				}
+			case *Schema:
+				problems = append(problems, d.validate(additional, object[name], path+"."+name)...)
+			}
+		}
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			return append(problems, typeProblem(path, s.Type, value))
+		}
+		if s.Items != nil {
+			for i, item := range array {
+				problems = append(problems, d.validate(s.Items, item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return append(problems, typeProblem(path, s.Type, value))
+		}
+		if s.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, str); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %q is not a date-time", path, str))
+			}
+		}
+	case "integer", "number":
+		n, ok := value.(json.Number)
+		if !ok {
+			return append(problems, typeProblem(path, s.Type, value))
+		}
+		if s.Type == "integer" {
+			if _, err := n.Int64(); err != nil {
+				return append(problems, fmt.Sprintf("%s: %s is not an integer", path, n))
+			}
+		}
+		f, _ := n.Float64()
+		if s.Minimum != nil && f < *s.Minimum {
+			problems = append(problems, fmt.Sprintf("%s: %s is less than %v", path, n, *s.Minimum))
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			problems = append(problems, fmt.Sprintf("%s: %s is greater than %v", path, n, *s.Maximum))
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return append(problems, typeProblem(path, s.Type, value))
+		}
+	}
+
+	if len(s.Enum) > 0 && !inEnum(s.Enum, value) {
+		problems = append(problems, fmt.Sprintf("%s: %v is not one of %v", path, value, s.Enum))
+	}
+	return problems
+}
+
+// typeProblem reports a value of the wrong JSON type
+func typeProblem(path, want string, value interface{}) string {
+	got := "string"
+	switch value.(type) {
+	case map[string]interface{}:
+		got = "object"
+	case []interface{}:
+		got = "array"
+	case json.Number:
+		got = "number"
+	case bool:
+		got = "boolean"
+	}
+	return fmt.Sprintf("%s: expected %s, got %s", path, want, got)
+}
+
+// inEnum reports whether a decoded value is one of the values of an enum
+func inEnum(enum []interface{}, value interface{}) bool {
+	for _, allowed := range enum {
+		if fmt.Sprint(allowed) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
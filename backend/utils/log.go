@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+)
+
+// LogLevel is the severity of a log message
+type LogLevel int
+
+// Log levels of the log.level setting, from the most to the least verbose
+const (
+	LogDebug LogLevel = iota
+	LogInfo
+	LogWarn
+	LogError
+)
+
+var logLevelNames = map[string]LogLevel{
+	"debug": LogDebug,
+	"info":  LogInfo,
+	"warn":  LogWarn,
+	"error": LogError,
+}
+
+var logLevelPrefixes = map[LogLevel]string{
+	LogDebug: "DEBUG ",
+	LogInfo:  "INFO ",
+	LogWarn:  "WARN ",
+	LogError: "ERROR ",
+}
+
+// CurrentLogLevel is the least severe level that is written to the log
+var CurrentLogLevel = LogInfo
+
+// ParseLogLevel returns the level named by a log.level setting
+func ParseLogLevel(name string) (LogLevel, bool) {
+	level, ok := logLevelNames[name]
+	return level, ok
+}
+
+// LogEnabled reports whether messages of a level are written to the log
+func LogEnabled(level LogLevel) bool {
+	return level >= CurrentLogLevel
+}
+
+// Debugf logs details that are only useful when tracking down a problem
+func Debugf(format string, args ...interface{}) {
+	logf(LogDebug, format, args...)
+}
+
+// Infof logs normal events, such as the server starting or a migration being applied
+func Infof(format string, args ...interface{}) {
+	logf(LogInfo, format, args...)
+}
+
+// Warnf logs problems the server works around, such as a missing optional feature
+func Warnf(format string, args ...interface{}) {
+	logf(LogWarn, format, args...)
+}
+
+// Errorf logs failures, such as a request that could not be served
+func Errorf(format string, args ...interface{}) {
+	logf(LogError, format, args...)
+}
+
+func logf(level LogLevel, format string, args ...interface{}) {
+	if !LogEnabled(level) {
+		return
+	}
+	// calldepth 3 points the file flags, when set, at the caller of Debugf and friends
+	log.Output(3, logLevelPrefixes[level]+fmt.Sprintf(format, args...))
+}
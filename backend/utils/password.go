@@ -1,9 +1,130 @@
 package utils
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"time"
+	"unicode"
+
 	"golang.org/x/crypto/bcrypt"
 )
 
+// PasswordPolicy describes the requirements for account passwords
+type PasswordPolicy struct {
+	MinLength  int
+	MinClasses int           // of lowercase, uppercase, digits and symbols
+	History    int           // recent passwords, including the current one, that may not be reused
+	MaxAge     time.Duration // 0 means passwords never expire
+}
+
+// CurrentPasswordPolicy is the policy applied to new passwords
+var CurrentPasswordPolicy = PasswordPolicy{MinLength: 8, MinClasses: 2, History: 5}
+
+// WeakPasswordError describes why a password does not meet the policy
+type WeakPasswordError struct {
+	Reason string // fmt format of the reason, formatted with Args
+	Args   []interface{}
+}
+
+func (e *WeakPasswordError) Error() string {
+	return fmt.Sprintf(e.Reason, e.Args...)
+}
+
+// Validate returns a *WeakPasswordError if the password does not meet the policy
+func (p PasswordPolicy) Validate(password string) error {
+	if len([]rune(password)) < p.MinLength {
+		return &WeakPasswordError{Reason: "Password must be at least %d characters", Args: []interface{}{p.MinLength}}
+	}
+	if len(password) > 72 {
+		return &WeakPasswordError{Reason: "Password must be at most 72 bytes"}
+	}
+	if characterClasses(password) < p.MinClasses {
+		return &WeakPasswordError{
+			Reason: "Password must contain at least %d of: lowercase letters, uppercase letters, digits, symbols",
+			Args:   []interface{}{p.MinClasses},
+		}
+	}
+	return nil
+}
+
+// Expired reports whether a password last changed at changedAt must be changed now
+func (p PasswordPolicy) Expired(changedAt time.Time) bool {
+	return p.MaxAge > 0 && time.Since(changedAt) > p.MaxAge
+}
+
+// Generate returns a random password that meets the policy
+func (p PasswordPolicy) Generate() (string, error) {
+	// Ambiguous characters such as 0/O and 1/l are left out
+	classes := []string{"abcdefghijkmnpqrstuvwxyz", "ABCDEFGHJKLMNPQRSTUVWXYZ", "23456789", "!@#$%^&*-_=+?"}
+	length := max(p.MinLength, 12)
+
+	// One character from every class, then fill from all of them
+	var all string
+	password := make([]byte, 0, length)
+	for _, class := range classes {
+		all += class
+		c, err := randomChar(class)
+		if err != nil {
+			return "", err
+		}
+		password = append(password, c)
+	}
+	for len(password) < length {
+		c, err := randomChar(all)
+		if err != nil {
+			return "", err
+		}
+		password = append(password, c)
+	}
+
+	// Shuffle so the class characters are not always first
+	for i := len(password) - 1; i > 0; i-- {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return "", err
+		}
+		password[i], password[j.Int64()] = password[j.Int64()], password[i]
+	}
+	return string(password), nil
+}
+
+// characterClasses counts the classes of characters used in a password
+func characterClasses(password string) int {
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+
+	count := 0
+	for _, used := range []bool{lower, upper, digit, symbol} {
+		if used {
+			count++
+		}
+	}
+	return count
+}
+
+// randomChar picks a random byte from an ASCII alphabet
+func randomChar(alphabet string) (byte, error) {
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+	if err != nil {
+		return 0, err
+	}
+	return alphabet[i.Int64()], nil
+}
+
 // HashPassword hashes a password using bcrypt
 func HashPassword(password string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
@@ -18,3 +139,18 @@ func CheckPasswordHash(password, hash string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
 }
+
+// RandomToken returns a random hex-encoded token of n bytes
+func RandomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// HashToken returns the SHA-256 hex digest of a token for storage
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,256 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Spreadsheet formats supported for import and export
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// utf8BOM lets Excel detect UTF-8 when opening a CSV file
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// SheetFormat returns the spreadsheet format for a file name, or an error if unsupported
+func SheetFormat(filename string) (string, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return FormatCSV, nil
+	case ".xlsx":
+		return FormatXLSX, nil
+	default:
+		return "", fmt.Errorf("unsupported file type: %s", filepath.Ext(filename))
+	}
+}
+
+// ReadSheet reads a CSV file or the first worksheet of an .xlsx file into rows of trimmed cells
+func ReadSheet(format string, r io.Reader) ([][]string, error) {
+	var rows [][]string
+
+	switch format {
+	case FormatCSV:
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, utf8BOM)))
+		reader.FieldsPerRecord = -1
+		rows, err = reader.ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse csv: %w", err)
+		}
+	case FormatXLSX:
+		f, err := excelize.OpenReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open xlsx: %w", err)
+		}
+		defer f.Close()
+
+		sheets := f.GetSheetList()
+		if len(sheets) == 0 {
+			return nil, fmt.Errorf("xlsx file has no worksheets")
+		}
+		rows, err = f.GetRows(sheets[0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to read xlsx: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported format: %s", format)
+	}
+
+	for _, row := range rows {
+		for i := range row {
+			row[i] = strings.TrimSpace(row[i])
+		}
+	}
+
+	return rows, nil
+}
+
+// WriteSheet writes rows to w as a CSV file or a single-sheet .xlsx workbook
+func WriteSheet(w io.Writer, format, sheetName string, rows [][]string) error {
+	sw, err := NewSheetWriter(w, format)
+	if err != nil {
+		return err
+	}
+
+	if err := sw.NewSheet(sheetName); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		values := make([]interface{}, len(row))
+		for i, v := range row {
+			values[i] = v
+		}
+		if err := sw.WriteRow(values); err != nil {
+			return err
+		}
+	}
+
+	return sw.Close()
+}
+
+// SheetWriter writes spreadsheet rows one at a time so large exports are not held in memory
+type SheetWriter interface {
+	// NewSheet starts a new worksheet; CSV output supports a single sheet only
+	NewSheet(name string) error
+	// WriteRow appends a row to the current worksheet
+	WriteRow(values []interface{}) error
+	// Close flushes all pending output to the underlying writer
+	Close() error
+}
+
+// NewSheetWriter returns a streaming SheetWriter for the given format
+func NewSheetWriter(w io.Writer, format string) (SheetWriter, error) {
+	switch format {
+	case FormatCSV:
+		return &csvSheetWriter{w: w}, nil
+	case FormatXLSX:
+		return &xlsxSheetWriter{w: w, f: excelize.NewFile()}, nil
+	default:
+		return nil, fmt.Errorf("unsupported format: %s", format)
+	}
+}
+
+type csvSheetWriter struct {
+	w      io.Writer
+	buf    *bufio.Writer
+	writer *csv.Writer
+}
+
+func (s *csvSheetWriter) NewSheet(name string) error {
+	if s.writer != nil {
+		return fmt.Errorf("csv output supports a single sheet only")
+	}
+	// the BOM is buffered with the first rows, so nothing reaches w before them
+	s.buf = bufio.NewWriter(s.w)
+	if _, err := s.buf.Write(utf8BOM); err != nil {
+		return err
+	}
+	s.writer = csv.NewWriter(s.buf)
+	return nil
+}
+
+func (s *csvSheetWriter) WriteRow(values []interface{}) error {
+	if s.writer == nil {
+		return fmt.Errorf("no sheet started")
+	}
+	record := make([]string, len(values))
+	for i, v := range values {
+		if v != nil {
+			record[i] = fmt.Sprint(v)
+		}
+	}
+	return s.writer.Write(record)
+}
+
+func (s *csvSheetWriter) Close() error {
+	if s.writer == nil {
+		return nil
+	}
+	s.writer.Flush()
+	if err := s.writer.Error(); err != nil {
+		return err
+	}
+	return s.buf.Flush()
+}
+
+type xlsxSheetWriter struct {
+	w      io.Writer
+	f      *excelize.File
+	stream *excelize.StreamWriter
+	sheets int
+	row    int
+}
+
+func (s *xlsxSheetWriter) NewSheet(name string) error {
+	if err := s.flush(); err != nil {
+		return err
+	}
+
+	if s.sheets == 0 {
+		if err := s.f.SetSheetName("Sheet1", name); err != nil {
+			return err
+		}
+	} else if _, err := s.f.NewSheet(name); err != nil {
+		return err
+	}
+
+	stream, err := s.f.NewStreamWriter(name)
+	if err != nil {
+		return err
+	}
+	s.stream = stream
+	s.sheets++
+	s.row = 0
+	return nil
+}
+
+func (s *xlsxSheetWriter) WriteRow(values []interface{}) error {
+	if s.stream == nil {
+		return fmt.Errorf("no sheet started")
+	}
+	s.row++
+	cell, err := excelize.CoordinatesToCellName(1, s.row)
+	if err != nil {
+		return err
+	}
+	return s.stream.SetRow(cell, values)
+}
+
+func (s *xlsxSheetWriter) flush() error {
+	if s.stream == nil {
+		return nil
+	}
+	err := s.stream.Flush()
+	s.stream = nil
+	return err
+}
+
+func (s *xlsxSheetWriter) Close() error {
+	defer s.f.Close()
+
+	if err := s.flush(); err != nil {
+		return err
+	}
+	return s.f.Write(s.w)
+}
+
+// SheetName makes a valid, unique worksheet name (at most 31 characters, no []:*?/\)
+func SheetName(name string, used map[string]bool) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if name == "" {
+		name = "Sheet"
+	}
+
+	candidate := truncateRunes(name, 31)
+	for i := 2; used[candidate]; i++ {
+		suffix := fmt.Sprintf("(%d)", i)
+		candidate = truncateRunes(name, 31-len(suffix)) + suffix
+	}
+	used[candidate] = true
+	return candidate
+}
+
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) > n {
+		return string(runes[:n])
+	}
+	return s
+}
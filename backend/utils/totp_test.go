@@ -0,0 +1,142 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 key of the RFC 6238 test vectors, "12345678901234567890",
+// base32-encoded
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// rfc6238Vectors are the SHA-1 vectors of RFC 6238 Appendix B, with 8-digit codes
+var rfc6238Vectors = []struct {
+	unix int64
+	step int64
+	code string
+}{
+	{59, 0x1, "94287082"},
+	{1111111109, 0x23523EC, "07081804"},
+	{1111111111, 0x23523ED, "14050471"},
+	{1234567890, 0x273EF07, "89005924"},
+	{2000000000, 0x3F940AA, "69279037"},
+	{20000000000, 0x27BC86AA, "65353130"},
+}
+
+func TestTOTPRFC6238Vectors(t *testing.T) {
+	key, err := decodeTOTPSecret(rfc6238Secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(key) != "12345678901234567890" {
+		t.Fatalf("decoded key = %q", key)
+	}
+
+	for _, v := range rfc6238Vectors {
+		at := time.Unix(v.unix, 0).UTC()
+		if step := TOTPStep(at); step != v.step {
+			t.Errorf("TOTPStep(%d) = %#x, want %#x", v.unix, step, v.step)
+		}
+		if code := hotp(key, uint64(v.step), 8); code != v.code {
+			t.Errorf("8-digit code at %d = %s, want %s", v.unix, code, v.code)
+		}
+
+		// authenticator apps show the last six digits
+		want := v.code[len(v.code)-TOTPDigits:]
+		code, err := TOTPCode(rfc6238Secret, at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != want {
+			t.Errorf("TOTPCode at %d = %s, want %s", v.unix, code, want)
+		}
+		if step, ok := ValidateTOTP(rfc6238Secret, want, at, 0); !ok || step != v.step {
+			t.Errorf("ValidateTOTP at %d = %#x, %v, want %#x, true", v.unix, step, ok, v.step)
+		}
+	}
+}
+
+func TestValidateTOTPSkew(t *testing.T) {
+	at := time.Unix(1234567890, 0)
+	for _, tc := range []struct {
+		offset time.Duration
+		ok     bool
+	}{
+		{-2 * TOTPPeriod, false},
+		{-TOTPPeriod, true},
+		{0, true},
+		{TOTPPeriod, true},
+		{2 * TOTPPeriod, false},
+	} {
+		code, err := TOTPCode(rfc6238Secret, at.Add(tc.offset))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := ValidateTOTP(rfc6238Secret, code, at, 0); ok != tc.ok {
+			t.Errorf("code from %v away: accepted = %v, want %v", tc.offset, ok, tc.ok)
+		}
+	}
+}
+
+func TestValidateTOTPRejectsReplay(t *testing.T) {
+	at := time.Unix(1234567890, 0)
+	code, err := TOTPCode(rfc6238Secret, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	step, ok := ValidateTOTP(rfc6238Secret, code, at, 0)
+	if !ok {
+		t.Fatal("fresh code rejected")
+	}
+	// the step is stored as totp_last_step once the code is accepted
+	if _, ok := ValidateTOTP(rfc6238Secret, code, at, step); ok {
+		t.Error("code accepted again after its step was used")
+	}
+	if _, ok := ValidateTOTP(rfc6238Secret, code, at.Add(TOTPPeriod), step); ok {
+		t.Error("code accepted again in the next period")
+	}
+
+	next, err := TOTPCode(rfc6238Secret, at.Add(TOTPPeriod))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nextStep, ok := ValidateTOTP(rfc6238Secret, next, at.Add(TOTPPeriod), step); !ok || nextStep != step+1 {
+		t.Errorf("code of the next step = %d, %v, want %d, true", nextStep, ok, step+1)
+	}
+}
+
+func TestValidateTOTPInput(t *testing.T) {
+	at := time.Unix(59, 0)
+	lower := strings.ToLower(rfc6238Secret[:8]) + " " + rfc6238Secret[8:] + "===="
+	for _, tc := range []struct {
+		name, secret, code string
+		ok                 bool
+	}{
+		{"spaces around the code", rfc6238Secret, " 287082 ", true},
+		{"lower-case secret with spaces and padding", lower, "287082", true},
+		{"wrong code", rfc6238Secret, "287083", false},
+		{"eight digits", rfc6238Secret, "94287082", false},
+		{"empty code", rfc6238Secret, "", false},
+		{"invalid secret", "not base32!", "287082", false},
+	} {
+		if _, ok := ValidateTOTP(tc.secret, tc.code, at, 0); ok != tc.ok {
+			t.Errorf("%s: accepted = %v, want %v", tc.name, ok, tc.ok)
+		}
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := decodeTOTPSecret(secret)
+	if err != nil || len(key) != 20 {
+		t.Fatalf("secret %q decodes to %d bytes, %v", secret, len(key), err)
+	}
+	if _, err := TOTPCode(secret, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+}
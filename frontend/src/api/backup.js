@@ -0,0 +1,30 @@
+import * as client from './client'
+
+// 请求由生成的 client.js 发出，路径与类型以后端 OpenAPI 文档为准
+export default {
+  /**
+   * 查询数据库备份列表，最新的在前（管理员）
+   * @param {Object} params - 可选 page、page_size、cursor
+   * @returns {Promise} - 分页结果 { items, total, page, page_size, next_cursor }
+   */
+  getBackups(params = {}) {
+    return client.getBackups(params)
+  },
+
+  /**
+   * 立即备份数据库（管理员）
+   * @returns {Promise} - 包含新备份信息的Promise
+   */
+  createBackup() {
+    return client.createBackup()
+  },
+
+  /**
+   * 下载备份文件，响应头 X-Checksum-SHA256 为文件的校验和（管理员）
+   * @param {string} name - 备份文件名
+   * @returns {Promise} - 包含备份文件的Promise
+   */
+  downloadBackup(name) {
+    return client.downloadBackup(name)
+  }
+}
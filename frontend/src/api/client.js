@@ -0,0 +1,1954 @@
+// Code generated by `go run . openapi -client` in backend/. DO NOT EDIT.
+// 高校教学管理系统 API 1.0.0 的接口客户端，路径相对于 axios.defaults.baseURL（/api）
+
+import axios from 'axios'
+
+/**
+ * @typedef {Object} Backup
+ * @property {string} created_at
+ * @property {string} name
+ * @property {number} schema_version
+ * @property {string} sha256
+ * @property {number} size
+ * @property {string} trigger
+ */
+
+/**
+ * @typedef {Object} BackupPage
+ * @property {?Array<Backup>} items
+ * @property {string} [next_cursor]
+ * @property {number} [page]
+ * @property {number} page_size
+ * @property {number} total
+ */
+
+/**
+ * @typedef {Object} ChangePasswordRequest
+ * @property {string} new_password
+ * @property {string} old_password
+ */
+
+/**
+ * @typedef {Object} Class
+ * @property {string} code
+ * @property {string} created_at
+ * @property {number} id
+ * @property {Major} major
+ * @property {number} major_id
+ * @property {string} name
+ * @property {string} updated_at
+ * @property {number} year
+ */
+
+/**
+ * @typedef {Object} Course
+ * @property {string} code
+ * @property {string} created_at
+ * @property {number} credits
+ * @property {?string} [deleted_at]
+ * @property {Department} department
+ * @property {number} department_id
+ * @property {string} description
+ * @property {number} hours
+ * @property {number} id
+ * @property {string} name
+ * @property {?Array<Course>} prerequisites
+ * @property {string} type
+ * @property {string} updated_at
+ */
+
+/**
+ * @typedef {Object} CourseInput
+ * @property {string} [code]
+ * @property {string} [created_at]
+ * @property {number} [credits]
+ * @property {?string} [deleted_at]
+ * @property {DepartmentInput} [department]
+ * @property {number} [department_id]
+ * @property {string} [description]
+ * @property {number} [hours]
+ * @property {number} [id]
+ * @property {string} [name]
+ * @property {Array<CourseInput>} [prerequisites]
+ * @property {string} [type]
+ * @property {string} [updated_at]
+ */
+
+/**
+ * @typedef {Object} CourseOffering
+ * @property {number} capacity
+ * @property {Course} course
+ * @property {number} course_id
+ * @property {string} created_at
+ * @property {?string} [deleted_at]
+ * @property {string} description
+ * @property {number} id
+ * @property {string} location
+ * @property {string} schedule
+ * @property {Semester} semester
+ * @property {number} semester_id
+ * @property {string} status
+ * @property {Teacher} teacher
+ * @property {number} teacher_id
+ * @property {string} updated_at
+ */
+
+/**
+ * @typedef {Object} CourseOfferingPage
+ * @property {?Array<CourseOffering>} items
+ * @property {string} [next_cursor]
+ * @property {number} [page]
+ * @property {number} page_size
+ * @property {number} total
+ */
+
+/**
+ * @typedef {Object} CoursePage
+ * @property {?Array<Course>} items
+ * @property {string} [next_cursor]
+ * @property {number} [page]
+ * @property {number} page_size
+ * @property {number} total
+ */
+
+/**
+ * @typedef {Object} CourseRequest
+ * @property {string} code
+ * @property {number} [credits]
+ * @property {number} department_id
+ * @property {string} [description]
+ * @property {number} [hours]
+ * @property {string} name
+ * @property {string} [type]
+ */
+
+/**
+ * @typedef {Object} CreateUserRequest
+ * @property {?number} [department_id]
+ * @property {string} [email]
+ * @property {string} name
+ * @property {string} [password]
+ * @property {string} [phone]
+ * @property {Role} role
+ * @property {string} username
+ */
+
+/**
+ * @typedef {Object} CreatedGradeResponse
+ * @property {Enrollment} data
+ * @property {string} message
+ */
+
+/**
+ * @typedef {Object} CreatedUserResponse
+ * @property {string} [password]
+ * @property {?User} user
+ */
+
+/**
+ * @typedef {Object} CurrentUserResponse
+ * @property {string} auth_source
+ * @property {string} created_at
+ * @property {?string} [deleted_at]
+ * @property {?number} [department_id]
+ * @property {boolean} disabled
+ * @property {string} email
+ * @property {number} id
+ * @property {boolean} must_change_password
+ * @property {string} name
+ * @property {string} password_changed_at
+ * @property {?Array<Permission>} permissions
+ * @property {string} phone
+ * @property {Role} role
+ * @property {?Array<Role>} roles
+ * @property {boolean} two_factor_enabled
+ * @property {boolean} [two_factor_setup_required]
+ * @property {string} updated_at
+ * @property {string} username
+ */
+
+/**
+ * @typedef {Object} DegreeAudit
+ * @property {number} earned_credits
+ * @property {?Array<DegreeAuditGroup>} groups
+ * @property {number} in_progress_credits
+ * @property {number} min_credits
+ * @property {string} plan_name
+ * @property {string} status
+ * @property {number} student_id
+ * @property {number} training_plan_id
+ */
+
+/**
+ * @typedef {Object} DegreeAuditCourse
+ * @property {string} code
+ * @property {number} course_id
+ * @property {number} credits
+ * @property {string} name
+ * @property {boolean} required
+ * @property {string} status
+ */
+
+/**
+ * @typedef {Object} DegreeAuditGroup
+ * @property {string} category
+ * @property {?Array<DegreeAuditCourse>} courses
+ * @property {number} earned_credits
+ * @property {number} group_id
+ * @property {number} in_progress_credits
+ * @property {number} min_credits
+ * @property {string} name
+ * @property {string} status
+ */
+
+/**
+ * @typedef {Object} Department
+ * @property {string} code
+ * @property {string} created_at
+ * @property {?string} [deleted_at]
+ * @property {number} id
+ * @property {string} name
+ * @property {string} updated_at
+ */
+
+/**
+ * @typedef {Object} DepartmentInput
+ * @property {string} [code]
+ * @property {string} [created_at]
+ * @property {?string} [deleted_at]
+ * @property {number} [id]
+ * @property {string} [name]
+ * @property {string} [updated_at]
+ */
+
+/**
+ * @typedef {Object} DepartmentPage
+ * @property {?Array<Department>} items
+ * @property {string} [next_cursor]
+ * @property {number} [page]
+ * @property {number} page_size
+ * @property {number} total
+ */
+
+/**
+ * @typedef {Object} DepartmentRequest
+ * @property {string} code
+ * @property {string} name
+ */
+
+/**
+ * @typedef {Object} DepartmentSummary
+ * @property {string} code
+ * @property {number} id
+ * @property {string} name
+ */
+
+/**
+ * @typedef {Object} Dependency
+ * @property {string} column
+ * @property {number} count
+ * @property {string} table
+ */
+
+/**
+ * @typedef {Object} DisableTwoFactorRequest
+ * @property {string} [code]
+ * @property {string} [password]
+ * @property {string} [recovery_code]
+ */
+
+/**
+ * @typedef {Object} Enrollment
+ * @property {CourseOffering} course_offering
+ * @property {number} course_offering_id
+ * @property {string} created_at
+ * @property {number} grade
+ * @property {number} id
+ * @property {?number} retake_of_id
+ * @property {string} status
+ * @property {Student} student
+ * @property {number} student_id
+ * @property {string} updated_at
+ */
+
+/**
+ * @typedef {Object} EnrollmentPage
+ * @property {?Array<Enrollment>} items
+ * @property {string} [next_cursor]
+ * @property {number} [page]
+ * @property {number} page_size
+ * @property {number} total
+ */
+
+/**
+ * @typedef {Object} ErrorResponse
+ * @property {?Array<Dependency>} [blocked_by]
+ * @property {boolean} [challenge_expired]
+ * @property {string} code
+ * @property {?Array<FieldError>} [details]
+ * @property {string} error
+ * @property {?Array<ImportError>} [errors]
+ * @property {boolean} [must_change_password]
+ * @property {number} [retry_after]
+ * @property {boolean} [two_factor_setup_required]
+ */
+
+/**
+ * @typedef {Object} ExamAttempt
+ * @property {string} created_at
+ * @property {Enrollment} enrollment
+ * @property {number} enrollment_id
+ * @property {number} id
+ * @property {?number} raw_score
+ * @property {?number} score
+ * @property {string} status
+ * @property {string} type
+ * @property {string} updated_at
+ */
+
+/**
+ * @typedef {Object} ExamAttemptList
+ * @property {?Array<ExamAttempt>} data
+ */
+
+/**
+ * @typedef {Object} FieldError
+ * @property {string} code
+ * @property {string} field
+ * @property {string} message
+ */
+
+/**
+ * @typedef {Object} GradeImportResult
+ * @property {?Array<GradeImportRow>} data
+ * @property {boolean} [dry_run]
+ * @property {number} [imported]
+ * @property {string} message
+ */
+
+/**
+ * @typedef {Object} GradeImportRow
+ * @property {number} enrollment_id
+ * @property {number} row
+ * @property {?Object<string, number>} scores
+ * @property {string} status
+ * @property {string} student_name
+ * @property {string} student_no
+ * @property {number} total
+ */
+
+/**
+ * @typedef {Object} GradeRequest
+ * @property {number} course_offering_id
+ * @property {number} grade
+ * @property {number} student_id
+ */
+
+/**
+ * @typedef {Object} GraduationOverrideRequest
+ * @property {boolean} eligible
+ * @property {string} justification
+ */
+
+/**
+ * @typedef {Object} GraduationReason
+ * @property {string} code
+ * @property {string} message
+ */
+
+/**
+ * @typedef {Object} GraduationResult
+ * @property {boolean} eligible
+ * @property {boolean} final_eligible
+ * @property {number} id
+ * @property {?number} overridden_by
+ * @property {?boolean} override_eligible
+ * @property {string} override_reason
+ * @property {?Array<GraduationReason>} reasons
+ * @property {number} review_id
+ * @property {number} student_id
+ * @property {string} student_name
+ * @property {string} student_no
+ */
+
+/**
+ * @typedef {Object} GraduationReview
+ * @property {number} cohort_year
+ * @property {?string} confirmed_at
+ * @property {string} created_at
+ * @property {number} created_by
+ * @property {number} eligible_count
+ * @property {number} graduation_year
+ * @property {number} id
+ * @property {number} major_id
+ * @property {?Array<GraduationResult>} [results]
+ * @property {string} status
+ * @property {number} total
+ * @property {string} updated_at
+ */
+
+/**
+ * @typedef {Object} GraduationReviewPage
+ * @property {?Array<GraduationReview>} items
+ * @property {string} [next_cursor]
+ * @property {number} [page]
+ * @property {number} page_size
+ * @property {number} total
+ */
+
+/**
+ * @typedef {Object} GraduationReviewRequest
+ * @property {number} [cohort_year]
+ * @property {number} graduation_year
+ * @property {number} [major_id]
+ */
+
+/**
+ * @typedef {Object} ImportError
+ * @property {string} [column]
+ * @property {string} message
+ * @property {number} row
+ * @property {string} [student_no]
+ * @property {string} [username]
+ */
+
+/**
+ * @typedef {Object} LoginEvent
+ * @property {string} created_at
+ * @property {number} id
+ * @property {string} ip_address
+ * @property {string} [reason]
+ * @property {boolean} success
+ * @property {string} user_agent
+ * @property {?number} user_id
+ * @property {string} username
+ */
+
+/**
+ * @typedef {Object} LoginEventPage
+ * @property {?Array<LoginEvent>} items
+ * @property {string} [next_cursor]
+ * @property {number} [page]
+ * @property {number} page_size
+ * @property {number} total
+ */
+
+/**
+ * @typedef {Object} LoginLockout
+ * @property {number} failures
+ * @property {string} kind
+ * @property {string} locked_until
+ * @property {string} value
+ */
+
+/**
+ * @typedef {Object} LoginLockoutPage
+ * @property {?Array<LoginLockout>} items
+ * @property {string} [next_cursor]
+ * @property {number} [page]
+ * @property {number} page_size
+ * @property {number} total
+ */
+
+/**
+ * @typedef {Object} LoginRequest
+ * @property {string} password
+ * @property {string} username
+ */
+
+/**
+ * @typedef {Object} LoginResponse
+ * @property {number} expires_in
+ * @property {string} refresh_token
+ * @property {string} token
+ * @property {?User} user
+ */
+
+/**
+ * @typedef {Object} Major
+ * @property {string} code
+ * @property {string} created_at
+ * @property {?string} [deleted_at]
+ * @property {Department} department
+ * @property {number} department_id
+ * @property {number} id
+ * @property {string} name
+ * @property {string} updated_at
+ */
+
+/**
+ * @typedef {Object} MajorPage
+ * @property {?Array<Major>} items
+ * @property {string} [next_cursor]
+ * @property {number} [page]
+ * @property {number} page_size
+ * @property {number} total
+ */
+
+/**
+ * @typedef {Object} MakeupRosterRequest
+ * @property {number} [course_offering_id]
+ * @property {number} [semester_id]
+ */
+
+/**
+ * @typedef {Object} MessageResponse
+ * @property {string} message
+ */
+
+/**
+ * @typedef {('department.write'|'department.delete'|'course.write'|'course.delete'|'grade.read'|'grade.write'|'grade.import'|'grade.export'|'grade.retake'|'makeup.read'|'makeup.generate'|'makeup.write'|'training_plan.write'|'training_plan.delete'|'graduation.read'|'graduation.review'|'user.manage'|'session.manage'|'role.manage'|'backup.manage'|'data.purge')} Permission
+ */
+
+/**
+ * @typedef {Object} PermissionInfo
+ * @property {string} description
+ * @property {Permission} name
+ */
+
+/**
+ * @typedef {Object} PlanCourse
+ * @property {Course} course
+ * @property {number} course_id
+ * @property {boolean} required
+ */
+
+/**
+ * @typedef {Object} PlanCourseGroup
+ * @property {string} category
+ * @property {?Array<PlanCourse>} courses
+ * @property {number} id
+ * @property {number} min_credits
+ * @property {string} name
+ * @property {number} training_plan_id
+ */
+
+/**
+ * @typedef {Object} PlanCourseGroupInput
+ * @property {string} [category]
+ * @property {Array<PlanCourseInput>} [courses]
+ * @property {number} [id]
+ * @property {number} [min_credits]
+ * @property {string} [name]
+ * @property {number} [training_plan_id]
+ */
+
+/**
+ * @typedef {Object} PlanCourseInput
+ * @property {CourseInput} [course]
+ * @property {number} [course_id]
+ * @property {boolean} [required]
+ */
+
+/**
+ * @typedef {Object} ProfileRequest
+ * @property {string} [email]
+ * @property {string} name
+ * @property {string} [phone]
+ */
+
+/**
+ * @typedef {Object} RefreshRequest
+ * @property {string} refresh_token
+ */
+
+/**
+ * @typedef {Object} ResetPasswordRequest
+ * @property {string} [password]
+ */
+
+/**
+ * @typedef {Object} RevokedResponse
+ * @property {string} message
+ * @property {number} revoked
+ */
+
+/**
+ * @typedef {('admin'|'academic'|'department'|'teacher'|'student'|'supervisor'|'finance')} Role
+ */
+
+/**
+ * @typedef {Object} RolePermissions
+ * @property {?Array<Permission>} permissions
+ * @property {Role} role
+ */
+
+/**
+ * @typedef {Object} RolePermissionsPage
+ * @property {?Array<RolePermissions>} items
+ * @property {string} [next_cursor]
+ * @property {number} [page]
+ * @property {number} page_size
+ * @property {number} total
+ */
+
+/**
+ * @typedef {Object} RolePermissionsRequest
+ * @property {Array<Permission>} permissions
+ */
+
+/**
+ * @typedef {Object} SSOLoginRequest
+ * @property {string} provider
+ * @property {string} ticket
+ */
+
+/**
+ * @typedef {Object} SSOProvider
+ * @property {string} label
+ * @property {string} login_url
+ * @property {string} name
+ */
+
+/**
+ * @typedef {Object} SearchGroup
+ * @property {?Array<SearchHit>} items
+ * @property {number} total
+ * @property {string} type
+ */
+
+/**
+ * @typedef {Object} SearchHit
+ * @property {string} [code]
+ * @property {string} [detail]
+ * @property {?Object<string, string>} highlight
+ * @property {number} id
+ * @property {string} title
+ */
+
+/**
+ * @typedef {Object} SearchResult
+ * @property {?Array<SearchGroup>} groups
+ * @property {string} query
+ */
+
+/**
+ * @typedef {Object} Semester
+ * @property {string} created_at
+ * @property {boolean} current
+ * @property {string} end_date
+ * @property {number} id
+ * @property {string} name
+ * @property {string} start_date
+ * @property {string} updated_at
+ */
+
+/**
+ * @typedef {Object} Session
+ * @property {string} created_at
+ * @property {boolean} current
+ * @property {string} expires_at
+ * @property {number} id
+ * @property {string} ip_address
+ * @property {?string} last_used_at
+ * @property {?string} [revoked_at]
+ * @property {Role} role
+ * @property {string} user_agent
+ * @property {number} user_id
+ */
+
+/**
+ * @typedef {Object} SessionPage
+ * @property {?Array<Session>} items
+ * @property {string} [next_cursor]
+ * @property {number} [page]
+ * @property {number} page_size
+ * @property {number} total
+ */
+
+/**
+ * @typedef {Object} SetupRequest
+ * @property {string} [email]
+ * @property {string} name
+ * @property {string} password
+ * @property {string} [phone]
+ * @property {string} setup_token
+ * @property {string} username
+ */
+
+/**
+ * @typedef {Object} Student
+ * @property {Class} class
+ * @property {number} class_id
+ * @property {string} created_at
+ * @property {number} enroll_year
+ * @property {number} id
+ * @property {string} status
+ * @property {string} student_id
+ * @property {string} updated_at
+ * @property {User} user
+ * @property {number} user_id
+ */
+
+/**
+ * @typedef {Object} Teacher
+ * @property {string} created_at
+ * @property {Department} department
+ * @property {number} department_id
+ * @property {number} id
+ * @property {string} title
+ * @property {string} updated_at
+ * @property {User} user
+ * @property {number} user_id
+ */
+
+/**
+ * @typedef {Object} TokenResponse
+ * @property {number} expires_in
+ * @property {string} refresh_token
+ * @property {string} token
+ */
+
+/**
+ * @typedef {Object} TrainingPlan
+ * @property {number} cohort_year
+ * @property {string} created_at
+ * @property {?Array<PlanCourseGroup>} groups
+ * @property {number} id
+ * @property {Major} major
+ * @property {number} major_id
+ * @property {number} min_credits
+ * @property {string} name
+ * @property {string} updated_at
+ */
+
+/**
+ * @typedef {Object} TrainingPlanPage
+ * @property {?Array<TrainingPlan>} items
+ * @property {string} [next_cursor]
+ * @property {number} [page]
+ * @property {number} page_size
+ * @property {number} total
+ */
+
+/**
+ * @typedef {Object} TrainingPlanRequest
+ * @property {number} cohort_year
+ * @property {Array<PlanCourseGroupInput>} [groups]
+ * @property {number} major_id
+ * @property {number} [min_credits]
+ * @property {string} name
+ */
+
+/**
+ * @typedef {Object} Transcript
+ * @property {number} earned_credits
+ * @property {?Array<TranscriptEntry>} entries
+ * @property {number} gpa
+ * @property {string} policy
+ * @property {number} student_id
+ */
+
+/**
+ * @typedef {Object} TranscriptData
+ * @property {Transcript} data
+ */
+
+/**
+ * @typedef {Object} TranscriptEntry
+ * @property {string} attempt_type
+ * @property {boolean} counted
+ * @property {string} course_code
+ * @property {number} course_id
+ * @property {string} course_name
+ * @property {number} credits
+ * @property {number} enrollment_id
+ * @property {number} grade_point
+ * @property {boolean} passed
+ * @property {number} score
+ * @property {string} semester_name
+ */
+
+/**
+ * @typedef {Object} TwoFactorChallengeResponse
+ * @property {string} challenge_token
+ * @property {number} expires_in
+ * @property {boolean} two_factor_required
+ */
+
+/**
+ * @typedef {Object} TwoFactorCodeRequest
+ * @property {string} code
+ */
+
+/**
+ * @typedef {Object} TwoFactorLoginRequest
+ * @property {string} challenge_token
+ * @property {string} [code]
+ * @property {string} [recovery_code]
+ */
+
+/**
+ * @typedef {Object} TwoFactorSetupResponse
+ * @property {string} otpauth_uri
+ * @property {string} secret
+ */
+
+/**
+ * @typedef {Object} TwoFactorStatus
+ * @property {boolean} enabled
+ * @property {number} recovery_codes_remaining
+ * @property {boolean} required
+ */
+
+/**
+ * @typedef {Object} User
+ * @property {string} auth_source
+ * @property {string} created_at
+ * @property {?string} [deleted_at]
+ * @property {?number} [department_id]
+ * @property {boolean} disabled
+ * @property {string} email
+ * @property {number} id
+ * @property {boolean} must_change_password
+ * @property {string} name
+ * @property {string} password_changed_at
+ * @property {string} phone
+ * @property {Role} role
+ * @property {boolean} two_factor_enabled
+ * @property {boolean} [two_factor_setup_required]
+ * @property {string} updated_at
+ * @property {string} username
+ */
+
+/**
+ * @typedef {Object} UserImportResult
+ * @property {?Array<UserImportRow>} data
+ * @property {boolean} [dry_run]
+ * @property {number} [imported]
+ * @property {string} message
+ */
+
+/**
+ * @typedef {Object} UserImportRow
+ * @property {number} [class_id]
+ * @property {number} [department_id]
+ * @property {string} [email]
+ * @property {number} [enroll_year]
+ * @property {string} name
+ * @property {string} [password]
+ * @property {string} [phone]
+ * @property {number} row
+ * @property {string} [student_no]
+ * @property {string} [title]
+ * @property {number} [user_id]
+ * @property {string} username
+ */
+
+/**
+ * @typedef {Object} UserPage
+ * @property {?Array<User>} items
+ * @property {string} [next_cursor]
+ * @property {number} [page]
+ * @property {number} page_size
+ * @property {number} total
+ */
+
+/**
+ * @typedef {Object} UserRoleRequest
+ * @property {?number} [department_id]
+ * @property {Role} role
+ */
+
+/**
+ * @typedef {Object} UserRolesRequest
+ * @property {Array<Role>} roles
+ */
+
+/**
+ * @typedef {Object} UserStatusRequest
+ * @property {boolean} disabled
+ */
+
+// 认证
+
+/**
+ * 用户名密码登录
+ * @param {LoginRequest} data
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<(LoginResponse|TwoFactorChallengeResponse)>>}
+ */
+export function login(data, config = {}) {
+  return axios.post('/login', data, config)
+}
+
+/**
+ * 提交两步验证码完成登录
+ * @param {TwoFactorLoginRequest} data
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<LoginResponse>>}
+ */
+export function loginTwoFactor(data, config = {}) {
+  return axios.post('/login/2fa', data, config)
+}
+
+/**
+ * 统一身份认证（CAS / OIDC）登录
+ * @param {SSOLoginRequest} data
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<(LoginResponse|TwoFactorChallengeResponse)>>}
+ */
+export function loginSSO(data, config = {}) {
+  return axios.post('/login/sso', data, config)
+}
+
+/**
+ * 获取统一身份认证登录入口
+ * @param {Object} [params] - 查询参数
+ * @param {string} [params.state] - 随机值，OIDC 回跳时原样带回
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<{ sso: ?Array<SSOProvider> }>>}
+ */
+export function getAuthProviders(params = {}, config = {}) {
+  return axios.get('/auth/providers', { ...config, params })
+}
+
+/**
+ * 用刷新令牌换取新的令牌
+ * @param {RefreshRequest} data
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<TokenResponse>>}
+ */
+export function refreshToken(data, config = {}) {
+  return axios.post('/refresh', data, config)
+}
+
+/**
+ * 首次安装时创建管理员
+ * @param {SetupRequest} data
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<{ message: string, user: ?User }>>}
+ */
+export function setup(data, config = {}) {
+  return axios.post('/setup', data, config)
+}
+
+// 当前用户
+
+/**
+ * 获取当前用户（含角色与权限）
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<CurrentUserResponse>>}
+ */
+export function getCurrentUser(config = {}) {
+  return axios.get('/user', config)
+}
+
+/**
+ * 修改个人资料
+ * @param {ProfileRequest} data
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<User>>}
+ */
+export function updateProfile(data, config = {}) {
+  return axios.put('/user/profile', data, config)
+}
+
+/**
+ * 修改密码
+ * @param {ChangePasswordRequest} data
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<MessageResponse>>}
+ */
+export function changePassword(data, config = {}) {
+  return axios.put('/user/password', data, config)
+}
+
+/**
+ * 获取本人的登录记录
+ * @param {Object} [params] - 查询参数
+ * @param {number} [params.page] - 页码，从1开始
+ * @param {number} [params.page_size] - 每页条数
+ * @param {string} [params.cursor] - 游标分页：上一页返回的 next_cursor
+ * @param {string} [params.sort] - 排序字段，逗号分隔，前缀 - 表示降序
+ * @param {string} [params.q] - 搜索关键字
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<LoginEventPage>>}
+ */
+export function getMyLoginEvents(params = {}, config = {}) {
+  return axios.get('/user/login-events', { ...config, params })
+}
+
+// 两步验证
+
+/**
+ * 获取两步验证状态
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<TwoFactorStatus>>}
+ */
+export function getTwoFactorStatus(config = {}) {
+  return axios.get('/user/2fa', config)
+}
+
+/**
+ * 生成新的 TOTP 密钥
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<TwoFactorSetupResponse>>}
+ */
+export function setupTwoFactor(config = {}) {
+  return axios.post('/user/2fa/setup', null, config)
+}
+
+/**
+ * 确认验证码并启用两步验证
+ * @param {TwoFactorCodeRequest} data
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<{ message: string, recovery_codes: ?Array<string> }>>}
+ */
+export function enableTwoFactor(data, config = {}) {
+  return axios.post('/user/2fa/enable', data, config)
+}
+
+/**
+ * 关闭两步验证
+ * @param {DisableTwoFactorRequest} data
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<MessageResponse>>}
+ */
+export function disableTwoFactor(data, config = {}) {
+  return axios.post('/user/2fa/disable', data, config)
+}
+
+/**
+ * 重新生成恢复码
+ * @param {TwoFactorCodeRequest} data
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<{ recovery_codes: ?Array<string> }>>}
+ */
+export function regenerateRecoveryCodes(data, config = {}) {
+  return axios.post('/user/2fa/recovery-codes', data, config)
+}
+
+// 用户管理
+
+/**
+ * 用户列表
+ * @param {Object} [params] - 查询参数
+ * @param {number} [params.page] - 页码，从1开始
+ * @param {number} [params.page_size] - 每页条数
+ * @param {string} [params.cursor] - 游标分页：上一页返回的 next_cursor
+ * @param {string} [params.sort] - 排序字段，逗号分隔，前缀 - 表示降序
+ * @param {string} [params.q] - 搜索关键字
+ * @param {string} [params.role] - 按角色筛选
+ * @param {number} [params.department_id] - 按院系筛选
+ * @param {boolean} [params.disabled] - 按是否停用筛选
+ * @param {boolean} [params.deleted] - 为 true 时列出已删除的数据
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<UserPage>>}
+ */
+export function getUsers(params = {}, config = {}) {
+  return axios.get('/users', { ...config, params })
+}
+
+/**
+ * 获取用户
+ * @param {number} id
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<User>>}
+ */
+export function getUser(id, config = {}) {
+  return axios.get(`/users/${id}`, config)
+}
+
+/**
+ * 创建用户
+ * @param {CreateUserRequest} data
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<CreatedUserResponse>>}
+ */
+export function createUser(data, config = {}) {
+  return axios.post('/users', data, config)
+}
+
+/**
+ * 批量导入学生或教师账号
+ * @param {FormData} data - 含 file 文件字段的表单
+ * @param {Object} params - 查询参数
+ * @param {('student'|'teacher')} params.role - 导入的账号角色
+ * @param {boolean} [params.dry_run] - 为 true 时只校验并返回预览
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<UserImportResult>>}
+ */
+export function importUsers(data, params, config = {}) {
+  return axios.post('/users/import', data, { ...config, params })
+}
+
+/**
+ * 下载账号导入模板
+ * @param {Object} params - 查询参数
+ * @param {('student'|'teacher')} params.role - 导入的账号角色
+ * @param {('xlsx'|'csv')} [params.format] - 文件格式，默认 xlsx
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<Blob>>}
+ */
+export function getUserImportTemplate(params, config = {}) {
+  return axios.get('/users/import/template', { ...config, params, responseType: 'blob' })
+}
+
+/**
+ * 修改用户资料
+ * @param {number} id
+ * @param {ProfileRequest} data
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<User>>}
+ */
+export function updateUser(id, data, config = {}) {
+  return axios.put(`/users/${id}`, data, config)
+}
+
+/**
+ * 修改用户主角色
+ * @param {number} id
+ * @param {UserRoleRequest} data
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<MessageResponse>>}
+ */
+export function updateUserRole(id, data, config = {}) {
+  return axios.put(`/users/${id}/role`, data, config)
+}
+
+/**
+ * 停用或启用用户
+ * @param {number} id
+ * @param {UserStatusRequest} data
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<MessageResponse>>}
+ */
+export function updateUserStatus(id, data, config = {}) {
+  return axios.put(`/users/${id}/status`, data, config)
+}
+
+/**
+ * 删除用户（可恢复）
+ * @param {number} id
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<MessageResponse>>}
+ */
+export function deleteUser(id, config = {}) {
+  return axios.delete(`/users/${id}`, config)
+}
+
+/**
+ * 恢复已删除的用户
+ * @param {number} id
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<MessageResponse>>}
+ */
+export function restoreUser(id, config = {}) {
+  return axios.post(`/users/${id}/restore`, null, config)
+}
+
+/**
+ * 彻底删除已删除的用户
+ * @param {number} id
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<MessageResponse>>}
+ */
+export function purgeUser(id, config = {}) {
+  return axios.delete(`/users/${id}/purge`, config)
+}
+
+/**
+ * 重置用户密码
+ * @param {number} id
+ * @param {ResetPasswordRequest} [data]
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<{ message: string, password?: string }>>}
+ */
+export function resetUserPassword(id, data, config = {}) {
+  return axios.post(`/users/${id}/reset-password`, data, config)
+}
+
+/**
+ * 解除用户的登录锁定
+ * @param {number} id
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<MessageResponse>>}
+ */
+export function unlockUser(id, config = {}) {
+  return axios.post(`/users/${id}/unlock`, null, config)
+}
+
+/**
+ * 重置用户的两步验证
+ * @param {number} id
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<MessageResponse>>}
+ */
+export function resetUserTwoFactor(id, config = {}) {
+  return axios.delete(`/users/${id}/2fa`, config)
+}
+
+/**
+ * 强制用户在所有设备下线
+ * @param {number} id
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<RevokedResponse>>}
+ */
+export function forceLogoutUser(id, config = {}) {
+  return axios.post(`/users/${id}/logout`, null, config)
+}
+
+/**
+ * 获取用户的全部角色
+ * @param {number} id
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<?Array<Role>>>}
+ */
+export function getUserRoles(id, config = {}) {
+  return axios.get(`/users/${id}/roles`, config)
+}
+
+/**
+ * 设置用户的附加角色
+ * @param {number} id
+ * @param {UserRolesRequest} data
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<?Array<Role>>>}
+ */
+export function updateUserRoles(id, data, config = {}) {
+  return axios.put(`/users/${id}/roles`, data, config)
+}
+
+// 登录安全
+
+/**
+ * 登录记录
+ * @param {Object} [params] - 查询参数
+ * @param {number} [params.page] - 页码，从1开始
+ * @param {number} [params.page_size] - 每页条数
+ * @param {string} [params.cursor] - 游标分页：上一页返回的 next_cursor
+ * @param {string} [params.sort] - 排序字段，逗号分隔，前缀 - 表示降序
+ * @param {string} [params.q] - 搜索关键字
+ * @param {string} [params.username] - 按用户名筛选
+ * @param {string} [params.ip] - 按客户端 IP 筛选
+ * @param {boolean} [params.success] - 按是否成功筛选
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<LoginEventPage>>}
+ */
+export function getLoginEvents(params = {}, config = {}) {
+  return axios.get('/login-events', { ...config, params })
+}
+
+/**
+ * 当前被锁定的用户名与 IP
+ * @param {Object} [params] - 查询参数
+ * @param {number} [params.page] - 页码，从1开始
+ * @param {number} [params.page_size] - 每页条数
+ * @param {string} [params.cursor] - 游标分页：上一页返回的 next_cursor
+ * @param {string} [params.sort] - 排序字段，逗号分隔，前缀 - 表示降序
+ * @param {string} [params.q] - 搜索关键字
+ * @param {('user'|'ip')} [params.kind] - 锁定类型
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<LoginLockoutPage>>}
+ */
+export function getLoginLockouts(params = {}, config = {}) {
+  return axios.get('/login-lockouts', { ...config, params })
+}
+
+/**
+ * 解除 IP 的登录锁定
+ * @param {string} ip
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<MessageResponse>>}
+ */
+export function unlockIP(ip, config = {}) {
+  return axios.delete(`/login-lockouts/ip/${encodeURIComponent(ip)}`, config)
+}
+
+// 会话
+
+/**
+ * 退出当前会话
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<MessageResponse>>}
+ */
+export function logout(config = {}) {
+  return axios.post('/logout', null, config)
+}
+
+/**
+ * 退出所有设备
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<RevokedResponse>>}
+ */
+export function logoutAll(config = {}) {
+  return axios.post('/logout/all', null, config)
+}
+
+/**
+ * 当前用户的登录会话
+ * @param {Object} [params] - 查询参数
+ * @param {number} [params.page] - 页码，从1开始
+ * @param {number} [params.page_size] - 每页条数
+ * @param {string} [params.cursor] - 游标分页：上一页返回的 next_cursor
+ * @param {string} [params.sort] - 排序字段，逗号分隔，前缀 - 表示降序
+ * @param {string} [params.q] - 搜索关键字
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<SessionPage>>}
+ */
+export function getSessions(params = {}, config = {}) {
+  return axios.get('/sessions', { ...config, params })
+}
+
+/**
+ * 注销一个会话
+ * @param {number} id
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<MessageResponse>>}
+ */
+export function revokeSession(id, config = {}) {
+  return axios.delete(`/sessions/${id}`, config)
+}
+
+// 院系
+
+/**
+ * 院系列表
+ * @param {Object} [params] - 查询参数
+ * @param {number} [params.page] - 页码，从1开始
+ * @param {number} [params.page_size] - 每页条数
+ * @param {string} [params.cursor] - 游标分页：上一页返回的 next_cursor
+ * @param {string} [params.sort] - 排序字段，逗号分隔，前缀 - 表示降序
+ * @param {string} [params.q] - 搜索关键字
+ * @param {boolean} [params.deleted] - 为 true 时列出已删除的数据
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<DepartmentPage>>}
+ */
+export function getDepartments(params = {}, config = {}) {
+  return axios.get('/departments', { ...config, params })
+}
+
+/**
+ * 获取院系
+ * @param {number} id
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<Department>>}
+ */
+export function getDepartment(id, config = {}) {
+  return axios.get(`/departments/${id}`, config)
+}
+
+/**
+ * 创建院系
+ * @param {DepartmentRequest} data
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<DepartmentSummary>>}
+ */
+export function createDepartment(data, config = {}) {
+  return axios.post('/departments', data, config)
+}
+
+/**
+ * 修改院系
+ * @param {number} id
+ * @param {DepartmentRequest} data
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<DepartmentSummary>>}
+ */
+export function updateDepartment(id, data, config = {}) {
+  return axios.put(`/departments/${id}`, data, config)
+}
+
+/**
+ * 删除院系（可恢复）
+ * @param {number} id
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<MessageResponse>>}
+ */
+export function deleteDepartment(id, config = {}) {
+  return axios.delete(`/departments/${id}`, config)
+}
+
+/**
+ * 恢复已删除的院系
+ * @param {number} id
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<MessageResponse>>}
+ */
+export function restoreDepartment(id, config = {}) {
+  return axios.post(`/departments/${id}/restore`, null, config)
+}
+
+/**
+ * 彻底删除已删除的院系
+ * @param {number} id
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<MessageResponse>>}
+ */
+export function purgeDepartment(id, config = {}) {
+  return axios.delete(`/departments/${id}/purge`, config)
+}
+
+// 专业
+
+/**
+ * 专业列表
+ * @param {Object} [params] - 查询参数
+ * @param {number} [params.page] - 页码，从1开始
+ * @param {number} [params.page_size] - 每页条数
+ * @param {string} [params.cursor] - 游标分页：上一页返回的 next_cursor
+ * @param {string} [params.sort] - 排序字段，逗号分隔，前缀 - 表示降序
+ * @param {string} [params.q] - 搜索关键字
+ * @param {number} [params.department_id] - 按院系筛选
+ * @param {boolean} [params.deleted] - 为 true 时列出已删除的数据
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<MajorPage>>}
+ */
+export function getMajors(params = {}, config = {}) {
+  return axios.get('/majors', { ...config, params })
+}
+
+/**
+ * 删除专业（可恢复）
+ * @param {number} id
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<MessageResponse>>}
+ */
+export function deleteMajor(id, config = {}) {
+  return axios.delete(`/majors/${id}`, config)
+}
+
+/**
+ * 恢复已删除的专业
+ * @param {number} id
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<MessageResponse>>}
+ */
+export function restoreMajor(id, config = {}) {
+  return axios.post(`/majors/${id}/restore`, null, config)
+}
+
+/**
+ * 彻底删除已删除的专业
+ * @param {number} id
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<MessageResponse>>}
+ */
+export function purgeMajor(id, config = {}) {
+  return axios.delete(`/majors/${id}/purge`, config)
+}
+
+// 课程
+
+/**
+ * 课程列表
+ * @param {Object} [params] - 查询参数
+ * @param {number} [params.page] - 页码，从1开始
+ * @param {number} [params.page_size] - 每页条数
+ * @param {string} [params.cursor] - 游标分页：上一页返回的 next_cursor
+ * @param {string} [params.sort] - 排序字段，逗号分隔，前缀 - 表示降序
+ * @param {string} [params.q] - 搜索关键字
+ * @param {string} [params.type] - 按课程类型筛选
+ * @param {number} [params.department_id] - 按院系筛选
+ * @param {number} [params.min_credits] - 最低学分
+ * @param {number} [params.max_credits] - 最高学分
+ * @param {boolean} [params.deleted] - 为 true 时列出已删除的数据
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<CoursePage>>}
+ */
+export function getCourses(params = {}, config = {}) {
+  return axios.get('/courses', { ...config, params })
+}
+
+/**
+ * 获取课程
+ * @param {number} id
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<Course>>}
+ */
+export function getCourse(id, config = {}) {
+  return axios.get(`/courses/${id}`, config)
+}
+
+/**
+ * 创建课程
+ * @param {CourseRequest} data
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<Course>>}
+ */
+export function createCourse(data, config = {}) {
+  return axios.post('/courses', data, config)
+}
+
+/**
+ * 修改课程
+ * @param {number} id
+ * @param {CourseRequest} data
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<Course>>}
+ */
+export function updateCourse(id, data, config = {}) {
+  return axios.put(`/courses/${id}`, data, config)
+}
+
+/**
+ * 删除课程（可恢复）
+ * @param {number} id
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<MessageResponse>>}
+ */
+export function deleteCourse(id, config = {}) {
+  return axios.delete(`/courses/${id}`, config)
+}
+
+/**
+ * 恢复已删除的课程
+ * @param {number} id
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<MessageResponse>>}
+ */
+export function restoreCourse(id, config = {}) {
+  return axios.post(`/courses/${id}/restore`, null, config)
+}
+
+/**
+ * 彻底删除已删除的课程
+ * @param {number} id
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<MessageResponse>>}
+ */
+export function purgeCourse(id, config = {}) {
+  return axios.delete(`/courses/${id}/purge`, config)
+}
+
+// 开课
+
+/**
+ * 开课列表
+ * @param {Object} [params] - 查询参数
+ * @param {number} [params.page] - 页码，从1开始
+ * @param {number} [params.page_size] - 每页条数
+ * @param {string} [params.cursor] - 游标分页：上一页返回的 next_cursor
+ * @param {string} [params.sort] - 排序字段，逗号分隔，前缀 - 表示降序
+ * @param {string} [params.q] - 搜索关键字
+ * @param {string} [params.status] - 按状态筛选
+ * @param {number} [params.semester_id] - 按学期筛选
+ * @param {number} [params.course_id] - 按课程筛选
+ * @param {number} [params.teacher_id] - 按授课教师筛选
+ * @param {boolean} [params.deleted] - 为 true 时列出已删除的数据
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<CourseOfferingPage>>}
+ */
+export function getCourseOfferings(params = {}, config = {}) {
+  return axios.get('/course-offerings', { ...config, params })
+}
+
+/**
+ * 删除开课（可恢复）
+ * @param {number} id
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<MessageResponse>>}
+ */
+export function deleteCourseOffering(id, config = {}) {
+  return axios.delete(`/course-offerings/${id}`, config)
+}
+
+/**
+ * 恢复已删除的开课
+ * @param {number} id
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<MessageResponse>>}
+ */
+export function restoreCourseOffering(id, config = {}) {
+  return axios.post(`/course-offerings/${id}/restore`, null, config)
+}
+
+/**
+ * 彻底删除已删除的开课
+ * @param {number} id
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<MessageResponse>>}
+ */
+export function purgeCourseOffering(id, config = {}) {
+  return axios.delete(`/course-offerings/${id}/purge`, config)
+}
+
+// 成绩
+
+/**
+ * 获取学生成绩
+ * @param {Object} [params] - 查询参数
+ * @param {number} [params.page] - 页码，从1开始
+ * @param {number} [params.page_size] - 每页条数
+ * @param {string} [params.cursor] - 游标分页：上一页返回的 next_cursor
+ * @param {string} [params.sort] - 排序字段，逗号分隔，前缀 - 表示降序
+ * @param {string} [params.q] - 搜索关键字
+ * @param {number} [params.student_id] - 学生ID，学生本人查询时可省略
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<EnrollmentPage>>}
+ */
+export function getStudentGrades(params = {}, config = {}) {
+  return axios.get('/grades/student', { ...config, params })
+}
+
+/**
+ * 获取课程的所有学生成绩
+ * @param {Object} params - 查询参数
+ * @param {number} [params.page] - 页码，从1开始
+ * @param {number} [params.page_size] - 每页条数
+ * @param {string} [params.cursor] - 游标分页：上一页返回的 next_cursor
+ * @param {string} [params.sort] - 排序字段，逗号分隔，前缀 - 表示降序
+ * @param {string} [params.q] - 搜索关键字
+ * @param {number} params.course_offering_id - 开课ID
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<EnrollmentPage>>}
+ */
+export function getCourseGrades(params, config = {}) {
+  return axios.get('/grades/course', { ...config, params })
+}
+
+/**
+ * 录入成绩
+ * @param {GradeRequest} data
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<CreatedGradeResponse>>}
+ */
+export function createGrade(data, config = {}) {
+  return axios.post('/grades', data, config)
+}
+
+/**
+ * 修改成绩
+ * @param {number} id
+ * @param {{ grade: number }} data
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<MessageResponse>>}
+ */
+export function updateGrade(id, data, config = {}) {
+  return axios.put(`/grades/${id}`, data, config)
+}
+
+/**
+ * 批量导入成绩（.xlsx 或 .csv）
+ * @param {FormData} data - 含 file 文件字段的表单
+ * @param {Object} params - 查询参数
+ * @param {number} params.course_offering_id - 开课ID
+ * @param {boolean} [params.dry_run] - 为 true 时只校验并返回预览
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<GradeImportResult>>}
+ */
+export function importGrades(data, params, config = {}) {
+  return axios.post('/grades/import', data, { ...config, params })
+}
+
+/**
+ * 下载预填学生名单的成绩导入模板
+ * @param {Object} params - 查询参数
+ * @param {number} params.course_offering_id - 开课ID
+ * @param {('xlsx'|'csv')} [params.format] - 文件格式，默认 xlsx
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<Blob>>}
+ */
+export function getGradeImportTemplate(params, config = {}) {
+  return axios.get('/grades/import/template', { ...config, params, responseType: 'blob' })
+}
+
+/**
+ * 导出成绩表
+ * @param {Object} [params] - 查询参数
+ * @param {number} [params.course_offering_id] - 开课ID
+ * @param {number} [params.semester_id] - 学期ID
+ * @param {number} [params.department_id] - 院系ID
+ * @param {('xlsx'|'csv')} [params.format] - 文件格式，默认 xlsx
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<Blob>>}
+ */
+export function exportGrades(params = {}, config = {}) {
+  return axios.get('/grades/export', { ...config, params, responseType: 'blob' })
+}
+
+/**
+ * 获取学生成绩单
+ * @param {Object} [params] - 查询参数
+ * @param {number} [params.student_id] - 学生ID，学生本人查询时可省略
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<TranscriptData>>}
+ */
+export function getTranscript(params = {}, config = {}) {
+  return axios.get('/grades/transcript', { ...config, params })
+}
+
+/**
+ * 为未通过的课程登记重修
+ * @param {number} id
+ * @param {{ course_offering_id: number }} data
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<{ id: number, message: string }>>}
+ */
+export function createRetake(id, data, config = {}) {
+  return axios.post(`/grades/${id}/retake`, data, config)
+}
+
+// 补考
+
+/**
+ * 获取补考名单
+ * @param {Object} [params] - 查询参数
+ * @param {number} [params.semester_id] - 学期ID
+ * @param {number} [params.course_offering_id] - 开课ID
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<ExamAttemptList>>}
+ */
+export function getMakeupRoster(params = {}, config = {}) {
+  return axios.get('/makeup-exams', { ...config, params })
+}
+
+/**
+ * 根据未通过的成绩生成补考名单
+ * @param {MakeupRosterRequest} data
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<{ created: number, message: string }>>}
+ */
+export function generateMakeupRoster(data, config = {}) {
+  return axios.post('/makeup-exams/generate', data, config)
+}
+
+/**
+ * 录入补考成绩
+ * @param {number} id
+ * @param {{ score: number }} data
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<MessageResponse>>}
+ */
+export function recordMakeupScore(id, data, config = {}) {
+  return axios.put(`/makeup-exams/${id}`, data, config)
+}
+
+// 培养方案
+
+/**
+ * 培养方案列表
+ * @param {Object} [params] - 查询参数
+ * @param {number} [params.page] - 页码，从1开始
+ * @param {number} [params.page_size] - 每页条数
+ * @param {string} [params.cursor] - 游标分页：上一页返回的 next_cursor
+ * @param {string} [params.sort] - 排序字段，逗号分隔，前缀 - 表示降序
+ * @param {string} [params.q] - 搜索关键字
+ * @param {number} [params.major_id] - 按专业筛选
+ * @param {number} [params.cohort_year] - 按入学年份筛选
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<TrainingPlanPage>>}
+ */
+export function getTrainingPlans(params = {}, config = {}) {
+  return axios.get('/training-plans', { ...config, params })
+}
+
+/**
+ * 获取培养方案（含课程组）
+ * @param {number} id
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<TrainingPlan>>}
+ */
+export function getTrainingPlan(id, config = {}) {
+  return axios.get(`/training-plans/${id}`, config)
+}
+
+/**
+ * 创建培养方案
+ * @param {TrainingPlanRequest} data
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<TrainingPlan>>}
+ */
+export function createTrainingPlan(data, config = {}) {
+  return axios.post('/training-plans', data, config)
+}
+
+/**
+ * 修改培养方案
+ * @param {number} id
+ * @param {TrainingPlanRequest} data
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<TrainingPlan>>}
+ */
+export function updateTrainingPlan(id, data, config = {}) {
+  return axios.put(`/training-plans/${id}`, data, config)
+}
+
+/**
+ * 删除培养方案
+ * @param {number} id
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<MessageResponse>>}
+ */
+export function deleteTrainingPlan(id, config = {}) {
+  return axios.delete(`/training-plans/${id}`, config)
+}
+
+/**
+ * 学位审核：对照培养方案检查学生修读情况
+ * @param {Object} [params] - 查询参数
+ * @param {number} [params.student_id] - 学生ID，学生本人查询时可省略
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<DegreeAudit>>}
+ */
+export function getDegreeAudit(params = {}, config = {}) {
+  return axios.get('/degree-audit', { ...config, params })
+}
+
+// 毕业审核
+
+/**
+ * 毕业审核批次列表
+ * @param {Object} [params] - 查询参数
+ * @param {number} [params.page] - 页码，从1开始
+ * @param {number} [params.page_size] - 每页条数
+ * @param {string} [params.cursor] - 游标分页：上一页返回的 next_cursor
+ * @param {string} [params.sort] - 排序字段，逗号分隔，前缀 - 表示降序
+ * @param {string} [params.q] - 搜索关键字
+ * @param {('draft'|'confirmed')} [params.status] - 按状态筛选
+ * @param {number} [params.graduation_year] - 按毕业年份筛选
+ * @param {number} [params.cohort_year] - 按入学年份筛选
+ * @param {number} [params.major_id] - 按专业筛选
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<GraduationReviewPage>>}
+ */
+export function getGraduationReviews(params = {}, config = {}) {
+  return axios.get('/graduation-reviews', { ...config, params })
+}
+
+/**
+ * 获取毕业审核批次（含每个学生的结果）
+ * @param {number} id
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<GraduationReview>>}
+ */
+export function getGraduationReview(id, config = {}) {
+  return axios.get(`/graduation-reviews/${id}`, config)
+}
+
+/**
+ * 执行毕业审核
+ * @param {GraduationReviewRequest} data
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<GraduationReview>>}
+ */
+export function createGraduationReview(data, config = {}) {
+  return axios.post('/graduation-reviews', data, config)
+}
+
+/**
+ * 人工调整学生的审核结果
+ * @param {number} id
+ * @param {number} studentId
+ * @param {GraduationOverrideRequest} data
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<MessageResponse>>}
+ */
+export function overrideGraduationResult(id, studentId, data, config = {}) {
+  return axios.put(`/graduation-reviews/${id}/results/${studentId}`, data, config)
+}
+
+/**
+ * 确认审核结果并将合格学生标记为已毕业
+ * @param {number} id
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<{ graduated: number, message: string }>>}
+ */
+export function confirmGraduationReview(id, config = {}) {
+  return axios.post(`/graduation-reviews/${id}/confirm`, null, config)
+}
+
+// 角色权限
+
+/**
+ * 全部权限
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<?Array<PermissionInfo>>>}
+ */
+export function getPermissions(config = {}) {
+  return axios.get('/permissions', config)
+}
+
+/**
+ * 全部角色及其权限
+ * @param {Object} [params] - 查询参数
+ * @param {number} [params.page] - 页码，从1开始
+ * @param {number} [params.page_size] - 每页条数
+ * @param {string} [params.cursor] - 游标分页：上一页返回的 next_cursor
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<RolePermissionsPage>>}
+ */
+export function getRoles(params = {}, config = {}) {
+  return axios.get('/roles', { ...config, params })
+}
+
+/**
+ * 设置角色的权限
+ * @param {string} role
+ * @param {RolePermissionsRequest} data
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<MessageResponse>>}
+ */
+export function updateRolePermissions(role, data, config = {}) {
+  return axios.put(`/roles/${encodeURIComponent(role)}/permissions`, data, config)
+}
+
+// 备份
+
+/**
+ * 备份列表，最新的在前
+ * @param {Object} [params] - 查询参数
+ * @param {number} [params.page] - 页码，从1开始
+ * @param {number} [params.page_size] - 每页条数
+ * @param {string} [params.cursor] - 游标分页：上一页返回的 next_cursor
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<BackupPage>>}
+ */
+export function getBackups(params = {}, config = {}) {
+  return axios.get('/backups', { ...config, params })
+}
+
+/**
+ * 立即备份数据库
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<Backup>>}
+ */
+export function createBackup(config = {}) {
+  return axios.post('/backups', null, config)
+}
+
+/**
+ * 下载备份文件
+ * @param {string} name
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<Blob>>}
+ */
+export function downloadBackup(name, config = {}) {
+  return axios.get(`/backups/${encodeURIComponent(name)}`, { ...config, responseType: 'blob' })
+}
+
+// 搜索
+
+/**
+ * 搜索课程、教师和学生
+ * @param {Object} params - 查询参数
+ * @param {string} params.q - 关键字
+ * @param {string} [params.types] - 逗号分隔的结果类型：course、teacher、student
+ * @param {number} [params.limit] - 每类最多返回的条数
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<SearchResult>>}
+ */
+export function searchAll(params, config = {}) {
+  return axios.get('/search', { ...config, params })
+}
+
+// 文档
+
+/**
+ * 本 OpenAPI 文档
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<?Object<string, *>>>}
+ */
+export function getOpenAPIDocument(config = {}) {
+  return axios.get('/openapi.json', config)
+}
+
+/**
+ * 接口文档页面
+ * @param {Object} [config] - axios 请求配置
+ * @returns {Promise<import('axios').AxiosResponse<string>>}
+ */
+export function getAPIDocs(config = {}) {
+  return axios.get('/docs', config)
+}
@@ -1,26 +1,25 @@
-import axios from 'axios'
+import * as client from './client'
 
-const apiBase = '/api'
+// 请求由生成的 client.js 发出，路径与类型以后端 OpenAPI 文档为准
 
 export default {
   /**
    * 获取学生成绩
-   * @param {Object} params - 请求参数，可选
-   * @returns {Promise} - 包含成绩数据的Promise
+   * @param {Object} params - 请求参数，可选 student_id 及分页、排序、搜索参数
+   * @returns {Promise} - 分页结果 { items, total, page, page_size, next_cursor }
    */
   getStudentGrades(params = {}) {
-    return axios.get(`${apiBase}/grades/student`, { params })
+    return client.getStudentGrades(params)
   },
 
   /**
    * 获取课程的所有学生成绩（教师用）
    * @param {number} courseOfferingId - 课程开设ID
-   * @returns {Promise} - 包含课程成绩数据的Promise
+   * @param {Object} params - 可选的分页、排序、搜索参数
+   * @returns {Promise} - 分页结果 { items, total, page, page_size, next_cursor }
    */
-  getCourseGrades(courseOfferingId) {
-    return axios.get(`${apiBase}/grades/course`, { 
-      params: { course_offering_id: courseOfferingId } 
-    })
+  getCourseGrades(courseOfferingId, params = {}) {
+    return client.getCourseGrades({ ...params, course_offering_id: courseOfferingId })
   },
 
   /**
@@ -30,7 +29,7 @@ export default {
    * @returns {Promise} - 更新结果的Promise
    */
   updateGrade(id, grade) {
-    return axios.put(`${apiBase}/grades/${id}`, { grade })
+    return client.updateGrade(id, { grade })
   },
 
   /**
@@ -39,6 +38,85 @@ export default {
    * @returns {Promise} - 创建结果的Promise
    */
   createGrade(gradeData) {
-    return axios.post(`${apiBase}/grades`, gradeData)
+    return client.createGrade(gradeData)
+  },
+
+  /**
+   * 获取学生成绩单（按补考/重修认定策略计算绩点）
+   * @param {number} studentId - 学生ID
+   * @returns {Promise} - 包含成绩单的Promise
+   */
+  getTranscript(studentId) {
+    return client.getTranscript({ student_id: studentId })
+  },
+
+  /**
+   * 为未通过的课程登记重修
+   * @param {number} id - 原成绩记录ID
+   * @param {number} courseOfferingId - 重修的课程开设ID
+   * @returns {Promise} - 登记结果的Promise
+   */
+  createRetake(id, courseOfferingId) {
+    return client.createRetake(id, { course_offering_id: courseOfferingId })
+  },
+
+  /**
+   * 获取补考名单
+   * @param {Object} params - 可选 semester_id、course_offering_id
+   * @returns {Promise} - 包含补考名单的Promise
+   */
+  getMakeupRoster(params = {}) {
+    return client.getMakeupRoster(params)
+  },
+
+  /**
+   * 根据未通过的成绩自动生成补考名单
+   * @param {Object} data - 可选 semester_id、course_offering_id
+   * @returns {Promise} - 生成结果的Promise
+   */
+  generateMakeupRoster(data = {}) {
+    return client.generateMakeupRoster(data)
+  },
+
+  /**
+   * 录入补考成绩
+   * @param {number} id - 补考记录ID
+   * @param {number} score - 补考卷面成绩
+   * @returns {Promise} - 录入结果的Promise
+   */
+  recordMakeupScore(id, score) {
+    return client.recordMakeupScore(id, { score })
+  },
+
+  /**
+   * 批量导入成绩（.xlsx 或 .csv）
+   * @param {number} courseOfferingId - 课程开设ID
+   * @param {File} file - 成绩文件
+   * @param {boolean} dryRun - 为 true 时只校验并返回预览
+   * @returns {Promise} - 导入结果或行级错误报告的Promise
+   */
+  importGrades(courseOfferingId, file, dryRun = false) {
+    const formData = new FormData()
+    formData.append('file', file)
+    return client.importGrades(formData, { course_offering_id: courseOfferingId, dry_run: dryRun })
+  },
+
+  /**
+   * 下载预填学生名单的成绩导入模板
+   * @param {number} courseOfferingId - 课程开设ID
+   * @param {string} format - xlsx 或 csv
+   * @returns {Promise} - 包含模板文件的Promise
+   */
+  downloadImportTemplate(courseOfferingId, format = 'xlsx') {
+    return client.getGradeImportTemplate({ course_offering_id: courseOfferingId, format })
+  },
+
+  /**
+   * 导出成绩表（含各成绩组成），可按课程开设、学期或院系导出
+   * @param {Object} params - course_offering_id、semester_id、department_id 之一，以及 format
+   * @returns {Promise} - 包含导出文件的Promise
+   */
+  exportGrades(params = {}) {
+    return client.exportGrades(params)
   }
-} 
\ No newline at end of file
+} 
@@ -0,0 +1,20 @@
+import * as client from './client'
+
+// 请求由生成的 client.js 发出，路径与类型以后端 OpenAPI 文档为准
+export default {
+  /**
+   * 全局搜索课程、教师与学生，结果按类型分组；学生结果按当前用户的数据范围过滤
+   * @param {string} q - 关键字，多个词以空格分隔，需全部匹配
+   * @param {Object} [options]
+   * @param {string[]} [options.types] - 只搜索这些类型（course、teacher、student）
+   * @param {number} [options.limit] - 每组返回的条数（默认 5，最大 20）
+   * @returns {Promise} - 包含 { query, groups: [{ type, total, items }] } 的Promise，
+   *   items 的 highlight 为已转义、以 <mark> 标出匹配部分的 HTML
+   */
+  search(q, { types, limit } = {}) {
+    const params = { q }
+    if (types && types.length) params.types = types.join(',')
+    if (limit) params.limit = limit
+    return client.searchAll(params)
+  }
+}
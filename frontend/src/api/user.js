@@ -0,0 +1,248 @@
+import * as client from './client'
+
+// 请求由生成的 client.js 发出，路径与类型以后端 OpenAPI 文档为准
+export default {
+  /**
+   * 获取统一身份认证（CAS / OIDC）登录入口
+   * @param {string} state - 随机值，OIDC 回跳时原样带回用于校验
+   * @returns {Promise}
+   */
+  getAuthProviders(state) {
+    return client.getAuthProviders({ state })
+  },
+
+  /**
+   * 获取当前登录用户的完整信息（含角色与权限）
+   * @returns {Promise}
+   */
+  getCurrentUser() {
+    return client.getCurrentUser()
+  },
+
+  /**
+   * 修改个人信息
+   * @param {Object} profile - { name, email, phone }
+   * @returns {Promise}
+   */
+  updateProfile(profile) {
+    return client.updateProfile(profile)
+  },
+
+  /**
+   * 修改密码（其他设备上的登录会被注销）
+   * @param {string} oldPassword - 原密码
+   * @param {string} newPassword - 新密码
+   * @returns {Promise}
+   */
+  changePassword(oldPassword, newPassword) {
+    return client.changePassword({ old_password: oldPassword, new_password: newPassword })
+  },
+
+  /**
+   * 获取当前用户的登录会话（设备），按创建时间倒序分页
+   * @param {Object} params - { page, page_size, cursor, sort }
+   * @returns {Promise} { items, total, page, page_size, next_cursor }
+   */
+  getSessions(params = {}) {
+    return client.getSessions(params)
+  },
+
+  /**
+   * 获取当前用户最近的登录记录（含失败记录）
+   * @param {number} pageSize - 返回条数，默认 20
+   * @returns {Promise} { items, total, page, page_size, next_cursor }
+   */
+  getMyLoginEvents(pageSize) {
+    return client.getMyLoginEvents({ page_size: pageSize })
+  },
+
+  /**
+   * 获取当前用户的两步验证状态
+   * @returns {Promise} { enabled, required, recovery_codes_remaining }
+   */
+  getTwoFactorStatus() {
+    return client.getTwoFactorStatus()
+  },
+
+  /**
+   * 开始设置两步验证，返回密钥与 otpauth:// 地址（用于生成二维码）
+   * @returns {Promise} { secret, otpauth_uri }
+   */
+  setupTwoFactor() {
+    return client.setupTwoFactor()
+  },
+
+  /**
+   * 用验证器中的验证码确认并启用两步验证，返回一次性恢复码（仅显示一次）
+   * @param {string} code - 6 位验证码
+   * @returns {Promise}
+   */
+  enableTwoFactor(code) {
+    return client.enableTwoFactor({ code })
+  },
+
+  /**
+   * 关闭两步验证（角色要求两步验证时不允许）
+   * @param {string} password - 当前密码
+   * @param {Object} factor - { code } 或 { recovery_code }
+   * @returns {Promise}
+   */
+  disableTwoFactor(password, factor) {
+    return client.disableTwoFactor({ password, ...factor })
+  },
+
+  /**
+   * 重新生成恢复码，旧的恢复码全部失效
+   * @param {string} code - 6 位验证码
+   * @returns {Promise}
+   */
+  regenerateRecoveryCodes(code) {
+    return client.regenerateRecoveryCodes({ code })
+  },
+
+  /**
+   * 分页查询用户列表（管理员），deleted 为 true 时查询已删除的用户
+   * @param {Object} params - { q, role, department_id, disabled, deleted, page, page_size, cursor, sort }
+   * @returns {Promise} { items, total, page, page_size, next_cursor }
+   */
+  getUsers(params = {}) {
+    return client.getUsers(params)
+  },
+
+  /**
+   * 创建用户（管理员），未提供密码时返回随机生成的初始密码
+   * @param {Object} user - { username, password, name, role, email, phone, department_id }
+   * @returns {Promise}
+   */
+  createUser(user) {
+    return client.createUser(user)
+  },
+
+  /**
+   * 批量导入学生或教师账号（管理员），导入的账号首次登录须修改密码
+   * @param {string} role - student 或 teacher
+   * @param {File} file - .xlsx 或 .csv 文件
+   * @param {boolean} dryRun - 仅校验预览，不写入
+   * @returns {Promise}
+   */
+  importUsers(role, file, dryRun = false) {
+    const formData = new FormData()
+    formData.append('file', file)
+    return client.importUsers(formData, { role, dry_run: dryRun }, {
+      headers: { 'Content-Type': 'multipart/form-data' }
+    })
+  },
+
+  /**
+   * 修改用户信息（管理员）
+   * @param {number} id - 用户ID
+   * @param {Object} profile - { name, email, phone }
+   * @returns {Promise}
+   */
+  updateUser(id, profile) {
+    return client.updateUser(id, profile)
+  },
+
+  /**
+   * 修改用户角色（管理员）
+   * @param {number} id - 用户ID
+   * @param {string} role - 角色
+   * @param {number} departmentId - 院系管理员所管理的院系
+   * @returns {Promise}
+   */
+  updateUserRole(id, role, departmentId) {
+    return client.updateUserRole(id, { role, department_id: departmentId })
+  },
+
+  /**
+   * 停用或启用账号（管理员）
+   * @param {number} id - 用户ID
+   * @param {boolean} disabled - 是否停用
+   * @returns {Promise}
+   */
+  setUserDisabled(id, disabled) {
+    return client.updateUserStatus(id, { disabled })
+  },
+
+  /**
+   * 删除用户（管理员），账号立即下线，可恢复
+   * @param {number} id - 用户ID
+   * @returns {Promise}
+   */
+  deleteUser(id) {
+    return client.deleteUser(id)
+  },
+
+  /**
+   * 恢复已删除的用户（管理员）
+   * @param {number} id - 用户ID
+   * @returns {Promise}
+   */
+  restoreUser(id) {
+    return client.restoreUser(id)
+  },
+
+  /**
+   * 彻底删除已删除的用户（仅管理员），仍被引用时返回 409 与 blocked_by
+   * @param {number} id - 用户ID
+   * @returns {Promise}
+   */
+  purgeUser(id) {
+    return client.purgeUser(id)
+  },
+
+  /**
+   * 重置密码（管理员），未提供密码时返回随机生成的新密码
+   * @param {number} id - 用户ID
+   * @param {string} password - 新密码，可选
+   * @returns {Promise}
+   */
+  resetPassword(id, password) {
+    return client.resetUserPassword(id, password ? { password } : {})
+  },
+
+  /**
+   * 解除账号的登录锁定（管理员）
+   * @param {number} id - 用户ID
+   * @returns {Promise}
+   */
+  unlockUser(id) {
+    return client.unlockUser(id)
+  },
+
+  /**
+   * 重置用户的两步验证（管理员），用于用户丢失手机与恢复码的情况
+   * @param {number} id - 用户ID
+   * @returns {Promise}
+   */
+  resetTwoFactor(id) {
+    return client.resetUserTwoFactor(id)
+  },
+
+  /**
+   * 分页查询当前被锁定的用户名与 IP（管理员）
+   * @param {Object} params - { kind, q, page, page_size, cursor, sort }
+   * @returns {Promise} { items, total, page, page_size, next_cursor }
+   */
+  getLoginLockouts(params = {}) {
+    return client.getLoginLockouts(params)
+  },
+
+  /**
+   * 解除 IP 的登录锁定（管理员）
+   * @param {string} ip - 客户端 IP
+   * @returns {Promise}
+   */
+  unlockIP(ip) {
+    return client.unlockIP(ip)
+  },
+
+  /**
+   * 分页查询登录记录（管理员），默认按时间倒序
+   * @param {Object} params - { username, ip, success, q, page, page_size, cursor, sort }
+   * @returns {Promise} { items, total, page, page_size, next_cursor }
+   */
+  getLoginEvents(params = {}) {
+    return client.getLoginEvents(params)
+  }
+}
@@ -15,6 +15,13 @@ const routes = [
     component: Login,
     meta: { requiresAuth: false }
   },
+  {
+    // 统一身份认证（CAS/OIDC）登录后回跳到此处，携带 ticket 或 code
+    path: '/login/sso/:provider',
+    name: 'sso-callback',
+    component: Login,
+    meta: { requiresAuth: false }
+  },
   {
     path: '/',
     component: Layout,
@@ -106,6 +113,13 @@ router.beforeEach((to, from, next) => {
         return
       }
       
+      // 必须修改密码时只能停留在个人中心；
+      // 角色要求两步验证时，启用前同样只能停留在个人中心
+      if ((store.getters.mustChangePassword || store.getters.twoFactorSetupRequired) && to.name !== 'Profile') {
+        next({ name: 'Profile' })
+        return
+      }
+
       if (to.meta && to.meta.roles) {
         console.log('路由所需角色:', to.meta.roles)
         // 判断用户是否有权限访问该路由
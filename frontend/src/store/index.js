@@ -1,6 +1,7 @@
 import Vue from 'vue'
 import Vuex from 'vuex'
 import axios from 'axios'
+import * as api from '../api/client'
 
 Vue.use(Vuex)
 
@@ -21,6 +22,69 @@ axios.interceptors.request.use(
   }
 )
 
+// Access tokens are short-lived: on 401, exchange the refresh token once and retry
+let refreshing = null
+
+function clearStoredAuth() {
+  localStorage.removeItem('token')
+  localStorage.removeItem('refreshToken')
+  localStorage.removeItem('userRole')
+  delete axios.defaults.headers.common['Authorization']
+}
+
+axios.interceptors.response.use(
+  response => response,
+  async error => {
+    const original = error.config
+    const refreshToken = localStorage.getItem('refreshToken')
+    if (!error.response || error.response.status !== 401 || !refreshToken ||
+        original._retried || ['/login', '/login/2fa', '/login/sso', '/refresh'].includes(original.url)) {
+      return Promise.reject(error)
+    }
+
+    original._retried = true
+    if (!refreshing) {
+      refreshing = axios.post('/refresh', { refresh_token: refreshToken })
+        .then(response => {
+          localStorage.setItem('token', response.data.token)
+          localStorage.setItem('refreshToken', response.data.refresh_token)
+          axios.defaults.headers.common['Authorization'] = `Bearer ${response.data.token}`
+          return response.data.token
+        })
+        .finally(() => {
+          refreshing = null
+        })
+    }
+
+    try {
+      const token = await refreshing
+      original.headers.Authorization = `Bearer ${token}`
+      return axios(original)
+    } catch (refreshError) {
+      clearStoredAuth()
+      return Promise.reject(error)
+    }
+  }
+)
+
+// saveLogin stores the tokens of a completed login and returns the user
+function saveLogin(commit, { token, refresh_token, user }) {
+  console.log('登录成功，用户信息:', user);
+  console.log('用户角色:', user.role);
+
+  // Save token to localStorage
+  localStorage.setItem('token', token)
+  localStorage.setItem('refreshToken', refresh_token)
+  localStorage.setItem('userRole', user.role) // 额外保存角色信息
+
+  // Set token in axios headers
+  axios.defaults.headers.common['Authorization'] = `Bearer ${token}`
+
+  commit('SET_TOKEN', token)
+  commit('SET_USER', user)
+  return user
+}
+
 export default new Vuex.Store({
   state: {
     token: localStorage.getItem('token') || '',
@@ -45,6 +109,14 @@ export default new Vuex.Store({
       // 默认返回普通用户角色
       return null;
     },
+    // 必须先修改初始密码或已过期的密码
+    mustChangePassword: state => !!(state.user && state.user.must_change_password),
+    // 角色要求两步验证但尚未启用
+    twoFactorSetupRequired: state => !!(state.user && state.user.two_factor_setup_required),
+    // 当前用户是否拥有某项权限（如 'grade.write'），权限来自 /api/user
+    hasPermission: state => permission => {
+      return !!(state.user && state.user.permissions && state.user.permissions.includes(permission))
+    },
     departments: state => state.departments,
     courses: state => state.courses
   },
@@ -55,6 +127,16 @@ export default new Vuex.Store({
     SET_USER(state, user) {
       state.user = user
     },
+    PASSWORD_CHANGED(state) {
+      if (state.user) {
+        state.user = { ...state.user, must_change_password: false }
+      }
+    },
+    TWO_FACTOR_CHANGED(state, enabled) {
+      if (state.user) {
+        state.user = { ...state.user, two_factor_enabled: enabled, two_factor_setup_required: false }
+      }
+    },
     CLEAR_AUTH(state) {
       state.token = ''
       state.user = null
@@ -68,37 +150,56 @@ export default new Vuex.Store({
   },
   actions: {
     // Authentication actions
+    // 账号启用了两步验证时返回 { two_factor_required, challenge_token }，需再调用 loginTwoFactor
     async login({ commit }, credentials) {
       try {
-        const response = await axios.post('/login', credentials)
-        const { token, user } = response.data
-        
-        console.log('登录成功，用户信息:', user);
-        console.log('用户角色:', user.role);
-        
-        // Save token to localStorage
-        localStorage.setItem('token', token)
-        localStorage.setItem('userRole', user.role) // 额外保存角色信息
-        
-        // Set token in axios headers
-        axios.defaults.headers.common['Authorization'] = `Bearer ${token}`
-        
-        commit('SET_TOKEN', token)
-        commit('SET_USER', user)
-        
-        return Promise.resolve(user)
+        const response = await api.login(credentials)
+        if (response.data.two_factor_required) {
+          return Promise.resolve(response.data)
+        }
+        return Promise.resolve(saveLogin(commit, response.data))
       } catch (error) {
         return Promise.reject(error)
       }
     },
+
+    // 统一身份认证回跳后，用 CAS ticket 或 OIDC code 登录；同样可能需要两步验证
+    async loginSSO({ commit }, { provider, ticket }) {
+      const response = await api.loginSSO({ provider, ticket })
+      if (response.data.two_factor_required) {
+        return response.data
+      }
+      return saveLogin(commit, response.data)
+    },
+
+    // 登录第二步：提交验证器中的验证码或一次性恢复码
+    async loginTwoFactor({ commit }, { challengeToken, code, recoveryCode }) {
+      const response = await api.loginTwoFactor({
+        challenge_token: challengeToken,
+        code: code || undefined,
+        recovery_code: recoveryCode || undefined
+      })
+      return saveLogin(commit, response.data)
+    },
     
-    logout({ commit }) {
-      // Remove token from localStorage
-      localStorage.removeItem('token')
-      
-      // Remove token from axios headers
-      delete axios.defaults.headers.common['Authorization']
-      
+    async logout({ commit }) {
+      // Revoke the session on the server; clear local state even if this fails
+      try {
+        await api.logout()
+      } catch (error) {
+        console.warn('退出登录请求失败:', error)
+      }
+
+      // Remove tokens from localStorage and axios headers
+      clearStoredAuth()
+
+      commit('CLEAR_AUTH')
+    },
+
+    // Revoke every session of the current user (log out all devices)
+    async logoutAll({ commit }) {
+      await api.logoutAll()
+      clearStoredAuth()
       commit('CLEAR_AUTH')
     },
     
@@ -109,7 +210,7 @@ export default new Vuex.Store({
       }
       
       try {
-        const response = await axios.get('/user')
+        const response = await api.getCurrentUser()
         console.log('获取用户信息成功:', response.data);
         
         // 如果后端没有返回角色信息，尝试从localStorage获取
@@ -121,8 +222,7 @@ export default new Vuex.Store({
         return Promise.resolve(response.data)
       } catch (error) {
         commit('CLEAR_AUTH')
-        localStorage.removeItem('token')
-        localStorage.removeItem('userRole')
+        clearStoredAuth()
         return Promise.reject(error)
       }
     },
@@ -130,9 +230,10 @@ export default new Vuex.Store({
     // Department actions
     async fetchDepartments({ commit }) {
       try {
-        const response = await axios.get('/departments')
-        commit('SET_DEPARTMENTS', response.data)
-        return Promise.resolve(response.data)
+        // 下拉选项需要全部院系，按接口允许的最大页长获取
+        const response = await api.getDepartments({ page_size: 100 })
+        commit('SET_DEPARTMENTS', response.data.items)
+        return Promise.resolve(response.data.items)
       } catch (error) {
         return Promise.reject(error)
       }
@@ -140,7 +241,7 @@ export default new Vuex.Store({
     
     async createDepartment({ dispatch }, department) {
       try {
-        const response = await axios.post('/departments', department)
+        const response = await api.createDepartment(department)
         // Refresh department list
         dispatch('fetchDepartments')
         return Promise.resolve(response.data)
@@ -151,7 +252,7 @@ export default new Vuex.Store({
     
     async updateDepartment({ dispatch }, { id, department }) {
       try {
-        const response = await axios.put(`/departments/${id}`, department)
+        const response = await api.updateDepartment(id, department)
         // Refresh department list
         dispatch('fetchDepartments')
         return Promise.resolve(response.data)
@@ -162,7 +263,7 @@ export default new Vuex.Store({
     
     async deleteDepartment({ dispatch }, id) {
       try {
-        const response = await axios.delete(`/departments/${id}`)
+        const response = await api.deleteDepartment(id)
         // Refresh department list
         dispatch('fetchDepartments')
         return Promise.resolve(response.data)
@@ -174,9 +275,9 @@ export default new Vuex.Store({
     // Course actions
     async fetchCourses({ commit }) {
       try {
-        const response = await axios.get('/courses')
-        commit('SET_COURSES', response.data)
-        return Promise.resolve(response.data)
+        const response = await api.getCourses({ page_size: 100 })
+        commit('SET_COURSES', response.data.items)
+        return Promise.resolve(response.data.items)
       } catch (error) {
         return Promise.reject(error)
       }
@@ -184,7 +285,7 @@ export default new Vuex.Store({
     
     async getCourse({ commit }, id) {
       try {
-        const response = await axios.get(`/courses/${id}`)
+        const response = await api.getCourse(id)
         return Promise.resolve(response.data)
       } catch (error) {
         return Promise.reject(error)
@@ -193,7 +294,7 @@ export default new Vuex.Store({
     
     async createCourse({ dispatch }, course) {
       try {
-        const response = await axios.post('/courses', course)
+        const response = await api.createCourse(course)
         // Refresh course list
         dispatch('fetchCourses')
         return Promise.resolve(response.data)
@@ -204,7 +305,7 @@ export default new Vuex.Store({
     
     async updateCourse({ dispatch }, { id, course }) {
       try {
-        const response = await axios.put(`/courses/${id}`, course)
+        const response = await api.updateCourse(id, course)
         // Refresh course list
         dispatch('fetchCourses')
         return Promise.resolve(response.data)
@@ -215,7 +316,7 @@ export default new Vuex.Store({
     
     async deleteCourse({ dispatch }, id) {
       try {
-        const response = await axios.delete(`/courses/${id}`)
+        const response = await api.deleteCourse(id)
         // Refresh course list
         dispatch('fetchCourses')
         return Promise.resolve(response.data)